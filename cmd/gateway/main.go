@@ -26,6 +26,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/xdas-research/peer-compute/internal/protocol"
 	"golang.org/x/crypto/acme/autocert"
 )
 
@@ -35,17 +36,18 @@ var (
 )
 
 type Config struct {
-	HTTPPort       int
-	HTTPSPort      int
-	TunnelPort     int
-	BaseDomain     string
-	ACMEEmail      string
-	DataDir        string
-	DisableTLS     bool
-	RateLimitRPS   int
-	IdleTimeout    time.Duration
-	ReadTimeout    time.Duration
-	WriteTimeout   time.Duration
+	HTTPPort     int
+	HTTPSPort    int
+	TunnelPort   int
+	BaseDomain   string
+	ACMEEmail    string
+	DataDir      string
+	DisableTLS   bool
+	RateLimitRPS int
+	Balance      string
+	IdleTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
 }
 
 func main() {
@@ -83,6 +85,7 @@ func parseFlags() *Config {
 	flag.StringVar(&cfg.DataDir, "data-dir", "/var/lib/peercompute-gateway", "Data directory")
 	flag.BoolVar(&cfg.DisableTLS, "disable-tls", false, "Disable TLS (for development)")
 	flag.IntVar(&cfg.RateLimitRPS, "rate-limit", 100, "Rate limit requests per second")
+	flag.StringVar(&cfg.Balance, "balance", BalanceLeastConn, "Load balancing across replicas: least-conn or round-robin")
 	flag.DurationVar(&cfg.IdleTimeout, "idle-timeout", 120*time.Second, "Idle connection timeout")
 	flag.DurationVar(&cfg.ReadTimeout, "read-timeout", 30*time.Second, "Read timeout")
 	flag.DurationVar(&cfg.WriteTimeout, "write-timeout", 30*time.Second, "Write timeout")
@@ -92,9 +95,13 @@ func parseFlags() *Config {
 }
 
 func run(ctx context.Context, cfg *Config) error {
+	if cfg.Balance != BalanceLeastConn && cfg.Balance != BalanceRoundRobin {
+		return fmt.Errorf("unknown balancing strategy %q (use %s or %s)", cfg.Balance, BalanceLeastConn, BalanceRoundRobin)
+	}
+
 	// Initialize tunnel server
 	log.Println("Initializing tunnel server...")
-	tunnelMgr := NewTunnelManager()
+	tunnelMgr := NewTunnelManager(cfg.Balance)
 
 	// Start tunnel listener
 	go func() {
@@ -196,40 +203,79 @@ func run(ctx context.Context, cfg *Config) error {
 
 // TunnelMessage is the JSON protocol for tunnel communication
 type TunnelMessage struct {
-	Type         string `json:"type"` // "register", "unregister", "request", "response"
-	DeploymentID string `json:"deployment_id,omitempty"`
-	Port         int    `json:"port,omitempty"`
-	PeerID       string `json:"peer_id,omitempty"`
-	RequestID    string `json:"request_id,omitempty"`
-	Method       string `json:"method,omitempty"`
-	Path         string `json:"path,omitempty"`
+	Type         string            `json:"type"` // "register", "unregister", "health", "request", "response"
+	DeploymentID string            `json:"deployment_id,omitempty"`
+	Name         string            `json:"name,omitempty"`
+	ReplicaSet   string            `json:"replica_set,omitempty"`
+	RequesterID  string            `json:"requester_id,omitempty"`
+	Healthy      bool              `json:"healthy,omitempty"`
+	Port         int               `json:"port,omitempty"`
+	PeerID       string            `json:"peer_id,omitempty"`
+	RequestID    string            `json:"request_id,omitempty"`
+	Method       string            `json:"method,omitempty"`
+	Path         string            `json:"path,omitempty"`
 	Headers      map[string]string `json:"headers,omitempty"`
-	Body         []byte `json:"body,omitempty"`
-	StatusCode   int    `json:"status_code,omitempty"`
+	Body         []byte            `json:"body,omitempty"`
+	StatusCode   int               `json:"status_code,omitempty"`
 }
 
+const (
+	// BalanceLeastConn sends each request to the replica with the fewest
+	// requests in flight
+	BalanceLeastConn = "least-conn"
+
+	// BalanceRoundRobin sends requests to the replicas in turn
+	BalanceRoundRobin = "round-robin"
+
+	// EjectionPeriod is how long a replica that failed a request is left
+	// out of rotation
+	EjectionPeriod = 30 * time.Second
+)
+
 // TunnelManager manages reverse tunnel connections from providers.
 type TunnelManager struct {
 	mu       sync.RWMutex
-	tunnels  map[string]*TunnelConn      // peerID -> tunnel
-	routes   map[string]*TunnelConn      // deploymentID -> tunnel
+	tunnels  map[string]*TunnelConn // peerID -> tunnel
+	routes   map[string]*Route      // subdomain -> route
+	backends map[string]string      // deploymentID -> subdomain
+	balance  string
 	listener net.Listener
 }
 
+// Route is a subdomain served by one or more replicas of a deployment.
+type Route struct {
+	Subdomain  string
+	ReplicaSet string
+	Owner      string
+	Backends   []*Backend
+	next       int // where the next pick starts
+}
+
+// Backend is one replica serving a route.
+type Backend struct {
+	DeploymentID string
+	Tunnel       *TunnelConn
+	Healthy      bool
+	EjectedUntil time.Time
+	active       int // requests in flight
+}
+
 type TunnelConn struct {
-	PeerID     string
-	Conn       net.Conn
-	Reader     *bufio.Reader
-	Writer     *bufio.Writer
-	Routes     map[string]int // deployment ID -> local port
-	mu         sync.Mutex
-	pending    map[string]chan *TunnelMessage // requestID -> response channel
+	PeerID  string
+	Conn    net.Conn
+	Reader  *bufio.Reader
+	Writer  *bufio.Writer
+	Routes  map[string]int // deployment ID -> local port
+	mu      sync.Mutex
+	pending map[string]chan *TunnelMessage // requestID -> response channel
 }
 
-func NewTunnelManager() *TunnelManager {
+func NewTunnelManager(balance string) *TunnelManager {
 	return &TunnelManager{
-		tunnels: make(map[string]*TunnelConn),
-		routes:  make(map[string]*TunnelConn),
+		tunnels:  make(map[string]*TunnelConn),
+		routes:   make(map[string]*Route),
+		backends: make(map[string]string),
+		balance:  balance,
 	}
 }
 
@@ -253,10 +299,10 @@ func (tm *TunnelManager) ListenAndServe(addr string, baseDomain string) error {
 
 func (tm *TunnelManager) handleTunnelConn(conn net.Conn, baseDomain string) {
 	log.Printf("New tunnel connection from %s", conn.RemoteAddr())
-	
+
 	reader := bufio.NewReader(conn)
 	writer := bufio.NewWriter(conn)
-	
+
 	tc := &TunnelConn{
 		Conn:    conn,
 		Reader:  reader,
@@ -283,17 +329,22 @@ func (tm *TunnelManager) handleTunnelConn(conn net.Conn, baseDomain string) {
 
 		switch msg.Type {
 		case "register":
-			tc.PeerID = msg.PeerID
-			tc.Routes[msg.DeploymentID] = msg.Port
-			
 			tm.mu.Lock()
-			tm.tunnels[msg.PeerID] = tc
-			tm.routes[msg.DeploymentID] = tc
+			subdomain, err := tm.addBackendLocked(&msg, tc)
+			if err == nil {
+				tc.PeerID = msg.PeerID
+				tc.Routes[msg.DeploymentID] = msg.Port
+				tm.tunnels[msg.PeerID] = tc
+			}
 			tm.mu.Unlock()
-			
-			url := fmt.Sprintf("https://%s.%s", msg.DeploymentID, baseDomain)
+			if err != nil {
+				log.Printf("Refused to register %s: %v", msg.DeploymentID, err)
+				continue
+			}
+
+			url := fmt.Sprintf("https://%s.%s", subdomain, baseDomain)
 			log.Printf("Registered deployment %s -> port %d (URL: %s)", msg.DeploymentID, msg.Port, url)
-			
+
 			// Send confirmation
 			resp := TunnelMessage{
 				Type:         "registered",
@@ -303,11 +354,20 @@ func (tm *TunnelManager) handleTunnelConn(conn net.Conn, baseDomain string) {
 
 		case "unregister":
 			tm.mu.Lock()
-			delete(tm.routes, msg.DeploymentID)
 			delete(tc.Routes, msg.DeploymentID)
+			tm.removeBackendLocked(msg.DeploymentID, tc)
 			tm.mu.Unlock()
 			log.Printf("Unregistered deployment %s", msg.DeploymentID)
 
+		case "health":
+			// Unhealthy replicas stay registered but get no requests
+			tm.mu.Lock()
+			if b := tm.backendLocked(msg.DeploymentID); b != nil && b.Tunnel == tc && b.Healthy != msg.Healthy {
+				b.Healthy = msg.Healthy
+				log.Printf("Deployment %s healthy: %v", msg.DeploymentID, msg.Healthy)
+			}
+			tm.mu.Unlock()
+
 		case "response":
 			tc.mu.Lock()
 			if ch, ok := tc.pending[msg.RequestID]; ok {
@@ -320,14 +380,14 @@ func (tm *TunnelManager) handleTunnelConn(conn net.Conn, baseDomain string) {
 
 	// Cleanup on disconnect
 	tm.mu.Lock()
-	if tc.PeerID != "" {
+	if tc.PeerID != "" && tm.tunnels[tc.PeerID] == tc {
 		delete(tm.tunnels, tc.PeerID)
 	}
 	for depID := range tc.Routes {
-		delete(tm.routes, depID)
+		tm.removeBackendLocked(depID, tc)
 	}
 	tm.mu.Unlock()
-	
+
 	conn.Close()
 	log.Printf("Tunnel disconnected: %s", conn.RemoteAddr())
 }
@@ -335,12 +395,12 @@ func (tm *TunnelManager) handleTunnelConn(conn net.Conn, baseDomain string) {
 func (tc *TunnelConn) Send(msg *TunnelMessage) error {
 	tc.mu.Lock()
 	defer tc.mu.Unlock()
-	
+
 	data, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
-	
+
 	tc.Writer.Write(data)
 	tc.Writer.WriteByte('\n')
 	return tc.Writer.Flush()
@@ -348,18 +408,18 @@ func (tc *TunnelConn) Send(msg *TunnelMessage) error {
 
 func (tc *TunnelConn) Request(msg *TunnelMessage, timeout time.Duration) (*TunnelMessage, error) {
 	ch := make(chan *TunnelMessage, 1)
-	
+
 	tc.mu.Lock()
 	tc.pending[msg.RequestID] = ch
 	tc.mu.Unlock()
-	
+
 	if err := tc.Send(msg); err != nil {
 		tc.mu.Lock()
 		delete(tc.pending, msg.RequestID)
 		tc.mu.Unlock()
 		return nil, err
 	}
-	
+
 	select {
 	case resp := <-ch:
 		return resp, nil
@@ -382,11 +442,183 @@ func (tm *TunnelManager) Close() {
 	}
 }
 
-func (tm *TunnelManager) GetTunnel(deploymentID string) (*TunnelConn, bool) {
-	tm.mu.RLock()
-	defer tm.mu.RUnlock()
-	tc, ok := tm.routes[deploymentID]
-	return tc, ok
+// addBackendLocked adds a deployment to the route of its subdomain, creating
+// the route if needed, and returns the subdomain (caller must hold tm.mu).
+// Named deployments are served under their name scoped to their owner, so
+// one requester can't claim another's name, and unnamed replicas under their
+// replica set. A deployment may only join a route of the same owner and
+// replica set.
+func (tm *TunnelManager) addBackendLocked(msg *TunnelMessage, tc *TunnelConn) (string, error) {
+	if err := validateRegistration(msg); err != nil {
+		return "", err
+	}
+	subdomain := protocol.GatewaySubdomain(msg.DeploymentID, msg.Name, msg.ReplicaSet, msg.RequesterID)
+
+	route, ok := tm.routes[subdomain]
+	if ok {
+		if b := route.backend(msg.DeploymentID); b != nil {
+			// Registered again, e.g. after an update
+			if b.Tunnel != tc && b.Tunnel.PeerID != msg.PeerID {
+				return "", fmt.Errorf("deployment is registered by another provider")
+			}
+			b.Tunnel = tc
+			b.Healthy = true
+			b.EjectedUntil = time.Time{}
+			return subdomain, nil
+		}
+		if route.ReplicaSet == "" || route.ReplicaSet != msg.ReplicaSet || route.Owner != msg.RequesterID {
+			return "", fmt.Errorf("subdomain %s is used by %s", subdomain, route.Backends[0].DeploymentID)
+		}
+	}
+
+	// A deployment is served under one subdomain only
+	if _, ok := tm.backends[msg.DeploymentID]; ok {
+		tm.removeBackendLocked(msg.DeploymentID, nil)
+	}
+	if route == nil {
+		route = &Route{Subdomain: subdomain, ReplicaSet: msg.ReplicaSet, Owner: msg.RequesterID}
+		tm.routes[subdomain] = route
+	}
+	route.Backends = append(route.Backends, &Backend{
+		DeploymentID: msg.DeploymentID,
+		Tunnel:       tc,
+		Healthy:      true,
+	})
+	tm.backends[msg.DeploymentID] = subdomain
+	return subdomain, nil
+}
+
+// validateRegistration checks that a registration's IDs and name have the
+// forms providers generate, so one deployment's subdomain can't be made to
+// look like another's.
+func validateRegistration(msg *TunnelMessage) error {
+	if err := protocol.ValidateDeploymentID(msg.DeploymentID); err != nil {
+		return err
+	}
+	if msg.ReplicaSet != "" {
+		if err := protocol.ValidateReplicaSetID(msg.ReplicaSet); err != nil {
+			return err
+		}
+	}
+	if msg.Name != "" {
+		if msg.RequesterID == "" {
+			return fmt.Errorf("named deployment %s has no owner", msg.DeploymentID)
+		}
+		if err := protocol.ValidateDeploymentName(msg.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeBackendLocked removes a deployment from its route, and the route
+// once it has no replicas left (caller must hold tm.mu). If tc is not nil,
+// the deployment is only removed if it is served through tc.
+func (tm *TunnelManager) removeBackendLocked(deploymentID string, tc *TunnelConn) {
+	subdomain, ok := tm.backends[deploymentID]
+	if !ok {
+		return
+	}
+	route := tm.routes[subdomain]
+	for i, b := range route.Backends {
+		if b.DeploymentID != deploymentID {
+			continue
+		}
+		if tc != nil && b.Tunnel != tc {
+			return
+		}
+		route.Backends = append(route.Backends[:i], route.Backends[i+1:]...)
+		break
+	}
+	delete(tm.backends, deploymentID)
+	if len(route.Backends) == 0 {
+		delete(tm.routes, subdomain)
+	}
+}
+
+// backendLocked returns the backend of a deployment (caller must hold tm.mu).
+func (tm *TunnelManager) backendLocked(deploymentID string) *Backend {
+	subdomain, ok := tm.backends[deploymentID]
+	if !ok {
+		return nil
+	}
+	return tm.routes[subdomain].backend(deploymentID)
+}
+
+// backend returns the backend of a deployment, if it serves the route.
+func (r *Route) backend(deploymentID string) *Backend {
+	for _, b := range r.Backends {
+		if b.DeploymentID == deploymentID {
+			return b
+		}
+	}
+	return nil
+}
+
+// pick returns the next eligible backend: the first one in turn for
+// round-robin, or the one with the fewest requests in flight, ties going to
+// the first in turn.
+func (r *Route) pick(balance string, eligible func(*Backend) bool) *Backend {
+	var chosen *Backend
+	chosenAt := 0
+	for i := range r.Backends {
+		at := (r.next + i) % len(r.Backends)
+		b := r.Backends[at]
+		if !eligible(b) {
+			continue
+		}
+		if chosen == nil || (balance == BalanceLeastConn && b.active < chosen.active) {
+			chosen, chosenAt = b, at
+		}
+		if balance == BalanceRoundRobin {
+			break
+		}
+	}
+	if chosen != nil {
+		r.next = chosenAt + 1
+	}
+	return chosen
+}
+
+// Pick chooses the replica to serve a request to a subdomain and counts the
+// request as in flight until Done is called. Unhealthy replicas are skipped;
+// replicas ejected after a failed request are only used when no other
+// healthy replica is left. The backend is nil if the route exists but has
+// no healthy replica.
+func (tm *TunnelManager) Pick(subdomain string) (*Backend, *TunnelConn, bool) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	route, ok := tm.routes[subdomain]
+	if !ok {
+		return nil, nil, false
+	}
+
+	now := time.Now()
+	b := route.pick(tm.balance, func(b *Backend) bool {
+		return b.Healthy && !now.Before(b.EjectedUntil)
+	})
+	if b == nil {
+		b = route.pick(tm.balance, func(b *Backend) bool { return b.Healthy })
+	}
+	if b == nil {
+		return nil, nil, true
+	}
+	b.active++
+	return b, b.Tunnel, true
+}
+
+// Done ends a request started with Pick. A failed request ejects the
+// replica from rotation for EjectionPeriod.
+func (tm *TunnelManager) Done(b *Backend, failed bool) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	b.active--
+	if failed {
+		b.EjectedUntil = time.Now().Add(EjectionPeriod)
+		log.Printf("Ejected deployment %s from rotation for %s after a failed request", b.DeploymentID, EjectionPeriod)
+	}
 }
 
 // GatewayHandler routes incoming HTTP requests to containers via tunnels.
@@ -411,15 +643,20 @@ func (h *GatewayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Find the tunnel for this subdomain
-	tunnel, ok := h.tunnels.GetTunnel(subdomain)
+	// Pick a replica serving this subdomain
+	backend, tunnel, ok := h.tunnels.Pick(subdomain)
 	if !ok {
 		http.Error(w, fmt.Sprintf("Deployment '%s' not found", subdomain), http.StatusNotFound)
 		return
 	}
+	if backend == nil {
+		http.Error(w, fmt.Sprintf("Deployment '%s' has no healthy replicas", subdomain), http.StatusServiceUnavailable)
+		return
+	}
 
 	// Proxy the request through the tunnel
-	h.proxyRequest(w, r, tunnel, subdomain)
+	failed := h.proxyRequest(w, r, tunnel, backend.DeploymentID)
+	h.tunnels.Done(backend, failed)
 }
 
 func (h *GatewayHandler) serveGatewayInfo(w http.ResponseWriter, r *http.Request) {
@@ -439,13 +676,16 @@ func (h *GatewayHandler) serveGatewayInfo(w http.ResponseWriter, r *http.Request
     <h1>🖥️ Peer Compute Gateway</h1>
     <p>This is the Peer Compute gateway for <code>%s</code>.</p>
     <p>Deployed containers are accessible via subdomains:</p>
-    <p><code>https://&lt;deployment-id&gt;.%s</code></p>
+    <p><code>https://&lt;deployment-name-or-id&gt;.%s</code></p>
     <p>Learn more at <a href="https://github.com/xdas-research/peer-compute">github.com/xdas-research/peer-compute</a></p>
 </body>
 </html>`, h.cfg.BaseDomain, h.cfg.BaseDomain)
 }
 
-func (h *GatewayHandler) proxyRequest(w http.ResponseWriter, r *http.Request, tunnel *TunnelConn, deploymentID string) {
+// proxyRequest forwards a request through a tunnel and reports whether the
+// replica failed it: the tunnel did not answer, or the provider could not
+// reach the container.
+func (h *GatewayHandler) proxyRequest(w http.ResponseWriter, r *http.Request, tunnel *TunnelConn, deploymentID string) bool {
 	// Read request body
 	var body []byte
 	if r.Body != nil {
@@ -477,21 +717,22 @@ func (h *GatewayHandler) proxyRequest(w http.ResponseWriter, r *http.Request, tu
 	if err != nil {
 		log.Printf("Proxy error for %s: %v", deploymentID, err)
 		http.Error(w, "Gateway error: "+err.Error(), http.StatusBadGateway)
-		return
+		return true
 	}
 
 	// Write response headers
 	for k, v := range resp.Headers {
 		w.Header().Set(k, v)
 	}
-	
+
 	if resp.StatusCode > 0 {
 		w.WriteHeader(resp.StatusCode)
 	}
-	
+
 	if len(resp.Body) > 0 {
 		w.Write(resp.Body)
 	}
+	return resp.StatusCode == http.StatusBadGateway
 }
 
 func extractSubdomain(host, baseDomain string) string {
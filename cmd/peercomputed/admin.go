@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/capacity"
+	"github.com/xdas-research/peer-compute/internal/handler"
+	"github.com/xdas-research/peer-compute/internal/identity"
+	"github.com/xdas-research/peer-compute/internal/scheduler"
+)
+
+const (
+	// AdminSocketName is the daemon's admin socket in the data directory
+	AdminSocketName = "admin.sock"
+
+	// adminTimeout bounds a single admin request
+	adminTimeout = 10 * time.Second
+
+	// defaultDrainGrace is how long deployments keep running after a drain
+	defaultDrainGrace = 10 * time.Minute
+)
+
+// adminRequest is a command sent to the running daemon over the admin socket.
+type adminRequest struct {
+	// Command is "drain" or "undrain"
+	Command string `json:"command"`
+
+	// GraceSeconds is how long deployments keep running after a drain
+	GraceSeconds int64 `json:"grace_seconds,omitempty"`
+
+	// Message is the operator's reason for a drain, passed on to owners
+	Message string `json:"message,omitempty"`
+
+	// Notify tells owners of running deployments about a drain
+	Notify bool `json:"notify,omitempty"`
+}
+
+// adminResponse is the daemon's answer to an admin command.
+type adminResponse struct {
+	Success bool                  `json:"success"`
+	Message string                `json:"message,omitempty"`
+	Error   string                `json:"error,omitempty"`
+	Drain   *scheduler.DrainState `json:"drain,omitempty"`
+}
+
+// serveAdmin accepts admin commands on a unix socket until the context is
+// cancelled.
+// SECURITY: The socket is only accessible to the user running the daemon;
+// anyone who can reach it can stop every deployment.
+func serveAdmin(ctx context.Context, path string, h *handler.Handler, sched *scheduler.Scheduler, publisher *capacity.Publisher) error {
+	// Remove the socket of a previous run that didn't shut down cleanly
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale admin socket: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on admin socket: %w", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to restrict admin socket: %w", err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("[ADMIN] Accept failed: %v", err)
+				}
+				return
+			}
+			go handleAdmin(ctx, conn, h, sched, publisher)
+		}
+	}()
+	return nil
+}
+
+// handleAdmin runs one admin command.
+func handleAdmin(ctx context.Context, conn net.Conn, h *handler.Handler, sched *scheduler.Scheduler, publisher *capacity.Publisher) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(adminTimeout))
+
+	var req adminRequest
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		json.NewEncoder(conn).Encode(adminResponse{Error: "invalid request format"})
+		return
+	}
+
+	var resp adminResponse
+	switch req.Command {
+	case "drain":
+		if req.GraceSeconds < 0 {
+			resp.Error = "grace period must not be negative"
+			break
+		}
+		grace := time.Duration(req.GraceSeconds) * time.Second
+		state, n := h.Drain(grace, req.Message, req.Notify)
+		resp.Success = true
+		resp.Drain = &state
+		resp.Message = fmt.Sprintf("Draining: new deployments are refused; %d deployments will be stopped at %s",
+			n, state.StopAt.Local().Format("2006-01-02 15:04:05"))
+	case "undrain":
+		resp.Success = true
+		if h.Undrain() {
+			resp.Message = "Accepting new deployments again"
+		} else {
+			resp.Message = "Not draining; nothing to do"
+		}
+		state := sched.DrainStatus()
+		resp.Drain = &state
+	default:
+		resp.Error = fmt.Sprintf("unknown command %q", req.Command)
+	}
+
+	// Tell requesters right away rather than at the next advert
+	if resp.Success {
+		log.Printf("[ADMIN] %s", resp.Message)
+		if err := publisher.Publish(ctx); err != nil {
+			log.Printf("[ADMIN] Failed to publish capacity advert: %v", err)
+		}
+	}
+
+	json.NewEncoder(conn).Encode(resp)
+}
+
+// runAdminCommand implements the drain and undrain subcommands, which talk
+// to the running daemon over its admin socket.
+func runAdminCommand(command string, args []string) error {
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	dataDir := fs.String("data-dir", identity.DefaultConfigDir(), "Data directory of the running daemon")
+	req := adminRequest{Command: command}
+	var grace time.Duration
+	if command == "drain" {
+		fs.DurationVar(&grace, "grace", defaultDrainGrace, "How long running deployments keep running before they are stopped")
+		fs.StringVar(&req.Message, "message", "", "Reason for the drain, passed on to owners")
+		fs.BoolVar(&req.Notify, "notify", false, "Notify the owners of running deployments")
+	}
+	fs.Parse(args)
+	if grace < 0 {
+		return errors.New("--grace must not be negative")
+	}
+	req.GraceSeconds = int64(grace.Seconds())
+
+	path := filepath.Join(*dataDir, AdminSocketName)
+	conn, err := net.DialTimeout("unix", path, adminTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to reach peercomputed (is it running with this data directory?): %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(adminTimeout))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return fmt.Errorf("failed to send command: %w", err)
+	}
+	var resp adminResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if !resp.Success {
+		return errors.New(resp.Error)
+	}
+
+	fmt.Println(resp.Message)
+	return nil
+}
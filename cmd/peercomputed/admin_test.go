@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// adminCall sends one request to the admin socket at path.
+func adminCall(t *testing.T, path string, req any) adminResponse {
+	t.Helper()
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		t.Fatal(err)
+	}
+	var resp adminResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestAdminSocketRefusesOtherCommands(t *testing.T) {
+	// Socket paths are limited to about 100 bytes, which t.TempDir can exceed
+	dir, err := os.MkdirTemp("", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, AdminSocketName)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Only drain and undrain reach the handler, scheduler and publisher
+	if err := serveAdmin(ctx, path, nil, nil, nil); err != nil {
+		t.Fatalf("serveAdmin() error = %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("admin socket mode = %o, want 600", perm)
+	}
+
+	for _, command := range []string{"deploy", "stop", "trust", "DRAIN", ""} {
+		resp := adminCall(t, path, adminRequest{Command: command})
+		if resp.Success || !strings.Contains(resp.Error, "unknown command") {
+			t.Errorf("command %q: response = %+v, want unknown command", command, resp)
+		}
+	}
+
+	resp := adminCall(t, path, "drain")
+	if resp.Success || resp.Error != "invalid request format" {
+		t.Errorf("malformed request: response = %+v, want invalid request format", resp)
+	}
+}
@@ -3,6 +3,11 @@
 // peercomputed is the provider agent that runs on machines offering compute
 // resources. It handles deployment requests, container execution, and tunnel
 // management.
+//
+// The drain and undrain subcommands control a running daemon:
+//
+//	peercomputed drain [--grace 10m] [--notify] [--message TEXT]
+//	peercomputed undrain
 package main
 
 import (
@@ -12,14 +17,23 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
 
+	"github.com/xdas-research/peer-compute/internal/artifact"
+	"github.com/xdas-research/peer-compute/internal/capacity"
+	"github.com/xdas-research/peer-compute/internal/client"
+	"github.com/xdas-research/peer-compute/internal/credit"
 	"github.com/xdas-research/peer-compute/internal/handler"
 	"github.com/xdas-research/peer-compute/internal/identity"
 	"github.com/xdas-research/peer-compute/internal/p2p"
 	"github.com/xdas-research/peer-compute/internal/runtime"
 	"github.com/xdas-research/peer-compute/internal/scheduler"
 	"github.com/xdas-research/peer-compute/internal/tunnel"
+	"github.com/xdas-research/peer-compute/internal/usage"
 )
 
 var (
@@ -28,16 +42,36 @@ var (
 )
 
 type Config struct {
-	ListenPort  int
-	GatewayAddr string
-	MaxCPU      int64
-	MaxMemory   int64
-	MaxDeploys  int
-	DataDir     string
-	Verbose     bool
+	ListenPort    int
+	GatewayAddr   string
+	MaxCPU        int64
+	MaxMemory     int64
+	ReserveCPU    int64
+	ReserveMemory int64
+	CPUOvercommit float64
+	MemOvercommit float64
+	MaxDeploys    int
+	MaxQueue      int
+	DataDir       string
+	DockerHost    string
+	Policy        string
+	Region        string
+	MaxLease      time.Duration
+	ArtifactQuota int64
+	CreditLimit   float64
+	StopOnExit    bool
+	Verbose       bool
 }
 
 func main() {
+	if len(os.Args) > 1 && (os.Args[1] == "drain" || os.Args[1] == "undrain") {
+		if err := runAdminCommand(os.Args[1], os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	cfg := parseFlags()
 
 	log.Printf("Peer Compute Daemon %s (commit: %s)", Version, Commit)
@@ -66,16 +100,31 @@ func parseFlags() *Config {
 
 	flag.IntVar(&cfg.ListenPort, "port", 9000, "P2P listen port")
 	flag.StringVar(&cfg.GatewayAddr, "gateway", "", "Gateway address for tunnel connections (e.g., peercompute.xdastechnology.com:8443)")
-	flag.Int64Var(&cfg.MaxCPU, "max-cpu", 4000, "Maximum CPU in millicores")
-	flag.Int64Var(&cfg.MaxMemory, "max-memory", 4*1024*1024*1024, "Maximum memory in bytes")
+	flag.Int64Var(&cfg.MaxCPU, "max-cpu", 0, "Maximum CPU in millicores (0 = detect, minus --reserve-cpu)")
+	flag.Int64Var(&cfg.MaxMemory, "max-memory", 0, "Maximum memory in bytes (0 = detect, minus --reserve-memory)")
+	flag.Int64Var(&cfg.ReserveCPU, "reserve-cpu", 0, "CPU in millicores kept for the host when detecting capacity")
+	flag.Int64Var(&cfg.ReserveMemory, "reserve-memory", 0, "Memory in bytes kept for the host when detecting capacity")
+	flag.Float64Var(&cfg.CPUOvercommit, "cpu-overcommit", 1, "Admit deployments up to this multiple of the CPU capacity")
+	flag.Float64Var(&cfg.MemOvercommit, "memory-overcommit", 1, "Admit deployments up to this multiple of the memory capacity")
 	flag.IntVar(&cfg.MaxDeploys, "max-deploys", 10, "Maximum concurrent deployments")
+	flag.IntVar(&cfg.MaxQueue, "max-queue", 0, "Admission queue length for requests that don't fit yet (0 = reject immediately)")
 	flag.StringVar(&cfg.DataDir, "data-dir", "", "Data directory (default: ~/.peercompute)")
+	flag.StringVar(&cfg.DockerHost, "docker-host", runtime.DockerHost(), "Docker daemon address (unix:///path/to/socket or tcp://host:port)")
+	flag.StringVar(&cfg.Policy, "security-policy", "", "Security policy file (default: <data-dir>/security_policy.json)")
+	flag.StringVar(&cfg.Region, "region", "", "Region label advertised to trusted peers (e.g., eu-west)")
+	flag.Int64Var(&cfg.ArtifactQuota, "artifact-quota", artifact.DefaultQuota, "Default job artifact storage per peer in bytes, for peers without their own quota")
+	flag.Float64Var(&cfg.CreditLimit, "credit-limit", 0, "Refuse deployments from peers whose credit balance with this provider is below minus this many CPU-hours (0 = no limit)")
+	flag.DurationVar(&cfg.MaxLease, "max-lease", 0, "Default maximum deployment lease for peers without their own limit (0 = unlimited)")
+	flag.BoolVar(&cfg.StopOnExit, "stop-on-shutdown", true, "Stop all deployments on shutdown (false = leave them running and re-adopt on restart)")
 	flag.BoolVar(&cfg.Verbose, "verbose", false, "Enable verbose logging")
 	flag.Parse()
 
 	if cfg.DataDir == "" {
 		cfg.DataDir = identity.DefaultConfigDir()
 	}
+	if cfg.Policy == "" {
+		cfg.Policy = cfg.DataDir + "/" + runtime.SecurityPolicyFileName
+	}
 
 	return cfg
 }
@@ -96,23 +145,40 @@ func run(ctx context.Context, cfg *Config) error {
 
 	// 2. Initialize Docker runtime
 	log.Println("Initializing Docker runtime...")
-	rt, err := runtime.NewRuntime()
+	engine, err := runtime.NewEngine(cfg.DockerHost)
 	if err != nil {
 		return fmt.Errorf("failed to initialize Docker runtime: %w", err)
 	}
-	defer rt.Close()
+	defer engine.Close()
 
-	if err := rt.Ping(ctx); err != nil {
+	if err := engine.Ping(ctx); err != nil {
 		return fmt.Errorf("Docker not available: %w", err)
 	}
 	log.Println("Docker runtime ready")
 
+	// SECURITY: Every container goes through the security policy
+	policy, err := runtime.LoadSecurityPolicy(cfg.Policy)
+	if err != nil {
+		return fmt.Errorf("failed to load security policy: %w", err)
+	}
+	logSecurityPolicy(policy)
+	rt := runtime.Enforce(engine, policy)
+
 	// 3. Initialize scheduler
 	log.Println("Initializing scheduler...")
+	if err := resolveCapacity(cfg); err != nil {
+		return err
+	}
+	log.Printf("Capacity: %d millicores CPU (overcommit %.2gx), %d MB memory (overcommit %.2gx)",
+		cfg.MaxCPU, cfg.CPUOvercommit, cfg.MaxMemory/(1024*1024), cfg.MemOvercommit)
 	sched := scheduler.NewScheduler(rt, &scheduler.Config{
-		MaxDeployments: cfg.MaxDeploys,
-		MaxCPU:         cfg.MaxCPU,
-		MaxMemory:      cfg.MaxMemory,
+		MaxDeployments:   cfg.MaxDeploys,
+		MaxCPU:           cfg.MaxCPU,
+		MaxMemory:        cfg.MaxMemory,
+		CPUOvercommit:    cfg.CPUOvercommit,
+		MemoryOvercommit: cfg.MemOvercommit,
+		StateDir:         cfg.DataDir,
+		MaxQueue:         cfg.MaxQueue,
 	})
 
 	// 4. Load trust list
@@ -124,12 +190,53 @@ func run(ctx context.Context, cfg *Config) error {
 	}
 	log.Printf("Trusted peers: %d", trust.Count())
 
-	// 5. Start P2P host
+	// Job artifacts are stored per peer, up to each peer's quota
+	artifacts := artifact.NewStore(cfg.DataDir+"/artifacts", func(owner string) int64 {
+		if pid, err := peer.Decode(owner); err == nil {
+			if tp, ok := trust.Get(pid); ok && tp.ArtifactQuotaBytes > 0 {
+				return tp.ArtifactQuotaBytes
+			}
+		}
+		return cfg.ArtifactQuota
+	})
+	sched.SetArtifactStore(artifacts)
+
+	// Re-adopt deployments that survived a restart. The artifact store must
+	// be set first, so outputs of jobs that finished while the daemon was
+	// down are collected
+	adopted, dropped, err := sched.Recover(ctx)
+	if err != nil {
+		log.Printf("Warning: failed to recover deployments: %v", err)
+	} else if adopted > 0 || dropped > 0 {
+		log.Printf("Recovered %d deployments (%d lost while the daemon was down)", adopted, dropped)
+	}
+
+	// Track container exits, OOM kills and external removals, as they
+	// happen and on a timer in case events are missed
+	go sched.WatchEvents(ctx)
+	go sched.RunReconciler(ctx, scheduler.DefaultReconcileInterval)
+
+	// Start scheduled jobs when they are due
+	go sched.RunCron(ctx)
+
+	// 5. Load private network key (optional)
+	psk, err := p2p.LoadSwarmKey(cfg.DataDir + "/" + p2p.SwarmKeyFileName)
+	if err != nil {
+		return fmt.Errorf("failed to load private network key: %w", err)
+	}
+	if psk != nil {
+		log.Println("Private network key loaded - only peers with the same key can connect")
+	}
+
+	// 6. Start P2P host with per-peer stream limits
 	log.Printf("Starting P2P host on port %d...", cfg.ListenPort)
+	limiter := handler.NewRateLimiter(handler.DefaultRateLimits())
 	host, err := p2p.NewHost(ctx, &p2p.Config{
 		Identity:     id,
 		ListenPort:   cfg.ListenPort,
 		TrustManager: trust,
+		StreamLimits: limiter.StreamLimits(),
+		PSK:          psk,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to start P2P host: %w", err)
@@ -141,7 +248,7 @@ func run(ctx context.Context, cfg *Config) error {
 		log.Printf("  %s/p2p/%s", addr, host.ID())
 	}
 
-	// 6. Connect to gateway (if specified)
+	// 7. Connect to gateway (if specified)
 	var tunnelClient *tunnel.Client
 	if cfg.GatewayAddr != "" {
 		log.Printf("Connecting to gateway: %s", cfg.GatewayAddr)
@@ -159,13 +266,96 @@ func run(ctx context.Context, cfg *Config) error {
 		log.Println("No gateway specified - containers will only be accessible locally")
 	}
 
-	// 7. Register protocol handlers with tunnel support
+	// 8. Register protocol handlers with tunnel support
 	log.Println("Registering protocol handlers...")
 	h := handler.NewHandler(sched, rt, trust, host.ID())
 	h.SetTunnelClient(tunnelClient)
+	h.SetSecurityPolicy(policy)
+	h.SetRateLimiter(limiter)
+	h.SetMaxLease(cfg.MaxLease)
+	h.SetNotificationInbox(cfg.DataDir + "/" + handler.NotificationsFileName)
+	h.SetArtifactStore(artifacts)
 	h.RegisterHandlers(host)
+	h.RestoreRoutes()
+
+	// Run health checks; held gateway routes are registered once healthy
+	sched.SetHealthHook(h.HealthChanged)
+	go sched.RunHealthChecks(ctx)
+
+	// Admit queued requests as resources free up
+	sched.SetStartHook(h.DeploymentStarted)
+	go sched.RunQueue(ctx)
 
-	// 8. Start discovery
+	// Tell owners when their deployments are preempted
+	sched.SetPreemptHook(h.NotifyPreempted)
+
+	// Point gateway routes at updated deployments' new containers
+	sched.SetUpdateHook(h.DeploymentUpdated)
+
+	// Stop deployments when a drain's grace period ends
+	sched.SetDrainHook(h.DeploymentDrained)
+	go sched.RunDrain(ctx)
+	if state := sched.DrainStatus(); state.Cordoned {
+		log.Printf("Draining since %s - not accepting new deployments (run 'peercomputed undrain' to resume)",
+			state.Since.Local().Format("2006-01-02 15:04:05"))
+	}
+
+	// Meter what each requester's deployments consume
+	ledger, err := usage.LoadLedger(cfg.DataDir + "/" + usage.LedgerFileName)
+	if err != nil {
+		// Keep the damaged file for inspection rather than overwrite it
+		log.Printf("Warning: failed to load usage ledger, metering in memory only: %v", err)
+		ledger = usage.NewLedger("")
+	}
+	h.SetUsageLedger(ledger)
+	meter := usage.NewMeter(sched, rt, ledger, usage.DefaultSampleInterval)
+
+	// Turn metered usage into receipts that requesters countersign, and
+	// keep the credit balance with each peer
+	credits, err := credit.LoadLedger(cfg.DataDir+"/"+credit.LedgerFileName, id)
+	if err != nil {
+		log.Printf("Warning: failed to load credit ledger, keeping credit in memory only: %v", err)
+		credits = credit.NewLedger("", id)
+	}
+	meter.SetSampleHook(credits.Accrue)
+	// Only countersign receipts for what peerctl requested with this identity
+	recordsPath := cfg.DataDir + "/" + client.DeploymentsFileName
+	credits.SetRequested(func(providerID, id string) (int64, bool) {
+		records, err := client.LoadDeploymentRecords(recordsPath)
+		if err != nil {
+			log.Printf("[CREDIT] %v", err)
+			return 0, false
+		}
+		rec, ok := client.FindDeploymentRecord(records, providerID, id)
+		return rec.CPUMillicores, ok
+	})
+	h.SetCreditLedger(credits)
+	h.SetCreditLimit(cfg.CreditLimit)
+	if cfg.CreditLimit > 0 {
+		log.Printf("Credit limit: peers may owe up to %.2f CPU-hours", cfg.CreditLimit)
+	}
+	go meter.Run(ctx)
+	go h.RunReceipts(ctx)
+
+	// 9. Start capacity advertisements on the private gossip topic
+	log.Println("Starting capacity advertisements...")
+	gossip := p2p.NewGossip(host, trust, limiter)
+	adverts := capacity.NewCache(cfg.DataDir + "/" + capacity.CacheFileName)
+	adverts.Subscribe(gossip)
+	publisher := capacity.NewPublisher(gossip, sched, id, &capacity.PublisherConfig{
+		Region: cfg.Region,
+		GatewayConnected: func() bool {
+			return tunnelClient != nil && tunnelClient.IsConnected()
+		},
+	})
+	go publisher.Run(ctx)
+
+	// Accept drain and undrain commands from the local operator
+	if err := serveAdmin(ctx, cfg.DataDir+"/"+AdminSocketName, h, sched, publisher); err != nil {
+		log.Printf("Warning: admin socket unavailable, drain and undrain will not work: %v", err)
+	}
+
+	// 10. Start discovery
 	log.Println("Starting peer discovery...")
 	discovery := p2p.NewDiscovery(host.Host(), trust)
 	if err := discovery.Start(ctx); err != nil {
@@ -173,7 +363,7 @@ func run(ctx context.Context, cfg *Config) error {
 	}
 	defer discovery.Stop()
 
-	// 9. Connect to known peers
+	// 11. Connect to known peers
 	go connectToKnownPeers(ctx, host, trust)
 
 	log.Println("")
@@ -192,6 +382,11 @@ func run(ctx context.Context, cfg *Config) error {
 	// Wait for shutdown
 	<-ctx.Done()
 
+	if !cfg.StopOnExit {
+		log.Printf("Leaving %d deployments running (--stop-on-shutdown=false)", len(sched.List()))
+		return nil
+	}
+
 	// Cleanup all containers
 	log.Println("Cleaning up containers...")
 	cleanupCtx := context.Background()
@@ -204,6 +399,62 @@ func run(ctx context.Context, cfg *Config) error {
 	return nil
 }
 
+// resolveCapacity fills in the CPU and memory capacity that wasn't set with
+// --max-cpu/--max-memory from the host's detected resources, keeping the
+// reserve for the host itself.
+func resolveCapacity(cfg *Config) error {
+	if cfg.ReserveCPU < 0 || cfg.ReserveMemory < 0 {
+		return fmt.Errorf("reserves must not be negative")
+	}
+	if cfg.CPUOvercommit < 1 || cfg.MemOvercommit < 1 {
+		return fmt.Errorf("overcommit ratios must be at least 1")
+	}
+	if cfg.MaxCPU > 0 && cfg.MaxMemory > 0 {
+		return nil
+	}
+
+	host, err := scheduler.DetectHostResources()
+	if err != nil {
+		// Fall back to the scheduler defaults rather than refusing to start
+		log.Printf("Warning: failed to detect host resources: %v", err)
+		defaults := scheduler.DefaultConfig()
+		host = scheduler.HostResources{CPUMillicores: defaults.MaxCPU, MemoryBytes: defaults.MaxMemory}
+	} else {
+		log.Printf("Detected %d millicores CPU, %d MB memory", host.CPUMillicores, host.MemoryBytes/(1024*1024))
+	}
+
+	if cfg.MaxCPU <= 0 {
+		cfg.MaxCPU = host.CPUMillicores - cfg.ReserveCPU
+		if cfg.MaxCPU <= 0 {
+			return fmt.Errorf("--reserve-cpu %d leaves no CPU for deployments (detected %d millicores)", cfg.ReserveCPU, host.CPUMillicores)
+		}
+	}
+	if cfg.MaxMemory <= 0 {
+		cfg.MaxMemory = host.MemoryBytes - cfg.ReserveMemory
+		if cfg.MaxMemory <= 0 {
+			return fmt.Errorf("--reserve-memory %d leaves no memory for deployments (detected %d bytes)", cfg.ReserveMemory, host.MemoryBytes)
+		}
+	}
+	return nil
+}
+
+// logSecurityPolicy logs the limits and image rules of the security policy.
+func logSecurityPolicy(p *runtime.SecurityPolicy) {
+	log.Printf("Security policy: max %d millicores CPU and %d MB memory per container, max %d containers",
+		p.MaxCPUMillicores, p.MaxMemoryBytes/(1024*1024), p.MaxContainers)
+	if len(p.AllowedImages) > 0 {
+		log.Printf("Allowed images: %s", strings.Join(p.AllowedImages, ", "))
+	} else {
+		log.Println("Allowed images: all")
+	}
+	if len(p.DeniedImages) > 0 {
+		log.Printf("Denied images: %s", strings.Join(p.DeniedImages, ", "))
+	}
+	if p.SeccompProfile != "" {
+		log.Printf("Seccomp profile: %s", p.SeccompProfile)
+	}
+}
+
 func connectToKnownPeers(ctx context.Context, host *p2p.Host, trust *p2p.TrustManager) {
 	for _, peer := range trust.List() {
 		if len(peer.Addresses) == 0 {
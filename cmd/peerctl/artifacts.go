@@ -0,0 +1,279 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/xdas-research/peer-compute/internal/client"
+	"github.com/xdas-research/peer-compute/internal/identity"
+	"github.com/xdas-research/peer-compute/internal/p2p"
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+func newArtifactsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "artifacts",
+		Short: "Download job output artifacts",
+	}
+
+	cmd.AddCommand(newArtifactsGetCmd())
+
+	return cmd
+}
+
+func newArtifactsGetCmd() *cobra.Command {
+	var (
+		peerName string
+		outDir   string
+		timeout  time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "get <deployment-id>",
+		Short: "Download a finished job's outputs",
+		Long: `Download the outputs of a finished job.
+
+Each output path declared with 'peerctl run --output' is saved as a tarball
+named after the path, and checked against its SHA-256 digest.
+
+Examples:
+  peerctl artifacts get dep-123456789
+  peerctl artifacts get dep-123456789 --out ./results`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			deploymentID := args[0]
+			if outDir == "" {
+				outDir = deploymentID
+			}
+
+			id, _, err := identity.LoadOrGenerate(identity.DefaultKeyPath())
+			if err != nil {
+				return fmt.Errorf("failed to load identity: %w", err)
+			}
+
+			tm := p2p.NewTrustManager(identity.DefaultTrustedPeersPath())
+			if err := tm.Load(); err != nil {
+				return fmt.Errorf("failed to load trust list: %w", err)
+			}
+
+			targetPeer, err := findDeploymentPeer(tm, deploymentID, peerName)
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			host, err := connectToPeer(ctx, id, tm, targetPeer)
+			if err != nil {
+				return err
+			}
+			defer host.Close()
+
+			c := client.NewClient(host)
+			status, err := c.Status(ctx, targetPeer.ID, deploymentID)
+			if err != nil {
+				return err
+			}
+			if status.Error != "" {
+				return fmt.Errorf("status request refused: %s", status.Error)
+			}
+			if len(status.Deployments) == 0 {
+				return fmt.Errorf("deployment %s not found on provider", deploymentID)
+			}
+			info := status.Deployments[0]
+			if !protocol.DeploymentStatus(info.Status).IsFinished() {
+				return fmt.Errorf("deployment %s is %s; outputs are collected when it finishes", deploymentID, info.Status)
+			}
+			if len(info.Outputs) == 0 {
+				return fmt.Errorf("deployment %s has no outputs", deploymentID)
+			}
+
+			if err := os.MkdirAll(outDir, 0755); err != nil {
+				return fmt.Errorf("failed to create output directory: %w", err)
+			}
+
+			failed := 0
+			for _, a := range info.Outputs {
+				if a.Error != "" {
+					fmt.Printf("✗ %s: %s\n", a.Path, a.Error)
+					failed++
+					continue
+				}
+				dest := filepath.Join(outDir, outputFileName(a.Path))
+				if err := downloadArtifact(ctx, c, targetPeer, a.Digest, dest); err != nil {
+					fmt.Printf("✗ %s: %v\n", a.Path, err)
+					failed++
+					continue
+				}
+				fmt.Printf("✓ %s -> %s (%d bytes)\n", a.Path, dest, a.Size)
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("%d of %d outputs could not be downloaded", failed, len(info.Outputs))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&peerName, "peer", "", "Peer running the deployment (default: from local deployment index)")
+	cmd.Flags().StringVar(&outDir, "out", "", "Directory to save outputs in (default: ./<deployment-id>)")
+	cmd.Flags().DurationVar(&timeout, "timeout", 10*time.Minute, "Download timeout")
+
+	return cmd
+}
+
+// outputFileName names the tarball an output path is saved as.
+func outputFileName(path string) string {
+	name := strings.Trim(strings.ReplaceAll(path, "/", "_"), "_")
+	if name == "" {
+		name = "root"
+	}
+	return name + ".tar"
+}
+
+// downloadArtifact downloads an artifact to dest and verifies its digest.
+func downloadArtifact(ctx context.Context, c *client.Client, target *p2p.TrustedPeer, digest, dest string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".download-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	resp, err := c.DownloadArtifact(ctx, target.ID, digest, io.MultiWriter(tmp, hash))
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return fmt.Errorf("%s", resp.Error)
+	}
+
+	// SECURITY: Don't trust the provider to return what we asked for
+	if got := "sha256:" + hex.EncodeToString(hash.Sum(nil)); got != digest {
+		return fmt.Errorf("digest mismatch: got %s, expected %s", got, digest)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// uploadInput packs a job input and uploads it to a provider, returning its
+// digest. A directory is packed into a tarball; a file is uploaded as is
+// and must already be a tarball.
+func uploadInput(ctx context.Context, c *client.Client, target *p2p.TrustedPeer, path string) (string, error) {
+	f, size, digest, err := packInput(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	resp, err := c.UploadArtifact(ctx, target.ID, f, size, digest)
+	if err != nil {
+		return "", err
+	}
+	if !resp.Success {
+		return "", fmt.Errorf("input upload failed: %s", resp.Error)
+	}
+	return digest, nil
+}
+
+// packInput opens an input for upload and returns its size and digest.
+func packInput(path string) (*os.File, int64, string, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("failed to read input: %w", err)
+	}
+
+	var f *os.File
+	if st.IsDir() {
+		if f, err = tarDirectory(path); err != nil {
+			return nil, 0, "", err
+		}
+	} else if f, err = os.Open(path); err != nil {
+		return nil, 0, "", fmt.Errorf("failed to read input: %w", err)
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return nil, 0, "", fmt.Errorf("failed to read input: %w", err)
+	}
+	return f, size, "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// tarDirectory writes a directory's contents to a temporary tarball, which
+// is removed when closed.
+func tarDirectory(dir string) (*os.File, error) {
+	f, err := os.CreateTemp("", "peerctl-input-*.tar")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create input archive: %w", err)
+	}
+	// Unlink now; the open file stays readable until closed
+	os.Remove(f.Name())
+
+	tw := tar.NewWriter(f)
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to pack input directory: %w", err)
+	}
+	return f, nil
+}
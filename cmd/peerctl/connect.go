@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/xdas-research/peer-compute/internal/identity"
+	"github.com/xdas-research/peer-compute/internal/p2p"
+)
+
+// connectToPeer creates a P2P host and connects it to a trusted peer.
+// The caller must close the returned host.
+func connectToPeer(ctx context.Context, id *identity.Identity, tm *p2p.TrustManager, target *p2p.TrustedPeer) (*p2p.Host, error) {
+	// Join the private network if a swarm key is configured
+	psk, err := p2p.LoadSwarmKey(identity.DefaultSwarmKeyPath())
+	if err != nil {
+		return nil, err
+	}
+
+	host, err := p2p.NewHost(ctx, &p2p.Config{
+		Identity:     id,
+		ListenPort:   0, // Random port
+		TrustManager: tm,
+		PSK:          psk,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create P2P host: %w", err)
+	}
+
+	addrInfo, err := p2p.ParseAddrInfo(target.ID.String(), target.Addresses)
+	if err != nil {
+		host.Close()
+		return nil, fmt.Errorf("failed to parse peer address: %w", err)
+	}
+
+	if err := host.Connect(ctx, addrInfo); err != nil {
+		host.Close()
+		return nil, fmt.Errorf("failed to connect to peer: %w", err)
+	}
+
+	return host, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/xdas-research/peer-compute/internal/credit"
+	"github.com/xdas-research/peer-compute/internal/identity"
+	"github.com/xdas-research/peer-compute/internal/p2p"
+)
+
+func newCreditsCmd() *cobra.Command {
+	var (
+		peerName string
+		receipts bool
+		dataDir  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "credits",
+		Short: "Show compute credit balances with your peers",
+		Long: `Show the compute credit between you and each peer, in CPU-hours.
+
+Providers sign a usage receipt for each deployment they run (at least every
+6 hours), and the requester's daemon countersigns it. GIVEN is CPU time you
+provided to the peer, RECEIVED is CPU time the peer provided to you, and
+BALANCE is the difference: positive means the peer has consumed more from you
+than it gave. UNCONFIRMED is CPU time you provided that the peer has not
+countersigned yet.
+
+Receipts are exchanged by peercomputed, so this shows your local daemon's
+ledger. Providers may refuse deployments from peers that owe them more than
+their --credit-limit.
+
+Examples:
+  peerctl credits
+  peerctl credits --receipts --peer alice`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, _, err := identity.LoadOrGenerate(identity.DefaultKeyPath())
+			if err != nil {
+				return fmt.Errorf("failed to load identity: %w", err)
+			}
+
+			tm := p2p.NewTrustManager(identity.DefaultTrustedPeersPath())
+			if err := tm.Load(); err != nil {
+				return fmt.Errorf("failed to load trust list: %w", err)
+			}
+
+			path := filepath.Join(dataDir, credit.LedgerFileName)
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				fmt.Println("No receipts yet.")
+				return nil
+			}
+			ledger, err := credit.LoadLedger(path, id)
+			if err != nil {
+				return err
+			}
+
+			var peerID string
+			if peerName != "" {
+				target, err := findPeerByName(tm, peerName)
+				if err != nil {
+					return err
+				}
+				peerID = target.ID.String()
+			}
+
+			if receipts {
+				printReceipts(tm, ledger, peerID, id.PeerID.String())
+				return nil
+			}
+			printBalances(tm, ledger, peerID)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&peerName, "peer", "", "Only show this peer")
+	cmd.Flags().BoolVar(&receipts, "receipts", false, "List individual receipts instead of balances")
+	cmd.Flags().StringVar(&dataDir, "data-dir", identity.DefaultConfigDir(), "Data directory of the local daemon")
+
+	return cmd
+}
+
+// printBalances prints the credit balance with each peer.
+func printBalances(tm *p2p.TrustManager, ledger *credit.Ledger, peerID string) {
+	var balances []credit.Balance
+	for _, b := range ledger.Balances() {
+		if peerID == "" || b.PeerID == peerID {
+			balances = append(balances, b)
+		}
+	}
+	if len(balances) == 0 {
+		fmt.Println("No receipts yet.")
+		return
+	}
+
+	fmt.Printf("%-20s  %10s  %10s  %10s  %11s  %8s\n",
+		"PEER", "GIVEN", "RECEIVED", "BALANCE", "UNCONFIRMED", "RECEIPTS")
+	for _, b := range balances {
+		label := peerLabel(tm, b.PeerID)
+		if len(label) > 20 {
+			label = label[:17] + "..."
+		}
+		fmt.Printf("%-20s  %10.2f  %10.2f  %+10.2f  %11.2f  %8d\n",
+			label, b.Given/3600, b.Received/3600, b.Net()/3600, b.Unconfirmed/3600, b.Receipts)
+	}
+}
+
+// printReceipts lists the kept receipts, newest first.
+func printReceipts(tm *p2p.TrustManager, ledger *credit.Ledger, peerID, self string) {
+	entries := ledger.Receipts(peerID)
+	if len(entries) == 0 {
+		fmt.Println("No receipts yet.")
+		return
+	}
+
+	for _, e := range entries {
+		direction, other := "given to", e.RequesterID
+		if e.ProviderID != self {
+			direction, other = "received from", e.ProviderID
+		}
+		state := "countersigned"
+		switch {
+		case e.Disputed != "":
+			state = "disputed: " + e.Disputed
+		case !e.Countersigned():
+			state = "awaiting countersignature"
+		}
+		fmt.Printf("%s  %.2f CPU-hours %s %s\n", e.ReceiptID, e.CPUSeconds/3600, direction, peerLabel(tm, other))
+		fmt.Printf("  Deployment: %s, %s - %s\n", e.DeploymentID,
+			e.PeriodStart.Local().Format("2006-01-02 15:04"), e.PeriodEnd.Local().Format("2006-01-02 15:04"))
+		fmt.Printf("  %s\n", state)
+	}
+}
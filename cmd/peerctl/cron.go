@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/xdas-research/peer-compute/internal/client"
+	"github.com/xdas-research/peer-compute/internal/identity"
+	"github.com/xdas-research/peer-compute/internal/p2p"
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+func newCronCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cron",
+		Short: "Manage scheduled jobs on peers",
+		Long: `Manage jobs that a provider runs on a schedule.
+
+Schedules are five-field cron expressions (minute hour day-of-month month
+day-of-week) evaluated in UTC, or one of @hourly, @daily, @weekly, @monthly
+and @yearly. The provider keeps the last runs of each schedule with their
+exit codes and logs.`,
+	}
+
+	cmd.AddCommand(
+		newCronAddCmd(),
+		newCronListCmd(),
+		newCronDeleteCmd(),
+	)
+
+	return cmd
+}
+
+func newCronAddCmd() *cobra.Command {
+	var (
+		peerName    string
+		schedule    string
+		name        string
+		concurrency string
+		history     int
+		jf          jobFlags
+		timeout     time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "add [flags] <image> [args...]",
+		Short: "Register a scheduled job with a peer",
+		Long: `Register a job that a peer runs on a schedule.
+
+--concurrency decides what happens when a run is due while the previous one
+is still active: forbid skips the new run (default), replace stops the active
+run and starts the new one, and allow runs both.
+
+The job flags work as for 'peerctl run'. An --input is uploaded once and
+copied into every run.
+
+Examples:
+  peerctl cron add --peer alice --schedule "0 2 * * *" my-backup:latest
+  peerctl cron add --peer bob --schedule @hourly --concurrency replace --history 10 my-sync:latest sync --all
+  peerctl cron add --peer alice --schedule "*/15 * * * *" --output /out --deadline 10m my-report:latest`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			job, err := jf.job(args[1:])
+			if err != nil {
+				return err
+			}
+			res, err := jf.resources()
+			if err != nil {
+				return err
+			}
+
+			spec := &protocol.CronSpec{
+				Name:                name,
+				Schedule:            schedule,
+				ConcurrencyPolicy:   protocol.ConcurrencyPolicy(concurrency),
+				History:             history,
+				Image:               args[0],
+				CPUMillicores:       res.cpuMillicores,
+				MemoryBytes:         res.memoryBytes,
+				Environment:         res.env,
+				Job:                 job,
+				PriorityClass:       res.class,
+				Priority:            jf.priority,
+				QueueTimeoutSeconds: int64(jf.queueFor.Seconds()),
+			}
+			if err := spec.Validate(); err != nil {
+				return fmt.Errorf("invalid schedule: %w", err)
+			}
+
+			var targetID string
+			resp, err := sendCronRequest(peerName, timeout, func(ctx context.Context, c *client.Client, target *p2p.TrustedPeer) (*protocol.CronRequest, error) {
+				targetID = target.ID.String()
+				// Stage the input first; the signed spec refers to its digest
+				if jf.input != "" {
+					fmt.Printf("Uploading input %s...\n", jf.input)
+					if job.Input, err = uploadInput(ctx, c, target, jf.input); err != nil {
+						return nil, err
+					}
+				}
+				return &protocol.CronRequest{Op: protocol.CronRegister, Spec: spec}, nil
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, s := range resp.Schedules {
+				// Remember the schedule, so receipts for its runs are
+				// countersigned
+				if err := saveDeploymentRecord(client.DeploymentRecord{
+					ID:            s.ID,
+					PeerID:        targetID,
+					Image:         spec.Image,
+					CPUMillicores: spec.CPUMillicores,
+					CreatedAt:     time.Now(),
+				}); err != nil {
+					fmt.Printf("Warning: %v\n", err)
+				}
+				fmt.Printf("✓ Registered schedule %s (%s)\n", s.ID, s.Spec.Schedule)
+				if s.NextRunAt != nil {
+					fmt.Printf("  Next run: %s\n", s.NextRunAt.Local().Format("2006-01-02 15:04:05"))
+				}
+			}
+			return nil
+		},
+	}
+
+	// Flags after the image belong to the job's command
+	cmd.Flags().SetInterspersed(false)
+
+	cmd.Flags().StringVar(&peerName, "peer", "", "Peer to run the schedule (ID or name)")
+	cmd.Flags().StringVar(&schedule, "schedule", "", "Cron expression in UTC (e.g., \"0 2 * * *\" or @daily)")
+	cmd.Flags().StringVar(&name, "name", "", "Label for the schedule")
+	cmd.Flags().StringVar(&concurrency, "concurrency", string(protocol.ConcurrencyForbid), "When a run is due while another is active: forbid, replace or allow")
+	cmd.Flags().IntVar(&history, "history", protocol.DefaultCronHistory, "Number of runs to keep with their exit codes and logs")
+	jf.register(cmd)
+	cmd.Flags().DurationVar(&timeout, "timeout", 5*time.Minute, "Request timeout")
+
+	cmd.MarkFlagRequired("peer")
+	cmd.MarkFlagRequired("schedule")
+
+	return cmd
+}
+
+func newCronListCmd() *cobra.Command {
+	var (
+		peerName string
+		timeout  time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List your schedules on a peer and their recent runs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resp, err := sendCronRequest(peerName, timeout, func(context.Context, *client.Client, *p2p.TrustedPeer) (*protocol.CronRequest, error) {
+				return &protocol.CronRequest{Op: protocol.CronList}, nil
+			})
+			if err != nil {
+				return err
+			}
+
+			if len(resp.Schedules) == 0 {
+				fmt.Println("No schedules.")
+				return nil
+			}
+
+			for i, s := range resp.Schedules {
+				if i > 0 {
+					fmt.Println()
+				}
+				fmt.Printf("%s", s.ID)
+				if s.Spec.Name != "" {
+					fmt.Printf(" (%s)", s.Spec.Name)
+				}
+				fmt.Println()
+				fmt.Printf("  Schedule:    %s\n", s.Spec.Schedule)
+				fmt.Printf("  Image:       %s\n", s.Spec.Image)
+				fmt.Printf("  Concurrency: %s\n", s.Spec.Policy())
+				if s.NextRunAt != nil {
+					fmt.Printf("  Next run:    %s\n", s.NextRunAt.Local().Format("2006-01-02 15:04:05"))
+				}
+				if len(s.Runs) == 0 {
+					continue
+				}
+				fmt.Println("  Runs:")
+				for _, r := range s.Runs {
+					fmt.Printf("    %s  %s\n", r.ScheduledAt.Local().Format("2006-01-02 15:04"), describeCronRun(r))
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&peerName, "peer", "", "Peer running the schedules (ID or name)")
+	cmd.Flags().DurationVar(&timeout, "timeout", 30*time.Second, "Request timeout")
+
+	cmd.MarkFlagRequired("peer")
+
+	return cmd
+}
+
+func newCronDeleteCmd() *cobra.Command {
+	var (
+		peerName string
+		timeout  time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "delete <schedule-id>",
+		Short: "Delete a schedule",
+		Long: `Delete a schedule so no further runs start.
+
+A run that is still active keeps running. Past runs stay on the provider as
+ordinary finished deployments until it prunes them.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resp, err := sendCronRequest(peerName, timeout, func(context.Context, *client.Client, *p2p.TrustedPeer) (*protocol.CronRequest, error) {
+				return &protocol.CronRequest{Op: protocol.CronDelete, ScheduleID: args[0]}, nil
+			})
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("✓ %s\n", resp.Message)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&peerName, "peer", "", "Peer running the schedule (ID or name)")
+	cmd.Flags().DurationVar(&timeout, "timeout", 30*time.Second, "Request timeout")
+
+	cmd.MarkFlagRequired("peer")
+
+	return cmd
+}
+
+// sendCronRequest connects to a peer, builds a cron request with build, signs
+// and sends it, and returns the successful response.
+func sendCronRequest(peerName string, timeout time.Duration, build func(context.Context, *client.Client, *p2p.TrustedPeer) (*protocol.CronRequest, error)) (*protocol.CronResponse, error) {
+	id, _, err := identity.LoadOrGenerate(identity.DefaultKeyPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load identity: %w", err)
+	}
+
+	tm := p2p.NewTrustManager(identity.DefaultTrustedPeersPath())
+	if err := tm.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load trust list: %w", err)
+	}
+
+	targetPeer, err := findPeerByName(tm, peerName)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	host, err := connectToPeer(ctx, id, tm, targetPeer)
+	if err != nil {
+		return nil, err
+	}
+	defer host.Close()
+
+	c := client.NewClient(host)
+	req, err := build(ctx, c, targetPeer)
+	if err != nil {
+		return nil, err
+	}
+	if err := protocol.SignCronRequest(req, id); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Cron(ctx, targetPeer.ID, req)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("cron request failed: %s", firstNonEmpty(resp.Error, resp.Message))
+	}
+	return resp, nil
+}
+
+// describeCronRun summarizes a run for cron list.
+func describeCronRun(r protocol.CronRun) string {
+	if r.DeploymentID == "" {
+		return r.Error
+	}
+
+	s := r.DeploymentID
+	if r.Status != "" {
+		s += "  " + string(r.Status)
+	}
+	if r.ExitCode != nil {
+		s += fmt.Sprintf(" (exit code %d)", *r.ExitCode)
+	}
+	if r.Reason != "" {
+		s += fmt.Sprintf(" [%s]", r.Reason)
+	}
+	if r.Error != "" {
+		s += "  " + r.Error
+	}
+	return s
+}
@@ -2,13 +2,20 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/spf13/cobra"
+	"github.com/xdas-research/peer-compute/internal/capacity"
+	"github.com/xdas-research/peer-compute/internal/client"
 	"github.com/xdas-research/peer-compute/internal/identity"
 	"github.com/xdas-research/peer-compute/internal/p2p"
 	"github.com/xdas-research/peer-compute/internal/protocol"
@@ -17,11 +24,21 @@ import (
 func newDeployCmd() *cobra.Command {
 	var (
 		peerName   string
+		name       string
+		replicas   int
 		cpu        string
 		memory     string
 		exposePort int
 		envVars    []string
+		region     string
+		restart    string
+		health     healthFlags
+		lease      time.Duration
+		priority   int
+		class      string
+		queueFor   time.Duration
 		timeout    time.Duration
+		dataDir    string
 	)
 
 	cmd := &cobra.Command{
@@ -33,17 +50,51 @@ The container will be pulled and started on the peer machine with the specified
 resource limits. If --expose is specified, the container will be accessible
 via a public URL through the gateway.
 
+--name gives the deployment a name that is unique among your deployments on
+that peer. The name can be used in place of the deployment ID in every other
+command, and becomes the subdomain of the public URL.
+
+Use --peer auto to pick the trusted provider with the most free capacity,
+based on the capacity adverts collected by the local daemon.
+
+--replicas runs several identical containers behind one public URL; the
+gateway balances requests between the healthy ones. Give --peer a
+comma-separated list of peers to spread the replicas over them in turn, or
+use --peer auto to spread them over the providers with free capacity.
+
 Examples:
   peerctl deploy nginx:alpine --peer alice --cpu 0.5 --memory 256M --expose 80
+  peerctl deploy nginx:alpine --peer alice --name web --expose 80
   peerctl deploy my-api:latest --peer bob --cpu 1 --memory 512M
-  peerctl deploy redis:7 --peer alice --cpu 0.25 --memory 128M`,
+  peerctl deploy redis:7 --peer alice --cpu 0.25 --memory 128M --restart always
+  peerctl deploy my-api:latest --peer bob --expose 8080 --health-http /healthz --health-hold-route
+  peerctl deploy my-job:latest --peer alice --lease 4h
+  peerctl deploy my-batch:latest --peer alice --queue-timeout 1h --priority 5
+  peerctl deploy demo:latest --peer bob --class critical --expose 80
+  peerctl deploy my-api:latest --peer auto --region eu-west --cpu 1 --memory 512M
+  peerctl deploy my-api:latest --peer alice,bob --replicas 4 --name api --expose 8080`,
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			imageName := args[0]
 
-			if peerName == "" {
+			if strings.Trim(peerName, ", ") == "" {
 				return fmt.Errorf("--peer is required")
 			}
+			if lease < 0 {
+				return fmt.Errorf("--lease must not be negative")
+			}
+			if replicas < 1 {
+				return fmt.Errorf("--replicas must be at least 1")
+			}
+			if name != "" {
+				if err := protocol.ValidateDeploymentName(name); err != nil {
+					return fmt.Errorf("invalid --name: %w", err)
+				}
+			}
+			priorityClass := protocol.PriorityClass(class)
+			if err := priorityClass.Validate(); err != nil {
+				return err
+			}
 
 			// Parse resource limits
 			cpuMillicores, err := parseCPU(cpu)
@@ -56,6 +107,16 @@ Examples:
 				return fmt.Errorf("invalid memory value: %w", err)
 			}
 
+			restartPolicy, err := parseRestartPolicy(restart)
+			if err != nil {
+				return fmt.Errorf("invalid restart policy: %w", err)
+			}
+
+			healthCheck, err := health.healthCheck()
+			if err != nil {
+				return fmt.Errorf("invalid health check: %w", err)
+			}
+
 			// Parse environment variables
 			env, err := parseEnvVars(envVars)
 			if err != nil {
@@ -75,29 +136,49 @@ Examples:
 				return fmt.Errorf("failed to load trust list: %w", err)
 			}
 
-			targetPeer, err := findPeerByName(tm, peerName)
+			placements, err := placeReplicas(dataDir, peerName, replicas, cpuMillicores, memoryBytes, region, exposePort > 0)
 			if err != nil {
 				return err
 			}
+			targets := make([]*p2p.TrustedPeer, len(placements))
+			for i, p := range placements {
+				if targets[i], err = findPeerByName(tm, p.PeerID); err != nil {
+					return err
+				}
+			}
 
 			// Create deployment request
 			req := &protocol.DeployRequest{
-				RequestID:     uuid.New().String(),
-				Image:         imageName,
-				CPUMillicores: cpuMillicores,
-				MemoryBytes:   memoryBytes,
-				ExposePort:    exposePort,
-				Environment:   env,
-				RequesterID:   id.PeerID.String(),
-				Timestamp:     time.Now().UnixNano(),
+				Image:               imageName,
+				Name:                name,
+				CPUMillicores:       cpuMillicores,
+				MemoryBytes:         memoryBytes,
+				ExposePort:          exposePort,
+				Environment:         env,
+				RestartPolicy:       restartPolicy,
+				HealthCheck:         healthCheck,
+				LeaseSeconds:        int64(lease.Seconds()),
+				PriorityClass:       priorityClass,
+				Priority:            priority,
+				QueueTimeoutSeconds: int64(queueFor.Seconds()),
+				RequesterID:         id.PeerID.String(),
 			}
-
-			// Sign the request
-			if err := signRequest(req, id); err != nil {
-				return fmt.Errorf("failed to sign request: %w", err)
+			// Replicas on every provider join one replica set, so the
+			// gateway serves them all under the same route
+			if replicas > 1 {
+				if req.ReplicaSet, err = newReplicaSetID(); err != nil {
+					return err
+				}
 			}
 
-			fmt.Printf("Deploying %s to peer %s...\n", imageName, targetPeer.ID)
+			if replicas > 1 {
+				fmt.Printf("Deploying %d replicas of %s to %d peer(s)...\n", replicas, imageName, len(targets))
+			} else {
+				fmt.Printf("Deploying %s to peer %s...\n", imageName, targets[0].ID)
+			}
+			if name != "" {
+				fmt.Printf("  Name: %s\n", name)
+			}
 			fmt.Printf("  CPU: %d millicores\n", cpuMillicores)
 			fmt.Printf("  Memory: %d bytes\n", memoryBytes)
 			if exposePort > 0 {
@@ -108,74 +189,105 @@ Examples:
 			ctx, cancel := context.WithTimeout(context.Background(), timeout)
 			defer cancel()
 
-			// Create P2P host
-			host, err := p2p.NewHost(ctx, &p2p.Config{
-				Identity:     id,
-				ListenPort:   0, // Random port
-				TrustManager: tm,
-			})
-			if err != nil {
-				return fmt.Errorf("failed to create P2P host: %w", err)
-			}
-			defer host.Close()
-
-			// Parse target peer address
-			addrInfo, err := p2p.ParseAddrInfo(targetPeer.ID.String(), targetPeer.Addresses)
-			if err != nil {
-				return fmt.Errorf("failed to parse peer address: %w", err)
-			}
-
-			// Connect to the peer
-			fmt.Print("\nConnecting to peer...")
-			if err := host.Connect(ctx, addrInfo); err != nil {
-				return fmt.Errorf("\nfailed to connect to peer: %w", err)
-			}
-			fmt.Println(" connected!")
-
-			// Open stream and send deploy request
-			fmt.Print("Sending deployment request...")
-			stream, err := host.NewStream(ctx, targetPeer.ID, "/peercompute/deploy/1.0.0")
-			if err != nil {
-				return fmt.Errorf("\nfailed to open stream: %w", err)
-			}
-			defer stream.Close()
+			var responses []*protocol.DeployResponse
+			var failures []error
+			started := 0
+			for i, target := range targets {
+				peerReq := *req
+				peerReq.RequestID = uuid.New().String()
+				peerReq.Replicas = placements[i].Replicas
+				peerReq.Timestamp = time.Now().UnixNano()
+
+				// Sign the request
+				if err := signRequest(&peerReq, id); err != nil {
+					return fmt.Errorf("failed to sign request: %w", err)
+				}
 
-			// Send request
-			encoder := json.NewEncoder(stream)
-			if err := encoder.Encode(req); err != nil {
-				return fmt.Errorf("\nfailed to send request: %w", err)
+				if len(targets) > 1 {
+					fmt.Printf("\nPeer %s (%d replicas)\n", target.ID, peerReq.Replicas)
+				}
+				resp, err := sendDeployRequest(ctx, id, tm, target, &peerReq)
+				if err != nil {
+					if len(targets) == 1 {
+						return err
+					}
+					fmt.Printf("Warning: %s\n", strings.TrimSpace(err.Error()))
+					failures = append(failures, err)
+					continue
+				}
+				responses = append(responses, resp)
+
+				// Remember where the deployment runs for later commands
+				for _, r := range replicaInfos(resp) {
+					started++
+					if err := saveDeploymentRecord(client.DeploymentRecord{
+						ID:            r.DeploymentID,
+						PeerID:        target.ID.String(),
+						Name:          name,
+						ReplicaSet:    resp.ReplicaSet,
+						Image:         imageName,
+						CPUMillicores: cpuMillicores,
+						CreatedAt:     time.Now(),
+					}); err != nil {
+						fmt.Printf("Warning: %v\n", err)
+					}
+				}
 			}
-
-			// Read response
-			var resp protocol.DeployResponse
-			decoder := json.NewDecoder(stream)
-			if err := decoder.Decode(&resp); err != nil {
-				return fmt.Errorf("\nfailed to read response: %w", err)
+			if len(responses) == 0 {
+				return fmt.Errorf("deployment failed on every peer: %s", strings.TrimSpace(failures[0].Error()))
 			}
 
-			if !resp.Success {
-				return fmt.Errorf("\ndeployment failed: %s", resp.Message)
+			if replicas > 1 {
+				printReplicaSummary(responses, started, replicas, name)
+				return nil
 			}
+			resp := responses[0]
 
-			fmt.Println(" success!")
 			fmt.Println("\n✓ Deployment successful!")
 			fmt.Printf("  Deployment ID: %s\n", resp.DeploymentID)
+			if name != "" {
+				fmt.Printf("  Name: %s\n", name)
+			}
+			if resp.QueuePosition > 0 {
+				fmt.Printf("  Queue position: %d (starts when the provider has capacity)\n", resp.QueuePosition)
+			}
 			if resp.ContainerID != "" {
-				fmt.Printf("  Container ID: %s\n", resp.ContainerID[:12])
+				fmt.Printf("  Container ID: %s\n", shortContainerID(resp.ContainerID))
+			}
+			if resp.LeaseExpiresAt != nil {
+				fmt.Printf("  Lease expires: %s\n", resp.LeaseExpiresAt.Local().Format("2006-01-02 15:04:05"))
+			}
+			if resp.ExposedURL != "" {
+				fmt.Printf("  URL: %s\n", resp.ExposedURL)
+			} else if healthCheck != nil && healthCheck.HoldRoute && exposePort > 0 {
+				fmt.Println("  URL: registered after the first successful health check")
 			}
 			fmt.Println("\nUse 'peerctl logs <deployment-id>' to view logs")
 			fmt.Println("Use 'peerctl stop <deployment-id>' to stop the deployment")
+			if resp.LeaseExpiresAt != nil {
+				fmt.Println("Use 'peerctl renew <deployment-id> --for <duration>' to extend the lease")
+			}
 
 			return nil
 		},
 	}
 
-	cmd.Flags().StringVar(&peerName, "peer", "", "Target peer (ID or name)")
+	cmd.Flags().StringVar(&peerName, "peer", "", "Target peer (ID, name, comma-separated list, or \"auto\")")
+	cmd.Flags().StringVar(&name, "name", "", "Deployment name, usable instead of the ID and as the URL subdomain")
+	cmd.Flags().IntVar(&replicas, "replicas", 1, fmt.Sprintf("Number of replicas behind the public URL (at most %d per peer)", protocol.MaxReplicas))
 	cmd.Flags().StringVar(&cpu, "cpu", "0.5", "CPU limit (e.g., 0.5, 1, 2)")
 	cmd.Flags().StringVar(&memory, "memory", "256M", "Memory limit (e.g., 128M, 1G)")
 	cmd.Flags().IntVar(&exposePort, "expose", 0, "Container port to expose via gateway")
 	cmd.Flags().StringSliceVar(&envVars, "env", nil, "Environment variables (KEY=VALUE)")
+	cmd.Flags().StringVar(&restart, "restart", "never", "Restart policy: never, on-failure[:max-retries], always")
+	cmd.Flags().DurationVar(&queueFor, "queue-timeout", 0, "Wait up to this long in the provider's queue if it is full (0 = fail immediately)")
+	cmd.Flags().StringVar(&class, "class", string(protocol.ClassNormal), "Priority class: best-effort, normal or critical (higher classes may preempt lower ones)")
+	cmd.Flags().IntVar(&priority, "priority", 0, "Queue priority; higher values are admitted first")
+	cmd.Flags().DurationVar(&lease, "lease", 0, "Lease after which the provider stops the deployment unless renewed (e.g., 4h)")
+	health.register(cmd)
+	cmd.Flags().StringVar(&region, "region", "", "Preferred provider region when using --peer auto")
 	cmd.Flags().DurationVar(&timeout, "timeout", 5*time.Minute, "Deployment timeout")
+	cmd.Flags().StringVar(&dataDir, "data-dir", identity.DefaultConfigDir(), "Data directory of the local daemon, for --peer auto")
 
 	cmd.MarkFlagRequired("peer")
 
@@ -191,8 +303,8 @@ func parseCPU(s string) (int64, error) {
 	if value <= 0 {
 		return 0, fmt.Errorf("must be positive")
 	}
-	if value > 32 {
-		return 0, fmt.Errorf("maximum 32 CPUs")
+	if value*1000 > protocol.MaxCPUMillicores {
+		return 0, fmt.Errorf("maximum %d CPUs", protocol.MaxCPUMillicores/1000)
 	}
 	return int64(value * 1000), nil
 }
@@ -218,6 +330,102 @@ func parseMemory(s string) (int64, error) {
 	}
 }
 
+// parseRestartPolicy parses a restart policy (e.g., "always", "on-failure:5").
+func parseRestartPolicy(s string) (*protocol.RestartPolicy, error) {
+	mode, retries, hasRetries := strings.Cut(s, ":")
+	policy := &protocol.RestartPolicy{Mode: protocol.RestartMode(mode)}
+
+	if hasRetries {
+		if policy.Mode != protocol.RestartOnFailure {
+			return nil, fmt.Errorf("max retries only apply to on-failure")
+		}
+		n, err := strconv.Atoi(retries)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max retries %q", retries)
+		}
+		policy.MaxRetries = n
+	}
+
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	if policy.Mode == protocol.RestartNever {
+		return nil, nil
+	}
+	return policy, nil
+}
+
+// healthFlags holds the --health-* flags of the deploy command.
+type healthFlags struct {
+	http        string
+	tcp         bool
+	cmd         string
+	port        int
+	interval    time.Duration
+	timeout     time.Duration
+	startPeriod time.Duration
+	retries     int
+	successes   int
+	holdRoute   bool
+}
+
+// register adds the health check flags to a command.
+func (f *healthFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.http, "health-http", "", "HTTP health check path (e.g., /healthz)")
+	cmd.Flags().BoolVar(&f.tcp, "health-tcp", false, "TCP health check")
+	cmd.Flags().StringVar(&f.cmd, "health-cmd", "", "Command run inside the container as a health check")
+	cmd.Flags().IntVar(&f.port, "health-port", 0, "Container port for HTTP/TCP health checks (default: --expose)")
+	cmd.Flags().DurationVar(&f.interval, "health-interval", 0, "Time between health checks (default 10s)")
+	cmd.Flags().DurationVar(&f.timeout, "health-timeout", 0, "Timeout of a single health check (default 3s)")
+	cmd.Flags().DurationVar(&f.startPeriod, "health-start-period", 0, "Grace period before failed checks count")
+	cmd.Flags().IntVar(&f.retries, "health-retries", 0, "Consecutive failures before unhealthy (default 3)")
+	cmd.Flags().IntVar(&f.successes, "health-successes", 0, "Consecutive successes before healthy (default 1)")
+	cmd.Flags().BoolVar(&f.holdRoute, "health-hold-route", false, "Register the public URL only after the first successful check")
+}
+
+// healthCheck builds the health check from the flags (nil = none).
+func (f *healthFlags) healthCheck() (*protocol.HealthCheck, error) {
+	hc := &protocol.HealthCheck{
+		Port:               f.port,
+		IntervalSeconds:    int(f.interval.Seconds()),
+		TimeoutSeconds:     int(f.timeout.Seconds()),
+		StartPeriodSeconds: int(f.startPeriod.Seconds()),
+		HealthyThreshold:   f.successes,
+		UnhealthyThreshold: f.retries,
+		HoldRoute:          f.holdRoute,
+	}
+
+	set := 0
+	if f.http != "" {
+		hc.Type, hc.Path = protocol.HealthCheckHTTP, f.http
+		set++
+	}
+	if f.tcp {
+		hc.Type = protocol.HealthCheckTCP
+		set++
+	}
+	if f.cmd != "" {
+		hc.Type, hc.Command = protocol.HealthCheckExec, []string{"sh", "-c", f.cmd}
+		set++
+	}
+
+	switch set {
+	case 0:
+		if f.holdRoute {
+			return nil, fmt.Errorf("--health-hold-route requires a health check")
+		}
+		return nil, nil
+	case 1:
+	default:
+		return nil, fmt.Errorf("use only one of --health-http, --health-tcp and --health-cmd")
+	}
+
+	if err := hc.Validate(); err != nil {
+		return nil, err
+	}
+	return hc, nil
+}
+
 // parseEnvVars parses environment variables from KEY=VALUE format.
 func parseEnvVars(vars []string) (map[string]string, error) {
 	result := make(map[string]string)
@@ -258,6 +466,182 @@ func findPeerByName(tm *p2p.TrustManager, nameOrID string) (*p2p.TrustedPeer, er
 	return nil, fmt.Errorf("peer '%s' not found in trust list", nameOrID)
 }
 
+// loadAdverts reads the capacity adverts collected by the local daemon
+// running with dataDir.
+func loadAdverts(dataDir string) (*capacity.Cache, error) {
+	return capacity.LoadCache(filepath.Join(dataDir, capacity.CacheFileName))
+}
+
+// pickPeer selects a provider from the cached capacity adverts.
+func pickPeer(dataDir string, cpuMillicores, memoryBytes int64, region string, needGateway bool) (string, error) {
+	cache, err := loadAdverts(dataDir)
+	if err != nil {
+		return "", err
+	}
+
+	advert, ok := cache.Pick(cpuMillicores, memoryBytes, region, needGateway)
+	if !ok {
+		return "", fmt.Errorf("no provider with enough free capacity found in capacity adverts (is peercomputed running?)")
+	}
+
+	return advert.PeerID, nil
+}
+
+// placeReplicas decides how many replicas each peer runs. --peer auto
+// spreads them over the providers in the capacity adverts; a comma-separated
+// list of peers takes them in turn.
+func placeReplicas(dataDir, peerFlag string, replicas int, cpuMillicores, memoryBytes int64, region string, needGateway bool) ([]capacity.Placement, error) {
+	var placements []capacity.Placement
+	if peerFlag == "auto" {
+		if replicas == 1 {
+			peerID, err := pickPeer(dataDir, cpuMillicores, memoryBytes, region, needGateway)
+			if err != nil {
+				return nil, err
+			}
+			return []capacity.Placement{{PeerID: peerID, Replicas: 1}}, nil
+		}
+
+		cache, err := loadAdverts(dataDir)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		placements, ok = cache.Spread(replicas, cpuMillicores, memoryBytes, region, needGateway)
+		if !ok {
+			return nil, fmt.Errorf("providers in the capacity adverts do not have room for %d replicas", replicas)
+		}
+	} else {
+		var peers []string
+		for _, p := range strings.Split(peerFlag, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				peers = append(peers, p)
+			}
+		}
+		if len(peers) == 0 {
+			return nil, fmt.Errorf("--peer is required")
+		}
+		for i := 0; i < replicas; i++ {
+			if i < len(peers) {
+				placements = append(placements, capacity.Placement{PeerID: peers[i]})
+			}
+			placements[i%len(peers)].Replicas++
+		}
+	}
+
+	for _, p := range placements {
+		if p.Replicas > protocol.MaxReplicas {
+			return nil, fmt.Errorf("%d replicas on peer %s exceed the maximum of %d per peer; add more peers",
+				p.Replicas, p.PeerID, protocol.MaxReplicas)
+		}
+	}
+	return placements, nil
+}
+
+// newReplicaSetID generates the ID of a new replica set.
+func newReplicaSetID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate replica set ID: %w", err)
+	}
+	return protocol.ReplicaSetIDPrefix + hex.EncodeToString(b), nil
+}
+
+// sendDeployRequest sends a signed deployment request to a peer and waits
+// for its response.
+func sendDeployRequest(ctx context.Context, id *identity.Identity, tm *p2p.TrustManager, targetPeer *p2p.TrustedPeer, req *protocol.DeployRequest) (*protocol.DeployResponse, error) {
+	// Connect to the peer
+	fmt.Print("\nConnecting to peer...")
+	host, err := connectToPeer(ctx, id, tm, targetPeer)
+	if err != nil {
+		return nil, fmt.Errorf("\n%w", err)
+	}
+	defer host.Close()
+	fmt.Println(" connected!")
+
+	// Open stream and send deploy request
+	fmt.Print("Sending deployment request...")
+	stream, err := host.NewStream(ctx, targetPeer.ID, "/peercompute/deploy/1.0.0")
+	if err != nil {
+		return nil, fmt.Errorf("\nfailed to open stream: %w", err)
+	}
+	defer stream.Close()
+
+	// Send request
+	encoder := json.NewEncoder(stream)
+	if err := encoder.Encode(req); err != nil {
+		return nil, fmt.Errorf("\nfailed to send request: %w", err)
+	}
+
+	// Read response
+	var resp protocol.DeployResponse
+	decoder := json.NewDecoder(stream)
+	if err := decoder.Decode(&resp); err != nil {
+		return nil, fmt.Errorf("\nfailed to read response: %w", err)
+	}
+
+	if !resp.Success {
+		return nil, fmt.Errorf("\ndeployment failed: %s", firstNonEmpty(resp.Message, resp.Error))
+	}
+
+	fmt.Println(" success!")
+	return &resp, nil
+}
+
+// replicaInfos returns the replicas a provider started for a request.
+// Providers that predate replicas only report a single deployment.
+func replicaInfos(resp *protocol.DeployResponse) []protocol.ReplicaInfo {
+	if len(resp.Replicas) > 0 {
+		return resp.Replicas
+	}
+	return []protocol.ReplicaInfo{{
+		DeploymentID:  resp.DeploymentID,
+		Status:        resp.Status,
+		ContainerID:   resp.ContainerID,
+		QueuePosition: resp.QueuePosition,
+	}}
+}
+
+// printReplicaSummary prints the outcome of a replicated deployment.
+func printReplicaSummary(responses []*protocol.DeployResponse, started, requested int, name string) {
+	if started < requested {
+		fmt.Printf("\n! %d of %d replicas deployed\n", started, requested)
+	} else {
+		fmt.Printf("\n✓ %d replicas deployed\n", started)
+	}
+
+	ref := responses[0].ReplicaSet
+	if name != "" {
+		ref = name
+	}
+	fmt.Printf("  Replica set: %s\n", responses[0].ReplicaSet)
+	if name != "" {
+		fmt.Printf("  Name: %s\n", name)
+	}
+
+	var url string
+	for _, resp := range responses {
+		for _, r := range replicaInfos(resp) {
+			switch {
+			case r.QueuePosition > 0:
+				fmt.Printf("  %s  queued at position %d\n", r.DeploymentID, r.QueuePosition)
+			case r.ContainerID != "":
+				fmt.Printf("  %s  %s\n", r.DeploymentID, shortContainerID(r.ContainerID))
+			default:
+				fmt.Printf("  %s  %s\n", r.DeploymentID, r.Status)
+			}
+		}
+		if url == "" {
+			url = resp.ExposedURL
+		}
+	}
+	if url != "" {
+		fmt.Printf("  URL: %s\n", url)
+	}
+
+	fmt.Printf("\nUse 'peerctl status %s' to see every replica\n", ref)
+	fmt.Printf("Use 'peerctl update %s' to roll out a new image to every replica\n", ref)
+}
+
 // signRequest signs a deployment request.
 func signRequest(req *protocol.DeployRequest, id *identity.Identity) error {
 	// Create signing payload
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/capacity"
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+func TestPickPeerReadsDaemonDataDir(t *testing.T) {
+	// The daemon writes the adverts it collects to its own data directory
+	dataDir := t.TempDir()
+	cache := capacity.NewCache(filepath.Join(dataDir, capacity.CacheFileName))
+	for _, a := range []*protocol.CapacityAdvert{
+		{PeerID: "12D3KooWSmall", FreeCPU: 500, FreeMemory: 1 << 30, FreeSlots: 1},
+		{PeerID: "12D3KooWLarge", FreeCPU: 4000, FreeMemory: 1 << 30, FreeSlots: 2},
+	} {
+		a.Timestamp = time.Now().UnixNano()
+		cache.Put(a)
+	}
+
+	got, err := pickPeer(dataDir, 1000, 256*1024*1024, "", false)
+	if err != nil {
+		t.Fatalf("pickPeer() error = %v", err)
+	}
+	if got != "12D3KooWLarge" {
+		t.Errorf("pickPeer() = %s, want 12D3KooWLarge", got)
+	}
+
+	placements, err := placeReplicas(dataDir, "auto", 2, 1000, 256*1024*1024, "", false)
+	if err != nil {
+		t.Fatalf("placeReplicas() error = %v", err)
+	}
+	if len(placements) != 1 || placements[0].PeerID != "12D3KooWLarge" || placements[0].Replicas != 2 {
+		t.Errorf("placeReplicas() = %+v", placements)
+	}
+
+	// Another data directory has no adverts
+	if _, err := pickPeer(t.TempDir(), 1000, 256*1024*1024, "", false); err == nil {
+		t.Error("pickPeer() found a provider without adverts")
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/xdas-research/peer-compute/internal/client"
+	"github.com/xdas-research/peer-compute/internal/identity"
+	"github.com/xdas-research/peer-compute/internal/p2p"
+)
+
+// deploymentsPath returns the path of the local deployment index, where
+// peerctl remembers which peer runs each of your deployments, so commands
+// only need the deployment ID or name.
+func deploymentsPath() string {
+	return filepath.Join(identity.DefaultConfigDir(), client.DeploymentsFileName)
+}
+
+// loadDeploymentRecords reads the local deployment index.
+func loadDeploymentRecords() ([]client.DeploymentRecord, error) {
+	return client.LoadDeploymentRecords(deploymentsPath())
+}
+
+// saveDeploymentRecord adds a deployment or cron schedule to the local
+// index.
+func saveDeploymentRecord(rec client.DeploymentRecord) error {
+	records, err := loadDeploymentRecords()
+	if err != nil {
+		return err
+	}
+	return client.SaveDeploymentRecords(deploymentsPath(), append(records, rec))
+}
+
+// raiseRecordedCPU records a new CPU limit for the deployments an update
+// applies to, so receipts billing the higher limit are countersigned.
+func raiseRecordedCPU(ref string, cpuMillicores int64) error {
+	records, err := loadDeploymentRecords()
+	if err != nil {
+		return err
+	}
+	changed := false
+	for i := range records {
+		rec := &records[i]
+		if rec.ID != ref && (rec.Name == "" || rec.Name != ref) && (rec.ReplicaSet == "" || rec.ReplicaSet != ref) {
+			continue
+		}
+		if cpuMillicores > rec.CPUMillicores {
+			rec.CPUMillicores = cpuMillicores
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return client.SaveDeploymentRecords(deploymentsPath(), records)
+}
+
+// findDeploymentPeer returns the peer running a deployment, given its ID or
+// name. An explicit --peer value wins; otherwise the local deployment index
+// is consulted, and a name refers to the most recent deployment with it.
+// For replicas spread over several peers, it returns the first one.
+func findDeploymentPeer(tm *p2p.TrustManager, deploymentID, peerName string) (*p2p.TrustedPeer, error) {
+	peers, err := findDeploymentPeers(tm, deploymentID, peerName)
+	if err != nil {
+		return nil, err
+	}
+	return peers[0], nil
+}
+
+// findDeploymentPeers returns every peer running a deployment, given its ID,
+// name or replica set. An ID refers to a single replica; a name or replica
+// set refers to every peer running a replica of the most recent deployment
+// with it.
+func findDeploymentPeers(tm *p2p.TrustManager, ref, peerName string) ([]*p2p.TrustedPeer, error) {
+	if peerName != "" {
+		p, err := findPeerByName(tm, peerName)
+		if err != nil {
+			return nil, err
+		}
+		return []*p2p.TrustedPeer{p}, nil
+	}
+
+	records, err := loadDeploymentRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *client.DeploymentRecord
+	for i := range records {
+		if records[i].ID == ref {
+			latest = &records[i]
+			break
+		}
+	}
+	if latest == nil {
+		for i := len(records) - 1; i >= 0; i-- {
+			rec := &records[i]
+			if (rec.Name != "" && rec.Name == ref) || (rec.ReplicaSet != "" && rec.ReplicaSet == ref) {
+				latest = rec
+				break
+			}
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("deployment %s was not created from this machine (use --peer)", ref)
+	}
+	if latest.ID == ref || latest.ReplicaSet == "" {
+		p, err := findPeerByName(tm, latest.PeerID)
+		if err != nil {
+			return nil, err
+		}
+		return []*p2p.TrustedPeer{p}, nil
+	}
+
+	var peers []*p2p.TrustedPeer
+	seen := make(map[string]bool)
+	for _, rec := range records {
+		if rec.ReplicaSet != latest.ReplicaSet || seen[rec.PeerID] {
+			continue
+		}
+		seen[rec.PeerID] = true
+		p, err := findPeerByName(tm, rec.PeerID)
+		if err != nil {
+			return nil, err
+		}
+		peers = append(peers, p)
+	}
+	return peers, nil
+}
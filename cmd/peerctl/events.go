@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/xdas-research/peer-compute/internal/client"
+	"github.com/xdas-research/peer-compute/internal/identity"
+	"github.com/xdas-research/peer-compute/internal/p2p"
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+func newEventsCmd() *cobra.Command {
+	var (
+		peerName string
+		follow   bool
+		timeout  time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "events [deployment-id]",
+		Short: "Show deployment lifecycle events",
+		Long: `Show lifecycle events of your deployments on a peer: created, pulling,
+started, healthy, unhealthy, exited, oom_killed, stopped, preempted and
+failed.
+
+Recent events are printed first. With --follow, new events are printed as
+they happen until interrupted.
+
+Examples:
+  peerctl events --peer alice
+  peerctl events dep-123456789 --follow
+  peerctl events --peer bob --follow`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var deploymentID string
+			if len(args) == 1 {
+				deploymentID = args[0]
+			} else if peerName == "" {
+				return fmt.Errorf("--peer is required without a deployment ID")
+			}
+
+			id, _, err := identity.LoadOrGenerate(identity.DefaultKeyPath())
+			if err != nil {
+				return fmt.Errorf("failed to load identity: %w", err)
+			}
+
+			tm := p2p.NewTrustManager(identity.DefaultTrustedPeersPath())
+			if err := tm.Load(); err != nil {
+				return fmt.Errorf("failed to load trust list: %w", err)
+			}
+
+			var targetPeer *p2p.TrustedPeer
+			if deploymentID != "" {
+				targetPeer, err = findDeploymentPeer(tm, deploymentID, peerName)
+			} else {
+				targetPeer, err = findPeerByName(tm, peerName)
+			}
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			connectCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			host, err := connectToPeer(connectCtx, id, tm, targetPeer)
+			if err != nil {
+				return err
+			}
+			defer host.Close()
+
+			if !follow {
+				ctx = connectCtx
+			}
+
+			count := 0
+			err = client.NewClient(host).Events(ctx, targetPeer.ID, &protocol.EventsRequest{
+				DeploymentID: deploymentID,
+				Follow:       follow,
+			}, func(e protocol.DeploymentEvent) error {
+				printEvent(e)
+				count++
+				return nil
+			})
+			if follow && ctx.Err() != nil {
+				// Interrupted by the user
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if follow {
+				return fmt.Errorf("provider ended the event stream")
+			}
+			if count == 0 {
+				fmt.Println("No recent events.")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&peerName, "peer", "", "Peer to read events from (default: from local deployment index)")
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "Keep printing new events")
+	cmd.Flags().DurationVar(&timeout, "timeout", 30*time.Second, "Connection timeout")
+
+	return cmd
+}
+
+// printEvent prints a single event on one line.
+func printEvent(e protocol.DeploymentEvent) {
+	line := fmt.Sprintf("%s  %-14s  %-10s  %s", e.Time.Local().Format("2006-01-02 15:04:05"), e.DeploymentID, e.Type, e.Status)
+	if e.ExitCode != nil {
+		line += fmt.Sprintf(" (exit code %d)", *e.ExitCode)
+	}
+	if e.Reason != "" {
+		line += fmt.Sprintf(" [%s]", e.Reason)
+	}
+	if e.Message != "" {
+		line += "  " + e.Message
+	}
+	fmt.Println(line)
+}
@@ -42,9 +42,20 @@ Getting Started:
 		newInitCmd(),
 		newPeersCmd(),
 		newDeployCmd(),
+		newRunCmd(),
+		newCronCmd(),
+		newArtifactsCmd(),
 		newLogsCmd(),
 		newStopCmd(),
+		newRenewCmd(),
+		newUpdateCmd(),
+		newRollbackCmd(),
+		newEventsCmd(),
+		newUsageCmd(),
+		newCreditsCmd(),
 		newStatusCmd(),
+		newNotificationsCmd(),
+		newNetworkCmd(),
 	)
 
 	if err := rootCmd.Execute(); err != nil {
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/xdas-research/peer-compute/internal/identity"
+	"github.com/xdas-research/peer-compute/internal/p2p"
+)
+
+func newNetworkCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "network",
+		Short: "Manage the private network key",
+		Long: `Manage the optional private network pre-shared key (swarm.key).
+
+When a swarm key is present, peerctl and peercomputed only talk to hosts that
+hold the same key. Hosts without it cannot complete the transport handshake,
+so they never see your peer ID or reach the trust list check.`,
+	}
+
+	cmd.AddCommand(newNetworkInitKeyCmd())
+
+	return cmd
+}
+
+func newNetworkInitKeyCmd() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "init-key",
+		Short: "Generate a new private network key",
+		Long: `Generate a new random private network key in ~/.peercompute/swarm.key.
+
+Share the file with your team out-of-band and copy it into the config
+directory of every peer (and the data directory of every peercomputed).
+Peers with different keys, or without a key, cannot connect to each other.
+
+Example:
+  peerctl network init-key`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			keyPath := identity.DefaultSwarmKeyPath()
+
+			if _, err := os.Stat(keyPath); err == nil && !force {
+				return fmt.Errorf("swarm key already exists at %s (use --force to overwrite)", keyPath)
+			}
+
+			if err := p2p.GenerateSwarmKey(keyPath); err != nil {
+				return err
+			}
+
+			fmt.Println("✓ Generated private network key")
+			fmt.Printf("  Key stored at: %s\n", keyPath)
+			fmt.Println("\nCopy this file to every peer in your team. Peers without it can no longer connect.")
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&force, "force", false, "Overwrite existing key")
+
+	return cmd
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/xdas-research/peer-compute/internal/handler"
+	"github.com/xdas-research/peer-compute/internal/identity"
+)
+
+func newNotificationsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "notifications",
+		Short: "Show notifications from providers",
+		Long: `Show notifications providers sent about your deployments, such as
+preemption by a higher priority class.
+
+Notifications are received by your local peercomputed, so they are only
+collected while it is running.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := handler.LoadNotifications(filepath.Join(identity.DefaultConfigDir(), handler.NotificationsFileName))
+			if err != nil {
+				return err
+			}
+
+			if len(entries) == 0 {
+				fmt.Println("No notifications.")
+				return nil
+			}
+
+			for i := len(entries) - 1; i >= 0; i-- {
+				n := entries[i]
+				fmt.Printf("%s  %s  %s", n.ReceivedAt.Local().Format("2006-01-02 15:04:05"), n.DeploymentID, n.Status)
+				if n.Reason != "" {
+					fmt.Printf(" (%s)", n.Reason)
+				}
+				fmt.Println()
+				if n.Message != "" {
+					fmt.Printf("  %s\n", n.Message)
+				}
+				if n.StopAt != nil {
+					fmt.Printf("  Stops at: %s\n", n.StopAt.Local().Format("2006-01-02 15:04:05"))
+				}
+				fmt.Printf("  Provider: %s\n", n.ProviderID)
+			}
+
+			return nil
+		},
+	}
+
+	return cmd
+}
@@ -3,11 +3,14 @@ package main
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/spf13/cobra"
+	"github.com/xdas-research/peer-compute/internal/capacity"
 	"github.com/xdas-research/peer-compute/internal/identity"
 	"github.com/xdas-research/peer-compute/internal/p2p"
+	"github.com/xdas-research/peer-compute/internal/protocol"
 )
 
 func newPeersCmd() *cobra.Command {
@@ -21,6 +24,7 @@ func newPeersCmd() *cobra.Command {
 		newPeersAddCmd(),
 		newPeersRemoveCmd(),
 		newPeersListCmd(),
+		newPeersSetCmd(),
 	)
 
 	return cmd
@@ -120,6 +124,8 @@ will not be able to deploy to you.`,
 }
 
 func newPeersListCmd() *cobra.Command {
+	var dataDir string
+
 	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List trusted peers",
@@ -132,6 +138,12 @@ func newPeersListCmd() *cobra.Command {
 				return fmt.Errorf("failed to load trust list: %w", err)
 			}
 
+			// Capacity adverts are collected by the local daemon, if running
+			adverts, err := loadAdverts(dataDir)
+			if err != nil {
+				adverts = capacity.NewCache("")
+			}
+
 			peers := tm.List()
 			if len(peers) == 0 {
 				fmt.Println("No trusted peers. Use 'peerctl peers add <peer-id>' to add one.")
@@ -148,6 +160,29 @@ func newPeersListCmd() *cobra.Command {
 					fmt.Printf("  Addrs: %s\n", strings.Join(p.Addresses, ", "))
 				}
 				fmt.Printf("  Added: %s\n", p.AddedAt.Format("2006-01-02 15:04:05"))
+				if p.MaxLease() > 0 {
+					fmt.Printf("  Max lease: %s\n", p.MaxLease())
+				}
+				if len(p.PriorityClasses) > 0 {
+					fmt.Printf("  Classes: %s\n", strings.Join(p.PriorityClasses, ", "))
+				}
+				if p.ArtifactQuotaBytes > 0 {
+					fmt.Printf("  Artifact quota: %d MB\n", p.ArtifactQuotaBytes/(1024*1024))
+				}
+				if a, ok := adverts.Get(p.ID); ok {
+					fmt.Printf("  Free:  %.2f CPU, %d MB, %d/%d slots",
+						float64(a.FreeCPU)/1000, a.FreeMemory/(1024*1024), a.FreeSlots, a.MaxSlots)
+					if a.Region != "" {
+						fmt.Printf(", region %s", a.Region)
+					}
+					if a.GatewayConnected {
+						fmt.Print(", gateway")
+					}
+					if a.Draining {
+						fmt.Print(", draining")
+					}
+					fmt.Println()
+				}
 				fmt.Println()
 			}
 
@@ -155,5 +190,118 @@ func newPeersListCmd() *cobra.Command {
 		},
 	}
 
+	cmd.Flags().StringVar(&dataDir, "data-dir", identity.DefaultConfigDir(), "Data directory of the local daemon, for capacity adverts")
+
+	return cmd
+}
+
+func newPeersSetCmd() *cobra.Command {
+	var (
+		maxLease      time.Duration
+		classes       []string
+		artifactQuota string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "set <peer>",
+		Short: "Set provider limits for a trusted peer",
+		Long: `Set limits that your provider daemon applies to a trusted peer's deployments.
+
+--max-lease caps the lease of the peer's deployments; deployments requested
+without a lease get the maximum. Use 0 to fall back to the daemon's
+--max-lease default.
+
+--classes lists the priority classes the peer may use. By default peers may
+use best-effort and normal; granting critical lets the peer preempt other
+deployments. Pass an empty value to restore the default.
+
+--artifact-quota caps the job input and output artifacts the peer may store
+(e.g., 512M, 2G). Use 0 to fall back to the daemon's --artifact-quota default.
+
+Restart peercomputed to apply changes.
+
+Examples:
+  peerctl peers set alice --max-lease 8h
+  peerctl peers set bob --classes best-effort,normal,critical
+  peerctl peers set alice --artifact-quota 5G`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tm := p2p.NewTrustManager(identity.DefaultTrustedPeersPath())
+			if err := tm.Load(); err != nil {
+				return fmt.Errorf("failed to load trust list: %w", err)
+			}
+
+			target, err := findPeerByName(tm, args[0])
+			if err != nil {
+				return err
+			}
+
+			setLease := cmd.Flags().Changed("max-lease")
+			setClasses := cmd.Flags().Changed("classes")
+			setQuota := cmd.Flags().Changed("artifact-quota")
+			if !setLease && !setClasses && !setQuota {
+				return fmt.Errorf("nothing to set (use --max-lease, --classes or --artifact-quota)")
+			}
+			if maxLease < 0 {
+				return fmt.Errorf("--max-lease must not be negative")
+			}
+			for _, c := range classes {
+				if err := protocol.PriorityClass(c).Validate(); err != nil {
+					return err
+				}
+			}
+			var quotaBytes int64
+			if setQuota && artifactQuota != "0" {
+				if quotaBytes, err = parseMemory(artifactQuota); err != nil {
+					return fmt.Errorf("invalid artifact quota: %w", err)
+				}
+			}
+
+			err = tm.Update(target.ID, func(p *p2p.TrustedPeer) {
+				if setLease {
+					p.MaxLeaseSeconds = int64(maxLease.Seconds())
+				}
+				if setClasses {
+					p.PriorityClasses = classes
+				}
+				if setQuota {
+					p.ArtifactQuotaBytes = quotaBytes
+				}
+			})
+			if err != nil {
+				return fmt.Errorf("failed to update peer: %w", err)
+			}
+
+			fmt.Printf("✓ Updated peer: %s\n", target.ID)
+			if setLease {
+				if maxLease > 0 {
+					fmt.Printf("  Max lease: %s\n", maxLease)
+				} else {
+					fmt.Println("  Max lease: provider default")
+				}
+			}
+			if setClasses {
+				if len(classes) > 0 {
+					fmt.Printf("  Priority classes: %s\n", strings.Join(classes, ", "))
+				} else {
+					fmt.Println("  Priority classes: provider default")
+				}
+			}
+			if setQuota {
+				if quotaBytes > 0 {
+					fmt.Printf("  Artifact quota: %d MB\n", quotaBytes/(1024*1024))
+				} else {
+					fmt.Println("  Artifact quota: provider default")
+				}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().DurationVar(&maxLease, "max-lease", 0, "Maximum lease for the peer's deployments (0 = provider default)")
+	cmd.Flags().StringSliceVar(&classes, "classes", nil, "Priority classes the peer may use (e.g., best-effort,normal,critical)")
+	cmd.Flags().StringVar(&artifactQuota, "artifact-quota", "", "Job artifact storage for the peer (e.g., 2G; 0 = provider default)")
+
 	return cmd
 }
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/xdas-research/peer-compute/internal/client"
+	"github.com/xdas-research/peer-compute/internal/identity"
+	"github.com/xdas-research/peer-compute/internal/p2p"
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+func newRenewCmd() *cobra.Command {
+	var (
+		peerName string
+		lease    time.Duration
+		timeout  time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "renew <deployment-id>",
+		Short: "Extend a deployment's lease",
+		Long: `Extend the lease of a deployment so the provider keeps it running.
+
+The new lease is counted from now. Providers may cap it at the maximum lease
+they allow for your peer.
+
+Examples:
+  peerctl renew dep-123456789 --for 4h
+  peerctl renew dep-123456789 --for 30m --peer alice`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			deploymentID := args[0]
+
+			if lease <= 0 {
+				return fmt.Errorf("--for must be positive")
+			}
+
+			// Load identity
+			id, _, err := identity.LoadOrGenerate(identity.DefaultKeyPath())
+			if err != nil {
+				return fmt.Errorf("failed to load identity: %w", err)
+			}
+
+			tm := p2p.NewTrustManager(identity.DefaultTrustedPeersPath())
+			if err := tm.Load(); err != nil {
+				return fmt.Errorf("failed to load trust list: %w", err)
+			}
+
+			targetPeers, err := findDeploymentPeers(tm, deploymentID, peerName)
+			if err != nil {
+				return err
+			}
+
+			req := &protocol.RenewRequest{
+				DeploymentID: deploymentID,
+				LeaseSeconds: int64(lease.Seconds()),
+			}
+			if err := protocol.SignRenewRequest(req, id); err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			// Replicas spread over several peers are renewed on each
+			for _, targetPeer := range targetPeers {
+				resp, err := renewOnPeer(ctx, id, tm, targetPeer, req)
+				if err != nil {
+					return err
+				}
+
+				fmt.Printf("✓ %s\n", resp.Message)
+				if len(targetPeers) > 1 {
+					fmt.Printf("  Peer: %s\n", targetPeer.ID)
+				}
+				if resp.LeaseExpiresAt != nil {
+					fmt.Printf("  Lease expires: %s\n", resp.LeaseExpiresAt.Local().Format("2006-01-02 15:04:05"))
+				}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().DurationVar(&lease, "for", 0, "New lease duration, counted from now (e.g., 4h)")
+	cmd.Flags().StringVar(&peerName, "peer", "", "Peer running the deployment (default: from local deployment index)")
+	cmd.Flags().DurationVar(&timeout, "timeout", 30*time.Second, "Request timeout")
+
+	cmd.MarkFlagRequired("for")
+
+	return cmd
+}
+
+// renewOnPeer sends a signed renewal to one peer.
+func renewOnPeer(ctx context.Context, id *identity.Identity, tm *p2p.TrustManager, targetPeer *p2p.TrustedPeer, req *protocol.RenewRequest) (*protocol.RenewResponse, error) {
+	host, err := connectToPeer(ctx, id, tm, targetPeer)
+	if err != nil {
+		return nil, err
+	}
+	defer host.Close()
+
+	resp, err := client.NewClient(host).Renew(ctx, targetPeer.ID, req)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("renewal failed: %s", firstNonEmpty(resp.Message, resp.Error))
+	}
+	return resp, nil
+}
+
+// firstNonEmpty returns the first non-empty string.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
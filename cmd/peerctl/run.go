@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"github.com/xdas-research/peer-compute/internal/client"
+	"github.com/xdas-research/peer-compute/internal/identity"
+	"github.com/xdas-research/peer-compute/internal/p2p"
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+// jobPollInterval is how often run --wait checks on the job.
+var jobPollInterval = 2 * time.Second
+
+func newRunCmd() *cobra.Command {
+	var (
+		peerName string
+		name     string
+		jf       jobFlags
+		wait     bool
+		timeout  time.Duration
+		dataDir  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "run [flags] <image> [args...]",
+		Short: "Run a job to completion on a peer",
+		Long: `Run a one-off job on a trusted peer.
+
+The container runs once and exits. Its final status is "succeeded" if it
+exited with code 0 and "failed" otherwise. Failed jobs are retried up to
+--retries times, and jobs still running after --deadline are stopped and
+failed. The provider keeps the job record and logs after it exits.
+
+--input copies a directory (or tarball) into the container before it starts,
+without mounting anything from the provider's host. Paths given with --output
+are copied out after the job exits; fetch them with 'peerctl artifacts get'.
+
+Flags go before the image; everything after it replaces the image's command.
+With --wait, peerctl blocks until the job finishes and exits with the
+container's exit code.
+
+Examples:
+  peerctl run --peer alice --wait python:3.12-slim python -c "print('hi')"
+  peerctl run --peer bob --cpu 2 --memory 1G --deadline 1h --retries 2 my-etl:latest
+  peerctl run --peer auto --entrypoint make --wait my-tests:latest test
+  peerctl run --peer alice --input ./data --input-path /work --output /work/out --wait my-etl:latest`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			imageName := args[0]
+
+			if peerName == "" {
+				return fmt.Errorf("--peer is required")
+			}
+			if name != "" {
+				if err := protocol.ValidateDeploymentName(name); err != nil {
+					return fmt.Errorf("invalid --name: %w", err)
+				}
+			}
+			job, err := jf.job(args[1:])
+			if err != nil {
+				return err
+			}
+			res, err := jf.resources()
+			if err != nil {
+				return err
+			}
+
+			// Load identity
+			id, _, err := identity.LoadOrGenerate(identity.DefaultKeyPath())
+			if err != nil {
+				return fmt.Errorf("failed to load identity: %w", err)
+			}
+
+			tm := p2p.NewTrustManager(identity.DefaultTrustedPeersPath())
+			if err := tm.Load(); err != nil {
+				return fmt.Errorf("failed to load trust list: %w", err)
+			}
+
+			if peerName == "auto" {
+				peerName, err = pickPeer(dataDir, res.cpuMillicores, res.memoryBytes, "", false)
+				if err != nil {
+					return err
+				}
+			}
+
+			targetPeer, err := findPeerByName(tm, peerName)
+			if err != nil {
+				return err
+			}
+
+			req := &protocol.DeployRequest{
+				RequestID:           uuid.New().String(),
+				Image:               imageName,
+				Name:                name,
+				CPUMillicores:       res.cpuMillicores,
+				MemoryBytes:         res.memoryBytes,
+				Environment:         res.env,
+				PriorityClass:       res.class,
+				Job:                 job,
+				Priority:            jf.priority,
+				QueueTimeoutSeconds: int64(jf.queueFor.Seconds()),
+				RequesterID:         id.PeerID.String(),
+				Timestamp:           time.Now().UnixNano(),
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			host, err := connectToPeer(ctx, id, tm, targetPeer)
+			if err != nil {
+				return err
+			}
+			defer host.Close()
+
+			c := client.NewClient(host)
+
+			// Stage the input first; the signed request refers to its digest
+			if jf.input != "" {
+				fmt.Printf("Uploading input %s...\n", jf.input)
+				if job.Input, err = uploadInput(ctx, c, targetPeer, jf.input); err != nil {
+					return err
+				}
+			}
+			if err := signRequest(req, id); err != nil {
+				return fmt.Errorf("failed to sign request: %w", err)
+			}
+
+			resp, err := c.Deploy(ctx, targetPeer.ID, req)
+			if err != nil {
+				return err
+			}
+			if !resp.Success {
+				return fmt.Errorf("job failed to start: %s", resp.Message)
+			}
+
+			if err := saveDeploymentRecord(client.DeploymentRecord{
+				ID:            resp.DeploymentID,
+				PeerID:        targetPeer.ID.String(),
+				Name:          name,
+				Image:         imageName,
+				CPUMillicores: req.CPUMillicores,
+				CreatedAt:     time.Now(),
+			}); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+
+			if resp.Status == protocol.StatusQueued {
+				fmt.Printf("✓ Job %s queued on %s\n", resp.DeploymentID, targetPeer.ID)
+			} else {
+				fmt.Printf("✓ Job %s started on %s\n", resp.DeploymentID, targetPeer.ID)
+			}
+			if resp.QueuePosition > 0 {
+				fmt.Printf("  Queue position: %d (starts when the provider has capacity)\n", resp.QueuePosition)
+			}
+
+			if !wait {
+				fmt.Println("\nUse 'peerctl status <deployment-id>' to check on the job")
+				fmt.Println("Use 'peerctl logs <deployment-id>' to view its output")
+				return nil
+			}
+
+			fmt.Println("Waiting for the job to finish...")
+			info, err := waitForJob(context.Background(), c, targetPeer, resp.DeploymentID)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("\nJob %s %s", info.DeploymentID, info.Status)
+			if info.ExitCode != nil {
+				fmt.Printf(" (exit code %d)", *info.ExitCode)
+			}
+			fmt.Println()
+			if info.Error != "" {
+				fmt.Printf("  %s\n", info.Error)
+			}
+			if info.RestartCount > 0 {
+				fmt.Printf("  Retries: %d\n", info.RestartCount)
+			}
+			for _, a := range info.Outputs {
+				if a.Error != "" {
+					fmt.Printf("  Output %s: %s\n", a.Path, a.Error)
+				} else {
+					fmt.Printf("  Output %s: %d bytes\n", a.Path, a.Size)
+				}
+			}
+			fmt.Println("\nUse 'peerctl logs <deployment-id>' to view its output")
+			if len(info.Outputs) > 0 {
+				fmt.Println("Use 'peerctl artifacts get <deployment-id>' to download its outputs")
+			}
+
+			code := jobExitCode(info)
+			if code == 0 {
+				return nil
+			}
+			// Deferred calls don't run on os.Exit
+			host.Close()
+			os.Exit(code)
+			return nil
+		},
+	}
+
+	// Flags after the image belong to the job's command
+	cmd.Flags().SetInterspersed(false)
+
+	cmd.Flags().StringVar(&peerName, "peer", "", "Target peer (ID, name, or \"auto\")")
+	cmd.Flags().StringVar(&name, "name", "", "Job name, usable instead of the deployment ID")
+	jf.register(cmd)
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for the job to finish and exit with its exit code")
+	cmd.Flags().DurationVar(&timeout, "timeout", 5*time.Minute, "Timeout for starting the job")
+	cmd.Flags().StringVar(&dataDir, "data-dir", identity.DefaultConfigDir(), "Data directory of the local daemon, for --peer auto")
+
+	cmd.MarkFlagRequired("peer")
+
+	return cmd
+}
+
+// jobFlags holds the flags describing a job, shared by run and cron add.
+type jobFlags struct {
+	cpu        string
+	memory     string
+	envVars    []string
+	entrypoint string
+	input      string
+	inputPath  string
+	outputs    []string
+	deadline   time.Duration
+	retries    int
+	priority   int
+	class      string
+	queueFor   time.Duration
+}
+
+// jobResources are the parsed resource flags of a job.
+type jobResources struct {
+	cpuMillicores int64
+	memoryBytes   int64
+	env           map[string]string
+	class         protocol.PriorityClass
+}
+
+// register adds the job flags to a command.
+func (f *jobFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.cpu, "cpu", "0.5", "CPU limit (e.g., 0.5, 1, 2)")
+	cmd.Flags().StringVar(&f.memory, "memory", "256M", "Memory limit (e.g., 128M, 1G)")
+	cmd.Flags().StringSliceVar(&f.envVars, "env", nil, "Environment variables (KEY=VALUE)")
+	cmd.Flags().StringVar(&f.entrypoint, "entrypoint", "", "Override the image entrypoint")
+	cmd.Flags().StringVar(&f.input, "input", "", "Directory or tarball copied into the container before it starts")
+	cmd.Flags().StringVar(&f.inputPath, "input-path", "", "Container directory the input is extracted into (default /)")
+	cmd.Flags().StringArrayVar(&f.outputs, "output", nil, "Container path to copy out after the job exits (repeatable)")
+	cmd.Flags().DurationVar(&f.deadline, "deadline", 0, "Stop and fail the job if it runs longer than this, including retries (e.g., 30m)")
+	cmd.Flags().IntVar(&f.retries, "retries", 0, "Times to retry the job if it fails")
+	cmd.Flags().DurationVar(&f.queueFor, "queue-timeout", 0, "Wait up to this long in the provider's queue if it is full (0 = fail immediately)")
+	cmd.Flags().StringVar(&f.class, "class", string(protocol.ClassNormal), "Priority class: best-effort, normal or critical")
+	cmd.Flags().IntVar(&f.priority, "priority", 0, "Queue priority; higher values are admitted first")
+}
+
+// job builds the job spec from the flags and the command arguments. The
+// input digest is filled in once the input is uploaded.
+func (f *jobFlags) job(args []string) (*protocol.JobSpec, error) {
+	if f.deadline < 0 {
+		return nil, fmt.Errorf("--deadline must not be negative")
+	}
+	if f.inputPath != "" && f.input == "" {
+		return nil, fmt.Errorf("--input-path requires --input")
+	}
+
+	job := &protocol.JobSpec{
+		Command:         strings.Fields(f.entrypoint),
+		Args:            args,
+		DeadlineSeconds: int64(f.deadline.Seconds()),
+		Retries:         f.retries,
+		InputPath:       f.inputPath,
+		Outputs:         f.outputs,
+	}
+	if err := job.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid job: %w", err)
+	}
+	return job, nil
+}
+
+// resources parses the resource and priority flags.
+func (f *jobFlags) resources() (jobResources, error) {
+	var res jobResources
+
+	res.class = protocol.PriorityClass(f.class)
+	if err := res.class.Validate(); err != nil {
+		return res, err
+	}
+
+	var err error
+	if res.cpuMillicores, err = parseCPU(f.cpu); err != nil {
+		return res, fmt.Errorf("invalid CPU value: %w", err)
+	}
+	if res.memoryBytes, err = parseMemory(f.memory); err != nil {
+		return res, fmt.Errorf("invalid memory value: %w", err)
+	}
+	if res.env, err = parseEnvVars(f.envVars); err != nil {
+		return res, fmt.Errorf("invalid environment variable: %w", err)
+	}
+	return res, nil
+}
+
+// jobExitCode returns the exit code run --wait exits with for a finished
+// job: the container's exit code, or 1 for jobs that failed without one
+// (they never ran, or exited 0 but were failed, e.g. by the deadline).
+func jobExitCode(info *protocol.DeploymentStatusInfo) int {
+	if protocol.DeploymentStatus(info.Status) == protocol.StatusSucceeded {
+		return 0
+	}
+	if info.ExitCode != nil && *info.ExitCode != 0 {
+		return *info.ExitCode
+	}
+	return 1
+}
+
+// waitForJob polls a job's status until it has finished.
+func waitForJob(ctx context.Context, c *client.Client, target *p2p.TrustedPeer, deploymentID string) (*protocol.DeploymentStatusInfo, error) {
+	for {
+		resp, err := c.Status(ctx, target.ID, deploymentID)
+		if err != nil {
+			return nil, err
+		}
+
+		delay := jobPollInterval
+		switch {
+		case resp.Error != "":
+			// Rate limited; back off as the provider asks
+			if resp.RetryAfterMs <= 0 {
+				return nil, fmt.Errorf("status request refused: %s", resp.Error)
+			}
+			delay = time.Duration(resp.RetryAfterMs) * time.Millisecond
+		case len(resp.Deployments) == 0:
+			return nil, fmt.Errorf("job %s not found on provider", deploymentID)
+		default:
+			info := resp.Deployments[0]
+			if protocol.DeploymentStatus(info.Status).IsFinished() {
+				return &info, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
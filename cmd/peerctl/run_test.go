@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+
+	"github.com/xdas-research/peer-compute/internal/client"
+	"github.com/xdas-research/peer-compute/internal/identity"
+	"github.com/xdas-research/peer-compute/internal/p2p"
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+// newLoopbackHost starts a host on a free loopback port.
+func newLoopbackHost(t *testing.T) (*p2p.Host, *p2p.TrustManager) {
+	t.Helper()
+	id, err := identity.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm := p2p.NewTrustManager(filepath.Join(t.TempDir(), "trusted_peers.json"))
+
+	// Listening on port 0 selects DefaultListenPort, so find a free one
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	cfg := p2p.DefaultConfig(id, tm)
+	cfg.ListenPort = port
+	h, err := p2p.NewHost(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("NewHost() error = %v", err)
+	}
+	t.Cleanup(func() { h.Close() })
+	return h, tm
+}
+
+// fakeProvider answers status requests with a script of responses, repeating
+// the last one.
+type fakeProvider struct {
+	mu        sync.Mutex
+	responses []protocol.StatusResponse
+	requests  int
+}
+
+func (p *fakeProvider) handleStatus(stream network.Stream) {
+	defer stream.Close()
+	var req protocol.StatusRequest
+	if err := json.NewDecoder(stream).Decode(&req); err != nil {
+		return
+	}
+
+	p.mu.Lock()
+	resp := p.responses[min(p.requests, len(p.responses)-1)]
+	p.requests++
+	p.mu.Unlock()
+
+	json.NewEncoder(stream).Encode(resp)
+}
+
+// startJobProvider connects a client to a provider serving the script.
+func startJobProvider(t *testing.T, responses ...protocol.StatusResponse) (*client.Client, *p2p.TrustedPeer, *fakeProvider) {
+	t.Helper()
+	provider, providerTrust := newLoopbackHost(t)
+	requester, requesterTrust := newLoopbackHost(t)
+	if err := providerTrust.Add(requester.ID(), "requester", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := requesterTrust.Add(provider.ID(), "provider", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	fake := &fakeProvider{responses: responses}
+	provider.SetStreamHandler(protocol.StatusProtocol, fake.handleStatus)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := requester.Connect(ctx, provider.AddrInfo()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	return client.NewClient(requester), &p2p.TrustedPeer{ID: provider.ID()}, fake
+}
+
+// jobStatus returns a status response for one job.
+func jobStatus(status protocol.DeploymentStatus, exitCode *int) protocol.StatusResponse {
+	return protocol.StatusResponse{Deployments: []protocol.DeploymentStatusInfo{{
+		DeploymentID: "dep-0000000000000001",
+		Status:       string(status),
+		ExitCode:     exitCode,
+	}}}
+}
+
+func TestWaitForJobPollsUntilFinished(t *testing.T) {
+	interval := jobPollInterval
+	jobPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { jobPollInterval = interval })
+
+	code := 3
+	c, target, fake := startJobProvider(t,
+		jobStatus(protocol.StatusQueued, nil),
+		protocol.StatusResponse{Error: "rate limited", RetryAfterMs: 10},
+		jobStatus(protocol.StatusRunning, nil),
+		jobStatus(protocol.StatusFailed, &code),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	info, err := waitForJob(ctx, c, target, "dep-0000000000000001")
+	if err != nil {
+		t.Fatalf("waitForJob() error = %v", err)
+	}
+	if info.Status != string(protocol.StatusFailed) || jobExitCode(info) != 3 {
+		t.Errorf("waitForJob() = %s exiting %d, want failed exiting 3", info.Status, jobExitCode(info))
+	}
+	if fake.requests != 4 {
+		t.Errorf("status polled %d times, want 4", fake.requests)
+	}
+}
+
+func TestWaitForJobErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		resp protocol.StatusResponse
+	}{
+		{"not found", protocol.StatusResponse{}},
+		{"refused", protocol.StatusResponse{Error: "not trusted"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, target, _ := startJobProvider(t, tt.resp)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if _, err := waitForJob(ctx, c, target, "dep-0000000000000001"); err == nil {
+				t.Error("waitForJob() succeeded")
+			}
+		})
+	}
+}
+
+func TestJobExitCode(t *testing.T) {
+	zero, two := 0, 2
+	tests := []struct {
+		name   string
+		status protocol.DeploymentStatus
+		exit   *int
+		want   int
+	}{
+		{"succeeded", protocol.StatusSucceeded, &zero, 0},
+		{"failed with a code", protocol.StatusFailed, &two, 2},
+		{"failed by the deadline after exiting 0", protocol.StatusFailed, &zero, 1},
+		{"never ran", protocol.StatusTerminated, nil, 1},
+	}
+
+	for _, tt := range tests {
+		info := &protocol.DeploymentStatusInfo{Status: string(tt.status), ExitCode: tt.exit}
+		if got := jobExitCode(info); got != tt.want {
+			t.Errorf("%s: jobExitCode() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
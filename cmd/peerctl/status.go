@@ -1,62 +1,351 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/spf13/cobra"
+
+	"github.com/xdas-research/peer-compute/internal/client"
+	"github.com/xdas-research/peer-compute/internal/identity"
+	"github.com/xdas-research/peer-compute/internal/p2p"
+	"github.com/xdas-research/peer-compute/internal/protocol"
 )
 
 func newStatusCmd() *cobra.Command {
+	var (
+		peerName string
+		timeout  time.Duration
+	)
+
 	cmd := &cobra.Command{
 		Use:   "status [deployment-id]",
 		Short: "Get deployment status",
 		Long: `Get the status of a deployment or list all active deployments.
 
-If a deployment ID is provided, shows detailed status for that deployment.
-Without an ID, lists all active deployments on connected peers.
+If a deployment ID or name is provided, shows detailed status for it,
+including the container's current CPU, memory, network and block I/O usage
+and process count. A name shows every replica with it.
+Without an ID, lists the deployments from the local deployment index that
+their peers still know about.
 
 Examples:
   peerctl status                    # List all deployments
-  peerctl status dep-123456789      # Show specific deployment`,
+  peerctl status dep-123456789      # Show specific deployment
+  peerctl status web --peer alice   # Show a deployment on a given peer`,
 		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if len(args) == 0 {
-				// List all deployments
-				fmt.Println("Active Deployments:\n")
-				fmt.Println("  ID                  IMAGE           PEER     STATUS    URL")
-				fmt.Println("  ────────────────────────────────────────────────────────────────────")
-				fmt.Println("  dep-1703688000000   nginx:alpine    alice    running   https://dep-170368.peercompute.xdastechnology.com")
-				fmt.Println("  dep-1703688100000   redis:7         bob      running   -")
-				fmt.Println()
-				fmt.Println("Total: 2 deployments")
-				return nil
-			}
-
-			deploymentID := args[0]
-
-			// Show specific deployment
-			fmt.Printf("Deployment: %s\n", deploymentID)
-			fmt.Println("────────────────────────────────")
-			fmt.Println("  Status:     running")
-			fmt.Println("  Image:      nginx:alpine")
-			fmt.Println("  Peer:       alice (12D3KooW...)")
-			fmt.Println("  Started:    2024-12-27 14:00:00")
-			fmt.Println("  Uptime:     2h 30m")
-			fmt.Println()
-			fmt.Println("  Resources:")
-			fmt.Println("    CPU:      0.5 cores (limit)")
-			fmt.Println("    Memory:   256 MB (limit)")
-			fmt.Println("    CPU Usage: 12%")
-			fmt.Println("    Mem Usage: 45 MB (18%)")
-			fmt.Println()
-			fmt.Println("  Networking:")
-			fmt.Println("    Exposed Port: 80")
-			fmt.Println("    Public URL:   https://dep-1703688000000.peercompute.xdastechnology.com")
-			fmt.Println("    Tunnel:       active")
+			id, _, err := identity.LoadOrGenerate(identity.DefaultKeyPath())
+			if err != nil {
+				return fmt.Errorf("failed to load identity: %w", err)
+			}
 
-			return nil
+			tm := p2p.NewTrustManager(identity.DefaultTrustedPeersPath())
+			if err := tm.Load(); err != nil {
+				return fmt.Errorf("failed to load trust list: %w", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			if len(args) == 0 {
+				return listDeployments(ctx, id, tm, peerName)
+			}
+			return showDeployment(ctx, id, tm, args[0], peerName)
 		},
 	}
 
+	cmd.Flags().StringVar(&peerName, "peer", "", "Peer running the deployment (default: from local deployment index)")
+	cmd.Flags().DurationVar(&timeout, "timeout", 30*time.Second, "Time to wait for peers to respond")
+
 	return cmd
 }
+
+// queryStatus asks a peer for the status of a deployment ("" = all of the
+// peer's deployments).
+func queryStatus(ctx context.Context, id *identity.Identity, tm *p2p.TrustManager, target *p2p.TrustedPeer, deploymentID string) ([]protocol.DeploymentStatusInfo, error) {
+	host, err := connectToPeer(ctx, id, tm, target)
+	if err != nil {
+		return nil, err
+	}
+	defer host.Close()
+
+	resp, err := client.NewClient(host).Status(ctx, target.ID, deploymentID)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("status request refused: %s", resp.Error)
+	}
+	return resp.Deployments, nil
+}
+
+// showDeployment prints the detailed status of a deployment on every peer
+// running a replica of it.
+func showDeployment(ctx context.Context, id *identity.Identity, tm *p2p.TrustManager, ref, peerName string) error {
+	targets, err := findDeploymentPeers(tm, ref, peerName)
+	if err != nil {
+		return err
+	}
+
+	found := 0
+	for _, target := range targets {
+		infos, err := queryStatus(ctx, id, tm, target, ref)
+		if err != nil {
+			if len(targets) == 1 {
+				return err
+			}
+			fmt.Printf("Peer %s: %v\n\n", peerLabel(tm, target.ID.String()), err)
+			continue
+		}
+		for _, info := range infos {
+			printDeploymentStatus(tm, target, info)
+			fmt.Println()
+			found++
+		}
+	}
+	if found == 0 {
+		return fmt.Errorf("deployment %s not found", ref)
+	}
+	return nil
+}
+
+// printDeploymentStatus prints one deployment's status in detail.
+func printDeploymentStatus(tm *p2p.TrustManager, target *p2p.TrustedPeer, info protocol.DeploymentStatusInfo) {
+	title := info.DeploymentID
+	if info.Name != "" {
+		title += " (" + info.Name + ")"
+	}
+	fmt.Printf("Deployment: %s\n", title)
+	fmt.Println("────────────────────────────────")
+	fmt.Printf("  Status:     %s\n", describeStatus(info))
+	fmt.Printf("  Image:      %s\n", info.Image)
+	if info.ImageDigest != "" {
+		fmt.Printf("  Digest:     %s\n", info.ImageDigest)
+	}
+	if info.Revision > 0 {
+		fmt.Printf("  Revision:   %d\n", info.Revision)
+	}
+	fmt.Printf("  Peer:       %s (%s)\n", peerLabel(tm, target.ID.String()), shortPeerID(target.ID))
+	if !info.StartedAt.IsZero() {
+		fmt.Printf("  Started:    %s\n", info.StartedAt.Local().Format("2006-01-02 15:04:05"))
+		if info.FinishedAt == nil && protocol.DeploymentStatus(info.Status) == protocol.StatusRunning {
+			fmt.Printf("  Uptime:     %s\n", formatUptime(time.Since(info.StartedAt)))
+		}
+	}
+	if info.FinishedAt != nil {
+		fmt.Printf("  Finished:   %s\n", info.FinishedAt.Local().Format("2006-01-02 15:04:05"))
+	}
+	if info.Error != "" {
+		fmt.Printf("  Error:      %s\n", info.Error)
+	}
+	if info.RestartCount > 0 {
+		fmt.Printf("  Restarts:   %d\n", info.RestartCount)
+	}
+	if info.Health != "" {
+		health := string(info.Health)
+		if info.HealthError != "" {
+			health += " (" + info.HealthError + ")"
+		}
+		fmt.Printf("  Health:     %s\n", health)
+	}
+	if info.LeaseExpiresAt != nil {
+		fmt.Printf("  Lease:      expires %s\n", info.LeaseExpiresAt.Local().Format("2006-01-02 15:04:05"))
+	}
+
+	fmt.Println()
+	fmt.Println("  Resources:")
+	cpuLimit := float64(info.CPULimit) / 1000
+	if u := info.ResourceUsage; u != nil {
+		fmt.Printf("    CPU:       %.2f of %.2f cores (%.1f%%)\n", u.CPUPercent/100, cpuLimit, percentOf(u.CPUPercent/100, cpuLimit))
+		memLimit := u.MemoryLimit
+		if memLimit <= 0 {
+			memLimit = info.MemoryLimit
+		}
+		fmt.Printf("    Memory:    %s of %s (%.1f%%)\n", formatBytes(uint64(u.MemoryBytes)), formatBytes(uint64(memLimit)),
+			percentOf(float64(u.MemoryBytes), float64(memLimit)))
+		fmt.Printf("    Network:   %s received, %s sent\n", formatBytes(u.NetworkRxBytes), formatBytes(u.NetworkTxBytes))
+		fmt.Printf("    Block I/O: %s read, %s written\n", formatBytes(u.BlockReadBytes), formatBytes(u.BlockWriteBytes))
+		if u.PIDsLimit > 0 {
+			fmt.Printf("    PIDs:      %d of %d\n", u.PIDs, u.PIDsLimit)
+		} else {
+			fmt.Printf("    PIDs:      %d\n", u.PIDs)
+		}
+	} else {
+		fmt.Printf("    CPU:       %.2f cores (limit)\n", cpuLimit)
+		fmt.Printf("    Memory:    %s (limit)\n", formatBytes(uint64(info.MemoryLimit)))
+		if protocol.DeploymentStatus(info.Status) == protocol.StatusRunning {
+			fmt.Println("    Usage:     unavailable")
+		}
+	}
+
+	if info.ExposePort > 0 {
+		fmt.Println()
+		fmt.Println("  Networking:")
+		fmt.Printf("    Exposed Port: %d\n", info.ExposePort)
+		fmt.Printf("    Public URL:   %s\n", firstNonEmpty(info.ExposedURL, "-"))
+	}
+}
+
+// listDeployments prints the deployments in the local deployment index,
+// querying each peer that runs one of them.
+func listDeployments(ctx context.Context, id *identity.Identity, tm *p2p.TrustManager, peerName string) error {
+	records, err := loadDeploymentRecords()
+	if err != nil {
+		return err
+	}
+
+	mine := make(map[string]bool, len(records))
+	var peerIDs []string
+	for _, rec := range records {
+		mine[rec.ID] = true
+		if !slices.Contains(peerIDs, rec.PeerID) {
+			peerIDs = append(peerIDs, rec.PeerID)
+		}
+	}
+
+	var targets []*p2p.TrustedPeer
+	if peerName != "" {
+		target, err := findPeerByName(tm, peerName)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, target)
+	} else {
+		for _, peerIDStr := range peerIDs {
+			pid, err := peer.Decode(peerIDStr)
+			if err != nil {
+				continue
+			}
+			if target, ok := tm.Get(pid); ok {
+				targets = append(targets, target)
+			}
+		}
+	}
+
+	type row struct {
+		info protocol.DeploymentStatusInfo
+		peer string
+	}
+	var rows []row
+	var unreachable []string
+	for _, target := range targets {
+		infos, err := queryStatus(ctx, id, tm, target, "")
+		if err != nil {
+			unreachable = append(unreachable, fmt.Sprintf("%s: %v", peerLabel(tm, target.ID.String()), err))
+			continue
+		}
+		for _, info := range infos {
+			// Providers answer with every deployment they run; only list
+			// the ones this CLI created
+			if mine[info.DeploymentID] {
+				rows = append(rows, row{info: info, peer: peerLabel(tm, target.ID.String())})
+			}
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].info.StartedAt.Before(rows[j].info.StartedAt)
+	})
+
+	fmt.Println("Deployments:")
+	fmt.Println()
+	if len(rows) == 0 {
+		fmt.Println("  (none)")
+	} else {
+		fmt.Printf("  %-22s  %-12s  %-20s  %-10s  %-10s  %6s  %9s  %s\n",
+			"ID", "NAME", "IMAGE", "PEER", "STATUS", "CPU", "MEMORY", "URL")
+		for _, r := range rows {
+			cpu, mem := "-", "-"
+			if u := r.info.ResourceUsage; u != nil {
+				cpu = fmt.Sprintf("%.1f%%", u.CPUPercent)
+				mem = formatBytes(uint64(u.MemoryBytes))
+			}
+			fmt.Printf("  %-22s  %-12s  %-20s  %-10s  %-10s  %6s  %9s  %s\n",
+				r.info.DeploymentID, truncate(firstNonEmpty(r.info.Name, "-"), 12), truncate(r.info.Image, 20),
+				truncate(r.peer, 10), r.info.Status, cpu, mem, firstNonEmpty(r.info.ExposedURL, "-"))
+		}
+	}
+	fmt.Println()
+	fmt.Printf("Total: %d deployments\n", len(rows))
+
+	for _, msg := range unreachable {
+		fmt.Printf("Warning: could not query %s\n", msg)
+	}
+	return nil
+}
+
+// describeStatus returns a deployment's status with its reason and exit
+// code, e.g. "failed (OOMKilled, exit code 137)".
+func describeStatus(info protocol.DeploymentStatusInfo) string {
+	var details []string
+	if info.Reason != "" {
+		details = append(details, string(info.Reason))
+	}
+	if info.ExitCode != nil {
+		details = append(details, fmt.Sprintf("exit code %d", *info.ExitCode))
+	}
+	if info.QueuePosition > 0 {
+		details = append(details, fmt.Sprintf("position %d", info.QueuePosition))
+	}
+	if info.NextRestartAt != nil {
+		details = append(details, "restarting at "+info.NextRestartAt.Local().Format("15:04:05"))
+	}
+	if len(details) == 0 {
+		return info.Status
+	}
+	return fmt.Sprintf("%s (%s)", info.Status, strings.Join(details, ", "))
+}
+
+// formatUptime formats a duration as days, hours and minutes.
+func formatUptime(d time.Duration) string {
+	d = d.Truncate(time.Minute)
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	minutes := int(d.Minutes()) % 60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh %dm", days, hours, minutes)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
+
+// percentOf returns part as a percentage of whole (0 if whole is 0).
+func percentOf(part, whole float64) float64 {
+	if whole <= 0 {
+		return 0
+	}
+	return part / whole * 100
+}
+
+// shortContainerID abbreviates a container ID for display, as docker ps does.
+func shortContainerID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// shortPeerID abbreviates a peer ID for display.
+func shortPeerID(id peer.ID) string {
+	s := id.String()
+	if len(s) > 12 {
+		return s[:12] + "..."
+	}
+	return s
+}
+
+// truncate shortens s to at most n characters.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-1] + "…"
+}
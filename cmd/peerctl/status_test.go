@@ -0,0 +1,131 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/identity"
+	"github.com/xdas-research/peer-compute/internal/p2p"
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+// captureStdout returns what fn prints to standard output.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan string)
+	go func() {
+		out, _ := io.ReadAll(r)
+		done <- string(out)
+	}()
+	fn()
+	w.Close()
+	return <-done
+}
+
+func TestPrintDeploymentStatus(t *testing.T) {
+	provider, err := identity.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm := p2p.NewTrustManager(filepath.Join(t.TempDir(), "trusted_peers.json"))
+	if err := tm.Add(provider.PeerID, "lab-box", nil); err != nil {
+		t.Fatal(err)
+	}
+	target, _ := tm.Get(provider.PeerID)
+
+	running := protocol.DeploymentStatusInfo{
+		DeploymentID: "dep-0000000000000001",
+		Name:         "web",
+		Status:       string(protocol.StatusRunning),
+		Image:        "nginx:1.27",
+		StartedAt:    time.Now().Add(-90 * time.Minute),
+		CPULimit:     1000,
+		MemoryLimit:  64 * 1024 * 1024,
+		ResourceUsage: &protocol.ResourceUsage{
+			CPUPercent:      50,
+			MemoryBytes:     16 * 1024 * 1024,
+			NetworkRxBytes:  1024,
+			NetworkTxBytes:  200,
+			BlockWriteBytes: 512,
+			PIDs:            3,
+			PIDsLimit:       100,
+		},
+	}
+
+	tests := []struct {
+		name    string
+		info    func() protocol.DeploymentStatusInfo
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "running with usage",
+			info: func() protocol.DeploymentStatusInfo { return running },
+			want: []string{
+				"Deployment: dep-0000000000000001 (web)",
+				"Peer:       lab-box (",
+				"Uptime:     1h 30m",
+				"CPU:       0.50 of 1.00 cores (50.0%)",
+				// The configured limit stands in for a limit the engine
+				// didn't report
+				"Memory:    16.0MiB of 64.0MiB (25.0%)",
+				"Network:   1.0KiB received, 200B sent",
+				"Block I/O: 0B read, 512B written",
+				"PIDs:      3 of 100",
+			},
+		},
+		{
+			name: "running without usage",
+			info: func() protocol.DeploymentStatusInfo {
+				info := running
+				info.ResourceUsage = nil
+				return info
+			},
+			want:    []string{"CPU:       1.00 cores (limit)", "Memory:    64.0MiB (limit)", "Usage:     unavailable"},
+			notWant: []string{"Network:"},
+		},
+		{
+			name: "finished",
+			info: func() protocol.DeploymentStatusInfo {
+				info := running
+				info.ResourceUsage = nil
+				info.Status = string(protocol.StatusFailed)
+				info.Reason = protocol.ReasonOOMKilled
+				exitCode := 137
+				info.ExitCode = &exitCode
+				finished := time.Now()
+				info.FinishedAt = &finished
+				return info
+			},
+			want:    []string{"Status:     failed (OOMKilled, exit code 137)", "CPU:       1.00 cores (limit)"},
+			notWant: []string{"Uptime:", "Usage:     unavailable"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := captureStdout(t, func() { printDeploymentStatus(tm, target, tt.info()) })
+			for _, s := range tt.want {
+				if !strings.Contains(out, s) {
+					t.Errorf("output is missing %q:\n%s", s, out)
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(out, s) {
+					t.Errorf("output has %q:\n%s", s, out)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/xdas-research/peer-compute/internal/client"
+	"github.com/xdas-research/peer-compute/internal/identity"
+	"github.com/xdas-research/peer-compute/internal/p2p"
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+// updateTimeout leaves the provider time to pull the image and wait for the
+// new revision to become healthy (providers give up after 5 minutes).
+const updateTimeout = 6 * time.Minute
+
+func newUpdateCmd() *cobra.Command {
+	var (
+		peerName string
+		image    string
+		envVars  []string
+		unsetEnv []string
+		cpu      string
+		memory   string
+		timeout  time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "update <deployment-id>",
+		Short: "Update a deployment's image or configuration in place",
+		Long: `Roll out a new revision of a running deployment without changing its ID
+or public URL.
+
+The provider starts the new revision next to the running one and waits for
+it to pass the deployment's health check (or, without one, to keep running
+for a few seconds). It then switches the gateway route to the new container
+and stops the old one. If the new revision fails, the old one keeps running.
+
+The provider needs room for both revisions while the update is in progress.
+Use 'peerctl rollback' to return to an earlier revision.
+
+Examples:
+  peerctl update web --image nginx:1.27-alpine
+  peerctl update dep-5f3c2a9e1b7d4c60 --env LOG_LEVEL=debug --unset-env DEBUG_TOKEN
+  peerctl update web --memory 512M`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req := &protocol.UpdateRequest{
+				DeploymentID:     args[0],
+				Image:            image,
+				UnsetEnvironment: unsetEnv,
+			}
+
+			env, err := parseEnvVars(envVars)
+			if err != nil {
+				return fmt.Errorf("invalid environment variable: %w", err)
+			}
+			req.Environment = env
+
+			if cpu != "" {
+				if req.CPUMillicores, err = parseCPU(cpu); err != nil {
+					return fmt.Errorf("invalid CPU value: %w", err)
+				}
+			}
+			if memory != "" {
+				if req.MemoryBytes, err = parseMemory(memory); err != nil {
+					return fmt.Errorf("invalid memory value: %w", err)
+				}
+			}
+			if err := req.Validate(); err != nil {
+				return err
+			}
+
+			fmt.Printf("Updating %s...\n", args[0])
+			resp, err := sendUpdate(peerName, timeout, req)
+			if err != nil {
+				return err
+			}
+			if req.CPUMillicores > 0 {
+				if err := raiseRecordedCPU(args[0], req.CPUMillicores); err != nil {
+					fmt.Printf("Warning: %v\n", err)
+				}
+			}
+
+			fmt.Printf("✓ %s\n", resp.Message)
+			fmt.Printf("  Image: %s\n", resp.Image)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&image, "image", "", "New image")
+	cmd.Flags().StringSliceVar(&envVars, "env", nil, "Environment variables to set (KEY=VALUE)")
+	cmd.Flags().StringSliceVar(&unsetEnv, "unset-env", nil, "Environment variables to remove")
+	cmd.Flags().StringVar(&cpu, "cpu", "", "New CPU limit (e.g., 0.5, 1, 2)")
+	cmd.Flags().StringVar(&memory, "memory", "", "New memory limit (e.g., 128M, 1G)")
+	cmd.Flags().StringVar(&peerName, "peer", "", "Peer running the deployment (default: from local deployment index)")
+	cmd.Flags().DurationVar(&timeout, "timeout", updateTimeout, "Time to wait for the update to complete")
+
+	return cmd
+}
+
+func newRollbackCmd() *cobra.Command {
+	var (
+		peerName string
+		to       int
+		list     bool
+		timeout  time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "rollback <deployment-id>",
+		Short: "Restore an earlier revision of a deployment",
+		Long: `Restore the image and configuration of an earlier revision of a deployment.
+
+The rollback is rolled out like an update, as a new revision that copies the
+old one. Without --to, the revision before the current one is restored.
+Providers keep the last 10 revisions; --list shows them.
+
+Examples:
+  peerctl rollback web
+  peerctl rollback web --to 2
+  peerctl rollback web --list`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if list {
+				return listRevisions(args[0], peerName)
+			}
+			if to < 0 {
+				return fmt.Errorf("--to must not be negative")
+			}
+
+			fmt.Printf("Rolling back %s...\n", args[0])
+			resp, err := sendUpdate(peerName, timeout, &protocol.UpdateRequest{
+				DeploymentID: args[0],
+				Rollback:     true,
+				ToRevision:   to,
+			})
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("✓ %s\n", resp.Message)
+			fmt.Printf("  Image: %s\n", resp.Image)
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&to, "to", 0, "Revision to restore (default: the previous one)")
+	cmd.Flags().BoolVar(&list, "list", false, "List the deployment's revisions instead")
+	cmd.Flags().StringVar(&peerName, "peer", "", "Peer running the deployment (default: from local deployment index)")
+	cmd.Flags().DurationVar(&timeout, "timeout", updateTimeout, "Time to wait for the rollback to complete")
+
+	return cmd
+}
+
+// sendUpdate signs an update request and sends it to the peer running the
+// deployment, waiting for the outcome. Replicas spread over several peers
+// are updated one peer at a time, stopping at the first failure.
+func sendUpdate(peerName string, timeout time.Duration, req *protocol.UpdateRequest) (*protocol.UpdateResponse, error) {
+	id, _, err := identity.LoadOrGenerate(identity.DefaultKeyPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load identity: %w", err)
+	}
+
+	tm := p2p.NewTrustManager(identity.DefaultTrustedPeersPath())
+	if err := tm.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load trust list: %w", err)
+	}
+
+	targetPeers, err := findDeploymentPeers(tm, req.DeploymentID, peerName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := protocol.SignUpdateRequest(req, id); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var resp *protocol.UpdateResponse
+	for i, targetPeer := range targetPeers {
+		if len(targetPeers) > 1 {
+			fmt.Printf("  Peer %s...\n", targetPeer.ID)
+		}
+		resp, err = updateOnPeer(ctx, id, tm, targetPeer, req)
+		if err != nil {
+			if i > 0 {
+				return nil, fmt.Errorf("%w (%d of %d peers updated)", err, i, len(targetPeers))
+			}
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// updateOnPeer sends a signed update request to one peer.
+func updateOnPeer(ctx context.Context, id *identity.Identity, tm *p2p.TrustManager, targetPeer *p2p.TrustedPeer, req *protocol.UpdateRequest) (*protocol.UpdateResponse, error) {
+	host, err := connectToPeer(ctx, id, tm, targetPeer)
+	if err != nil {
+		return nil, err
+	}
+	defer host.Close()
+
+	resp, err := client.NewClient(host).Update(ctx, targetPeer.ID, req)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("update failed: %s", firstNonEmpty(resp.Message, resp.Error))
+	}
+	return resp, nil
+}
+
+// listRevisions prints a deployment's revision history.
+func listRevisions(deploymentID, peerName string) error {
+	id, _, err := identity.LoadOrGenerate(identity.DefaultKeyPath())
+	if err != nil {
+		return fmt.Errorf("failed to load identity: %w", err)
+	}
+
+	tm := p2p.NewTrustManager(identity.DefaultTrustedPeersPath())
+	if err := tm.Load(); err != nil {
+		return fmt.Errorf("failed to load trust list: %w", err)
+	}
+
+	targetPeer, err := findDeploymentPeer(tm, deploymentID, peerName)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	host, err := connectToPeer(ctx, id, tm, targetPeer)
+	if err != nil {
+		return err
+	}
+	defer host.Close()
+
+	resp, err := client.NewClient(host).Status(ctx, targetPeer.ID, deploymentID)
+	if err != nil {
+		return err
+	}
+	if len(resp.Deployments) == 0 {
+		return fmt.Errorf("deployment %s not found", deploymentID)
+	}
+
+	info := resp.Deployments[0]
+	if len(info.Revisions) == 0 {
+		fmt.Printf("%s is at revision %d (%s); it has not been updated.\n",
+			info.DeploymentID, max(info.Revision, 1), info.Image)
+		return nil
+	}
+
+	for _, r := range info.Revisions {
+		marker := " "
+		if r.Revision == info.Revision {
+			marker = "*"
+		}
+		fmt.Printf("%s %3d  %-30s  %5dm  %6dMB  %s  %s\n", marker, r.Revision, r.Image,
+			r.CPULimit, r.MemoryLimit/(1024*1024), r.CreatedAt.Local().Format("2006-01-02 15:04"), r.Cause)
+	}
+	if info.UpdatingTo > 0 {
+		fmt.Printf("\nUpdate to revision %d in progress\n", info.UpdatingTo)
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/spf13/cobra"
+	"github.com/xdas-research/peer-compute/internal/client"
+	"github.com/xdas-research/peer-compute/internal/identity"
+	"github.com/xdas-research/peer-compute/internal/p2p"
+	"github.com/xdas-research/peer-compute/internal/protocol"
+	"github.com/xdas-research/peer-compute/internal/usage"
+)
+
+func newUsageCmd() *cobra.Command {
+	var (
+		peerName string
+		since    string
+		given    bool
+		dataDir  string
+		timeout  time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "usage",
+		Short: "Show metered resource usage",
+		Long: `Show how much compute you have consumed on your peers, or with --given,
+how much this machine's daemon has given to each peer.
+
+Providers sample every running container and record CPU time, memory over
+time, run time and network traffic per requester, in hourly buckets kept for
+90 days. Without --peer, every trusted peer is asked for your usage.
+
+Examples:
+  peerctl usage
+  peerctl usage --peer alice --since 7d
+  peerctl usage --given --since 24h`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			from, err := parseSince(since)
+			if err != nil {
+				return fmt.Errorf("invalid --since: %w", err)
+			}
+
+			tm := p2p.NewTrustManager(identity.DefaultTrustedPeersPath())
+			if err := tm.Load(); err != nil {
+				return fmt.Errorf("failed to load trust list: %w", err)
+			}
+
+			if given {
+				return printGivenUsage(tm, filepath.Join(dataDir, usage.LedgerFileName), from)
+			}
+			return printConsumedUsage(tm, peerName, from, timeout)
+		},
+	}
+
+	cmd.Flags().StringVar(&peerName, "peer", "", "Only ask this peer (default: every trusted peer)")
+	cmd.Flags().StringVar(&since, "since", "", "Only count usage from this long ago (e.g., 24h, 7d; default: everything kept)")
+	cmd.Flags().BoolVar(&given, "given", false, "Show what this machine's daemon has given to other peers")
+	cmd.Flags().StringVar(&dataDir, "data-dir", identity.DefaultConfigDir(), "Data directory of the local daemon, for --given")
+	cmd.Flags().DurationVar(&timeout, "timeout", 15*time.Second, "Timeout per peer")
+
+	return cmd
+}
+
+// printConsumedUsage asks peers what this identity has consumed on them.
+func printConsumedUsage(tm *p2p.TrustManager, peerName string, since time.Time, timeout time.Duration) error {
+	id, _, err := identity.LoadOrGenerate(identity.DefaultKeyPath())
+	if err != nil {
+		return fmt.Errorf("failed to load identity: %w", err)
+	}
+
+	var targets []*p2p.TrustedPeer
+	if peerName != "" {
+		target, err := findPeerByName(tm, peerName)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, target)
+	} else {
+		targets = tm.List()
+		if len(targets) == 0 {
+			return fmt.Errorf("no trusted peers; add one with 'peerctl peers add'")
+		}
+	}
+
+	var rows []usageRow
+	for _, target := range targets {
+		resp, err := queryUsage(id, tm, target, since, timeout)
+		if err != nil {
+			if peerName != "" {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", peerLabel(tm, target.ID.String()), err)
+			continue
+		}
+		rows = append(rows, usageRow{label: peerLabel(tm, target.ID.String()), record: *resp.Usage})
+	}
+
+	fmt.Printf("Consumed on your peers%s:\n\n", sinceLabel(since))
+	printUsageTable("PEER", rows)
+	return nil
+}
+
+// queryUsage asks one peer for this identity's usage.
+func queryUsage(id *identity.Identity, tm *p2p.TrustManager, target *p2p.TrustedPeer, since time.Time, timeout time.Duration) (*protocol.UsageResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	host, err := connectToPeer(ctx, id, tm, target)
+	if err != nil {
+		return nil, err
+	}
+	defer host.Close()
+
+	resp, err := client.NewClient(host).Usage(ctx, target.ID, &protocol.UsageRequest{Since: since})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success || resp.Usage == nil {
+		return nil, fmt.Errorf("usage query failed: %s", resp.Error)
+	}
+	return resp, nil
+}
+
+// printGivenUsage prints the local daemon's usage ledger.
+func printGivenUsage(tm *p2p.TrustManager, path string, since time.Time) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return fmt.Errorf("no usage ledger at %s (is peercomputed running with this data directory?)", path)
+	}
+	ledger, err := usage.LoadLedger(path)
+	if err != nil {
+		return err
+	}
+
+	var rows []usageRow
+	for _, r := range ledger.List(since) {
+		rows = append(rows, usageRow{label: peerLabel(tm, r.RequesterID), record: r})
+	}
+
+	fmt.Printf("Given to other peers%s:\n\n", sinceLabel(since))
+	printUsageTable("REQUESTER", rows)
+	return nil
+}
+
+// usageRow is one line of a usage table.
+type usageRow struct {
+	label  string
+	record protocol.UsageRecord
+}
+
+// printUsageTable prints usage records with a total line.
+func printUsageTable(who string, rows []usageRow) {
+	if len(rows) == 0 {
+		fmt.Println("No usage recorded.")
+		return
+	}
+
+	fmt.Printf("%-20s  %10s  %12s  %10s  %10s  %10s  %11s\n",
+		who, "CPU-HOURS", "MEM GB-HOURS", "RUN HOURS", "NET IN", "NET OUT", "DEPLOYMENTS")
+	var total protocol.UsageRecord
+	for _, row := range rows {
+		printUsageLine(row.label, row.record)
+		total.CPUSeconds += row.record.CPUSeconds
+		total.MemoryByteSeconds += row.record.MemoryByteSeconds
+		total.WallSeconds += row.record.WallSeconds
+		total.NetworkRxBytes += row.record.NetworkRxBytes
+		total.NetworkTxBytes += row.record.NetworkTxBytes
+		total.Deployments += row.record.Deployments
+	}
+	if len(rows) > 1 {
+		printUsageLine("TOTAL", total)
+	}
+}
+
+func printUsageLine(label string, r protocol.UsageRecord) {
+	if len(label) > 20 {
+		label = label[:17] + "..."
+	}
+	fmt.Printf("%-20s  %10.2f  %12.2f  %10.1f  %10s  %10s  %11d\n",
+		label,
+		r.CPUSeconds/3600,
+		r.MemoryByteSeconds/(1<<30)/3600,
+		r.WallSeconds/3600,
+		formatBytes(r.NetworkRxBytes),
+		formatBytes(r.NetworkTxBytes),
+		r.Deployments)
+}
+
+// peerLabel returns a peer's name from the trust list, or its ID.
+func peerLabel(tm *p2p.TrustManager, peerID string) string {
+	if pid, err := peer.Decode(peerID); err == nil {
+		if tp, ok := tm.Get(pid); ok && tp.Name != "" {
+			return tp.Name
+		}
+	}
+	return peerID
+}
+
+// parseSince parses a --since value such as "24h" or "7d" into the time
+// that long ago. Empty means the beginning of the kept usage.
+func parseSince(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	var d time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return time.Time{}, fmt.Errorf("invalid number of days %q", s)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(s); err != nil || d < 0 {
+			return time.Time{}, fmt.Errorf("invalid duration %q (use e.g. 24h or 7d)", s)
+		}
+	}
+	return time.Now().Add(-d), nil
+}
+
+func sinceLabel(since time.Time) string {
+	if since.IsZero() {
+		return ""
+	}
+	return " since " + since.Local().Truncate(time.Hour).Format("2006-01-02 15:04")
+}
+
+// formatBytes formats a byte count with a binary unit.
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
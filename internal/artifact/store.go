@@ -0,0 +1,252 @@
+// Package artifact stores job input and output artifacts on a provider.
+//
+// Artifacts are tarballs addressed by the SHA-256 digest of their content.
+// Each peer has its own namespace, so a peer can only reference and download
+// artifacts it uploaded or that its own jobs produced, and storage is
+// accounted per peer against a quota.
+package artifact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DigestPrefix prefixes every artifact digest
+	DigestPrefix = "sha256:"
+
+	// DefaultQuota is how many bytes of artifacts a peer may store
+	DefaultQuota = 1 << 30
+)
+
+// QuotaFunc returns how many bytes of artifacts a peer may store.
+type QuotaFunc func(owner string) int64
+
+// Info describes a stored artifact.
+type Info struct {
+	Digest string
+	Size   int64
+}
+
+// Store keeps artifacts on disk under <dir>/<owner>/<hex digest>.
+type Store struct {
+	dir   string
+	quota QuotaFunc
+	mu    sync.Mutex
+}
+
+// NewStore creates a store in dir. A nil quota applies DefaultQuota to
+// every peer.
+func NewStore(dir string, quota QuotaFunc) *Store {
+	if quota == nil {
+		quota = func(string) int64 { return DefaultQuota }
+	}
+	return &Store{dir: dir, quota: quota}
+}
+
+// Put stores an artifact for owner, reading r to EOF. If digest is set the
+// content must match it. The artifact is rejected if it would take the
+// owner over their quota.
+func (s *Store) Put(owner string, r io.Reader, digest string) (Info, error) {
+	ownerDir, err := s.ownerDir(owner)
+	if err != nil {
+		return Info{}, err
+	}
+	if digest != "" {
+		if _, err := digestHex(digest); err != nil {
+			return Info{}, err
+		}
+	}
+
+	quota := s.quota(owner)
+	remaining := s.Remaining(owner)
+	if remaining <= 0 {
+		return Info{}, fmt.Errorf("artifact quota of %d bytes is used up", quota)
+	}
+
+	if err := os.MkdirAll(ownerDir, 0700); err != nil {
+		return Info{}, fmt.Errorf("failed to create artifact directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(ownerDir, ".upload-*")
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to create artifact file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	// SECURITY: Never write more than the remaining quota to disk
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(r, remaining+1))
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to write artifact: %w", err)
+	}
+	if size > remaining {
+		return Info{}, fmt.Errorf("artifact exceeds the remaining quota of %d bytes", remaining)
+	}
+	if err := tmp.Close(); err != nil {
+		return Info{}, fmt.Errorf("failed to write artifact: %w", err)
+	}
+
+	info := Info{Digest: DigestPrefix + hex.EncodeToString(hash.Sum(nil)), Size: size}
+	if digest != "" && digest != info.Digest {
+		return Info{}, fmt.Errorf("artifact digest mismatch: got %s, expected %s", info.Digest, digest)
+	}
+
+	// Re-check under the lock so concurrent uploads can't overshoot the quota
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	final := filepath.Join(ownerDir, strings.TrimPrefix(info.Digest, DigestPrefix))
+	if _, err := os.Stat(final); err == nil {
+		// Already stored; refresh its age so it isn't collected
+		now := time.Now()
+		os.Chtimes(final, now, now)
+		return info, nil
+	}
+	if s.Usage(owner)+size > quota {
+		return Info{}, fmt.Errorf("artifact exceeds the quota of %d bytes", quota)
+	}
+	if err := os.Rename(tmp.Name(), final); err != nil {
+		return Info{}, fmt.Errorf("failed to store artifact: %w", err)
+	}
+	return info, nil
+}
+
+// Open opens an owner's artifact for reading.
+func (s *Store) Open(owner, digest string) (*os.File, Info, error) {
+	path, err := s.path(owner, digest)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, Info{}, fmt.Errorf("artifact %s not found", digest)
+	}
+	if err != nil {
+		return nil, Info{}, fmt.Errorf("failed to open artifact: %w", err)
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, Info{}, fmt.Errorf("failed to open artifact: %w", err)
+	}
+	return f, Info{Digest: digest, Size: st.Size()}, nil
+}
+
+// Path returns the file of an owner's artifact if it exists.
+func (s *Store) Path(owner, digest string) (string, bool) {
+	path, err := s.path(owner, digest)
+	if err != nil {
+		return "", false
+	}
+	_, err = os.Stat(path)
+	return path, err == nil
+}
+
+// Remaining returns how many more bytes of artifacts an owner may store.
+func (s *Store) Remaining(owner string) int64 {
+	return s.quota(owner) - s.Usage(owner)
+}
+
+// Usage returns how many bytes of artifacts an owner stores.
+func (s *Store) Usage(owner string) int64 {
+	ownerDir, err := s.ownerDir(owner)
+	if err != nil {
+		return 0
+	}
+	entries, err := os.ReadDir(ownerDir)
+	if err != nil {
+		return 0
+	}
+
+	var total int64
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
+// Collect removes artifacts older than minAge that referenced no longer
+// reports as in use, along with abandoned partial uploads.
+func (s *Store) Collect(referenced func(owner, digest string) bool, minAge time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	owners, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-minAge)
+	for _, o := range owners {
+		if !o.IsDir() {
+			continue
+		}
+		ownerDir := filepath.Join(s.dir, o.Name())
+		entries, err := os.ReadDir(ownerDir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			info, err := e.Info()
+			if err != nil || info.ModTime().After(cutoff) {
+				continue
+			}
+			if !strings.HasPrefix(e.Name(), ".") && referenced(o.Name(), DigestPrefix+e.Name()) {
+				continue
+			}
+			os.Remove(filepath.Join(ownerDir, e.Name()))
+		}
+	}
+}
+
+// ownerDir returns an owner's directory.
+func (s *Store) ownerDir(owner string) (string, error) {
+	// SECURITY: Owners are peer IDs; refuse anything that could escape the store
+	if owner == "" || strings.ContainsAny(owner, `/\.`) {
+		return "", fmt.Errorf("invalid artifact owner %q", owner)
+	}
+	return filepath.Join(s.dir, owner), nil
+}
+
+// path returns the file of an owner's artifact.
+func (s *Store) path(owner, digest string) (string, error) {
+	ownerDir, err := s.ownerDir(owner)
+	if err != nil {
+		return "", err
+	}
+	h, err := digestHex(digest)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(ownerDir, h), nil
+}
+
+// digestHex validates a digest and returns its hex part.
+func digestHex(digest string) (string, error) {
+	h := strings.TrimPrefix(digest, DigestPrefix)
+	if h == digest || len(h) != sha256.Size*2 || h != strings.ToLower(h) {
+		return "", fmt.Errorf("invalid artifact digest %q", digest)
+	}
+	if _, err := hex.DecodeString(h); err != nil {
+		return "", fmt.Errorf("invalid artifact digest %q", digest)
+	}
+	return h, nil
+}
+
+// ValidDigest reports whether digest is a well-formed artifact digest.
+func ValidDigest(digest string) bool {
+	_, err := digestHex(digest)
+	return err == nil
+}
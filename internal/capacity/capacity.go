@@ -0,0 +1,352 @@
+// Package capacity publishes and collects provider capacity advertisements.
+//
+// Providers periodically publish a signed protocol.CapacityAdvert on a private
+// gossip topic. Requesters keep the latest advert per provider so placement
+// decisions can be made without querying every provider.
+package capacity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/xdas-research/peer-compute/internal/identity"
+	"github.com/xdas-research/peer-compute/internal/p2p"
+	"github.com/xdas-research/peer-compute/internal/protocol"
+	"github.com/xdas-research/peer-compute/internal/scheduler"
+)
+
+const (
+	// DefaultInterval is how often providers publish adverts
+	DefaultInterval = 30 * time.Second
+
+	// MaxAdvertAge is how long an advert is considered current
+	MaxAdvertAge = 3 * DefaultInterval
+
+	// CacheFileName is the file the requester-side cache is persisted to
+	CacheFileName = "capacity_adverts.json"
+)
+
+// Publisher periodically advertises this provider's free capacity.
+type Publisher struct {
+	gossip           *p2p.Gossip
+	scheduler        *scheduler.Scheduler
+	identity         *identity.Identity
+	region           string
+	gatewayConnected func() bool
+	interval         time.Duration
+}
+
+// PublisherConfig contains publisher configuration.
+type PublisherConfig struct {
+	// Region is the operator-assigned location label
+	Region string
+	// GatewayConnected reports gateway connectivity (nil = never connected)
+	GatewayConnected func() bool
+	// Interval is the publish interval (0 = DefaultInterval)
+	Interval time.Duration
+}
+
+// NewPublisher creates a new capacity publisher.
+func NewPublisher(g *p2p.Gossip, sched *scheduler.Scheduler, id *identity.Identity, cfg *PublisherConfig) *Publisher {
+	if cfg == nil {
+		cfg = &PublisherConfig{}
+	}
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Publisher{
+		gossip:           g,
+		scheduler:        sched,
+		identity:         id,
+		region:           cfg.Region,
+		gatewayConnected: cfg.GatewayConnected,
+		interval:         interval,
+	}
+}
+
+// Run publishes adverts until the context is cancelled.
+func (p *Publisher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Publish(ctx); err != nil {
+			log.Printf("[CAPACITY] Failed to publish advert: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Publish builds, signs and publishes a single advert.
+func (p *Publisher) Publish(ctx context.Context) error {
+	cpuUsed, cpuTotal, memUsed, memTotal, slots, maxSlots := p.scheduler.ResourceUsage()
+
+	advert := &protocol.CapacityAdvert{
+		FreeCPU:     cpuTotal - cpuUsed,
+		TotalCPU:    cpuTotal,
+		FreeMemory:  memTotal - memUsed,
+		TotalMemory: memTotal,
+		FreeSlots:   maxSlots - slots,
+		MaxSlots:    maxSlots,
+		Region:      p.region,
+		Draining:    p.scheduler.DrainStatus().Cordoned,
+	}
+	if p.gatewayConnected != nil {
+		advert.GatewayConnected = p.gatewayConnected()
+	}
+
+	if err := protocol.SignCapacityAdvert(advert, p.identity); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(advert)
+	if err != nil {
+		return fmt.Errorf("failed to marshal advert: %w", err)
+	}
+
+	return p.gossip.Publish(ctx, protocol.CapacityTopic, data)
+}
+
+// Cache keeps the latest verified advert from each provider.
+type Cache struct {
+	adverts map[string]*protocol.CapacityAdvert
+	path    string
+	mu      sync.RWMutex
+}
+
+// NewCache creates an advert cache. If path is non-empty, the cache is
+// persisted there so short-lived tools such as peerctl can read it.
+func NewCache(path string) *Cache {
+	return &Cache{
+		adverts: make(map[string]*protocol.CapacityAdvert),
+		path:    path,
+	}
+}
+
+// LoadCache reads a persisted advert cache.
+func LoadCache(path string) (*Cache, error) {
+	c := NewCache(path)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read advert cache: %w", err)
+	}
+
+	var adverts []*protocol.CapacityAdvert
+	if err := json.Unmarshal(data, &adverts); err != nil {
+		return nil, fmt.Errorf("failed to parse advert cache: %w", err)
+	}
+	for _, a := range adverts {
+		c.adverts[a.PeerID] = a
+	}
+
+	return c, nil
+}
+
+// Subscribe starts collecting adverts from the gossip topic.
+func (c *Cache) Subscribe(g *p2p.Gossip) {
+	g.Subscribe(protocol.CapacityTopic, c.handleAdvert)
+}
+
+// handleAdvert verifies and stores an advert received via gossip.
+func (c *Cache) handleAdvert(from peer.ID, data []byte) {
+	var advert protocol.CapacityAdvert
+	if err := json.Unmarshal(data, &advert); err != nil {
+		log.Printf("[CAPACITY] Invalid advert from %s: %v", from, err)
+		return
+	}
+
+	// SECURITY: The advert must be signed by the peer that published it.
+	if advert.PeerID != from.String() {
+		log.Printf("[CAPACITY] Advert for %s published by %s rejected", advert.PeerID, from)
+		return
+	}
+	if err := protocol.VerifyCapacityAdvert(&advert); err != nil {
+		log.Printf("[CAPACITY] Advert from %s rejected: %v", from, err)
+		return
+	}
+
+	c.Put(&advert)
+}
+
+// Put stores an advert if it is newer than the one already cached.
+func (c *Cache) Put(advert *protocol.CapacityAdvert) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if existing, ok := c.adverts[advert.PeerID]; ok && existing.Timestamp >= advert.Timestamp {
+		return
+	}
+	c.adverts[advert.PeerID] = advert
+
+	if c.path != "" {
+		if err := c.saveUnlocked(); err != nil {
+			log.Printf("[CAPACITY] Failed to persist advert cache: %v", err)
+		}
+	}
+}
+
+// Get returns the latest current advert for a provider.
+func (c *Cache) Get(peerID peer.ID) (*protocol.CapacityAdvert, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	a, ok := c.adverts[peerID.String()]
+	if !ok || isStale(a) {
+		return nil, false
+	}
+	copy := *a
+	return &copy, true
+}
+
+// List returns all current adverts, most free CPU first.
+func (c *Cache) List() []*protocol.CapacityAdvert {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make([]*protocol.CapacityAdvert, 0, len(c.adverts))
+	for _, a := range c.adverts {
+		if isStale(a) {
+			continue
+		}
+		copy := *a
+		result = append(result, &copy)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].FreeCPU > result[j].FreeCPU
+	})
+	return result
+}
+
+// Pick returns the provider with the most free CPU that can fit the request.
+// An empty region matches any provider.
+func (c *Cache) Pick(cpuMillicores, memoryBytes int64, region string, needGateway bool) (*protocol.CapacityAdvert, bool) {
+	for _, a := range c.List() {
+		if a.FreeSlots <= 0 || a.FreeCPU < cpuMillicores || a.FreeMemory < memoryBytes {
+			continue
+		}
+		if !eligible(a, region, needGateway) {
+			continue
+		}
+		return a, true
+	}
+	return nil, false
+}
+
+// Placement is a number of replicas to run on one provider.
+type Placement struct {
+	PeerID   string
+	Replicas int
+}
+
+// Spread places replicas on the providers that can fit them, as evenly as
+// the providers' free capacity allows and preferring providers with more
+// free CPU. It returns false if the providers cannot fit every replica.
+func (c *Cache) Spread(replicas int, cpuMillicores, memoryBytes int64, region string, needGateway bool) ([]Placement, bool) {
+	type candidate struct {
+		peerID string
+		cpu    int64
+		memory int64
+		slots  int
+		placed int
+	}
+
+	var candidates []*candidate
+	for _, a := range c.List() {
+		if eligible(a, region, needGateway) {
+			candidates = append(candidates, &candidate{
+				peerID: a.PeerID,
+				cpu:    a.FreeCPU,
+				memory: a.FreeMemory,
+				slots:  a.FreeSlots,
+			})
+		}
+	}
+
+	for i := 0; i < replicas; i++ {
+		var best *candidate
+		for _, cand := range candidates {
+			if cand.slots <= 0 || cand.cpu < cpuMillicores || cand.memory < memoryBytes {
+				continue
+			}
+			if best == nil || cand.placed < best.placed {
+				best = cand
+			}
+		}
+		if best == nil {
+			return nil, false
+		}
+		best.placed++
+		best.slots--
+		best.cpu -= cpuMillicores
+		best.memory -= memoryBytes
+	}
+
+	var placements []Placement
+	for _, cand := range candidates {
+		if cand.placed > 0 {
+			placements = append(placements, Placement{PeerID: cand.peerID, Replicas: cand.placed})
+		}
+	}
+	return placements, true
+}
+
+// eligible reports whether a provider matches the placement constraints
+// that do not depend on its free capacity. Draining providers are never
+// eligible.
+func eligible(a *protocol.CapacityAdvert, region string, needGateway bool) bool {
+	if a.Draining {
+		return false
+	}
+	if region != "" && a.Region != region {
+		return false
+	}
+	return !needGateway || a.GatewayConnected
+}
+
+// saveUnlocked persists the cache (caller must hold lock). The file is
+// replaced atomically, so peerctl never reads a partly written cache.
+func (c *Cache) saveUnlocked() error {
+	adverts := make([]*protocol.CapacityAdvert, 0, len(c.adverts))
+	for _, a := range c.adverts {
+		adverts = append(adverts, a)
+	}
+
+	data, err := json.MarshalIndent(adverts, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return err
+	}
+
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// isStale reports whether an advert is too old to base decisions on.
+func isStale(a *protocol.CapacityAdvert) bool {
+	return time.Since(time.Unix(0, a.Timestamp)) > MaxAdvertAge
+}
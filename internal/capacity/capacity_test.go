@@ -0,0 +1,169 @@
+package capacity
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/identity"
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+// signedAdvert returns an advert of a new provider, signed by it.
+func signedAdvert(t *testing.T, freeCPU int64) (*protocol.CapacityAdvert, *identity.Identity) {
+	t.Helper()
+	id, err := identity.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	advert := &protocol.CapacityAdvert{
+		FreeCPU:     freeCPU,
+		TotalCPU:    4000,
+		FreeMemory:  1 << 30,
+		TotalMemory: 4 << 30,
+		FreeSlots:   5,
+		MaxSlots:    10,
+	}
+	if err := protocol.SignCapacityAdvert(advert, id); err != nil {
+		t.Fatal(err)
+	}
+	return advert, id
+}
+
+func mustMarshal(t *testing.T, v any) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestHandleAdvertVerifiesSignature(t *testing.T) {
+	c := NewCache("")
+	advert, provider := signedAdvert(t, 2000)
+
+	c.handleAdvert(provider.PeerID, mustMarshal(t, advert))
+	got, ok := c.Get(provider.PeerID)
+	if !ok || got.FreeCPU != 2000 {
+		t.Fatalf("Get() = %+v, %v, want the signed advert", got, ok)
+	}
+
+	// Inflating free capacity invalidates the signature
+	forged := *advert
+	forged.FreeCPU = 4000
+	forged.Timestamp++
+	c.handleAdvert(provider.PeerID, mustMarshal(t, &forged))
+	if got, _ := c.Get(provider.PeerID); got.FreeCPU != 2000 {
+		t.Errorf("tampered advert replaced the signed one: FreeCPU = %d", got.FreeCPU)
+	}
+}
+
+func TestHandleAdvertRejects(t *testing.T) {
+	advert, provider := signedAdvert(t, 2000)
+	_, other := signedAdvert(t, 0)
+
+	unsigned := *advert
+	unsigned.Signature = nil
+
+	// A valid advert of another provider, signed with a key that isn't its own
+	impersonated := *advert
+	impersonated.PeerID = other.PeerID.String()
+
+	// Signed by the provider an hour ago
+	old := *advert
+	old.Timestamp = time.Now().Add(-time.Hour).UnixNano()
+	old.Signature = nil
+	payload := sha256.Sum256(mustMarshal(t, &old))
+	signature, err := provider.Sign(payload[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	old.Signature = signature
+
+	tests := []struct {
+		name string
+		from *identity.Identity
+		data []byte
+	}{
+		{"published by another peer", other, mustMarshal(t, advert)},
+		{"unsigned", provider, mustMarshal(t, &unsigned)},
+		{"signed by another key", other, mustMarshal(t, &impersonated)},
+		{"replayed", provider, mustMarshal(t, &old)},
+		{"not JSON", provider, []byte("free capacity")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCache("")
+			c.handleAdvert(tt.from.PeerID, tt.data)
+			if adverts := c.List(); len(adverts) != 0 {
+				t.Errorf("cache holds %+v, want nothing", adverts)
+			}
+		})
+	}
+}
+
+func TestCachePersistsAndReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", CacheFileName)
+	c := NewCache(path)
+	fresh, provider := signedAdvert(t, 3000)
+	other, _ := signedAdvert(t, 1000)
+	c.Put(fresh)
+	c.Put(other)
+
+	// An older advert doesn't replace a newer one
+	older := *fresh
+	older.FreeCPU = 0
+	older.Timestamp--
+	c.Put(&older)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("cache not persisted: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("cache file mode = %o, want 600", perm)
+	}
+
+	loaded, err := LoadCache(path)
+	if err != nil {
+		t.Fatalf("LoadCache() error = %v", err)
+	}
+	list := loaded.List()
+	if len(list) != 2 || list[0].FreeCPU != 3000 || list[1].FreeCPU != 1000 {
+		t.Fatalf("List() after reload = %+v, want both adverts, most free CPU first", list)
+	}
+	if got, ok := loaded.Get(provider.PeerID); !ok || got.Timestamp != fresh.Timestamp || string(got.Signature) != string(fresh.Signature) {
+		t.Errorf("Get() after reload = %+v, want the newest advert", got)
+	}
+	// Reloaded adverts keep their signatures
+	if err := protocol.VerifyCapacityAdvert(list[0]); err != nil {
+		t.Errorf("reloaded advert does not verify: %v", err)
+	}
+
+	// Adverts too old to act on are kept but not offered
+	stale := *other
+	stale.PeerID = "12D3KooWStale"
+	stale.Timestamp = time.Now().Add(-2 * MaxAdvertAge).UnixNano()
+	loaded.Put(&stale)
+	reloaded, err := LoadCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(reloaded.List()); n != 2 {
+		t.Errorf("List() = %d adverts, want the 2 current ones", n)
+	}
+}
+
+func TestLoadCacheMissingFile(t *testing.T) {
+	c, err := LoadCache(filepath.Join(t.TempDir(), CacheFileName))
+	if err != nil {
+		t.Fatalf("LoadCache() error = %v", err)
+	}
+	if adverts := c.List(); len(adverts) != 0 {
+		t.Errorf("List() = %+v, want empty", adverts)
+	}
+}
@@ -2,6 +2,7 @@
 package client
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -74,6 +75,147 @@ func (c *Client) Stop(ctx context.Context, peerID peer.ID, deploymentID string)
 	return &resp, nil
 }
 
+// Renew sends a signed lease renewal to a provider.
+func (c *Client) Renew(ctx context.Context, peerID peer.ID, req *protocol.RenewRequest) (*protocol.RenewResponse, error) {
+	stream, err := c.host.NewStream(ctx, peerID, protocol.RenewProtocol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer stream.Close()
+
+	encoder := json.NewEncoder(stream)
+	if err := encoder.Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	var resp protocol.RenewResponse
+	decoder := json.NewDecoder(stream)
+	if err := decoder.Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return &resp, nil
+}
+
+// Update sends a signed update or rollback request to a provider and waits
+// until the provider switched to the new revision or abandoned the update.
+func (c *Client) Update(ctx context.Context, peerID peer.ID, req *protocol.UpdateRequest) (*protocol.UpdateResponse, error) {
+	stream, err := c.host.NewStream(ctx, peerID, protocol.UpdateProtocol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer stream.Close()
+
+	encoder := json.NewEncoder(stream)
+	if err := encoder.Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	var resp protocol.UpdateResponse
+	decoder := json.NewDecoder(stream)
+	if err := decoder.Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return &resp, nil
+}
+
+// Usage asks a provider how much of its resources this peer has consumed.
+func (c *Client) Usage(ctx context.Context, peerID peer.ID, req *protocol.UsageRequest) (*protocol.UsageResponse, error) {
+	stream, err := c.host.NewStream(ctx, peerID, protocol.UsageProtocol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer stream.Close()
+
+	encoder := json.NewEncoder(stream)
+	if err := encoder.Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	var resp protocol.UsageResponse
+	decoder := json.NewDecoder(stream)
+	if err := decoder.Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return &resp, nil
+}
+
+// Cron sends a signed cron request to manage scheduled jobs on a provider.
+func (c *Client) Cron(ctx context.Context, peerID peer.ID, req *protocol.CronRequest) (*protocol.CronResponse, error) {
+	stream, err := c.host.NewStream(ctx, peerID, protocol.CronProtocol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer stream.Close()
+
+	encoder := json.NewEncoder(stream)
+	if err := encoder.Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	var resp protocol.CronResponse
+	decoder := json.NewDecoder(stream)
+	if err := decoder.Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return &resp, nil
+}
+
+// Events streams the requester's deployment events from a provider, calling
+// fn for each event until the stream ends, fn returns an error or the context
+// is cancelled. A nil error means the provider ended the stream.
+func (c *Client) Events(ctx context.Context, peerID peer.ID, req *protocol.EventsRequest, fn func(protocol.DeploymentEvent) error) error {
+	stream, err := c.host.NewStream(ctx, peerID, protocol.EventsProtocol)
+	if err != nil {
+		return fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer stream.Close()
+
+	// Reset the stream on cancellation to unblock the decoder
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			stream.Reset()
+		case <-done:
+		}
+	}()
+
+	encoder := json.NewEncoder(stream)
+	if err := encoder.Encode(req); err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+
+	decoder := json.NewDecoder(stream)
+	var resp protocol.EventsResponse
+	if err := decoder.Decode(&resp); err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if !resp.Success {
+		return fmt.Errorf("events request refused: %s", resp.Error)
+	}
+
+	for {
+		var e protocol.DeploymentEvent
+		if err := decoder.Decode(&e); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("failed to read event: %w", err)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+}
+
 // Status gets deployment status from a provider.
 func (c *Client) Status(ctx context.Context, peerID peer.ID, deploymentID string) (*protocol.StatusResponse, error) {
 	stream, err := c.host.NewStream(ctx, peerID, "/peercompute/status/1.0.0")
@@ -124,3 +266,80 @@ func (c *Client) Logs(ctx context.Context, peerID peer.ID, deploymentID string,
 	// Return stream for reading logs
 	return stream, nil
 }
+
+// UploadArtifact uploads size bytes from r as an artifact with the given
+// digest.
+func (c *Client) UploadArtifact(ctx context.Context, peerID peer.ID, r io.Reader, size int64, digest string) (*protocol.ArtifactResponse, error) {
+	stream, err := c.host.NewStream(ctx, peerID, protocol.ArtifactProtocol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer stream.Close()
+
+	req := protocol.ArtifactRequest{
+		Op:     protocol.ArtifactUpload,
+		Digest: digest,
+		Size:   size,
+	}
+	encoder := json.NewEncoder(stream)
+	if err := encoder.Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	// The provider may refuse before reading the bytes; its response
+	// explains why, so a failed write isn't reported on its own
+	_, copyErr := io.Copy(stream, io.LimitReader(r, size))
+
+	var resp protocol.ArtifactResponse
+	if err := json.NewDecoder(stream).Decode(&resp); err != nil {
+		if copyErr != nil {
+			return nil, fmt.Errorf("failed to send artifact: %w", copyErr)
+		}
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return &resp, nil
+}
+
+// DownloadArtifact writes an artifact to w. The bytes are only written if
+// the response reports success.
+func (c *Client) DownloadArtifact(ctx context.Context, peerID peer.ID, digest string, w io.Writer) (*protocol.ArtifactResponse, error) {
+	stream, err := c.host.NewStream(ctx, peerID, protocol.ArtifactProtocol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer stream.Close()
+
+	req := protocol.ArtifactRequest{
+		Op:     protocol.ArtifactDownload,
+		Digest: digest,
+	}
+	encoder := json.NewEncoder(stream)
+	if err := encoder.Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	// Read the response line without reading ahead into the artifact bytes
+	br := bufio.NewReader(stream)
+	line, err := br.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	var resp protocol.ArtifactResponse
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if !resp.Success {
+		return &resp, nil
+	}
+
+	n, err := io.Copy(w, io.LimitReader(br, resp.Size))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download artifact: %w", err)
+	}
+	if n != resp.Size {
+		return nil, fmt.Errorf("artifact download truncated: got %d of %d bytes", n, resp.Size)
+	}
+
+	return &resp, nil
+}
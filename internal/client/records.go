@@ -0,0 +1,77 @@
+// Package client - Local deployment index
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DeploymentsFileName is where peerctl remembers which peer runs each of
+// your deployments and cron schedules. The daemon reads it to check that
+// usage receipts are for something this peer asked for.
+const DeploymentsFileName = "requested_deployments.json"
+
+// DeploymentRecord is a deployment or cron schedule requested from this
+// machine.
+type DeploymentRecord struct {
+	ID         string `json:"id"`
+	PeerID     string `json:"peer_id"`
+	Name       string `json:"name,omitempty"`
+	ReplicaSet string `json:"replica_set,omitempty"`
+	Image      string `json:"image"`
+	// CPUMillicores is the highest CPU limit requested for the deployment
+	CPUMillicores int64     `json:"cpu_millicores,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// LoadDeploymentRecords reads a deployment index. A missing file is an
+// empty index.
+func LoadDeploymentRecords(path string) ([]DeploymentRecord, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read deployment index: %w", err)
+	}
+
+	var records []DeploymentRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse deployment index: %w", err)
+	}
+	return records, nil
+}
+
+// SaveDeploymentRecords replaces a deployment index.
+func SaveDeploymentRecords(path string, records []DeploymentRecord) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal deployment index: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write deployment index: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace deployment index: %w", err)
+	}
+	return nil
+}
+
+// FindDeploymentRecord returns the record of a deployment or cron schedule
+// requested from a peer, if there is one.
+func FindDeploymentRecord(records []DeploymentRecord, peerID, id string) (DeploymentRecord, bool) {
+	for _, rec := range records {
+		if rec.PeerID == peerID && rec.ID == id {
+			return rec, true
+		}
+	}
+	return DeploymentRecord{}, false
+}
@@ -0,0 +1,581 @@
+// Package credit keeps bilateral compute credit between peers.
+//
+// Providers turn what the usage meter records for each deployment into
+// usage receipts: signed statements of what the deployment consumed during
+// a period. The provider sends each receipt to the requester's daemon, which
+// checks it and countersigns it. Both sides keep the receipts, and each peer
+// computes its balance with every counterparty from them: CPU time it has
+// given to the peer minus CPU time it has received from it. Providers may
+// refuse deployments from peers that have received much more than they gave.
+package credit
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/identity"
+	"github.com/xdas-research/peer-compute/internal/protocol"
+	"github.com/xdas-research/peer-compute/internal/usage"
+)
+
+const (
+	// LedgerFileName is the file the ledger is persisted to
+	LedgerFileName = "credits.json"
+
+	// ReceiptInterval is the longest period a receipt covers; long-running
+	// deployments get a receipt at least this often
+	ReceiptInterval = 6 * time.Hour
+
+	// IdleAfter is how long a deployment must go unsampled (because it
+	// stopped) before its usage so far is receipted
+	IdleAfter = 5 * time.Minute
+
+	// RetryInterval is how often a receipt the requester has not
+	// countersigned yet is sent again
+	RetryInterval = 10 * time.Minute
+
+	// Retention is how long individual receipts are kept; older ones are
+	// folded into per-peer totals
+	Retention = usage.Retention
+
+	// clockSkew is the tolerance for receipt periods ending in the future
+	clockSkew = 5 * time.Minute
+)
+
+// RequestedFunc looks up a deployment or cron schedule this peer requested
+// from a provider, returning its CPU limit in millicores (0 = unknown) and
+// whether this peer requested it.
+type RequestedFunc func(providerID, id string) (cpuMillicores int64, ok bool)
+
+// Balance is the credit between this peer and one counterparty. Amounts are
+// CPU-seconds.
+type Balance struct {
+	// PeerID is the counterparty
+	PeerID string `json:"peer_id"`
+
+	// Given is CPU time this peer provided to the counterparty, countersigned
+	Given float64 `json:"given"`
+
+	// Received is CPU time the counterparty provided to this peer
+	Received float64 `json:"received"`
+
+	// Unconfirmed is CPU time this peer provided to the counterparty that
+	// the counterparty has not countersigned (yet)
+	Unconfirmed float64 `json:"unconfirmed"`
+
+	// Receipts is how many receipts the balance was computed from
+	Receipts int `json:"receipts"`
+}
+
+// Net is this peer's balance with the counterparty: what it has given minus
+// what it has received. Positive means the counterparty is in its debt.
+func (b Balance) Net() float64 {
+	return b.Given - b.Received
+}
+
+// PeerBalance is the counterparty's balance with this peer, with usage it
+// has not countersigned counted against it. Credit policies use this.
+func (b Balance) PeerBalance() float64 {
+	return b.Received - b.Given - b.Unconfirmed
+}
+
+// Entry is a receipt kept in the ledger.
+type Entry struct {
+	protocol.UsageReceipt
+
+	// LastAttempt is when the provider last sent the receipt for
+	// countersigning
+	LastAttempt time.Time `json:"last_attempt,omitempty"`
+
+	// Disputed is why the requester refused to countersign the receipt
+	Disputed string `json:"disputed,omitempty"`
+}
+
+// accrual is a deployment's usage that has not been receipted yet.
+type accrual struct {
+	RequesterID  string       `json:"requester_id"`
+	DeploymentID string       `json:"deployment_id"`
+	CronID       string       `json:"cron_id,omitempty"`
+	PeriodStart  time.Time    `json:"period_start"`
+	LastSample   time.Time    `json:"last_sample"`
+	Usage        usage.Sample `json:"usage"`
+}
+
+// state is the persisted form of the ledger.
+type state struct {
+	Receipts []*Entry   `json:"receipts"`
+	Accruals []*accrual `json:"accruals,omitempty"`
+	Carried  []*Balance `json:"carried,omitempty"`
+}
+
+// Ledger keeps this peer's receipts with every counterparty.
+type Ledger struct {
+	path     string
+	id       *identity.Identity
+	self     string
+	receipts map[string]*Entry   // receiptID -> receipt
+	accruals map[string]*accrual // deploymentID -> unreceipted usage
+	carried  map[string]*Balance // peerID -> totals of folded receipts
+	dirty    bool
+
+	// requested looks up what this peer requested from providers
+	requested RequestedFunc
+
+	mu sync.Mutex
+}
+
+// NewLedger creates an empty ledger for an identity, persisted at path
+// (empty = in memory).
+func NewLedger(path string, id *identity.Identity) *Ledger {
+	return &Ledger{
+		path:     path,
+		id:       id,
+		self:     id.PeerID.String(),
+		receipts: make(map[string]*Entry),
+		accruals: make(map[string]*accrual),
+		carried:  make(map[string]*Balance),
+	}
+}
+
+// LoadLedger reads a persisted ledger. A missing file is an empty ledger.
+func LoadLedger(path string, id *identity.Identity) (*Ledger, error) {
+	l := NewLedger(path, id)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credit ledger: %w", err)
+	}
+
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("failed to parse credit ledger: %w", err)
+	}
+	for _, e := range st.Receipts {
+		l.receipts[e.ReceiptID] = e
+	}
+	for _, a := range st.Accruals {
+		l.accruals[a.DeploymentID] = a
+	}
+	for _, b := range st.Carried {
+		l.carried[b.PeerID] = b
+	}
+	return l, nil
+}
+
+// SetRequested sets how the ledger looks up the deployments and cron
+// schedules this peer requested. Receipts for anything else are disputed.
+func (l *Ledger) SetRequested(fn RequestedFunc) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.requested = fn
+}
+
+// Accrue adds a metered sample of one of this provider's deployments to its
+// next receipt. It is installed as the usage meter's sample hook.
+func (l *Ledger) Accrue(d *protocol.Deployment, at time.Time, s usage.Sample) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	a, ok := l.accruals[d.ID]
+	if !ok {
+		// The first sample covers the time since the one before it, but
+		// never time an earlier receipt already billed
+		start := at.Add(-time.Duration(s.WallSeconds * float64(time.Second))).UTC()
+		for _, e := range l.receipts {
+			if e.ProviderID == l.self && e.DeploymentID == d.ID && e.PeriodEnd.After(start) {
+				start = e.PeriodEnd
+			}
+		}
+		a = &accrual{
+			RequesterID:  d.RequesterID,
+			DeploymentID: d.ID,
+			CronID:       d.CronID,
+			PeriodStart:  start,
+		}
+		l.accruals[d.ID] = a
+	}
+	a.LastSample = at.UTC()
+	a.Usage.CPUSeconds += s.CPUSeconds
+	a.Usage.MemoryByteSeconds += s.MemoryByteSeconds
+	a.Usage.WallSeconds += s.WallSeconds
+	a.Usage.NetworkRxBytes += s.NetworkRxBytes
+	a.Usage.NetworkTxBytes += s.NetworkTxBytes
+	l.dirty = true
+}
+
+// Due signs receipts for usage that is due, and returns the receipts that
+// should be sent to their requesters for countersigning now.
+func (l *Ledger) Due(now time.Time) []protocol.UsageReceipt {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for id, a := range l.accruals {
+		if now.Sub(a.LastSample) < IdleAfter && now.Sub(a.PeriodStart) < ReceiptInterval {
+			continue
+		}
+		r := protocol.UsageReceipt{
+			ReceiptID:         protocol.ReceiptIDPrefix + randomHex(),
+			RequesterID:       a.RequesterID,
+			DeploymentID:      a.DeploymentID,
+			CronID:            a.CronID,
+			PeriodStart:       a.PeriodStart,
+			PeriodEnd:         a.LastSample,
+			CPUSeconds:        a.Usage.CPUSeconds,
+			MemoryByteSeconds: a.Usage.MemoryByteSeconds,
+			WallSeconds:       a.Usage.WallSeconds,
+			NetworkRxBytes:    a.Usage.NetworkRxBytes,
+			NetworkTxBytes:    a.Usage.NetworkTxBytes,
+			IssuedAt:          now.UTC(),
+		}
+		if err := protocol.SignReceipt(&r, l.id); err != nil {
+			log.Printf("[CREDIT] Failed to sign receipt for %s: %v", id, err)
+			continue
+		}
+		l.receipts[r.ReceiptID] = &Entry{UsageReceipt: r}
+		delete(l.accruals, id)
+		l.dirty = true
+		log.Printf("[CREDIT] Issued receipt %s to %s for %s (%.2f CPU-hours)",
+			r.ReceiptID, r.RequesterID, r.DeploymentID, r.CPUSeconds/3600)
+	}
+
+	var due []protocol.UsageReceipt
+	for _, e := range l.receipts {
+		if e.ProviderID != l.self || e.Countersigned() || e.Disputed != "" {
+			continue
+		}
+		if now.Sub(e.LastAttempt) < RetryInterval {
+			continue
+		}
+		e.LastAttempt = now.UTC()
+		due = append(due, e.UsageReceipt)
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].IssuedAt.Before(due[j].IssuedAt)
+	})
+	return due
+}
+
+// Settle records the requester's countersignature on one of this provider's
+// receipts.
+func (l *Ledger) Settle(signed *protocol.UsageReceipt) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.receipts[signed.ReceiptID]
+	if !ok || e.ProviderID != l.self {
+		return fmt.Errorf("unknown receipt %s", signed.ReceiptID)
+	}
+	if e.Countersigned() {
+		return nil
+	}
+
+	// SECURITY: Only the countersignature is taken from the requester; it
+	// must be valid over the receipt as this provider issued it
+	r := e.UsageReceipt
+	r.RequesterSignature = signed.RequesterSignature
+	if len(r.RequesterSignature) == 0 {
+		return fmt.Errorf("receipt %s is not countersigned", r.ReceiptID)
+	}
+	if err := protocol.VerifyReceipt(&r); err != nil {
+		return fmt.Errorf("invalid countersignature on %s: %w", r.ReceiptID, err)
+	}
+	e.UsageReceipt = r
+	l.dirty = true
+	return nil
+}
+
+// Dispute marks one of this provider's receipts as refused by its
+// requester, so it is no longer sent. It still counts as unconfirmed usage.
+func (l *Ledger) Dispute(receiptID, reason string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e, ok := l.receipts[receiptID]; ok && !e.Countersigned() {
+		e.Disputed = reason
+		l.dirty = true
+	}
+}
+
+// Countersign checks a receipt a provider issued to this peer, countersigns
+// it and records it. Receipts that were already countersigned are returned
+// again, so a provider that missed the reply can retry. An error means the
+// receipt is disputed.
+func (l *Ledger) Countersign(providerID string, r *protocol.UsageReceipt) (*protocol.UsageReceipt, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if r.ProviderID != providerID {
+		return nil, fmt.Errorf("receipt was issued by another provider")
+	}
+	if !strings.HasPrefix(r.ReceiptID, protocol.ReceiptIDPrefix) {
+		return nil, fmt.Errorf("invalid receipt ID %q", r.ReceiptID)
+	}
+	unsigned := *r
+	unsigned.RequesterSignature = nil
+	if err := protocol.VerifyReceipt(&unsigned); err != nil {
+		return nil, err
+	}
+	if e, ok := l.receipts[r.ReceiptID]; ok {
+		if e.ProviderID != r.ProviderID || string(e.ProviderSignature) != string(r.ProviderSignature) {
+			return nil, fmt.Errorf("receipt ID %s was already used for another receipt", r.ReceiptID)
+		}
+		signed := e.UsageReceipt
+		return &signed, nil
+	}
+
+	if err := l.checkReceiptLocked(r, time.Now()); err != nil {
+		return nil, err
+	}
+
+	signed := unsigned
+	if err := protocol.CountersignReceipt(&signed, l.id); err != nil {
+		return nil, err
+	}
+	l.receipts[signed.ReceiptID] = &Entry{UsageReceipt: signed}
+	l.dirty = true
+	log.Printf("[CREDIT] Countersigned receipt %s from %s for %s (%.2f CPU-hours)",
+		signed.ReceiptID, providerID, signed.DeploymentID, signed.CPUSeconds/3600)
+	return &signed, nil
+}
+
+// checkReceiptLocked checks that a receipt's figures are plausible (caller
+// must hold lock). The requester cannot measure the provider's CPU, but it
+// can refuse deployments it never asked for, impossible periods, CPU time
+// beyond the deployment's limit and usage that was already receipted.
+func (l *Ledger) checkReceiptLocked(r *protocol.UsageReceipt, now time.Time) error {
+	if r.RequesterID != l.self {
+		return fmt.Errorf("receipt is for another requester")
+	}
+	if r.DeploymentID == "" {
+		return fmt.Errorf("receipt names no deployment")
+	}
+
+	// SECURITY: Only usage of deployments this peer requested from the
+	// provider is countersigned; cron runs are matched by their schedule
+	if l.requested == nil {
+		return fmt.Errorf("this peer keeps no record of the deployments it requested")
+	}
+	cpuMillicores, ok := l.requested(r.ProviderID, r.DeploymentID)
+	if !ok && r.CronID != "" {
+		cpuMillicores, ok = l.requested(r.ProviderID, r.CronID)
+	}
+	if !ok {
+		return fmt.Errorf("deployment %s was not requested from this provider", r.DeploymentID)
+	}
+	if cpuMillicores <= 0 {
+		cpuMillicores = protocol.MaxCPUMillicores
+	}
+	if r.PeriodEnd.Before(r.PeriodStart) {
+		return fmt.Errorf("receipt period ends before it starts")
+	}
+	if r.PeriodEnd.After(now.Add(clockSkew)) {
+		return fmt.Errorf("receipt period ends in the future")
+	}
+	if r.PeriodStart.Before(now.Add(-Retention)) {
+		return fmt.Errorf("receipt period is older than %v", Retention)
+	}
+	for _, v := range []float64{r.CPUSeconds, r.MemoryByteSeconds, r.WallSeconds} {
+		if v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("receipt has invalid usage figures")
+		}
+	}
+	// Allow a second for rounding of the sampled intervals
+	if period := r.PeriodEnd.Sub(r.PeriodStart).Seconds(); r.WallSeconds > period+1 {
+		return fmt.Errorf("receipt bills %.0fs of run time for a %.0fs period", r.WallSeconds, period)
+	}
+	// SECURITY: A container cannot use more CPU than its limit while it runs
+	if limit := (r.WallSeconds + 1) * float64(cpuMillicores) / 1000; r.CPUSeconds > limit {
+		return fmt.Errorf("receipt bills %.0f CPU-seconds, more than the deployment's limit of %.2f CPUs allows in %.0fs",
+			r.CPUSeconds, float64(cpuMillicores)/1000, r.WallSeconds)
+	}
+
+	// SECURITY: A provider must not bill the same time twice
+	for _, e := range l.receipts {
+		if e.ProviderID != r.ProviderID || e.DeploymentID != r.DeploymentID {
+			continue
+		}
+		if r.PeriodStart.Before(e.PeriodEnd.Add(-time.Second)) && e.PeriodStart.Before(r.PeriodEnd.Add(-time.Second)) {
+			return fmt.Errorf("receipt overlaps receipt %s for the same deployment", e.ReceiptID)
+		}
+	}
+	return nil
+}
+
+// Balance returns the credit between this peer and a counterparty.
+func (l *Ledger) Balance(peerID string) Balance {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.balancesLocked()[peerID]
+}
+
+// Balances returns the credit with every counterparty, largest debt to this
+// peer first.
+func (l *Ledger) Balances() []Balance {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var list []Balance
+	for _, b := range l.balancesLocked() {
+		list = append(list, b)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Net() != list[j].Net() {
+			return list[i].Net() > list[j].Net()
+		}
+		return list[i].PeerID < list[j].PeerID
+	})
+	return list
+}
+
+// Receipts returns the kept receipts exchanged with a counterparty (empty =
+// all), newest first.
+func (l *Ledger) Receipts(peerID string) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var list []Entry
+	for _, e := range l.receipts {
+		if peerID == "" || e.ProviderID == peerID || e.RequesterID == peerID {
+			list = append(list, *e)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].IssuedAt.After(list[j].IssuedAt)
+	})
+	return list
+}
+
+// balancesLocked computes the balance with every counterparty (caller must
+// hold lock).
+func (l *Ledger) balancesLocked() map[string]Balance {
+	balances := make(map[string]Balance)
+	for peerID, b := range l.carried {
+		balances[peerID] = *b
+	}
+	for _, e := range l.receipts {
+		switch {
+		case e.ProviderID == l.self:
+			b := balances[e.RequesterID]
+			b.PeerID = e.RequesterID
+			if e.Countersigned() {
+				b.Given += e.CPUSeconds
+			} else {
+				b.Unconfirmed += e.CPUSeconds
+			}
+			b.Receipts++
+			balances[e.RequesterID] = b
+		case e.RequesterID == l.self && e.Countersigned():
+			b := balances[e.ProviderID]
+			b.PeerID = e.ProviderID
+			b.Received += e.CPUSeconds
+			b.Receipts++
+			balances[e.ProviderID] = b
+		}
+	}
+	for _, a := range l.accruals {
+		b := balances[a.RequesterID]
+		b.PeerID = a.RequesterID
+		b.Unconfirmed += a.Usage.CPUSeconds
+		balances[a.RequesterID] = b
+	}
+	return balances
+}
+
+// Save folds receipts older than Retention into per-peer totals and
+// persists the ledger if it changed.
+func (l *Ledger) Save() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cutoff := time.Now().Add(-Retention)
+	for id, e := range l.receipts {
+		if !e.PeriodEnd.Before(cutoff) {
+			continue
+		}
+		var peerID string
+		var given, received, unconfirmed float64
+		switch {
+		case e.ProviderID == l.self && e.Countersigned():
+			peerID, given = e.RequesterID, e.CPUSeconds
+		case e.ProviderID == l.self:
+			peerID, unconfirmed = e.RequesterID, e.CPUSeconds
+		default:
+			peerID, received = e.ProviderID, e.CPUSeconds
+		}
+		b, ok := l.carried[peerID]
+		if !ok {
+			b = &Balance{PeerID: peerID}
+			l.carried[peerID] = b
+		}
+		b.Given += given
+		b.Received += received
+		b.Unconfirmed += unconfirmed
+		b.Receipts++
+		delete(l.receipts, id)
+		l.dirty = true
+	}
+	if !l.dirty || l.path == "" {
+		return nil
+	}
+
+	var st state
+	for _, e := range l.receipts {
+		st.Receipts = append(st.Receipts, e)
+	}
+	sort.Slice(st.Receipts, func(i, j int) bool {
+		return st.Receipts[i].IssuedAt.Before(st.Receipts[j].IssuedAt)
+	})
+	for _, a := range l.accruals {
+		st.Accruals = append(st.Accruals, a)
+	}
+	for _, b := range l.carried {
+		st.Carried = append(st.Carried, b)
+	}
+
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal credit ledger: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write credit ledger: %w", err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return fmt.Errorf("failed to replace credit ledger: %w", err)
+	}
+	l.dirty = false
+	return nil
+}
+
+// SaveOrLog persists the ledger, logging failures like other state files.
+func (l *Ledger) SaveOrLog() {
+	if err := l.Save(); err != nil {
+		log.Printf("[CREDIT] %v", err)
+	}
+}
+
+// randomHex returns 16 random hex digits for a receipt ID.
+func randomHex() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand only fails if the OS entropy source is broken
+		panic(fmt.Sprintf("failed to generate receipt ID: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,166 @@
+package credit
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/identity"
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+// receiptFixture is a provider and a requester whose ledger records one
+// deployment and one cron schedule requested from that provider.
+type receiptFixture struct {
+	provider  *identity.Identity
+	requester *Ledger
+}
+
+func newReceiptFixture(t *testing.T) *receiptFixture {
+	t.Helper()
+	provider, err := identity.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	requester, err := identity.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := NewLedger("", requester)
+	requested := map[string]int64{
+		"dep-0000000000000001":  500,
+		"cron-0000000000000001": 1000,
+	}
+	l.SetRequested(func(providerID, id string) (int64, bool) {
+		if providerID != provider.PeerID.String() {
+			return 0, false
+		}
+		cpu, ok := requested[id]
+		return cpu, ok
+	})
+	return &receiptFixture{provider: provider, requester: l}
+}
+
+// receipt returns an unsigned receipt for an hour of run time
+// that ended a minute ago.
+func (f *receiptFixture) receipt(t *testing.T, deploymentID string, cpuSeconds float64) *protocol.UsageReceipt {
+	t.Helper()
+	end := time.Now().Add(-time.Minute).UTC()
+	return &protocol.UsageReceipt{
+		ReceiptID:    protocol.ReceiptIDPrefix + randomHex(),
+		RequesterID:  f.requester.self,
+		DeploymentID: deploymentID,
+		PeriodStart:  end.Add(-time.Hour),
+		PeriodEnd:    end,
+		CPUSeconds:   cpuSeconds,
+		WallSeconds:  3600,
+		IssuedAt:     time.Now().UTC(),
+	}
+}
+
+func (f *receiptFixture) countersign(t *testing.T, r *protocol.UsageReceipt) (*protocol.UsageReceipt, error) {
+	t.Helper()
+	if err := protocol.SignReceipt(r, f.provider); err != nil {
+		t.Fatal(err)
+	}
+	return f.requester.Countersign(f.provider.PeerID.String(), r)
+}
+
+func TestCountersignRequestedDeployment(t *testing.T) {
+	f := newReceiptFixture(t)
+
+	// Half a CPU for an hour is the most the deployment can use
+	signed, err := f.countersign(t, f.receipt(t, "dep-0000000000000001", 1800))
+	if err != nil {
+		t.Fatalf("Countersign() error = %v", err)
+	}
+	if !signed.Countersigned() {
+		t.Fatal("receipt is not countersigned")
+	}
+	if got := f.requester.Balance(f.provider.PeerID.String()).Received; got != 1800 {
+		t.Errorf("Received = %v, want 1800", got)
+	}
+}
+
+func TestCountersignCronRun(t *testing.T) {
+	f := newReceiptFixture(t)
+
+	r := f.receipt(t, "dep-00000000000000ff", 3600)
+	r.CronID = "cron-0000000000000001"
+	if _, err := f.countersign(t, r); err != nil {
+		t.Fatalf("Countersign() error = %v", err)
+	}
+}
+
+func TestCountersignRejectsUnrequestedDeployment(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*protocol.UsageReceipt)
+	}{
+		{"unknown deployment", func(r *protocol.UsageReceipt) { r.DeploymentID = "dep-00000000000000ff" }},
+		{"unknown cron schedule", func(r *protocol.UsageReceipt) {
+			r.DeploymentID = "dep-00000000000000ff"
+			r.CronID = "cron-00000000000000ff"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newReceiptFixture(t)
+			r := f.receipt(t, "dep-0000000000000001", 60)
+			tt.modify(r)
+			_, err := f.countersign(t, r)
+			if err == nil || !strings.Contains(err.Error(), "was not requested") {
+				t.Fatalf("Countersign() error = %v, want not requested", err)
+			}
+			if got := f.requester.Balance(f.provider.PeerID.String()).Received; got != 0 {
+				t.Errorf("Received = %v, want 0", got)
+			}
+		})
+	}
+}
+
+func TestCountersignRejectsDeploymentOfOtherProvider(t *testing.T) {
+	f := newReceiptFixture(t)
+	other, err := identity.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The deployment was requested from f.provider, not from other
+	r := f.receipt(t, "dep-0000000000000001", 60)
+	if err := protocol.SignReceipt(r, other); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.requester.Countersign(other.PeerID.String(), r); err == nil {
+		t.Fatal("Countersign() succeeded for a deployment requested from another provider")
+	}
+}
+
+func TestCountersignRejectsCPUAboveLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		cpuSeconds float64
+	}{
+		{"just above the limit", 1900},
+		{"inflated", 1e12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newReceiptFixture(t)
+			_, err := f.countersign(t, f.receipt(t, "dep-0000000000000001", tt.cpuSeconds))
+			if err == nil || !strings.Contains(err.Error(), "CPU-seconds") {
+				t.Fatalf("Countersign() error = %v, want CPU limit error", err)
+			}
+		})
+	}
+}
+
+func TestCountersignRequiresRecords(t *testing.T) {
+	f := newReceiptFixture(t)
+	f.requester.SetRequested(nil)
+
+	if _, err := f.countersign(t, f.receipt(t, "dep-0000000000000001", 60)); err == nil {
+		t.Fatal("Countersign() succeeded without a record of requested deployments")
+	}
+}
@@ -0,0 +1,126 @@
+// Package handler - Job artifact transfers
+package handler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/xdas-research/peer-compute/internal/artifact"
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+// maxArtifactHeader bounds the JSON line that precedes artifact bytes.
+const maxArtifactHeader = 4096
+
+// SetArtifactStore sets the store job artifacts are uploaded to and
+// downloaded from.
+func (h *Handler) SetArtifactStore(store *artifact.Store) {
+	h.artifacts = store
+}
+
+// checkJobArtifacts checks that a job's input was uploaded by the requester
+// and that the provider can store its outputs.
+func (h *Handler) checkJobArtifacts(p peer.ID, job *protocol.JobSpec) error {
+	if job.Input == "" && len(job.Outputs) == 0 {
+		return nil
+	}
+	if h.artifacts == nil {
+		return fmt.Errorf("job artifacts are not supported by this provider")
+	}
+	// SECURITY: Inputs are looked up in the requester's own namespace, so a
+	// peer can't stage another peer's files into its job
+	if job.Input != "" {
+		if _, ok := h.artifacts.Path(p.String(), job.Input); !ok {
+			return fmt.Errorf("job input %s has not been uploaded", job.Input)
+		}
+	}
+	return nil
+}
+
+// handleArtifact uploads or downloads an artifact. The request and response
+// are single JSON lines, followed by the artifact's raw bytes.
+func (h *Handler) handleArtifact(stream network.Stream) {
+	defer stream.Close()
+
+	remotePeer := stream.Conn().RemotePeer()
+	owner := remotePeer.String()
+
+	// Read the header line without reading ahead into the artifact bytes
+	br := bufio.NewReaderSize(stream, maxArtifactHeader)
+	line, err := br.ReadSlice('\n')
+	if err != nil {
+		log.Printf("[ARTIFACT] Failed to read request: %v", err)
+		return
+	}
+	var req protocol.ArtifactRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		log.Printf("[ARTIFACT] Failed to parse request: %v", err)
+		return
+	}
+
+	// SECURITY: Only trusted peers may store files on this machine
+	if !h.trust.IsTrusted(remotePeer) {
+		log.Printf("[ARTIFACT] Untrusted peer rejected: %s", remotePeer)
+		sendArtifactError(stream, "not trusted")
+		return
+	}
+	if h.artifacts == nil {
+		sendArtifactError(stream, "job artifacts are not supported by this provider")
+		return
+	}
+	if !artifact.ValidDigest(req.Digest) {
+		sendArtifactError(stream, fmt.Sprintf("invalid artifact digest %q", req.Digest))
+		return
+	}
+
+	switch req.Op {
+	case protocol.ArtifactUpload:
+		if req.Size <= 0 {
+			sendArtifactError(stream, "artifact size must be positive")
+			return
+		}
+		// SECURITY: Refuse before reading anything if the quota can't fit it
+		if remaining := h.artifacts.Remaining(owner); req.Size > remaining {
+			sendArtifactError(stream, fmt.Sprintf("artifact of %d bytes exceeds the remaining quota of %d bytes", req.Size, remaining))
+			return
+		}
+
+		log.Printf("[ARTIFACT] Receiving %s (%d bytes) from %s", req.Digest, req.Size, remotePeer)
+		info, err := h.artifacts.Put(owner, io.LimitReader(br, req.Size), req.Digest)
+		if err != nil {
+			log.Printf("[ARTIFACT] Upload from %s failed: %v", remotePeer, err)
+			sendArtifactError(stream, err.Error())
+			return
+		}
+		writeJSON(stream, protocol.ArtifactResponse{Success: true, Digest: info.Digest, Size: info.Size})
+
+	case protocol.ArtifactDownload:
+		f, info, err := h.artifacts.Open(owner, req.Digest)
+		if err != nil {
+			sendArtifactError(stream, err.Error())
+			return
+		}
+		defer f.Close()
+
+		log.Printf("[ARTIFACT] Sending %s (%d bytes) to %s", info.Digest, info.Size, remotePeer)
+		if err := writeJSON(stream, protocol.ArtifactResponse{Success: true, Digest: info.Digest, Size: info.Size}); err != nil {
+			return
+		}
+		if _, err := io.Copy(stream, f); err != nil {
+			log.Printf("[ARTIFACT] Download by %s failed: %v", remotePeer, err)
+		}
+
+	default:
+		sendArtifactError(stream, fmt.Sprintf("unknown artifact operation %q", req.Op))
+	}
+}
+
+func sendArtifactError(w io.Writer, message string) {
+	writeJSON(w, protocol.ArtifactResponse{Success: false, Error: message})
+}
@@ -0,0 +1,151 @@
+// Package handler - Reciprocity credit
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/xdas-research/peer-compute/internal/credit"
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+const (
+	// ReceiptCheckInterval is how often due receipts are issued and sent
+	ReceiptCheckInterval = time.Minute
+
+	// ReceiptTimeout bounds sending one receipt for countersigning
+	ReceiptTimeout = 15 * time.Second
+)
+
+// SetCreditLedger sets the ledger receipts are issued from and countersigned
+// into.
+func (h *Handler) SetCreditLedger(ledger *credit.Ledger) {
+	h.credits = ledger
+}
+
+// SetCreditLimit sets how far, in CPU-hours, a peer's balance with this
+// provider may fall below zero before its deployments are refused (0 = no
+// limit).
+func (h *Handler) SetCreditLimit(cpuHours float64) {
+	h.creditLimit = cpuHours
+}
+
+// checkCredit refuses peers whose balance is below the credit limit.
+func (h *Handler) checkCredit(p peer.ID) error {
+	if h.credits == nil || h.creditLimit <= 0 {
+		return nil
+	}
+	balance := h.credits.Balance(p.String()).PeerBalance() / 3600
+	if balance < -h.creditLimit {
+		return fmt.Errorf("your credit balance with this provider is %.2f CPU-hours, below its limit of -%.2f; provide compute to this peer to earn credit",
+			balance, h.creditLimit)
+	}
+	return nil
+}
+
+// RunReceipts issues usage receipts as they fall due and sends them to
+// their requesters for countersigning, until the context is cancelled.
+func (h *Handler) RunReceipts(ctx context.Context) {
+	ticker := time.NewTicker(ReceiptCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			h.credits.SaveOrLog()
+			return
+		case <-ticker.C:
+			for _, r := range h.credits.Due(time.Now()) {
+				h.sendReceipt(ctx, r)
+			}
+			h.credits.SaveOrLog()
+		}
+	}
+}
+
+// sendReceipt asks a receipt's requester to countersign it. Unreachable
+// requesters are retried later; disputed receipts are not sent again.
+func (h *Handler) sendReceipt(ctx context.Context, r protocol.UsageReceipt) {
+	if h.host == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ReceiptTimeout)
+	defer cancel()
+
+	stream, err := h.openStream(ctx, r.RequesterID, protocol.ReceiptProtocol)
+	if err != nil {
+		log.Printf("[CREDIT] Could not send receipt %s to %s: %v", r.ReceiptID, r.RequesterID, err)
+		return
+	}
+	defer stream.Close()
+
+	if err := writeJSON(stream, r); err != nil {
+		log.Printf("[CREDIT] Failed to send receipt %s: %v", r.ReceiptID, err)
+		return
+	}
+	var resp protocol.ReceiptResponse
+	if err := readJSON(stream, &resp); err != nil {
+		log.Printf("[CREDIT] Failed to read countersignature for %s: %v", r.ReceiptID, err)
+		return
+	}
+
+	switch {
+	case resp.Success && resp.Receipt != nil:
+		if err := h.credits.Settle(resp.Receipt); err != nil {
+			log.Printf("[CREDIT] %v", err)
+			return
+		}
+		log.Printf("[CREDIT] Receipt %s countersigned by %s", r.ReceiptID, r.RequesterID)
+	case resp.Code == protocol.ErrCodeReceiptRejected:
+		log.Printf("[CREDIT] Receipt %s disputed by %s: %s", r.ReceiptID, r.RequesterID, resp.Error)
+		h.credits.Dispute(r.ReceiptID, resp.Error)
+	default:
+		log.Printf("[CREDIT] Receipt %s not countersigned by %s: %s", r.ReceiptID, r.RequesterID, resp.Error)
+	}
+}
+
+// handleReceipt countersigns a usage receipt from a provider this peer
+// deployed to.
+func (h *Handler) handleReceipt(stream network.Stream) {
+	defer stream.Close()
+
+	remotePeer := stream.Conn().RemotePeer()
+
+	var r protocol.UsageReceipt
+	if err := readJSON(stream, &r); err != nil {
+		log.Printf("[CREDIT] Failed to read receipt: %v", err)
+		writeJSON(stream, protocol.ReceiptResponse{Error: "invalid request format"})
+		return
+	}
+
+	if !h.trust.IsTrusted(remotePeer) {
+		log.Printf("[CREDIT] Untrusted peer rejected: %s", remotePeer)
+		writeJSON(stream, protocol.ReceiptResponse{Error: "not trusted"})
+		return
+	}
+	if h.credits == nil {
+		writeJSON(stream, protocol.ReceiptResponse{Error: "credit ledger is not enabled on this peer"})
+		return
+	}
+
+	// SECURITY: Only the provider named in the receipt may present it, so
+	// receipts cannot be relayed to bill usage from someone else
+	signed, err := h.credits.Countersign(remotePeer.String(), &r)
+	if err != nil {
+		log.Printf("[CREDIT] Disputed receipt %s from %s: %v", r.ReceiptID, remotePeer, err)
+		writeJSON(stream, protocol.ReceiptResponse{
+			Error: err.Error(),
+			Code:  protocol.ErrCodeReceiptRejected,
+		})
+		return
+	}
+	h.credits.SaveOrLog()
+
+	writeJSON(stream, protocol.ReceiptResponse{Success: true, Receipt: signed})
+}
@@ -0,0 +1,140 @@
+// Package handler - Cron schedules
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/libp2p/go-libp2p/core/network"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+	"github.com/xdas-research/peer-compute/internal/runtime"
+	"github.com/xdas-research/peer-compute/internal/scheduler"
+)
+
+// handleCron registers, lists and deletes the requester's scheduled jobs.
+func (h *Handler) handleCron(stream network.Stream) {
+	defer stream.Close()
+
+	remotePeer := stream.Conn().RemotePeer()
+	log.Printf("[CRON] Request from peer: %s", remotePeer)
+
+	var req protocol.CronRequest
+	if err := readJSON(stream, &req); err != nil {
+		log.Printf("[CRON] Failed to read request: %v", err)
+		sendCronError(stream, "invalid request format")
+		return
+	}
+
+	// SECURITY: Schedules run jobs long after the request was made, so the
+	// request must be signed by the connected peer, who owns the schedule
+	if req.RequesterID != remotePeer.String() {
+		sendCronError(stream, "requester does not match connection")
+		return
+	}
+	if err := protocol.VerifyCronRequest(&req); err != nil {
+		log.Printf("[CRON] Invalid request from %s: %v", remotePeer, err)
+		sendCronError(stream, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	if !h.trust.IsTrusted(remotePeer) {
+		log.Printf("[CRON] Untrusted peer rejected: %s", remotePeer)
+		sendCronError(stream, "not trusted")
+		return
+	}
+
+	owner := remotePeer.String()
+	switch req.Op {
+	case protocol.CronRegister:
+		spec := req.Spec
+		if spec == nil {
+			sendCronError(stream, "schedule spec is required")
+			return
+		}
+		if err := spec.Validate(); err != nil {
+			sendCronError(stream, err.Error())
+			return
+		}
+		if _, err := scheduler.ParseCron(spec.Schedule); err != nil {
+			sendCronError(stream, err.Error())
+			return
+		}
+		if !h.classAllowed(remotePeer, spec.PriorityClass) {
+			log.Printf("[CRON] Peer %s may not use priority class %s", remotePeer, spec.PriorityClass)
+			sendCronError(stream, fmt.Sprintf("priority class %q is not allowed for this peer", spec.PriorityClass))
+			return
+		}
+		if err := h.checkJobArtifacts(remotePeer, spec.Job); err != nil {
+			sendCronError(stream, err.Error())
+			return
+		}
+		if h.scheduler.DrainStatus().Cordoned {
+			sendCronError(stream, scheduler.ErrCordoned.Error())
+			return
+		}
+		if err := h.checkCredit(remotePeer); err != nil {
+			log.Printf("[CRON] Peer %s is over its credit limit", remotePeer)
+			sendCronError(stream, err.Error())
+			return
+		}
+
+		if err := h.checkPolicy(spec.Image, spec.CPUMillicores, spec.MemoryBytes); err != nil {
+			log.Printf("[CRON] Refusing schedule from %s: %v", remotePeer, err)
+			sendCronError(stream, err.Error())
+			return
+		}
+
+		// Pull now so a bad image is reported to the requester rather than
+		// at the first tick
+		// Each run resolves the tag again when it starts
+		digest, err := h.resolveDigest(context.Background(), spec.Image)
+		if err != nil {
+			sendCronError(stream, err.Error())
+			return
+		}
+		image := runtime.PinImage(spec.Image, digest)
+		log.Printf("[CRON] Pulling image: %s", image)
+		if err := h.runtime.Pull(context.Background(), image); err != nil {
+			log.Printf("[CRON] Image pull failed: %v", err)
+			sendCronError(stream, fmt.Sprintf("failed to pull image: %v", err))
+			return
+		}
+
+		c, err := h.scheduler.AddCron(owner, *spec)
+		if err != nil {
+			log.Printf("[CRON] Failed to register schedule: %v", err)
+			sendCronError(stream, err.Error())
+			return
+		}
+		writeJSON(stream, protocol.CronResponse{
+			Success:   true,
+			Schedules: []protocol.CronJob{*c},
+			Message:   "Schedule registered",
+		})
+
+	case protocol.CronList:
+		writeJSON(stream, protocol.CronResponse{
+			Success:   true,
+			Schedules: h.scheduler.ListCrons(owner),
+		})
+
+	case protocol.CronDelete:
+		if err := h.scheduler.DeleteCron(owner, req.ScheduleID); err != nil {
+			sendCronError(stream, err.Error())
+			return
+		}
+		writeJSON(stream, protocol.CronResponse{
+			Success: true,
+			Message: fmt.Sprintf("Schedule %s deleted", req.ScheduleID),
+		})
+
+	default:
+		sendCronError(stream, fmt.Sprintf("unknown operation %q", req.Op))
+	}
+}
+
+func sendCronError(w io.Writer, message string) {
+	writeJSON(w, protocol.CronResponse{Success: false, Error: message})
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+	"github.com/xdas-research/peer-compute/internal/scheduler"
+)
+
+// pullRecorder records the images a fake runtime is asked to pull.
+type pullRecorder struct {
+	mu     sync.Mutex
+	images []string
+}
+
+func (r *pullRecorder) hook(image string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.images = append(r.images, image)
+	return nil
+}
+
+func (r *pullRecorder) pulled(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, image := range r.images {
+		if strings.Contains(image, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestQueuedDeployPullsOnAdmission(t *testing.T) {
+	cfg := scheduler.DefaultConfig()
+	cfg.MaxCPU = 1000
+	cfg.MaxQueue = 4
+	h, sched, rt, requester := newTestHandlerWithConfig(t, cfg)
+
+	// Fill the provider
+	full := schedule(t, sched, rt, requester.PeerID)
+	schedule(t, sched, rt, requester.PeerID)
+
+	var pulls pullRecorder
+	rt.PullHook = pulls.hook
+
+	req := protocol.DeployRequest{
+		Image:               "redis:7",
+		CPUMillicores:       500,
+		MemoryBytes:         64 * 1024 * 1024,
+		QueueTimeoutSeconds: 60,
+	}
+	if err := protocol.SignDeployRequest(&req, requester); err != nil {
+		t.Fatal(err)
+	}
+	var resp protocol.DeployResponse
+	call(t, h.handleDeploy, requester.PeerID, req, &resp)
+
+	if !resp.Success || len(resp.Replicas) != 1 || resp.Replicas[0].Status != protocol.StatusQueued {
+		t.Fatalf("response = %+v, want one queued replica", resp)
+	}
+	if pulls.pulled("redis") {
+		t.Fatal("queued request pulled its image before admission")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sched.RunQueue(ctx)
+	if err := sched.Stop(ctx, full.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	id := resp.Replicas[0].DeploymentID
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if d, ok := sched.Get(id); ok && d.Status == protocol.StatusRunning {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("queued deployment was not admitted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !pulls.pulled("redis") {
+		t.Error("admitted deployment started without pulling its image")
+	}
+}
+
+func TestDeployReportsPullFailure(t *testing.T) {
+	h, sched, rt, requester := newTestHandler(t)
+	rt.PullHook = func(string) error { return errors.New("manifest unknown") }
+
+	req := protocol.DeployRequest{
+		Image:         "nginx:1.27",
+		CPUMillicores: 500,
+		MemoryBytes:   64 * 1024 * 1024,
+	}
+	if err := protocol.SignDeployRequest(&req, requester); err != nil {
+		t.Fatal(err)
+	}
+	var resp protocol.DeployResponse
+	call(t, h.handleDeploy, requester.PeerID, req, &resp)
+
+	if resp.Success || !strings.Contains(resp.Message, "manifest unknown") {
+		t.Errorf("response = %+v, want the pull error", resp)
+	}
+	if used, _, _, _, _, _ := sched.ResourceUsage(); used != 0 {
+		t.Errorf("used CPU = %d after a failed pull, want 0", used)
+	}
+}
@@ -0,0 +1,86 @@
+// Package handler - Draining for maintenance
+package handler
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+	"github.com/xdas-research/peer-compute/internal/scheduler"
+)
+
+// Drain cordons the provider: new deployments are refused and deployments
+// still running are stopped when the grace period ends. With notify, their
+// owners are told now when that will happen, and again when it does.
+func (h *Handler) Drain(grace time.Duration, message string, notify bool) (scheduler.DrainState, int) {
+	state, affected := h.scheduler.Cordon(grace, message, notify)
+	log.Printf("[DRAIN] Draining %d deployments, grace period %v", len(affected), grace)
+
+	if notify {
+		for _, d := range affected {
+			n := protocol.Notification{
+				DeploymentID: d.ID,
+				Status:       d.Status,
+				Reason:       protocol.ReasonDrained,
+				Message:      drainMessage(state, "the provider is draining for maintenance; the deployment will be stopped"),
+				StopAt:       state.StopAt,
+				Timestamp:    time.Now().UnixNano(),
+			}
+			if d.Status.IsFinished() {
+				// Queued requests were terminated right away
+				n.Message = drainMessage(state, d.Error)
+				n.StopAt = nil
+			}
+			go h.notify(d.RequesterID, n)
+		}
+	}
+	return state, len(affected)
+}
+
+// Undrain admits new deployments again and cancels the pending stop. It
+// reports whether the provider was draining.
+func (h *Handler) Undrain() bool {
+	return h.scheduler.Uncordon()
+}
+
+// DeploymentDrained removes the gateway route of a deployment a drain
+// stopped and tells its owner if the drain asked to. It is installed as the
+// scheduler's drain hook.
+func (h *Handler) DeploymentDrained(d *protocol.Deployment) {
+	if h.tunnelClient != nil && h.tunnelClient.IsConnected() {
+		h.tunnelClient.UnregisterDeployment(d.ID)
+	}
+	h.scheduler.SetExposedURL(d.ID, "")
+
+	state := h.scheduler.DrainStatus()
+	if !state.Notify {
+		return
+	}
+	go h.notify(d.RequesterID, protocol.Notification{
+		DeploymentID: d.ID,
+		Status:       d.Status,
+		Reason:       d.Reason,
+		Message:      drainMessage(state, d.Error),
+		Timestamp:    time.Now().UnixNano(),
+	})
+}
+
+// drainMessage appends the operator's reason for a drain to a message.
+func drainMessage(state scheduler.DrainState, message string) string {
+	if state.Message == "" {
+		return message
+	}
+	return fmt.Sprintf("%s (%s)", message, state.Message)
+}
+
+// sendDraining refuses a deployment because the provider is draining.
+func sendDraining(w io.Writer) {
+	writeJSON(w, protocol.DeployResponse{
+		Success: false,
+		Message: scheduler.ErrCordoned.Error(),
+		Error:   scheduler.ErrCordoned.Error(),
+		Code:    protocol.ErrCodeDraining,
+	})
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+func TestDeployRefusedWhileDraining(t *testing.T) {
+	h, sched, _, requester := newTestHandler(t)
+	sched.Cordon(time.Hour, "kernel upgrade", false)
+
+	req := protocol.DeployRequest{
+		Image:         "nginx:1.27",
+		CPUMillicores: 500,
+		MemoryBytes:   64 * 1024 * 1024,
+	}
+	if err := protocol.SignDeployRequest(&req, requester); err != nil {
+		t.Fatal(err)
+	}
+	var resp protocol.DeployResponse
+	call(t, h.handleDeploy, requester.PeerID, req, &resp)
+
+	if resp.Success || resp.Code != protocol.ErrCodeDraining {
+		t.Errorf("response = %+v, want refused with %s", resp, protocol.ErrCodeDraining)
+	}
+	if n := len(sched.List()); n != 0 {
+		t.Errorf("%d deployments scheduled while draining", n)
+	}
+}
+
+func TestUpdateRefusedWhileDraining(t *testing.T) {
+	h, sched, rt, requester := newTestHandler(t)
+	d := schedule(t, sched, rt, requester.PeerID)
+	sched.Cordon(time.Hour, "", false)
+
+	req := protocol.UpdateRequest{DeploymentID: d.ID, Image: "nginx:1.28"}
+	if err := protocol.SignUpdateRequest(&req, requester); err != nil {
+		t.Fatal(err)
+	}
+	var resp protocol.UpdateResponse
+	call(t, h.handleUpdate, requester.PeerID, req, &resp)
+
+	if resp.Success || resp.Code != protocol.ErrCodeDraining {
+		t.Errorf("response = %+v, want refused with %s", resp, protocol.ErrCodeDraining)
+	}
+	if got, _ := sched.Get(d.ID); got.Image != "nginx:1.27" || got.Status != protocol.StatusRunning {
+		t.Errorf("deployment after refused update = %s running %s", got.Status, got.Image)
+	}
+}
@@ -0,0 +1,80 @@
+// Package handler - Deployment event streams
+package handler
+
+import (
+	"io"
+	"log"
+
+	"github.com/libp2p/go-libp2p/core/network"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+// handleEvents streams lifecycle events of the requester's deployments.
+func (h *Handler) handleEvents(stream network.Stream) {
+	defer stream.Close()
+
+	remotePeer := stream.Conn().RemotePeer()
+	log.Printf("[EVENTS] Request from peer: %s", remotePeer)
+
+	var req protocol.EventsRequest
+	if err := readJSON(stream, &req); err != nil {
+		log.Printf("[EVENTS] Failed to read request: %v", err)
+		writeJSON(stream, protocol.EventsResponse{Error: "invalid request format"})
+		return
+	}
+
+	if !h.trust.IsTrusted(remotePeer) {
+		log.Printf("[EVENTS] Untrusted peer rejected: %s", remotePeer)
+		writeJSON(stream, protocol.EventsResponse{Error: "not trusted"})
+		return
+	}
+
+	// SECURITY: Peers only see events of their own deployments
+	owner := remotePeer.String()
+	// An empty ID means all deployments; resolving it would match an
+	// unnamed one
+	if req.DeploymentID != "" {
+		if d, ok := h.scheduler.Resolve(owner, req.DeploymentID); ok {
+			req.DeploymentID = d.ID
+		}
+	}
+	recent, events, cancel := h.scheduler.Events().Subscribe(func(e *protocol.DeploymentEvent) bool {
+		return e.RequesterID == owner && (req.DeploymentID == "" || e.DeploymentID == req.DeploymentID)
+	})
+	defer cancel()
+
+	if err := writeJSON(stream, protocol.EventsResponse{Success: true}); err != nil {
+		return
+	}
+	for _, e := range recent {
+		if err := writeJSON(stream, e); err != nil {
+			return
+		}
+	}
+	if !req.Follow {
+		return
+	}
+
+	// The requester closes its side when it stops following
+	closed := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, stream)
+		close(closed)
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case e, ok := <-events:
+			if !ok {
+				log.Printf("[EVENTS] Dropping slow subscriber %s", remotePeer)
+				return
+			}
+			if err := writeJSON(stream, e); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/xdas-research/peer-compute/internal/identity"
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+// readEvents asks for the recent events visible to remote and returns the
+// IDs of the deployments they are about.
+func readEvents(t *testing.T, h *Handler, remote peer.ID, req protocol.EventsRequest) []string {
+	t.Helper()
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := &testStream{conn: &testConn{remote: remote}, in: bytes.NewReader(data)}
+	h.handleEvents(stream)
+
+	dec := json.NewDecoder(&stream.out)
+	var resp protocol.EventsResponse
+	if err := dec.Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("events refused: %s", resp.Error)
+	}
+
+	var ids []string
+	for {
+		var e protocol.DeploymentEvent
+		if err := dec.Decode(&e); err == io.EOF {
+			return ids
+		} else if err != nil {
+			t.Fatalf("failed to decode event: %v", err)
+		}
+		if e.RequesterID != remote.String() {
+			t.Errorf("%s received an event of %s's deployment %s", remote, e.RequesterID, e.DeploymentID)
+		}
+		ids = append(ids, e.DeploymentID)
+	}
+}
+
+// onlyAbout reports whether every ID is want, and there is at least one.
+func onlyAbout(ids []string, want string) bool {
+	for _, id := range ids {
+		if id != want {
+			return false
+		}
+	}
+	return len(ids) > 0
+}
+
+func TestEventsFilteredByOwnerAndDeployment(t *testing.T) {
+	h, sched, rt, requester := newTestHandler(t)
+	other, err := identity.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.trust.Add(other.PeerID, "other", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	web, err := sched.Schedule(context.Background(), &protocol.DeployRequest{
+		Image:         "nginx:1.27",
+		Name:          "web",
+		CPUMillicores: 500,
+		MemoryBytes:   64 * 1024 * 1024,
+		RequesterID:   requester.PeerID.String(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mine := schedule(t, sched, rt, requester.PeerID)
+	theirs := schedule(t, sched, rt, other.PeerID)
+
+	all := readEvents(t, h, requester.PeerID, protocol.EventsRequest{})
+	seen := make(map[string]bool)
+	for _, id := range all {
+		seen[id] = true
+	}
+	if !seen[web.ID] || !seen[mine.ID] || seen[theirs.ID] {
+		t.Errorf("requester's events are about %v, want %s and %s only", all, web.ID, mine.ID)
+	}
+
+	if ids := readEvents(t, h, requester.PeerID, protocol.EventsRequest{DeploymentID: mine.ID}); !onlyAbout(ids, mine.ID) {
+		t.Errorf("events of %s = %v", mine.ID, ids)
+	}
+	if ids := readEvents(t, h, requester.PeerID, protocol.EventsRequest{DeploymentID: "web"}); !onlyAbout(ids, web.ID) {
+		t.Errorf("events of web = %v, want only %s", ids, web.ID)
+	}
+
+	// Naming another peer's deployment shows nothing
+	if ids := readEvents(t, h, other.PeerID, protocol.EventsRequest{DeploymentID: mine.ID}); len(ids) != 0 {
+		t.Errorf("other peer saw events of %s: %v", mine.ID, ids)
+	}
+	if ids := readEvents(t, h, other.PeerID, protocol.EventsRequest{}); !onlyAbout(ids, theirs.ID) {
+		t.Errorf("other peer's events = %v, want only %s", ids, theirs.ID)
+	}
+}
@@ -5,36 +5,51 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 
+	"github.com/xdas-research/peer-compute/internal/artifact"
+	"github.com/xdas-research/peer-compute/internal/credit"
 	"github.com/xdas-research/peer-compute/internal/p2p"
 	"github.com/xdas-research/peer-compute/internal/protocol"
 	"github.com/xdas-research/peer-compute/internal/runtime"
 	"github.com/xdas-research/peer-compute/internal/scheduler"
 	"github.com/xdas-research/peer-compute/internal/tunnel"
+	"github.com/xdas-research/peer-compute/internal/usage"
 )
 
 // Handler processes incoming P2P protocol requests.
 type Handler struct {
 	scheduler    *scheduler.Scheduler
-	runtime      *runtime.Runtime
+	runtime      runtime.Runtime
 	trust        *p2p.TrustManager
 	peerID       peer.ID
 	tunnelClient *tunnel.Client
+	limiter      *RateLimiter
+	maxLease     time.Duration
+	host         *p2p.Host
+	inboxPath    string
+	artifacts    *artifact.Store
+	usage        *usage.Ledger
+	credits      *credit.Ledger
+	creditLimit  float64
+	policy       *runtime.SecurityPolicy
 }
 
 // NewHandler creates a new protocol handler.
-func NewHandler(sched *scheduler.Scheduler, rt *runtime.Runtime, trust *p2p.TrustManager, peerID peer.ID) *Handler {
+func NewHandler(sched *scheduler.Scheduler, rt runtime.Runtime, trust *p2p.TrustManager, peerID peer.ID) *Handler {
 	return &Handler{
 		scheduler: sched,
 		runtime:   rt,
 		trust:     trust,
 		peerID:    peerID,
+		limiter:   NewRateLimiter(nil),
 	}
 }
 
@@ -43,12 +58,176 @@ func (h *Handler) SetTunnelClient(tc *tunnel.Client) {
 	h.tunnelClient = tc
 }
 
+// RestoreRoutes re-registers exposed deployments with the gateway, e.g. after
+// the scheduler re-adopted them on daemon restart.
+func (h *Handler) RestoreRoutes() {
+	if h.tunnelClient == nil || !h.tunnelClient.IsConnected() {
+		return
+	}
+
+	for _, d := range h.scheduler.List() {
+		if d.ExposePort <= 0 || routeHeld(d) {
+			continue
+		}
+		if _, err := h.registerRoute(d); err != nil {
+			log.Printf("[DEPLOY] Warning: failed to restore gateway route for %s: %v", d.ID, err)
+		}
+	}
+}
+
+// registerRoute registers an exposed deployment with the gateway. Replicas
+// join the route of their replica set.
+func (h *Handler) registerRoute(d *protocol.Deployment) (string, error) {
+	url, err := h.tunnelClient.RegisterDeployment(tunnel.Registration{
+		DeploymentID: d.ID,
+		Name:         d.Name,
+		ReplicaSet:   d.ReplicaSet,
+		RequesterID:  d.RequesterID,
+		Port:         d.ExposePort,
+	})
+	if err != nil {
+		return "", err
+	}
+	h.scheduler.SetExposedURL(d.ID, url)
+	return url, nil
+}
+
+// HealthChanged registers a deployment whose gateway route was held until
+// its first successful health check, and takes exposed deployments out of
+// the gateway's rotation while they are unhealthy. It is installed as the
+// scheduler's health hook.
+func (h *Handler) HealthChanged(d *protocol.Deployment, prev protocol.HealthState) {
+	if d.ExposePort <= 0 || h.tunnelClient == nil || !h.tunnelClient.IsConnected() {
+		return
+	}
+
+	switch {
+	case d.Health == protocol.HealthHealthy && prev == protocol.HealthStarting:
+		if d.HealthCheck == nil || !d.HealthCheck.HoldRoute {
+			return
+		}
+		url, err := h.registerRoute(d)
+		if err != nil {
+			log.Printf("[DEPLOY] Warning: failed to register %s with gateway: %v", d.ID, err)
+			return
+		}
+		log.Printf("[DEPLOY] Deployment %s is healthy, public URL: %s", d.ID, url)
+
+	case d.Health == protocol.HealthHealthy || d.Health == protocol.HealthUnhealthy:
+		healthy := d.Health == protocol.HealthHealthy
+		if err := h.tunnelClient.SetHealth(d.ID, healthy); err != nil {
+			log.Printf("[DEPLOY] Warning: failed to update gateway health of %s: %v", d.ID, err)
+		}
+	}
+}
+
+// classAllowed reports whether a peer may deploy with a priority class.
+func (h *Handler) classAllowed(p peer.ID, class protocol.PriorityClass) bool {
+	if class == "" {
+		class = protocol.ClassNormal
+	}
+
+	allowed := protocol.DefaultAllowedClasses
+	if tp, ok := h.trust.Get(p); ok && len(tp.PriorityClasses) > 0 {
+		allowed = nil
+		for _, c := range tp.PriorityClasses {
+			allowed = append(allowed, protocol.PriorityClass(c))
+		}
+	}
+
+	for _, c := range allowed {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// DeploymentStarted registers a deployment that waited in the admission
+// queue with the gateway once it starts. It is installed as the scheduler's
+// start hook.
+func (h *Handler) DeploymentStarted(d *protocol.Deployment) {
+	if d.ExposePort <= 0 || routeHeld(d) {
+		return
+	}
+	if h.tunnelClient == nil || !h.tunnelClient.IsConnected() {
+		return
+	}
+
+	url, err := h.registerRoute(d)
+	if err != nil {
+		log.Printf("[DEPLOY] Warning: failed to register %s with gateway: %v", d.ID, err)
+		return
+	}
+	log.Printf("[DEPLOY] Queued deployment %s started, public URL: %s", d.ID, url)
+}
+
+// routeHeld reports whether a deployment's gateway route is being held until
+// its health check first succeeds.
+func routeHeld(d *protocol.Deployment) bool {
+	return d.HealthCheck != nil && d.HealthCheck.HoldRoute && d.Health != protocol.HealthHealthy
+}
+
+// SetRateLimiter replaces the default per-peer rate limiter.
+func (h *Handler) SetRateLimiter(rl *RateLimiter) {
+	h.limiter = rl
+}
+
+// SetMaxLease sets the default maximum lease for peers without their own
+// limit in the trust list (0 = unlimited).
+func (h *Handler) SetMaxLease(d time.Duration) {
+	h.maxLease = d
+}
+
+// leaseFor returns the lease granted to a peer that requested the given lease.
+// Requests without a lease, or above the peer's maximum, get the maximum.
+func (h *Handler) leaseFor(p peer.ID, requested time.Duration) time.Duration {
+	max := h.maxLease
+	if tp, ok := h.trust.Get(p); ok && tp.MaxLease() > 0 {
+		max = tp.MaxLease()
+	}
+	if max > 0 && (requested <= 0 || requested > max) {
+		return max
+	}
+	return requested
+}
+
 // RegisterHandlers registers all protocol handlers on the host.
 func (h *Handler) RegisterHandlers(host *p2p.Host) {
-	host.SetStreamHandler("/peercompute/deploy/1.0.0", h.handleDeploy)
-	host.SetStreamHandler("/peercompute/logs/1.0.0", h.handleLogs)
-	host.SetStreamHandler("/peercompute/status/1.0.0", h.handleStatus)
-	host.SetStreamHandler("/peercompute/stop/1.0.0", h.handleStop)
+	h.host = host
+
+	host.SetStreamHandler(protocol.DeployProtocol, h.limited(protocol.DeployProtocol, h.handleDeploy))
+	host.SetStreamHandler(protocol.LogProtocol, h.limited(protocol.LogProtocol, h.handleLogs))
+	host.SetStreamHandler(protocol.StatusProtocol, h.limited(protocol.StatusProtocol, h.handleStatus))
+	host.SetStreamHandler(protocol.StopProtocol, h.limited(protocol.StopProtocol, h.handleStop))
+	host.SetStreamHandler(protocol.RenewProtocol, h.limited(protocol.RenewProtocol, h.handleRenew))
+	host.SetStreamHandler(protocol.NotifyProtocol, h.limited(protocol.NotifyProtocol, h.handleNotify))
+	host.SetStreamHandler(protocol.ArtifactProtocol, h.limited(protocol.ArtifactProtocol, h.handleArtifact))
+	host.SetStreamHandler(protocol.CronProtocol, h.limited(protocol.CronProtocol, h.handleCron))
+	host.SetStreamHandler(protocol.EventsProtocol, h.limited(protocol.EventsProtocol, h.handleEvents))
+	host.SetStreamHandler(protocol.UpdateProtocol, h.limited(protocol.UpdateProtocol, h.handleUpdate))
+	host.SetStreamHandler(protocol.UsageProtocol, h.limited(protocol.UsageProtocol, h.handleUsage))
+	host.SetStreamHandler(protocol.ReceiptProtocol, h.limited(protocol.ReceiptProtocol, h.handleReceipt))
+}
+
+// limited wraps a stream handler with per-peer stream and request rate limits.
+// SECURITY: Prevents a buggy or compromised trusted peer from exhausting host
+// resources with unbounded pulls, log followers or status queries.
+func (h *Handler) limited(proto string, next network.StreamHandler) network.StreamHandler {
+	return func(stream network.Stream) {
+		remotePeer := stream.Conn().RemotePeer()
+
+		release, err := h.limiter.Acquire(remotePeer, proto)
+		if err != nil {
+			log.Printf("[LIMIT] Refused %s from %s: %v", proto, remotePeer, err)
+			sendRateLimited(stream, err)
+			stream.Close()
+			return
+		}
+		defer release()
+
+		next(stream)
+	}
 }
 
 // handleDeploy processes deployment requests.
@@ -75,54 +254,203 @@ func (h *Handler) handleDeploy(stream network.Stream) {
 		return
 	}
 
-	// Pull image
+	if h.scheduler.DrainStatus().Cordoned {
+		log.Printf("[DEPLOY] Refusing deployment from %s: draining", remotePeer)
+		sendDraining(stream)
+		return
+	}
+
+	if err := h.checkCredit(remotePeer); err != nil {
+		log.Printf("[DEPLOY] Peer %s is over its credit limit", remotePeer)
+		writeJSON(stream, protocol.DeployResponse{
+			Message: err.Error(),
+			Error:   err.Error(),
+			Code:    protocol.ErrCodeInsufficientCredit,
+		})
+		return
+	}
+
+	if req.Name != "" {
+		if err := protocol.ValidateDeploymentName(req.Name); err != nil {
+			sendError(stream, err.Error())
+			return
+		}
+	}
+
+	if err := req.ValidateReplicas(); err != nil {
+		sendError(stream, err.Error())
+		return
+	}
+
+	if req.RestartPolicy != nil {
+		if err := req.RestartPolicy.Validate(); err != nil {
+			sendError(stream, err.Error())
+			return
+		}
+	}
+
+	if req.Job != nil {
+		if err := req.Job.Validate(); err != nil {
+			sendError(stream, err.Error())
+			return
+		}
+		if req.RestartPolicy != nil {
+			sendError(stream, "jobs use a retry count instead of a restart policy")
+			return
+		}
+		if err := h.checkJobArtifacts(remotePeer, req.Job); err != nil {
+			sendError(stream, err.Error())
+			return
+		}
+	}
+
+	if hc := req.HealthCheck; hc != nil {
+		if err := hc.Validate(); err != nil {
+			sendError(stream, err.Error())
+			return
+		}
+		if hc.Type != protocol.HealthCheckExec && hc.TargetPort(req.ExposePort) == 0 {
+			sendError(stream, "health check requires a port (set a health check port or expose a port)")
+			return
+		}
+	}
+
+	// SECURITY: Higher classes can preempt other peers' deployments, so
+	// each peer may only use the classes the provider granted it
+	if err := req.PriorityClass.Validate(); err != nil {
+		sendError(stream, err.Error())
+		return
+	}
+	if !h.classAllowed(remotePeer, req.PriorityClass) {
+		log.Printf("[DEPLOY] Peer %s may not use priority class %s", remotePeer, req.PriorityClass)
+		sendError(stream, fmt.Sprintf("priority class %q is not allowed for this peer", req.PriorityClass))
+		return
+	}
+
+	if req.LeaseSeconds < 0 {
+		sendError(stream, "lease must not be negative")
+		return
+	}
+	lease := h.leaseFor(remotePeer, time.Duration(req.LeaseSeconds)*time.Second)
+
+	if err := h.checkPolicy(req.Image, req.CPUMillicores, req.MemoryBytes); err != nil {
+		log.Printf("[DEPLOY] Refusing deployment from %s: %v", remotePeer, err)
+		writeJSON(stream, protocol.DeployResponse{
+			Message: err.Error(),
+			Error:   err.Error(),
+			Code:    protocol.ErrCodePolicyViolation,
+		})
+		return
+	}
+
+	// Resolve the tag now, so every replica runs the same image even if the
+	// tag moves while they start or restart
 	ctx := context.Background()
-	log.Printf("[DEPLOY] Pulling image: %s", req.Image)
-	if err := h.runtime.Pull(ctx, req.Image); err != nil {
-		log.Printf("[DEPLOY] Image pull failed: %v", err)
-		sendError(stream, fmt.Sprintf("failed to pull image: %v", err))
+	digest, err := h.resolveDigest(ctx, req.Image)
+	if err != nil {
+		log.Printf("[DEPLOY] Refusing deployment from %s: %v", remotePeer, err)
+		writeJSON(stream, protocol.DeployResponse{
+			Message: err.Error(),
+			Error:   err.Error(),
+			Code:    protocol.ErrCodePolicyViolation,
+		})
 		return
 	}
 
-	// Schedule and run via scheduler
-	result, err := h.scheduler.Schedule(ctx, &protocol.DeployRequest{
-		RequestID:     req.RequestID,
-		Image:         req.Image,
-		CPUMillicores: req.CPUMillicores,
-		MemoryBytes:   req.MemoryBytes,
-		ExposePort:    req.ExposePort,
-		Environment:   req.Environment,
-		RequesterID:   remotePeer.String(),
+	// Schedule and run via scheduler. The image is pulled when each replica
+	// is launched, so a queued request pulls nothing until it is admitted.
+	replicas, err := h.scheduler.ScheduleReplicas(ctx, &protocol.DeployRequest{
+		RequestID:           req.RequestID,
+		Image:               req.Image,
+		ImageDigest:         digest,
+		Name:                req.Name,
+		Replicas:            req.Replicas,
+		ReplicaSet:          req.ReplicaSet,
+		CPUMillicores:       req.CPUMillicores,
+		MemoryBytes:         req.MemoryBytes,
+		ExposePort:          req.ExposePort,
+		Environment:         req.Environment,
+		RestartPolicy:       req.RestartPolicy,
+		HealthCheck:         req.HealthCheck,
+		LeaseSeconds:        int64(lease.Seconds()),
+		PriorityClass:       req.PriorityClass,
+		Job:                 req.Job,
+		Priority:            req.Priority,
+		QueueTimeoutSeconds: req.QueueTimeoutSeconds,
+		RequesterID:         remotePeer.String(),
 	})
-
+	if err == nil && len(replicas) == 0 {
+		err = fmt.Errorf("deployment was stopped while starting")
+	}
+	if errors.Is(err, scheduler.ErrCordoned) {
+		sendDraining(stream)
+		return
+	}
 	if err != nil {
 		log.Printf("[DEPLOY] Scheduling failed: %v", err)
 		sendError(stream, err.Error())
 		return
 	}
 
-	log.Printf("[DEPLOY] Success! Deployment: %s, Container: %s", result.ID, result.ContainerID[:12])
-
-	// Register with gateway if tunnel client is available and port is exposed
+	// Register with gateway if tunnel client is available and port is exposed.
+	// Held routes are registered by HealthChanged once the container is
+	// healthy, and queued replicas by DeploymentStarted once they start.
 	var exposedURL string
-	if h.tunnelClient != nil && h.tunnelClient.IsConnected() && req.ExposePort > 0 {
-		log.Printf("[DEPLOY] Registering with gateway for public access...")
-		url, err := h.tunnelClient.RegisterDeployment(result.ID, req.ExposePort)
-		if err != nil {
-			log.Printf("[DEPLOY] Warning: failed to register with gateway: %v", err)
-		} else {
-			exposedURL = url
-			log.Printf("[DEPLOY] Public URL: %s", exposedURL)
+	var queued int
+	resp := protocol.DeployResponse{
+		Success:    true,
+		ReplicaSet: replicas[0].ReplicaSet,
+	}
+	for _, result := range replicas {
+		resp.Replicas = append(resp.Replicas, protocol.ReplicaInfo{
+			DeploymentID:  result.ID,
+			Status:        result.Status,
+			ContainerID:   result.ContainerID,
+			QueuePosition: result.QueuePosition,
+		})
+
+		if result.Status == protocol.StatusQueued {
+			log.Printf("[DEPLOY] Queued deployment %s at position %d", result.ID, result.QueuePosition)
+			queued++
+			continue
+		}
+		log.Printf("[DEPLOY] Success! Deployment: %s, Container: %s", result.ID, result.ContainerID)
+
+		if req.ExposePort > 0 && !routeHeld(result) && h.tunnelClient != nil && h.tunnelClient.IsConnected() {
+			log.Printf("[DEPLOY] Registering with gateway for public access...")
+			url, err := h.registerRoute(result)
+			if err != nil {
+				log.Printf("[DEPLOY] Warning: failed to register with gateway: %v", err)
+			} else {
+				exposedURL = url
+				log.Printf("[DEPLOY] Public URL: %s", exposedURL)
+			}
 		}
 	}
 
-	// Send response
-	resp := protocol.DeployResponse{
-		Success:      true,
-		DeploymentID: result.ID,
-		ContainerID:  result.ContainerID,
-		ExposedURL:   exposedURL,
-		Message:      "Container deployed successfully",
+	first := replicas[0]
+	resp.DeploymentID = first.ID
+	resp.Status = first.Status
+	resp.ContainerID = first.ContainerID
+	resp.QueuePosition = first.QueuePosition
+	resp.LeaseExpiresAt = first.LeaseExpiresAt
+	resp.ExposedURL = exposedURL
+
+	switch {
+	case queued == len(replicas) && len(replicas) == 1:
+		// The provider is full; the deployment starts when resources free up
+		resp.Message = fmt.Sprintf("Provider is full; queued at position %d", first.QueuePosition)
+	case queued > 0:
+		resp.Message = fmt.Sprintf("%d of %d replicas started; the rest are queued until the provider has capacity",
+			len(replicas)-queued, len(replicas))
+	case req.Job != nil:
+		resp.Message = "Job started"
+	case req.ExposePort > 0 && req.HealthCheck != nil && req.HealthCheck.HoldRoute:
+		resp.Message = "Container deployed; public URL will be registered after the first successful health check"
+	case len(replicas) > 1:
+		resp.Message = fmt.Sprintf("%d replicas deployed successfully", len(replicas))
+	default:
+		resp.Message = "Container deployed successfully"
 	}
 	writeJSON(stream, resp)
 }
@@ -146,7 +474,7 @@ func (h *Handler) handleLogs(stream network.Stream) {
 	}
 
 	// Get deployment
-	deployment, ok := h.scheduler.Get(req.DeploymentID)
+	deployment, ok := h.scheduler.Resolve(remotePeer.String(), req.DeploymentID)
 	if !ok {
 		log.Printf("[LOGS] Deployment not found: %s", req.DeploymentID)
 		return
@@ -179,32 +507,17 @@ func (h *Handler) handleStatus(stream network.Stream) {
 		return
 	}
 
-	var resp protocol.StatusResponse
-
+	// SECURITY: Peers only see their own deployments
+	var deployments []*protocol.Deployment
 	if req.DeploymentID != "" {
-		// Single deployment status
-		deployment, ok := h.scheduler.Get(req.DeploymentID)
-		if ok {
-			resp.Deployments = []protocol.DeploymentStatusInfo{{
-				DeploymentID: deployment.ID,
-				Status:       string(deployment.Status),
-				Image:        deployment.Image,
-				StartedAt:    deployment.StartedAt,
-			}}
-		}
+		// Single deployment status, by ID or by the requester's name for it.
+		// A name returns every replica with it
+		deployments = h.scheduler.ResolveAll(remotePeer.String(), req.DeploymentID)
 	} else {
-		// All deployments
-		for _, d := range h.scheduler.List() {
-			resp.Deployments = append(resp.Deployments, protocol.DeploymentStatusInfo{
-				DeploymentID: d.ID,
-				Status:       string(d.Status),
-				Image:        d.Image,
-				StartedAt:    d.StartedAt,
-			})
-		}
+		deployments = h.scheduler.ListByRequester(remotePeer.String())
 	}
 
-	writeJSON(stream, resp)
+	writeJSON(stream, protocol.StatusResponse{Deployments: h.statusInfos(deployments)})
 }
 
 // handleStop stops a deployment.
@@ -222,31 +535,143 @@ func (h *Handler) handleStop(stream network.Stream) {
 		return
 	}
 
-	// Unregister from gateway if tunnel client is available
-	if h.tunnelClient != nil && h.tunnelClient.IsConnected() {
-		h.tunnelClient.UnregisterDeployment(req.DeploymentID)
+	// SECURITY: Only the deployment's owner may stop it; names are resolved
+	// among the owner's deployments. A name stops every replica with it
+	deployments := h.scheduler.ResolveAll(remotePeer.String(), req.DeploymentID)
+	if len(deployments) == 0 {
+		sendError(stream, fmt.Sprintf("deployment %s not found", req.DeploymentID))
+		return
 	}
 
-	// Stop via scheduler
 	ctx := context.Background()
-	if err := h.scheduler.Stop(ctx, req.DeploymentID); err != nil {
-		log.Printf("[STOP] Failed to stop: %v", err)
-		sendError(stream, fmt.Sprintf("failed to stop: %v", err))
-		return
-	}
+	for _, deployment := range deployments {
+		// Unregister from gateway if tunnel client is available
+		if h.tunnelClient != nil && h.tunnelClient.IsConnected() {
+			h.tunnelClient.UnregisterDeployment(deployment.ID)
+		}
 
-	log.Printf("[STOP] Stopped deployment: %s", req.DeploymentID)
+		// Stop via scheduler
+		if err := h.scheduler.Stop(ctx, deployment.ID); err != nil {
+			log.Printf("[STOP] Failed to stop: %v", err)
+			sendError(stream, fmt.Sprintf("failed to stop %s: %v", deployment.ID, err))
+			return
+		}
 
+		log.Printf("[STOP] Stopped deployment: %s", deployment.ID)
+	}
+
+	message := "Container stopped"
+	if len(deployments) > 1 {
+		message = fmt.Sprintf("%d replicas stopped", len(deployments))
+	}
 	resp := protocol.StopResponse{
 		Success:      true,
-		DeploymentID: req.DeploymentID,
-		Message:      "Container stopped",
+		DeploymentID: deployments[0].ID,
+		Message:      message,
 	}
 	writeJSON(stream, resp)
 }
 
+// handleRenew extends a deployment's lease.
+func (h *Handler) handleRenew(stream network.Stream) {
+	defer stream.Close()
+
+	remotePeer := stream.Conn().RemotePeer()
+	log.Printf("[RENEW] Request from peer: %s", remotePeer)
+
+	var req protocol.RenewRequest
+	if err := readJSON(stream, &req); err != nil {
+		log.Printf("[RENEW] Failed to read request: %v", err)
+		sendError(stream, "invalid request format")
+		return
+	}
+
+	// SECURITY: The renewal must be signed by the connected peer, and only
+	// the deployment's owner may renew it
+	if req.RequesterID != remotePeer.String() {
+		sendError(stream, "requester does not match connection")
+		return
+	}
+	if err := protocol.VerifyRenewRequest(&req); err != nil {
+		log.Printf("[RENEW] Invalid request from %s: %v", remotePeer, err)
+		sendError(stream, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	// A name renews every replica with it
+	deployments := h.scheduler.ResolveAll(remotePeer.String(), req.DeploymentID)
+	if len(deployments) == 0 {
+		sendError(stream, fmt.Sprintf("deployment %s not found", req.DeploymentID))
+		return
+	}
+
+	requested := time.Duration(req.LeaseSeconds) * time.Second
+	lease := h.leaseFor(remotePeer, requested)
+	var expiresAt time.Time
+	for _, d := range deployments {
+		var err error
+		expiresAt, err = h.scheduler.Renew(d.ID, lease)
+		if err != nil {
+			log.Printf("[RENEW] Failed to renew: %v", err)
+			sendError(stream, fmt.Sprintf("failed to renew %s: %v", d.ID, err))
+			return
+		}
+	}
+
+	message := "Lease renewed"
+	if len(deployments) > 1 {
+		message = fmt.Sprintf("Lease of %d replicas renewed", len(deployments))
+	}
+	if lease < requested {
+		message = fmt.Sprintf("%s (capped at the provider maximum of %s)", message, lease)
+	}
+
+	writeJSON(stream, protocol.RenewResponse{
+		DeploymentID:   deployments[0].ID,
+		Success:        true,
+		LeaseExpiresAt: &expiresAt,
+		Message:        message,
+	})
+}
+
 // Helper functions
 
+// statusInfo converts a deployment record into a status response entry.
+func statusInfo(d *protocol.Deployment) protocol.DeploymentStatusInfo {
+	return protocol.DeploymentStatusInfo{
+		DeploymentID:    d.ID,
+		Name:            d.Name,
+		ReplicaSet:      d.ReplicaSet,
+		Status:          string(d.Status),
+		Image:           d.Image,
+		ImageDigest:     d.ImageDigest,
+		StartedAt:       d.StartedAt,
+		CPULimit:        d.CPULimit,
+		MemoryLimit:     d.MemoryLimit,
+		ExposePort:      d.ExposePort,
+		ExposedURL:      exposedURL(d),
+		Error:           d.Error,
+		ExitCode:        d.ExitCode,
+		Reason:          d.Reason,
+		FinishedAt:      d.StoppedAt,
+		RestartCount:    d.RestartCount,
+		LastExitReason:  d.LastExitReason,
+		NextRestartAt:   d.NextRestartAt,
+		Health:          d.Health,
+		HealthCheckedAt: d.HealthCheckedAt,
+		HealthError:     d.HealthError,
+		LeaseExpiresAt:  d.LeaseExpiresAt,
+		QueuePosition:   d.QueuePosition,
+		QueueDeadline:   d.QueueDeadline,
+		PriorityClass:   d.PriorityClass,
+		Job:             d.Job != nil,
+		Outputs:         d.Outputs,
+		CronID:          d.CronID,
+		Revision:        d.Revision,
+		Revisions:       revisionSummaries(d.Revisions),
+		UpdatingTo:      updatingTo(d),
+	}
+}
+
 func readJSON(r io.Reader, v interface{}) error {
 	decoder := json.NewDecoder(r)
 	return decoder.Decode(v)
@@ -257,6 +682,19 @@ func writeJSON(w io.Writer, v interface{}) error {
 	return encoder.Encode(v)
 }
 
+func sendRateLimited(w io.Writer, err error) {
+	resp := protocol.ErrorResponse{
+		Success: false,
+		Code:    protocol.ErrCodeRateLimited,
+		Message: err.Error(),
+		Error:   err.Error(),
+	}
+	if rle, ok := err.(*RateLimitError); ok {
+		resp.RetryAfterMs = rle.RetryAfter.Milliseconds()
+	}
+	writeJSON(w, resp)
+}
+
 func sendError(w io.Writer, message string) {
 	resp := protocol.DeployResponse{
 		Success: false,
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/xdas-research/peer-compute/internal/identity"
+	"github.com/xdas-research/peer-compute/internal/p2p"
+	"github.com/xdas-research/peer-compute/internal/protocol"
+	"github.com/xdas-research/peer-compute/internal/runtime"
+	"github.com/xdas-research/peer-compute/internal/scheduler"
+)
+
+// testConn is a connection from a remote peer. Methods the handlers don't
+// use are left to the nil embedded interface.
+type testConn struct {
+	network.Conn
+	remote peer.ID
+}
+
+func (c *testConn) RemotePeer() peer.ID { return c.remote }
+
+// testStream is a stream carrying one request from a remote peer and
+// collecting the handler's response.
+type testStream struct {
+	network.Stream
+	conn *testConn
+	in   *bytes.Reader
+	out  bytes.Buffer
+}
+
+func (s *testStream) Read(p []byte) (int, error)  { return s.in.Read(p) }
+func (s *testStream) Write(p []byte) (int, error) { return s.out.Write(p) }
+func (s *testStream) Close() error                { return nil }
+func (s *testStream) Conn() network.Conn          { return s.conn }
+
+// call sends req from remote to a stream handler and decodes its response
+// into resp.
+func call(t *testing.T, handle network.StreamHandler, remote peer.ID, req, resp any) {
+	t.Helper()
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := &testStream{conn: &testConn{remote: remote}, in: bytes.NewReader(data)}
+	handle(stream)
+	if err := json.Unmarshal(stream.out.Bytes(), resp); err != nil {
+		t.Fatalf("failed to decode response %q: %v", stream.out.String(), err)
+	}
+}
+
+// newTestHandler returns a handler for a scheduler on a fake runtime, and
+// the identity of a peer it trusts.
+func newTestHandler(t *testing.T) (*Handler, *scheduler.Scheduler, *runtime.Fake, *identity.Identity) {
+	t.Helper()
+	return newTestHandlerWithConfig(t, nil)
+}
+
+// newTestHandlerWithConfig is newTestHandler with a scheduler configuration.
+func newTestHandlerWithConfig(t *testing.T, cfg *scheduler.Config) (*Handler, *scheduler.Scheduler, *runtime.Fake, *identity.Identity) {
+	t.Helper()
+	dir := t.TempDir()
+	if cfg == nil {
+		cfg = scheduler.DefaultConfig()
+	}
+	cfg.StateDir = dir
+	rt := runtime.NewFake()
+	sched := scheduler.NewScheduler(rt, cfg)
+
+	requester, err := identity.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	trust := p2p.NewTrustManager(filepath.Join(dir, "trusted_peers.json"))
+	if err := trust.Add(requester.PeerID, "requester", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	provider, err := identity.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewHandler(sched, rt, trust, provider.PeerID), sched, rt, requester
+}
+
+// schedule runs an nginx deployment owned by a peer.
+func schedule(t *testing.T, sched *scheduler.Scheduler, rt *runtime.Fake, owner peer.ID) *protocol.Deployment {
+	t.Helper()
+	ctx := context.Background()
+	if err := rt.Pull(ctx, "nginx:1.27"); err != nil {
+		t.Fatal(err)
+	}
+	d, err := sched.Schedule(ctx, &protocol.DeployRequest{
+		Image:         "nginx:1.27",
+		CPUMillicores: 500,
+		MemoryBytes:   64 * 1024 * 1024,
+		RequesterID:   owner.String(),
+	})
+	if err != nil {
+		t.Fatalf("Schedule() error = %v", err)
+	}
+	return d
+}
@@ -0,0 +1,173 @@
+// Package handler - Notifications to deployment owners
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/xdas-research/peer-compute/internal/p2p"
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+const (
+	// NotificationsFileName is the inbox of notifications received from providers
+	NotificationsFileName = "notifications.json"
+
+	// MaxNotifications is how many received notifications the inbox keeps
+	MaxNotifications = 100
+
+	// NotifyTimeout bounds delivery of a single notification
+	NotifyTimeout = 15 * time.Second
+)
+
+// ReceivedNotification is a notification stored in the inbox.
+type ReceivedNotification struct {
+	// ProviderID is the peer that sent the notification
+	ProviderID string `json:"provider_id"`
+
+	// ReceivedAt is when the notification arrived
+	ReceivedAt time.Time `json:"received_at"`
+
+	protocol.Notification
+}
+
+// inboxMu serializes inbox file updates.
+var inboxMu sync.Mutex
+
+// SetNotificationInbox sets the file received notifications are appended to.
+func (h *Handler) SetNotificationInbox(path string) {
+	h.inboxPath = path
+}
+
+// NotifyPreempted tells a deployment's owner that it was preempted. It is
+// installed as the scheduler's preempt hook. Delivery is best effort: the
+// owner only receives it while their own daemon is reachable, and the
+// reason is always visible in status.
+func (h *Handler) NotifyPreempted(d *protocol.Deployment) {
+	go h.notify(d.RequesterID, protocol.Notification{
+		DeploymentID: d.ID,
+		Status:       d.Status,
+		Reason:       d.Reason,
+		Message:      d.Error,
+		Timestamp:    time.Now().UnixNano(),
+	})
+}
+
+// notify delivers a notification to a peer.
+func (h *Handler) notify(peerIDStr string, n protocol.Notification) {
+	if h.host == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), NotifyTimeout)
+	defer cancel()
+
+	stream, err := h.openStream(ctx, peerIDStr, protocol.NotifyProtocol)
+	if err != nil {
+		log.Printf("[NOTIFY] Could not notify %s about %s: %v", peerIDStr, n.DeploymentID, err)
+		return
+	}
+	defer stream.Close()
+
+	if err := writeJSON(stream, n); err != nil {
+		log.Printf("[NOTIFY] Failed to send notification to %s: %v", peerIDStr, err)
+	}
+}
+
+// openStream opens a stream to a peer, dialing its known addresses from the
+// trust list if we're not connected.
+func (h *Handler) openStream(ctx context.Context, peerIDStr string, proto string) (network.Stream, error) {
+	peerID, err := peer.Decode(peerIDStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer ID: %w", err)
+	}
+
+	if !h.host.IsConnected(peerID) {
+		if tp, ok := h.trust.Get(peerID); ok && len(tp.Addresses) > 0 {
+			if pi, err := p2p.ParseAddrInfo(peerIDStr, tp.Addresses); err == nil {
+				h.host.Connect(ctx, pi)
+			}
+		}
+	}
+
+	return h.host.NewStream(ctx, peerID, proto)
+}
+
+// handleNotify stores a notification from a provider in the inbox.
+func (h *Handler) handleNotify(stream network.Stream) {
+	defer stream.Close()
+
+	remotePeer := stream.Conn().RemotePeer()
+
+	var n protocol.Notification
+	if err := readJSON(stream, &n); err != nil {
+		log.Printf("[NOTIFY] Failed to read notification: %v", err)
+		return
+	}
+
+	log.Printf("[NOTIFY] Deployment %s on %s is %s (%s): %s",
+		n.DeploymentID, remotePeer, n.Status, n.Reason, n.Message)
+
+	if h.inboxPath == "" {
+		return
+	}
+	entry := ReceivedNotification{
+		ProviderID:   remotePeer.String(),
+		ReceivedAt:   time.Now(),
+		Notification: n,
+	}
+	if err := appendNotification(h.inboxPath, entry); err != nil {
+		log.Printf("[NOTIFY] Failed to store notification: %v", err)
+	}
+}
+
+// LoadNotifications reads the notification inbox.
+func LoadNotifications(path string) ([]ReceivedNotification, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notifications: %w", err)
+	}
+
+	var entries []ReceivedNotification
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse notifications: %w", err)
+	}
+	return entries, nil
+}
+
+// appendNotification adds an entry to the inbox, keeping the newest
+// MaxNotifications.
+func appendNotification(path string, entry ReceivedNotification) error {
+	inboxMu.Lock()
+	defer inboxMu.Unlock()
+
+	entries, err := LoadNotifications(path)
+	if err != nil {
+		return err
+	}
+	entries = append(entries, entry)
+	if len(entries) > MaxNotifications {
+		entries = entries[len(entries)-MaxNotifications:]
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal notifications: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	return os.WriteFile(path, data, 0600)
+}
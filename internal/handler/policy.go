@@ -0,0 +1,54 @@
+// Package handler - Security policy checks
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/xdas-research/peer-compute/internal/runtime"
+)
+
+// SetSecurityPolicy sets the security policy requests are checked against
+// before any image is pulled. The runtime enforces the same policy on every
+// container; checking early gives requesters a clear refusal.
+func (h *Handler) SetSecurityPolicy(policy *runtime.SecurityPolicy) {
+	h.policy = policy
+}
+
+// checkPolicy checks a requested image and resource limits against the
+// security policy. An empty image or zero limit is not checked, for
+// updates that leave them unchanged.
+func (h *Handler) checkPolicy(image string, cpuMillicores, memoryBytes int64) error {
+	if h.policy == nil {
+		return nil
+	}
+	if cpuMillicores > h.policy.MaxCPUMillicores {
+		return fmt.Errorf("CPU limit %d millicores exceeds this provider's maximum of %d per container",
+			cpuMillicores, h.policy.MaxCPUMillicores)
+	}
+	if memoryBytes > h.policy.MaxMemoryBytes {
+		return fmt.Errorf("memory limit %d bytes exceeds this provider's maximum of %d per container",
+			memoryBytes, h.policy.MaxMemoryBytes)
+	}
+	if image == "" {
+		return nil
+	}
+	return h.policy.CheckRequestedImage(image)
+}
+
+// resolveDigest resolves a requested image's tag to the digest it points to
+// now, so every container started for the request runs the same image even
+// if the tag moves. Images that can't be resolved, such as locally built
+// ones, give "" and run by tag, unless the security policy requires digests.
+func (h *Handler) resolveDigest(ctx context.Context, image string) (string, error) {
+	digest, err := h.runtime.ResolveDigest(ctx, image)
+	if err == nil {
+		return digest, nil
+	}
+	if h.policy != nil && h.policy.RequiresDigest() {
+		return "", fmt.Errorf("this provider only runs images pinned by digest, and %s could not be resolved to one: %w", image, err)
+	}
+	log.Printf("[IMAGE] Warning: failed to resolve %s to a digest, running it by tag: %v", image, err)
+	return "", nil
+}
@@ -0,0 +1,169 @@
+// Package handler - Per-peer stream and request rate limiting
+package handler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/xdas-research/peer-compute/internal/p2p"
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+// ProtocolLimit limits how a single peer may use a single protocol.
+type ProtocolLimit struct {
+	// MaxConcurrent is the maximum number of concurrent streams (0 = unlimited)
+	MaxConcurrent int
+	// RatePerMinute is the sustained request rate (0 = unlimited)
+	RatePerMinute float64
+	// Burst is the number of requests allowed above the sustained rate
+	Burst int
+}
+
+// DefaultRateLimits returns the default per-peer limits for each protocol.
+// SECURITY: Deploys and updates trigger image pulls and logs spawn a
+// follower process, so those are the most tightly limited.
+func DefaultRateLimits() map[string]ProtocolLimit {
+	return map[string]ProtocolLimit{
+		protocol.DeployProtocol:  {MaxConcurrent: 2, RatePerMinute: 10, Burst: 3},
+		protocol.LogProtocol:     {MaxConcurrent: 4, RatePerMinute: 30, Burst: 5},
+		protocol.StatusProtocol:  {MaxConcurrent: 8, RatePerMinute: 120, Burst: 20},
+		protocol.StopProtocol:    {MaxConcurrent: 4, RatePerMinute: 30, Burst: 10},
+		protocol.RenewProtocol:   {MaxConcurrent: 4, RatePerMinute: 30, Burst: 10},
+		protocol.NotifyProtocol:  {MaxConcurrent: 4, RatePerMinute: 60, Burst: 20},
+		protocol.CronProtocol:    {MaxConcurrent: 4, RatePerMinute: 30, Burst: 10},
+		protocol.EventsProtocol:  {MaxConcurrent: 4, RatePerMinute: 30, Burst: 10},
+		protocol.UpdateProtocol:  {MaxConcurrent: 2, RatePerMinute: 10, Burst: 3},
+		protocol.UsageProtocol:   {MaxConcurrent: 4, RatePerMinute: 30, Burst: 10},
+		protocol.ReceiptProtocol: {MaxConcurrent: 4, RatePerMinute: 60, Burst: 20},
+		// Transfers are large, so few may run at once
+		protocol.ArtifactProtocol: {MaxConcurrent: 2, RatePerMinute: 20, Burst: 5},
+		// Peers relay every trusted peer's capacity adverts
+		p2p.GossipProtocolID: {MaxConcurrent: 8, RatePerMinute: 240, Burst: 60},
+	}
+}
+
+// limitKey identifies a (peer, protocol) pair.
+type limitKey struct {
+	peer  peer.ID
+	proto string
+}
+
+// tokenBucket is a simple token bucket refilled continuously.
+type tokenBucket struct {
+	tokens   float64
+	lastFill time.Time
+}
+
+// RateLimiter enforces ProtocolLimits per peer.
+type RateLimiter struct {
+	limits  map[string]ProtocolLimit
+	buckets map[limitKey]*tokenBucket
+	active  map[limitKey]int
+	mu      sync.Mutex
+}
+
+// RateLimitError is returned when a peer exceeds its limits.
+type RateLimitError struct {
+	// Reason describes which limit was hit
+	Reason string
+	// RetryAfter suggests when the request may succeed
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited: %s", e.Reason)
+}
+
+// NewRateLimiter creates a rate limiter with the given per-protocol limits.
+func NewRateLimiter(limits map[string]ProtocolLimit) *RateLimiter {
+	if limits == nil {
+		limits = DefaultRateLimits()
+	}
+	return &RateLimiter{
+		limits:  limits,
+		buckets: make(map[limitKey]*tokenBucket),
+		active:  make(map[limitKey]int),
+	}
+}
+
+// Acquire admits a request from a peer on a protocol. On success the caller
+// must call the returned release function when the stream is done.
+func (rl *RateLimiter) Acquire(p peer.ID, proto string) (func(), error) {
+	limit, ok := rl.limits[proto]
+	if !ok {
+		return func() {}, nil
+	}
+
+	key := limitKey{peer: p, proto: proto}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if limit.MaxConcurrent > 0 && rl.active[key] >= limit.MaxConcurrent {
+		return nil, &RateLimitError{
+			Reason:     fmt.Sprintf("too many concurrent %s streams (max %d)", proto, limit.MaxConcurrent),
+			RetryAfter: time.Second,
+		}
+	}
+
+	if limit.RatePerMinute > 0 {
+		perSecond := limit.RatePerMinute / 60
+		capacity := float64(limit.Burst)
+		if capacity < 1 {
+			capacity = 1
+		}
+
+		now := time.Now()
+		b, ok := rl.buckets[key]
+		if !ok {
+			b = &tokenBucket{tokens: capacity, lastFill: now}
+			rl.buckets[key] = b
+		}
+
+		b.tokens += now.Sub(b.lastFill).Seconds() * perSecond
+		if b.tokens > capacity {
+			b.tokens = capacity
+		}
+		b.lastFill = now
+
+		if b.tokens < 1 {
+			wait := time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+			return nil, &RateLimitError{
+				Reason:     fmt.Sprintf("too many %s requests (max %.0f/min)", proto, limit.RatePerMinute),
+				RetryAfter: wait,
+			}
+		}
+		b.tokens--
+	}
+
+	rl.active[key]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			rl.mu.Lock()
+			defer rl.mu.Unlock()
+			rl.active[key]--
+			if rl.active[key] <= 0 {
+				delete(rl.active, key)
+			}
+		})
+	}, nil
+}
+
+// StreamLimits returns the per-peer concurrent stream caps for the libp2p
+// resource manager. They are set above the handler limits so that peers
+// normally get a structured "rate limited" response, with the resource
+// manager as a hard backstop that resets excess streams.
+func (rl *RateLimiter) StreamLimits() map[string]int {
+	result := make(map[string]int, len(rl.limits))
+	for proto, l := range rl.limits {
+		if l.MaxConcurrent > 0 {
+			result[proto] = 2 * l.MaxConcurrent
+		}
+	}
+	return result
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestRateLimiterRefillsTokens(t *testing.T) {
+	const proto = "/test/1.0.0"
+	rl := NewRateLimiter(map[string]ProtocolLimit{
+		proto: {RatePerMinute: 60, Burst: 2},
+	})
+	p := peer.ID("requester")
+
+	for i := 0; i < 2; i++ {
+		release, err := rl.Acquire(p, proto)
+		if err != nil {
+			t.Fatalf("request %d within burst: %v", i, err)
+		}
+		release()
+	}
+
+	_, err := rl.Acquire(p, proto)
+	var rlErr *RateLimitError
+	if !errors.As(err, &rlErr) {
+		t.Fatalf("expected RateLimitError past the burst, got %v", err)
+	}
+	if rlErr.RetryAfter <= 0 || rlErr.RetryAfter > time.Second {
+		t.Errorf("RetryAfter = %s, want within one token at 1/s", rlErr.RetryAfter)
+	}
+
+	// Another peer has its own bucket
+	release, err := rl.Acquire(peer.ID("other"), proto)
+	if err != nil {
+		t.Fatalf("other peer limited by requester's bucket: %v", err)
+	}
+	release()
+
+	// Wind the bucket back 1.5s: 60/min refills one and a half tokens
+	rl.mu.Lock()
+	rl.buckets[limitKey{peer: p, proto: proto}].lastFill = time.Now().Add(-1500 * time.Millisecond)
+	rl.mu.Unlock()
+
+	release, err = rl.Acquire(p, proto)
+	if err != nil {
+		t.Fatalf("request after refill: %v", err)
+	}
+	release()
+	if _, err := rl.Acquire(p, proto); err == nil {
+		t.Fatal("second request after refilling one token should be limited")
+	}
+
+	// A long idle period refills only up to the burst
+	rl.mu.Lock()
+	rl.buckets[limitKey{peer: p, proto: proto}].lastFill = time.Now().Add(-time.Hour)
+	rl.mu.Unlock()
+
+	for i := 0; i < 2; i++ {
+		release, err := rl.Acquire(p, proto)
+		if err != nil {
+			t.Fatalf("request %d after idle: %v", i, err)
+		}
+		release()
+	}
+	if _, err := rl.Acquire(p, proto); err == nil {
+		t.Fatal("refill exceeded the burst")
+	}
+}
+
+func TestRateLimiterCapsConcurrentStreams(t *testing.T) {
+	const proto = "/test/1.0.0"
+	const otherProto = "/test-other/1.0.0"
+	rl := NewRateLimiter(map[string]ProtocolLimit{
+		proto:      {MaxConcurrent: 2},
+		otherProto: {MaxConcurrent: 1},
+	})
+	p := peer.ID("requester")
+
+	first, err := rl.Acquire(p, proto)
+	if err != nil {
+		t.Fatalf("first stream: %v", err)
+	}
+	second, err := rl.Acquire(p, proto)
+	if err != nil {
+		t.Fatalf("second stream: %v", err)
+	}
+
+	var rlErr *RateLimitError
+	if _, err := rl.Acquire(p, proto); !errors.As(err, &rlErr) {
+		t.Fatalf("expected RateLimitError over the cap, got %v", err)
+	}
+
+	// The cap is per (peer, protocol)
+	release, err := rl.Acquire(peer.ID("other"), proto)
+	if err != nil {
+		t.Fatalf("other peer limited by requester's streams: %v", err)
+	}
+	release()
+	release, err = rl.Acquire(p, otherProto)
+	if err != nil {
+		t.Fatalf("other protocol limited by requester's streams: %v", err)
+	}
+	release()
+
+	// Releasing twice frees only one slot
+	first()
+	first()
+	third, err := rl.Acquire(p, proto)
+	if err != nil {
+		t.Fatalf("stream after release: %v", err)
+	}
+	if _, err := rl.Acquire(p, proto); err == nil {
+		t.Fatal("double release freed two slots")
+	}
+
+	second()
+	third()
+	rl.mu.Lock()
+	active := len(rl.active)
+	rl.mu.Unlock()
+	if active != 0 {
+		t.Errorf("%d keys still active after all streams released", active)
+	}
+}
+
+func TestRateLimiterIgnoresUnlimitedProtocols(t *testing.T) {
+	rl := NewRateLimiter(map[string]ProtocolLimit{})
+	for i := 0; i < 100; i++ {
+		if _, err := rl.Acquire(peer.ID("requester"), "/unlisted/1.0.0"); err != nil {
+			t.Fatalf("request %d on an unlisted protocol: %v", i, err)
+		}
+	}
+}
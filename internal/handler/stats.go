@@ -0,0 +1,89 @@
+// Package handler - Container resource usage
+package handler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+const (
+	// StatsTimeout bounds how long a status request waits for container
+	// stats. The engine takes about a second per sample.
+	StatsTimeout = 5 * time.Second
+
+	// maxConcurrentStats limits how many containers are sampled at once
+	maxConcurrentStats = 8
+)
+
+// statusInfos returns the status of deployments, with the current resource
+// usage of running ones. Containers are sampled in parallel; deployments
+// whose container could not be sampled in time are reported without usage.
+func (h *Handler) statusInfos(deployments []*protocol.Deployment) []protocol.DeploymentStatusInfo {
+	infos := make([]protocol.DeploymentStatusInfo, len(deployments))
+	for i, d := range deployments {
+		infos[i] = statusInfo(d)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), StatsTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentStats)
+	for i, d := range deployments {
+		if d.Status != protocol.StatusRunning || d.ContainerID == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(info *protocol.DeploymentStatusInfo, containerID string) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+			info.ResourceUsage = h.resourceUsage(ctx, info.DeploymentID, containerID)
+		}(&infos[i], d.ContainerID)
+	}
+	wg.Wait()
+
+	return infos
+}
+
+// resourceUsage samples a container's resource usage, or returns nil if it
+// cannot be sampled.
+func (h *Handler) resourceUsage(ctx context.Context, deploymentID, containerID string) *protocol.ResourceUsage {
+	stats, err := h.runtime.Stats(ctx, containerID)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("[STATUS] Failed to get stats of %s: %v", deploymentID, err)
+		}
+		return nil
+	}
+
+	return &protocol.ResourceUsage{
+		CPUPercent:      stats.CPUPercent,
+		MemoryBytes:     int64(stats.MemoryBytes),
+		MemoryLimit:     int64(stats.MemoryLimit),
+		NetworkRxBytes:  stats.NetworkRxBytes,
+		NetworkTxBytes:  stats.NetworkTxBytes,
+		BlockReadBytes:  stats.BlockReadBytes,
+		BlockWriteBytes: stats.BlockWriteBytes,
+		PIDs:            stats.PIDs,
+		PIDsLimit:       stats.PIDsLimit,
+		SampledAt:       time.Now(),
+	}
+}
+
+// exposedURL returns a deployment's public URL while it can still be
+// reached there.
+func exposedURL(d *protocol.Deployment) string {
+	if d.Status.IsFinished() {
+		return ""
+	}
+	return d.ExposedURL
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+	"github.com/xdas-research/peer-compute/internal/runtime"
+)
+
+func TestStatusInfosSampleRunningContainers(t *testing.T) {
+	h, sched, rt, requester := newTestHandler(t)
+	sampled := schedule(t, sched, rt, requester.PeerID)
+	exited := schedule(t, sched, rt, requester.PeerID)
+	removed := schedule(t, sched, rt, requester.PeerID)
+
+	if err := rt.SetStats(sampled.ContainerID, runtime.ResourceUsage{
+		CPUPercent:      42.5,
+		MemoryBytes:     16 * 1024 * 1024,
+		NetworkRxBytes:  1024,
+		BlockWriteBytes: 512,
+		PIDs:            3,
+		PIDsLimit:       100,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Exit(exited.ContainerID, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	sched.Reconcile(context.Background())
+	// A container that disappeared since the last reconcile can't be sampled
+	rt.Remove(removed.ContainerID)
+
+	var deployments []*protocol.Deployment
+	for _, id := range []string{sampled.ID, exited.ID, removed.ID} {
+		d, _ := sched.Get(id)
+		deployments = append(deployments, d)
+	}
+	infos := h.statusInfos(deployments)
+
+	u := infos[0].ResourceUsage
+	if u == nil {
+		t.Fatal("running deployment reported without usage")
+	}
+	if u.CPUPercent != 42.5 || u.MemoryBytes != 16*1024*1024 || u.MemoryLimit != 64*1024*1024 ||
+		u.NetworkRxBytes != 1024 || u.BlockWriteBytes != 512 || u.PIDs != 3 || u.PIDsLimit != 100 {
+		t.Errorf("usage = %+v", u)
+	}
+	if u.SampledAt.IsZero() {
+		t.Error("usage has no sample time")
+	}
+	if infos[1].ResourceUsage != nil {
+		t.Errorf("exited deployment reported with usage %+v", infos[1].ResourceUsage)
+	}
+	if infos[2].ResourceUsage != nil {
+		t.Errorf("removed container reported with usage %+v", infos[2].ResourceUsage)
+	}
+}
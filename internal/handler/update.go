@@ -0,0 +1,164 @@
+// Package handler - Deployment updates and rollbacks
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/libp2p/go-libp2p/core/network"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+	"github.com/xdas-research/peer-compute/internal/scheduler"
+)
+
+// handleUpdate rolls out a new revision of one of the requester's
+// deployments, or rolls it back to an earlier one. The response is sent once
+// the new revision serves the deployment or the update was abandoned.
+func (h *Handler) handleUpdate(stream network.Stream) {
+	defer stream.Close()
+
+	remotePeer := stream.Conn().RemotePeer()
+	log.Printf("[UPDATE] Request from peer: %s", remotePeer)
+
+	var req protocol.UpdateRequest
+	if err := readJSON(stream, &req); err != nil {
+		log.Printf("[UPDATE] Failed to read request: %v", err)
+		sendUpdateError(stream, "invalid request format")
+		return
+	}
+
+	// SECURITY: An update replaces what a deployment runs, so it must be
+	// signed by the connected peer, and only the owner may update
+	if req.RequesterID != remotePeer.String() {
+		sendUpdateError(stream, "requester does not match connection")
+		return
+	}
+	if err := protocol.VerifyUpdateRequest(&req); err != nil {
+		log.Printf("[UPDATE] Invalid request from %s: %v", remotePeer, err)
+		sendUpdateError(stream, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	if !h.trust.IsTrusted(remotePeer) {
+		log.Printf("[UPDATE] Untrusted peer rejected: %s", remotePeer)
+		sendUpdateError(stream, "not trusted")
+		return
+	}
+
+	// An update reserves room for a second revision, so it is held to the
+	// same drain and credit checks as a deployment
+	if h.scheduler.DrainStatus().Cordoned {
+		log.Printf("[UPDATE] Refusing update from %s: draining", remotePeer)
+		sendUpdateRefusal(stream, scheduler.ErrCordoned.Error(), protocol.ErrCodeDraining)
+		return
+	}
+	if err := h.checkCredit(remotePeer); err != nil {
+		log.Printf("[UPDATE] Peer %s is over its credit limit", remotePeer)
+		sendUpdateRefusal(stream, err.Error(), protocol.ErrCodeInsufficientCredit)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		sendUpdateError(stream, err.Error())
+		return
+	}
+	if err := h.checkPolicy(req.Image, req.CPUMillicores, req.MemoryBytes); err != nil {
+		log.Printf("[UPDATE] Refusing update from %s: %v", remotePeer, err)
+		sendUpdateError(stream, err.Error())
+		return
+	}
+
+	// A name updates every replica with it, one at a time, so the others
+	// keep serving while each one switches. The rollout stops at the first
+	// replica that fails; the remaining ones keep their revision
+	deployments := h.scheduler.ResolveAll(remotePeer.String(), req.DeploymentID)
+	if len(deployments) == 0 {
+		sendUpdateError(stream, fmt.Sprintf("deployment %s not found", req.DeploymentID))
+		return
+	}
+
+	var updated *protocol.Deployment
+	for i, d := range deployments {
+		var err error
+		updated, err = h.scheduler.Update(context.Background(), remotePeer.String(), d.ID, &req)
+		if errors.Is(err, scheduler.ErrCordoned) && i == 0 {
+			sendUpdateRefusal(stream, err.Error(), protocol.ErrCodeDraining)
+			return
+		}
+		if err != nil {
+			log.Printf("[UPDATE] Update of %s failed: %v", d.ID, err)
+			if i > 0 {
+				err = fmt.Errorf("%d of %d replicas updated; %s: %w", i, len(deployments), d.ID, err)
+			}
+			sendUpdateError(stream, err.Error())
+			return
+		}
+	}
+
+	message := fmt.Sprintf("Deployment updated to revision %d", updated.Revision)
+	if req.Rollback {
+		message = fmt.Sprintf("Deployment rolled back; now at revision %d", updated.Revision)
+	}
+	if len(deployments) > 1 {
+		message = fmt.Sprintf("%s (%d replicas)", message, len(deployments))
+	}
+	writeJSON(stream, protocol.UpdateResponse{
+		DeploymentID: updated.ID,
+		Success:      true,
+		Revision:     updated.Revision,
+		Image:        updated.Image,
+		Message:      message,
+	})
+}
+
+// DeploymentUpdated re-registers an exposed deployment with the gateway once
+// it switched to a new revision, so the route serves the new container. It
+// is installed as the scheduler's update hook.
+func (h *Handler) DeploymentUpdated(d *protocol.Deployment) {
+	if d.ExposePort <= 0 {
+		return
+	}
+	if h.tunnelClient == nil || !h.tunnelClient.IsConnected() {
+		return
+	}
+
+	url, err := h.registerRoute(d)
+	if err != nil {
+		log.Printf("[UPDATE] Warning: failed to update gateway route for %s: %v", d.ID, err)
+		return
+	}
+	log.Printf("[UPDATE] Deployment %s now serves revision %d at %s", d.ID, d.Revision, url)
+}
+
+// revisionSummaries returns a revision history for status responses.
+// SECURITY: Only the owner sees a deployment's status, but environment
+// values may hold secrets and status output is printed and shared freely,
+// so they are left out; the owner already has them.
+func revisionSummaries(revisions []protocol.DeploymentRevision) []protocol.DeploymentRevision {
+	if len(revisions) == 0 {
+		return nil
+	}
+	summaries := make([]protocol.DeploymentRevision, len(revisions))
+	for i, r := range revisions {
+		r.Environment = nil
+		summaries[i] = r
+	}
+	return summaries
+}
+
+// updatingTo returns the revision a deployment is being updated to, if any.
+func updatingTo(d *protocol.Deployment) int {
+	if d.Update == nil {
+		return 0
+	}
+	return d.Update.Revision.Revision
+}
+
+func sendUpdateError(w io.Writer, message string) {
+	writeJSON(w, protocol.UpdateResponse{Success: false, Error: message})
+}
+
+func sendUpdateRefusal(w io.Writer, message string, code protocol.ErrorCode) {
+	writeJSON(w, protocol.UpdateResponse{Success: false, Error: message, Code: code})
+}
@@ -0,0 +1,51 @@
+// Package handler - Usage metering queries
+package handler
+
+import (
+	"log"
+
+	"github.com/libp2p/go-libp2p/core/network"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+	"github.com/xdas-research/peer-compute/internal/usage"
+)
+
+// SetUsageLedger sets the ledger usage queries are answered from.
+func (h *Handler) SetUsageLedger(ledger *usage.Ledger) {
+	h.usage = ledger
+}
+
+// handleUsage reports how much of this provider's resources the requester
+// has consumed.
+func (h *Handler) handleUsage(stream network.Stream) {
+	defer stream.Close()
+
+	remotePeer := stream.Conn().RemotePeer()
+	log.Printf("[USAGE] Request from peer: %s", remotePeer)
+
+	var req protocol.UsageRequest
+	if err := readJSON(stream, &req); err != nil {
+		log.Printf("[USAGE] Failed to read request: %v", err)
+		writeJSON(stream, protocol.UsageResponse{Error: "invalid request format"})
+		return
+	}
+
+	if !h.trust.IsTrusted(remotePeer) {
+		log.Printf("[USAGE] Untrusted peer rejected: %s", remotePeer)
+		writeJSON(stream, protocol.UsageResponse{Error: "not trusted"})
+		return
+	}
+	if h.usage == nil {
+		writeJSON(stream, protocol.UsageResponse{Error: "usage metering is not enabled on this provider"})
+		return
+	}
+
+	// SECURITY: Peers only see their own usage; what others run here is
+	// the provider's business
+	record := h.usage.Get(remotePeer.String(), req.Since)
+	writeJSON(stream, protocol.UsageResponse{
+		Success: true,
+		Usage:   &record,
+		Since:   req.Since.UTC().Truncate(usage.BucketSize),
+	})
+}
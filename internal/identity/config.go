@@ -32,3 +32,8 @@ func DefaultKeyPath() string {
 func DefaultTrustedPeersPath() string {
 	return filepath.Join(DefaultConfigDir(), "trusted_peers.json")
 }
+
+// DefaultSwarmKeyPath returns the default path for the private network key.
+func DefaultSwarmKeyPath() string {
+	return filepath.Join(DefaultConfigDir(), "swarm.key")
+}
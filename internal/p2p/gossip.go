@@ -0,0 +1,209 @@
+// Package p2p - Topic-based gossip between trusted peers
+package p2p
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// GossipProtocolID is the libp2p protocol used to exchange gossip messages
+	GossipProtocolID = "/peercompute/gossip/1.0.0"
+	// GossipSeenTTL is how long message IDs are remembered for deduplication
+	GossipSeenTTL = 2 * time.Minute
+	// GossipMaxHops bounds how far a message is relayed
+	GossipMaxHops = 4
+	// GossipSendTimeout is the timeout for delivering a message to one peer
+	GossipSendTimeout = 10 * time.Second
+	// GossipMaxMessageSize bounds an encoded gossip message
+	GossipMaxMessageSize = 64 * 1024
+)
+
+// StreamLimiter admits inbound streams per peer and protocol, returning a
+// function that releases the stream when it is done (see
+// handler.RateLimiter).
+type StreamLimiter interface {
+	Acquire(p peer.ID, proto string) (func(), error)
+}
+
+// GossipHandler is called for every new message received on a topic.
+// from is the peer that originally published the message.
+type GossipHandler func(from peer.ID, data []byte)
+
+// gossipMessage is the wire format of a gossip message.
+type gossipMessage struct {
+	Topic string `json:"topic"`
+	From  string `json:"from"`
+	Seq   int64  `json:"seq"`
+	Hops  int    `json:"hops"`
+	Data  []byte `json:"data"`
+}
+
+// id returns the deduplication ID of the message.
+func (m *gossipMessage) id() string {
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s/%s/%d", m.Topic, m.From, m.Seq)))
+	return hex.EncodeToString(h[:])
+}
+
+// Gossip implements private publish/subscribe topics over direct streams.
+//
+// Messages are flooded to every connected peer and relayed at most
+// GossipMaxHops times.
+//
+// SECURITY: Only trusted peers can join a topic. The connection gater already
+// rejects untrusted connections, and messages from or originating at peers
+// outside the trust list are dropped. Payloads that need authenticity beyond
+// "some trusted peer relayed this" must carry their own signature.
+type Gossip struct {
+	host    *Host
+	trust   *TrustManager
+	limiter StreamLimiter
+	subs    map[string][]GossipHandler
+	seen    map[string]time.Time
+	seq     int64
+	mu      sync.Mutex
+}
+
+// NewGossip creates a gossip router and registers its stream handler on the
+// host. Inbound streams are admitted by limiter (nil = unlimited).
+func NewGossip(h *Host, trust *TrustManager, limiter StreamLimiter) *Gossip {
+	g := &Gossip{
+		host:    h,
+		trust:   trust,
+		limiter: limiter,
+		subs:    make(map[string][]GossipHandler),
+		seen:    make(map[string]time.Time),
+		seq:     time.Now().UnixNano(),
+	}
+	h.SetStreamHandler(GossipProtocolID, g.handleStream)
+	return g
+}
+
+// Subscribe registers a handler for messages on a topic.
+func (g *Gossip) Subscribe(topic string, handler GossipHandler) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.subs[topic] = append(g.subs[topic], handler)
+}
+
+// Publish sends a message to all trusted peers on a topic.
+func (g *Gossip) Publish(ctx context.Context, topic string, data []byte) error {
+	g.mu.Lock()
+	g.seq++
+	msg := &gossipMessage{
+		Topic: topic,
+		From:  g.host.ID().String(),
+		Seq:   g.seq,
+		Data:  data,
+	}
+	g.markSeenUnlocked(msg.id())
+	g.mu.Unlock()
+
+	g.broadcast(ctx, msg, "")
+	return nil
+}
+
+// handleStream processes a gossip message from a peer.
+func (g *Gossip) handleStream(stream network.Stream) {
+	defer stream.Close()
+
+	sender := stream.Conn().RemotePeer()
+	if !g.trust.IsTrusted(sender) {
+		stream.Reset()
+		return
+	}
+
+	// SECURITY: A trusted peer must not flood this peer with messages to
+	// handle and relay, or send unbounded ones
+	if g.limiter != nil {
+		release, err := g.limiter.Acquire(sender, GossipProtocolID)
+		if err != nil {
+			log.Printf("[GOSSIP] Refused message from %s: %v", sender, err)
+			stream.Reset()
+			return
+		}
+		defer release()
+	}
+
+	var msg gossipMessage
+	if err := json.NewDecoder(io.LimitReader(stream, GossipMaxMessageSize)).Decode(&msg); err != nil {
+		log.Printf("[GOSSIP] Invalid message from %s: %v", sender, err)
+		return
+	}
+
+	origin, err := peer.Decode(msg.From)
+	if err != nil {
+		return
+	}
+	// SECURITY: Drop messages originating outside the trust list, even when
+	// relayed by a trusted peer.
+	if origin != g.host.ID() && !g.trust.IsTrusted(origin) {
+		return
+	}
+
+	g.mu.Lock()
+	id := msg.id()
+	if _, dup := g.seen[id]; dup || origin == g.host.ID() {
+		g.mu.Unlock()
+		return
+	}
+	g.markSeenUnlocked(id)
+	handlers := append([]GossipHandler(nil), g.subs[msg.Topic]...)
+	g.mu.Unlock()
+
+	for _, h := range handlers {
+		h(origin, msg.Data)
+	}
+
+	if msg.Hops < GossipMaxHops {
+		msg.Hops++
+		go g.broadcast(context.Background(), &msg, sender)
+	}
+}
+
+// broadcast sends a message to every connected trusted peer except skip.
+func (g *Gossip) broadcast(ctx context.Context, msg *gossipMessage, skip peer.ID) {
+	for _, p := range g.host.Peers() {
+		if p == skip || p.String() == msg.From || !g.trust.IsTrusted(p) {
+			continue
+		}
+		if err := g.send(ctx, p, msg); err != nil {
+			log.Printf("[GOSSIP] Failed to send to %s: %v", p, err)
+		}
+	}
+}
+
+// send delivers a message to a single peer.
+func (g *Gossip) send(ctx context.Context, p peer.ID, msg *gossipMessage) error {
+	ctx, cancel := context.WithTimeout(ctx, GossipSendTimeout)
+	defer cancel()
+
+	stream, err := g.host.NewStream(ctx, p, GossipProtocolID)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	return json.NewEncoder(stream).Encode(msg)
+}
+
+// markSeenUnlocked records a message ID and expires old ones (caller must hold lock).
+func (g *Gossip) markSeenUnlocked(id string) {
+	now := time.Now()
+	for k, t := range g.seen {
+		if now.Sub(t) > GossipSeenTTL {
+			delete(g.seen, k)
+		}
+	}
+	g.seen[id] = now
+}
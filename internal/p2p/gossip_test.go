@@ -0,0 +1,204 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/pnet"
+	"github.com/multiformats/go-multiaddr"
+
+	"github.com/xdas-research/peer-compute/internal/identity"
+)
+
+// testPeer is a host on the loopback interface with its own trust list.
+type testPeer struct {
+	*Host
+	trust *TrustManager
+}
+
+// newTestPeer starts a host on a free loopback port.
+func newTestPeer(t *testing.T) *testPeer {
+	t.Helper()
+	return newTestPeerWithKey(t, nil)
+}
+
+// newTestPeerWithKey starts a host on a private network keyed by psk.
+func newTestPeerWithKey(t *testing.T, psk pnet.PSK) *testPeer {
+	t.Helper()
+	id, err := identity.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	trust := NewTrustManager(filepath.Join(t.TempDir(), "trusted_peers.json"))
+
+	// Listening on port 0 selects DefaultListenPort, so find a free one
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	cfg := DefaultConfig(id, trust)
+	cfg.ListenPort = port
+	cfg.PSK = psk
+	h, err := NewHost(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("NewHost() error = %v", err)
+	}
+	t.Cleanup(func() { h.Close() })
+	return &testPeer{Host: h, trust: trust}
+}
+
+// trustEachOther adds two peers to each other's trust list.
+func trustEachOther(t *testing.T, a, b *testPeer) {
+	t.Helper()
+	if err := a.trust.Add(b.ID(), "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.trust.Add(a.ID(), "", nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// connect connects a to b over loopback.
+func connect(t *testing.T, a, b *testPeer) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := a.Connect(ctx, loopbackInfo(b)); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+}
+
+// loopbackInfo returns a peer's ID and loopback addresses.
+func loopbackInfo(p *testPeer) peer.AddrInfo {
+	info := peer.AddrInfo{ID: p.ID()}
+	for _, addr := range p.Addrs() {
+		if ip, err := addr.ValueForProtocol(multiaddr.P_IP4); err == nil && ip == "127.0.0.1" {
+			info.Addrs = append(info.Addrs, addr)
+		}
+	}
+	return info
+}
+
+// received collects gossip messages delivered to a subscriber.
+type received struct {
+	mu   sync.Mutex
+	msgs []string // "from: data"
+}
+
+func (r *received) handle(from peer.ID, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.msgs = append(r.msgs, fmt.Sprintf("%s: %s", from, data))
+}
+
+func (r *received) has(from peer.ID, data string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range r.msgs {
+		if m == fmt.Sprintf("%s: %s", from, data) {
+			return true
+		}
+	}
+	return false
+}
+
+// waitFor waits until cond holds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGossipRelaysFromTrustedOrigins(t *testing.T) {
+	// a - b - c: a and c aren't connected, but trust each other
+	a, b, c := newTestPeer(t), newTestPeer(t), newTestPeer(t)
+	trustEachOther(t, a, b)
+	trustEachOther(t, b, c)
+	trustEachOther(t, a, c)
+	connect(t, a, b)
+	connect(t, b, c)
+
+	ga := NewGossip(a.Host, a.trust, nil)
+	gb := NewGossip(b.Host, b.trust, nil)
+	gc := NewGossip(c.Host, c.trust, nil)
+	var atB, atC received
+	gb.Subscribe("test", atB.handle)
+	gc.Subscribe("test", atC.handle)
+	ga.Subscribe("test", func(peer.ID, []byte) { t.Error("publisher received its own message") })
+
+	if err := ga.Publish(context.Background(), "test", []byte("hello")); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	waitFor(t, "the message at b", func() bool { return atB.has(a.ID(), "hello") })
+	// c learns the original publisher, not the relay
+	waitFor(t, "the relayed message at c", func() bool { return atC.has(a.ID(), "hello") })
+}
+
+func TestGossipDropsUntrustedOrigins(t *testing.T) {
+	// b trusts x and relays its messages to c, which doesn't trust x
+	x, b, c := newTestPeer(t), newTestPeer(t), newTestPeer(t)
+	trustEachOther(t, x, b)
+	trustEachOther(t, b, c)
+	connect(t, x, b)
+	connect(t, b, c)
+
+	gx := NewGossip(x.Host, x.trust, nil)
+	gb := NewGossip(b.Host, b.trust, nil)
+	gc := NewGossip(c.Host, c.trust, nil)
+	var atB, atC received
+	gb.Subscribe("test", atB.handle)
+	gc.Subscribe("test", atC.handle)
+
+	if err := gx.Publish(context.Background(), "test", []byte("from x")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the message at b", func() bool { return atB.has(x.ID(), "from x") })
+
+	// b's own message reaches c after the relayed one was handled
+	if err := gb.Publish(context.Background(), "test", []byte("from b")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "b's message at c", func() bool { return atC.has(b.ID(), "from b") })
+	time.Sleep(100 * time.Millisecond)
+	if atC.has(x.ID(), "from x") {
+		t.Error("c accepted a message originating at a peer it doesn't trust")
+	}
+}
+
+func TestGossipDropsOversizedMessages(t *testing.T) {
+	a, b := newTestPeer(t), newTestPeer(t)
+	trustEachOther(t, a, b)
+	connect(t, a, b)
+
+	ga := NewGossip(a.Host, a.trust, nil)
+	gb := NewGossip(b.Host, b.trust, nil)
+	var atB received
+	gb.Subscribe("test", atB.handle)
+
+	big := bytes.Repeat([]byte("x"), GossipMaxMessageSize)
+	if err := ga.Publish(context.Background(), "test", big); err != nil {
+		t.Fatal(err)
+	}
+	if err := ga.Publish(context.Background(), "test", []byte("small")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the small message", func() bool { return atB.has(a.ID(), "small") })
+	if atB.has(a.ID(), string(big)) {
+		t.Error("message larger than GossipMaxMessageSize was delivered")
+	}
+}
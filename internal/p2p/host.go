@@ -15,7 +15,9 @@ import (
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/pnet"
 	"github.com/libp2p/go-libp2p/core/protocol"
+	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
 	"github.com/libp2p/go-libp2p/p2p/net/connmgr"
 	"github.com/libp2p/go-libp2p/p2p/security/noise"
 	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
@@ -59,6 +61,11 @@ type Config struct {
 	LowWater int
 	// HighWater is the high watermark for connection pruning
 	HighWater int
+	// StreamLimits caps concurrent inbound streams per peer for each protocol
+	// ID, enforced by the libp2p resource manager
+	StreamLimits map[string]int
+	// PSK is an optional private network pre-shared key (nil = no pnet)
+	PSK pnet.PSK
 }
 
 // DefaultConfig returns a configuration with sensible defaults.
@@ -122,9 +129,17 @@ func NewHost(ctx context.Context, cfg *Config) (*Host, error) {
 		return nil, fmt.Errorf("failed to create connection manager: %w", err)
 	}
 
+	// Create resource manager with per-peer protocol stream limits
+	// SECURITY: Excess streams from a single peer are reset by libp2p before
+	// they ever reach a protocol handler
+	resourceMgr, err := newResourceManager(cfg.StreamLimits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource manager: %w", err)
+	}
+
 	// Create the libp2p host
 	// SECURITY: Uses Noise protocol for authenticated encryption
-	h, err := libp2p.New(
+	opts := []libp2p.Option{
 		// Use our cryptographic identity
 		libp2p.Identity(cfg.Identity.PrivKey),
 		// Listen on specified addresses
@@ -137,9 +152,17 @@ func NewHost(ctx context.Context, cfg *Config) (*Host, error) {
 		libp2p.ConnectionGater(connGater),
 		// Connection manager for resource limits
 		libp2p.ConnectionManager(connMgr),
+		// Resource manager for per-peer stream limits
+		libp2p.ResourceManager(resourceMgr),
 		// Disable relay (we use direct connections)
 		libp2p.DisableRelay(),
-	)
+	}
+	if cfg.PSK != nil {
+		// SECURITY: Private network - peers without the key cannot connect
+		opts = append(opts, libp2p.PrivateNetwork(cfg.PSK))
+	}
+
+	h, err := libp2p.New(opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create libp2p host: %w", err)
 	}
@@ -152,6 +175,21 @@ func NewHost(ctx context.Context, cfg *Config) (*Host, error) {
 	}, nil
 }
 
+// newResourceManager builds a resource manager using libp2p's default scaled
+// limits, with per-peer inbound stream caps for the given protocols.
+func newResourceManager(streamLimits map[string]int) (network.ResourceManager, error) {
+	limits := rcmgr.DefaultLimits
+	for proto, max := range streamLimits {
+		base := limits.ProtocolPeerBaseLimit
+		base.StreamsInbound = max
+		base.Streams = max + base.StreamsOutbound
+		limits.AddProtocolPeerLimit(protocol.ID(proto), base, rcmgr.BaseLimitIncrease{})
+	}
+	libp2p.SetDefaultServiceLimits(&limits)
+
+	return rcmgr.NewResourceManager(rcmgr.NewFixedLimiter(limits.AutoScale()))
+}
+
 // ID returns this host's peer ID.
 func (h *Host) ID() peer.ID {
 	return h.host.ID()
@@ -0,0 +1,67 @@
+// Package p2p - Private network pre-shared key support
+package p2p
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/libp2p/go-libp2p/core/pnet"
+)
+
+const (
+	// SwarmKeyFileName is the filename of the private network key
+	SwarmKeyFileName = "swarm.key"
+	// swarmKeyHeader is the header of the libp2p/IPFS v1 swarm key format
+	swarmKeyHeader = "/key/swarm/psk/1.0.0/\n/base16/\n"
+)
+
+// LoadSwarmKey reads a private network pre-shared key in the standard
+// libp2p swarm.key format. It returns nil if the file does not exist,
+// meaning the host joins the public (non-pnet) network.
+//
+// SECURITY: With a PSK configured, every connection is encrypted with the
+// key before the Noise handshake. Hosts without the key cannot complete a
+// handshake at all, so they never learn our peer ID or reach the
+// ConnectionGater.
+func LoadSwarmKey(path string) (pnet.PSK, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read swarm key: %w", err)
+	}
+
+	psk, err := pnet.DecodeV1PSK(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode swarm key: %w", err)
+	}
+
+	return psk, nil
+}
+
+// GenerateSwarmKey creates a new random 256-bit pre-shared key at path.
+func GenerateSwarmKey(path string) error {
+	key := make([]byte, 32)
+	// SECURITY: Use crypto/rand for key generation
+	if _, err := rand.Read(key); err != nil {
+		return fmt.Errorf("failed to generate swarm key: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data := swarmKeyHeader + hex.EncodeToString(key) + "\n"
+
+	// SECURITY: The swarm key is a team secret; restrict to owner
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		return fmt.Errorf("failed to write swarm key: %w", err)
+	}
+
+	return nil
+}
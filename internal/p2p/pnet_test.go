@@ -0,0 +1,134 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSwarmKeyRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config", SwarmKeyFileName)
+	if err := GenerateSwarmKey(path); err != nil {
+		t.Fatalf("GenerateSwarmKey() error = %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("swarm key mode = %o, want 600", info.Mode().Perm())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), swarmKeyHeader) {
+		t.Errorf("swarm key missing v1 header: %q", data)
+	}
+
+	psk, err := LoadSwarmKey(path)
+	if err != nil {
+		t.Fatalf("LoadSwarmKey() error = %v", err)
+	}
+	if len(psk) != 32 {
+		t.Fatalf("key length = %d, want 32", len(psk))
+	}
+
+	again, err := LoadSwarmKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(psk, again) {
+		t.Error("loading the same file gave different keys")
+	}
+
+	other := filepath.Join(t.TempDir(), SwarmKeyFileName)
+	if err := GenerateSwarmKey(other); err != nil {
+		t.Fatal(err)
+	}
+	otherPSK, err := LoadSwarmKey(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(psk, otherPSK) {
+		t.Error("two generated keys are identical")
+	}
+}
+
+func TestLoadSwarmKeyMissingFile(t *testing.T) {
+	psk, err := LoadSwarmKey(filepath.Join(t.TempDir(), SwarmKeyFileName))
+	if err != nil {
+		t.Fatalf("LoadSwarmKey() error = %v", err)
+	}
+	if psk != nil {
+		t.Error("missing swarm key should mean no private network")
+	}
+}
+
+func TestLoadSwarmKeyMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty", ""},
+		{"no header", strings.Repeat("ab", 32) + "\n"},
+		{"not hex", swarmKeyHeader + strings.Repeat("zz", 32) + "\n"},
+		{"short key", swarmKeyHeader + "abcd\n"},
+		{"unknown encoding", "/key/swarm/psk/1.0.0/\n/base99/\n" + strings.Repeat("ab", 32) + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), SwarmKeyFileName)
+			if err := os.WriteFile(path, []byte(tt.data), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadSwarmKey(path); err == nil {
+				t.Error("LoadSwarmKey() accepted a malformed key")
+			}
+		})
+	}
+}
+
+func TestPrivateNetworkRequiresSameKey(t *testing.T) {
+	dir := t.TempDir()
+	keyA := filepath.Join(dir, "a.key")
+	keyB := filepath.Join(dir, "b.key")
+	for _, path := range []string{keyA, keyB} {
+		if err := GenerateSwarmKey(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pskA, err := LoadSwarmKey(keyA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pskB, err := LoadSwarmKey(keyB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := newTestPeerWithKey(t, pskA)
+	sameKey := newTestPeerWithKey(t, pskA)
+	otherKey := newTestPeerWithKey(t, pskB)
+	noKey := newTestPeer(t)
+	trustEachOther(t, a, sameKey)
+	trustEachOther(t, a, otherKey)
+	trustEachOther(t, a, noKey)
+
+	connect(t, a, sameKey)
+
+	for name, p := range map[string]*testPeer{"another key": otherKey, "no key": noKey} {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		err := a.Connect(ctx, loopbackInfo(p))
+		cancel()
+		if err == nil {
+			t.Errorf("connected to a host with %s", name)
+		}
+	}
+}
@@ -22,6 +22,20 @@ type TrustedPeer struct {
 	AddedAt time.Time `json:"added_at"`
 	// Addresses are known multiaddresses for this peer
 	Addresses []string `json:"addresses,omitempty"`
+	// MaxLeaseSeconds caps the lease of this peer's deployments on our
+	// provider (0 = provider default)
+	MaxLeaseSeconds int64 `json:"max_lease_seconds,omitempty"`
+	// PriorityClasses are the priority classes this peer may use on our
+	// provider (empty = provider default)
+	PriorityClasses []string `json:"priority_classes,omitempty"`
+	// ArtifactQuotaBytes caps the job artifacts this peer may store on our
+	// provider (0 = provider default)
+	ArtifactQuotaBytes int64 `json:"artifact_quota_bytes,omitempty"`
+}
+
+// MaxLease returns the peer's maximum deployment lease (0 = provider default).
+func (p *TrustedPeer) MaxLease() time.Duration {
+	return time.Duration(p.MaxLeaseSeconds) * time.Second
 }
 
 // TrustManager manages the list of trusted peers.
@@ -128,6 +142,21 @@ func (tm *TrustManager) Add(peerID peer.ID, name string, addresses []string) err
 	return tm.saveUnlocked()
 }
 
+// Update applies fn to a trusted peer's settings and saves the trust list.
+func (tm *TrustManager) Update(peerID peer.ID, fn func(p *TrustedPeer)) error {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	p, exists := tm.peers[peerID]
+	if !exists {
+		return fmt.Errorf("peer %s not in trust list", peerID)
+	}
+
+	fn(p)
+	p.ID = peerID
+	return tm.saveUnlocked()
+}
+
 // Remove removes a peer from the trust list.
 // SECURITY: After removal, all connections from this peer will be rejected.
 func (tm *TrustManager) Remove(peerID peer.ID) error {
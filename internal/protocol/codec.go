@@ -10,6 +10,8 @@ import (
 	"io"
 	"time"
 
+	"github.com/libp2p/go-libp2p/core/peer"
+
 	"github.com/xdas-research/peer-compute/internal/identity"
 )
 
@@ -193,25 +195,379 @@ func SignStopRequest(req *StopRequest, id *identity.Identity) error {
 	return nil
 }
 
+// SignRenewRequest signs a lease renewal request.
+func SignRenewRequest(req *RenewRequest, id *identity.Identity) error {
+	req.Signature = nil
+	req.RequesterID = id.PeerID.String()
+	req.Timestamp = time.Now().UnixNano()
+
+	payload, err := renewSigningPayload(req)
+	if err != nil {
+		return fmt.Errorf("failed to create signing payload: %w", err)
+	}
+
+	signature, err := id.Sign(payload)
+	if err != nil {
+		return fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	req.Signature = signature
+	return nil
+}
+
+// SignUpdateRequest signs an update or rollback request.
+func SignUpdateRequest(req *UpdateRequest, id *identity.Identity) error {
+	req.Signature = nil
+	req.RequesterID = id.PeerID.String()
+	req.Timestamp = time.Now().UnixNano()
+
+	payload, err := updateSigningPayload(req)
+	if err != nil {
+		return fmt.Errorf("failed to create signing payload: %w", err)
+	}
+
+	signature, err := id.Sign(payload)
+	if err != nil {
+		return fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	req.Signature = signature
+	return nil
+}
+
+// SignCronRequest signs a request managing scheduled jobs.
+func SignCronRequest(req *CronRequest, id *identity.Identity) error {
+	req.Signature = nil
+	req.RequesterID = id.PeerID.String()
+	req.Timestamp = time.Now().UnixNano()
+
+	payload, err := cronSigningPayload(req)
+	if err != nil {
+		return fmt.Errorf("failed to create signing payload: %w", err)
+	}
+
+	signature, err := id.Sign(payload)
+	if err != nil {
+		return fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	req.Signature = signature
+	return nil
+}
+
+// VerifyCronRequest verifies that a cron request was signed by its requester
+// and is fresh.
+// SECURITY: A registered schedule keeps launching jobs on the provider long
+// after the requester went offline, so registrations must be authentic.
+func VerifyCronRequest(req *CronRequest) error {
+	if err := checkTimestamp(req.Timestamp); err != nil {
+		return err
+	}
+
+	payload, err := cronSigningPayload(req)
+	if err != nil {
+		return fmt.Errorf("failed to create signing payload: %w", err)
+	}
+
+	return VerifyPeerSignature(req.RequesterID, payload, req.Signature)
+}
+
+// VerifyUpdateRequest verifies that an update was signed by its requester
+// and is recent.
+// SECURITY: An update replaces the image a deployment runs, so it must come
+// from the deployment's owner and must not be replayable.
+func VerifyUpdateRequest(req *UpdateRequest) error {
+	if err := checkTimestamp(req.Timestamp); err != nil {
+		return err
+	}
+
+	payload, err := updateSigningPayload(req)
+	if err != nil {
+		return fmt.Errorf("failed to create signing payload: %w", err)
+	}
+
+	return VerifyPeerSignature(req.RequesterID, payload, req.Signature)
+}
+
+// VerifyRenewRequest verifies that a renewal was signed by its requester and
+// is recent.
+// SECURITY: Prevents replaying an old renewal to keep a deployment alive.
+func VerifyRenewRequest(req *RenewRequest) error {
+	if err := checkTimestamp(req.Timestamp); err != nil {
+		return err
+	}
+
+	payload, err := renewSigningPayload(req)
+	if err != nil {
+		return fmt.Errorf("failed to create signing payload: %w", err)
+	}
+
+	return VerifyPeerSignature(req.RequesterID, payload, req.Signature)
+}
+
+// SignCapacityAdvert signs a capacity advertisement.
+func SignCapacityAdvert(advert *CapacityAdvert, id *identity.Identity) error {
+	advert.Signature = nil
+	advert.PeerID = id.PeerID.String()
+	advert.Timestamp = time.Now().UnixNano()
+
+	payload, err := advertSigningPayload(advert)
+	if err != nil {
+		return fmt.Errorf("failed to create signing payload: %w", err)
+	}
+
+	signature, err := id.Sign(payload)
+	if err != nil {
+		return fmt.Errorf("failed to sign advert: %w", err)
+	}
+
+	advert.Signature = signature
+	return nil
+}
+
+// VerifyCapacityAdvert verifies that an advert was signed by the peer it names.
+// SECURITY: Adverts are relayed by other peers, so the signature (not the
+// sender of the stream) is what proves which provider produced them.
+func VerifyCapacityAdvert(advert *CapacityAdvert) error {
+	if err := checkTimestamp(advert.Timestamp); err != nil {
+		return err
+	}
+
+	payload, err := advertSigningPayload(advert)
+	if err != nil {
+		return fmt.Errorf("failed to create signing payload: %w", err)
+	}
+
+	return VerifyPeerSignature(advert.PeerID, payload, advert.Signature)
+}
+
+// SignReceipt signs a usage receipt as its provider.
+func SignReceipt(r *UsageReceipt, id *identity.Identity) error {
+	r.ProviderID = id.PeerID.String()
+	r.ProviderSignature = nil
+	r.RequesterSignature = nil
+
+	payload, err := receiptSigningPayload(r)
+	if err != nil {
+		return fmt.Errorf("failed to create signing payload: %w", err)
+	}
+
+	signature, err := id.Sign(payload)
+	if err != nil {
+		return fmt.Errorf("failed to sign receipt: %w", err)
+	}
+
+	r.ProviderSignature = signature
+	return nil
+}
+
+// CountersignReceipt signs a usage receipt as its requester, after checking
+// the provider's signature.
+func CountersignReceipt(r *UsageReceipt, id *identity.Identity) error {
+	if r.RequesterID != id.PeerID.String() {
+		return fmt.Errorf("receipt is for another requester")
+	}
+	if err := VerifyReceipt(r); err != nil {
+		return err
+	}
+
+	payload, err := receiptSigningPayload(r)
+	if err != nil {
+		return fmt.Errorf("failed to create signing payload: %w", err)
+	}
+
+	signature, err := id.Sign(payload)
+	if err != nil {
+		return fmt.Errorf("failed to countersign receipt: %w", err)
+	}
+
+	r.RequesterSignature = signature
+	return nil
+}
+
+// VerifyReceipt verifies the provider's signature on a usage receipt and,
+// if present, the requester's countersignature.
+// SECURITY: Receipts are not time-limited, since they may be delivered long
+// after they were issued; ledgers reject receipt IDs they have already seen.
+func VerifyReceipt(r *UsageReceipt) error {
+	payload, err := receiptSigningPayload(r)
+	if err != nil {
+		return fmt.Errorf("failed to create signing payload: %w", err)
+	}
+
+	if err := VerifyPeerSignature(r.ProviderID, payload, r.ProviderSignature); err != nil {
+		return fmt.Errorf("provider signature: %w", err)
+	}
+	if len(r.RequesterSignature) > 0 {
+		if err := VerifyPeerSignature(r.RequesterID, payload, r.RequesterSignature); err != nil {
+			return fmt.Errorf("requester signature: %w", err)
+		}
+	}
+	return nil
+}
+
+// VerifyPeerSignature checks a signature against the public key embedded in a
+// peer ID. Ed25519 peer IDs inline their public key, so no key exchange is needed.
+func VerifyPeerSignature(peerIDStr string, payload, signature []byte) error {
+	peerID, err := peer.Decode(peerIDStr)
+	if err != nil {
+		return fmt.Errorf("invalid peer ID: %w", err)
+	}
+
+	pubKey, err := peerID.ExtractPublicKey()
+	if err != nil {
+		return fmt.Errorf("failed to extract public key: %w", err)
+	}
+
+	ok, err := pubKey.Verify(payload, signature)
+	if err != nil {
+		return fmt.Errorf("failed to verify signature: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("invalid signature")
+	}
+
+	return nil
+}
+
+// checkTimestamp rejects timestamps outside MaxTimestampDrift.
+func checkTimestamp(timestamp int64) error {
+	drift := time.Since(time.Unix(0, timestamp))
+	if drift < -MaxTimestampDrift || drift > MaxTimestampDrift {
+		return fmt.Errorf("timestamp too old or in future: drift=%v", drift)
+	}
+	return nil
+}
+
+// advertSigningPayload creates the signing payload for a capacity advert.
+func advertSigningPayload(advert *CapacityAdvert) ([]byte, error) {
+	unsigned := *advert
+	unsigned.Signature = nil
+
+	data, err := json.Marshal(unsigned)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(data)
+	return hash[:], nil
+}
+
+// receiptSigningPayload creates the signing payload for a usage receipt.
+// Provider and requester sign the same payload.
+func receiptSigningPayload(r *UsageReceipt) ([]byte, error) {
+	unsigned := *r
+	unsigned.ProviderSignature = nil
+	unsigned.RequesterSignature = nil
+
+	data, err := json.Marshal(unsigned)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(data)
+	return hash[:], nil
+}
+
+// cronSigningPayload creates the canonical signing payload for a cron request.
+func cronSigningPayload(req *CronRequest) ([]byte, error) {
+	return json.Marshal(struct {
+		Op          CronOp    `json:"op"`
+		ScheduleID  string    `json:"schedule_id"`
+		Spec        *CronSpec `json:"spec"`
+		RequesterID string    `json:"requester_id"`
+		Timestamp   int64     `json:"timestamp"`
+	}{
+		Op:          req.Op,
+		ScheduleID:  req.ScheduleID,
+		Spec:        req.Spec,
+		RequesterID: req.RequesterID,
+		Timestamp:   req.Timestamp,
+	})
+}
+
+// renewSigningPayload creates the canonical signing payload for a renewal.
+func renewSigningPayload(req *RenewRequest) ([]byte, error) {
+	return json.Marshal(struct {
+		DeploymentID string `json:"deployment_id"`
+		LeaseSeconds int64  `json:"lease_seconds"`
+		RequesterID  string `json:"requester_id"`
+		Timestamp    int64  `json:"timestamp"`
+	}{
+		DeploymentID: req.DeploymentID,
+		LeaseSeconds: req.LeaseSeconds,
+		RequesterID:  req.RequesterID,
+		Timestamp:    req.Timestamp,
+	})
+}
+
+// updateSigningPayload creates the canonical signing payload for an update.
+// Maps are marshalled with sorted keys, so the payload is deterministic.
+func updateSigningPayload(req *UpdateRequest) ([]byte, error) {
+	return json.Marshal(struct {
+		DeploymentID     string            `json:"deployment_id"`
+		Image            string            `json:"image"`
+		Environment      map[string]string `json:"environment"`
+		UnsetEnvironment []string          `json:"unset_environment"`
+		CPUMillicores    int64             `json:"cpu_millicores"`
+		MemoryBytes      int64             `json:"memory_bytes"`
+		Rollback         bool              `json:"rollback"`
+		ToRevision       int               `json:"to_revision"`
+		RequesterID      string            `json:"requester_id"`
+		Timestamp        int64             `json:"timestamp"`
+	}{
+		DeploymentID:     req.DeploymentID,
+		Image:            req.Image,
+		Environment:      req.Environment,
+		UnsetEnvironment: req.UnsetEnvironment,
+		CPUMillicores:    req.CPUMillicores,
+		MemoryBytes:      req.MemoryBytes,
+		Rollback:         req.Rollback,
+		ToRevision:       req.ToRevision,
+		RequesterID:      req.RequesterID,
+		Timestamp:        req.Timestamp,
+	})
+}
+
 // createSigningPayload creates a deterministic payload for signing.
 func createSigningPayload(req *DeployRequest) ([]byte, error) {
 	// Create a canonical representation without the signature field
 	canonical := struct {
 		RequestID     string            `json:"request_id"`
 		Image         string            `json:"image"`
+		Name          string            `json:"name,omitempty"`
+		Replicas      int               `json:"replicas,omitempty"`
+		ReplicaSet    string            `json:"replica_set,omitempty"`
 		CPUMillicores int64             `json:"cpu_millicores"`
 		MemoryBytes   int64             `json:"memory_bytes"`
 		ExposePort    int               `json:"expose_port"`
 		Environment   map[string]string `json:"environment"`
+		RestartPolicy *RestartPolicy    `json:"restart_policy,omitempty"`
+		HealthCheck   *HealthCheck      `json:"health_check,omitempty"`
+		LeaseSeconds  int64             `json:"lease_seconds,omitempty"`
+		PriorityClass PriorityClass     `json:"priority_class,omitempty"`
+		Job           *JobSpec          `json:"job,omitempty"`
+		Priority      int               `json:"priority,omitempty"`
+		QueueTimeout  int64             `json:"queue_timeout_seconds,omitempty"`
 		RequesterID   string            `json:"requester_id"`
 		Timestamp     int64             `json:"timestamp"`
 	}{
 		RequestID:     req.RequestID,
 		Image:         req.Image,
+		Name:          req.Name,
+		Replicas:      req.Replicas,
+		ReplicaSet:    req.ReplicaSet,
 		CPUMillicores: req.CPUMillicores,
 		MemoryBytes:   req.MemoryBytes,
 		ExposePort:    req.ExposePort,
 		Environment:   req.Environment,
+		RestartPolicy: req.RestartPolicy,
+		HealthCheck:   req.HealthCheck,
+		LeaseSeconds:  req.LeaseSeconds,
+		PriorityClass: req.PriorityClass,
+		Job:           req.Job,
+		Priority:      req.Priority,
+		QueueTimeout:  req.QueueTimeoutSeconds,
 		RequesterID:   req.RequesterID,
 		Timestamp:     req.Timestamp,
 	}
@@ -6,6 +6,10 @@
 package protocol
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -22,6 +26,37 @@ const (
 	// StatusProtocol is the protocol for status updates
 	StatusProtocol = "/peercompute/status/1.0.0"
 
+	// StopProtocol is the protocol for stop requests
+	StopProtocol = "/peercompute/stop/1.0.0"
+
+	// RenewProtocol is the protocol for lease renewals
+	RenewProtocol = "/peercompute/renew/1.0.0"
+
+	// NotifyProtocol is the protocol providers use to notify deployment owners
+	NotifyProtocol = "/peercompute/notify/1.0.0"
+
+	// ArtifactProtocol is the protocol for uploading and downloading job artifacts
+	ArtifactProtocol = "/peercompute/artifacts/1.0.0"
+
+	// CronProtocol is the protocol for managing scheduled jobs
+	CronProtocol = "/peercompute/cron/1.0.0"
+
+	// EventsProtocol is the protocol for streaming deployment lifecycle events
+	EventsProtocol = "/peercompute/events/1.0.0"
+
+	// UpdateProtocol is the protocol for in-place updates and rollbacks
+	UpdateProtocol = "/peercompute/update/1.0.0"
+
+	// UsageProtocol is the protocol for querying metered resource usage
+	UsageProtocol = "/peercompute/usage/1.0.0"
+
+	// ReceiptProtocol is the protocol providers use to have usage receipts
+	// countersigned by requesters
+	ReceiptProtocol = "/peercompute/receipts/1.0.0"
+
+	// CapacityTopic is the gossip topic for provider capacity advertisements
+	CapacityTopic = "peercompute/capacity/1"
+
 	// MaxMessageSize is the maximum size of a protocol message (10MB)
 	MaxMessageSize = 10 * 1024 * 1024
 
@@ -41,6 +76,7 @@ const (
 	MessageTypeLogEntry
 	MessageTypeStatusRequest
 	MessageTypeStatusResponse
+	MessageTypeCapacityAdvert
 )
 
 // DeployRequest represents a request to deploy a container.
@@ -51,6 +87,25 @@ type DeployRequest struct {
 	// Image is the Docker image to deploy (e.g., "nginx:alpine")
 	Image string `json:"image"`
 
+	// ImageDigest is the digest the provider resolved Image to when it
+	// received the request. It is not sent; requesters pin an image with
+	// "image@sha256:..."
+	ImageDigest string `json:"-"`
+
+	// Name is an optional name, unique among the requester's active
+	// deployments on the provider, usable in place of the deployment ID and
+	// as the gateway subdomain
+	Name string `json:"name,omitempty"`
+
+	// Replicas is how many identical containers this provider runs for the
+	// request (0 = 1)
+	Replicas int `json:"replicas,omitempty"`
+
+	// ReplicaSet groups replicas that serve the same gateway route, possibly
+	// on several providers. The requester picks it when spreading replicas;
+	// the provider generates one otherwise
+	ReplicaSet string `json:"replica_set,omitempty"`
+
 	// CPUMillicores is the CPU limit in millicores (1000 = 1 CPU)
 	CPUMillicores int64 `json:"cpu_millicores"`
 
@@ -63,6 +118,30 @@ type DeployRequest struct {
 	// Environment is a map of environment variables
 	Environment map[string]string `json:"environment,omitempty"`
 
+	// RestartPolicy controls whether the provider restarts the container when
+	// it exits (nil = never)
+	RestartPolicy *RestartPolicy `json:"restart_policy,omitempty"`
+
+	// HealthCheck is an optional probe the provider runs against the container
+	HealthCheck *HealthCheck `json:"health_check,omitempty"`
+
+	// LeaseSeconds is how long the deployment may run before it is stopped
+	// unless renewed (0 = no lease, subject to the provider's maximum)
+	LeaseSeconds int64 `json:"lease_seconds,omitempty"`
+
+	// PriorityClass decides which deployments may preempt which (empty = normal)
+	PriorityClass PriorityClass `json:"priority_class,omitempty"`
+
+	// Job runs the container once to completion instead of as a service
+	Job *JobSpec `json:"job,omitempty"`
+
+	// Priority orders queued requests; higher values are admitted first
+	Priority int `json:"priority,omitempty"`
+
+	// QueueTimeoutSeconds is how long the request may wait in the provider's
+	// admission queue when it is full (0 = fail immediately)
+	QueueTimeoutSeconds int64 `json:"queue_timeout_seconds,omitempty"`
+
 	// RequesterID is the peer ID of the requester
 	RequesterID string `json:"requester_id"`
 
@@ -73,6 +152,426 @@ type DeployRequest struct {
 	Signature []byte `json:"signature"`
 }
 
+// MaxDeploymentNameLength keeps names usable as a DNS label once the
+// gateway appends the owner's label (see GatewaySubdomain).
+const MaxDeploymentNameLength = 63 - 1 - ownerLabelLength
+
+// ownerLabelLength is the length of an owner label in hex digits.
+const ownerLabelLength = 8
+
+// ValidateDeploymentName checks that a deployment name can be used as a
+// gateway subdomain: lowercase letters, digits and hyphens, starting with a
+// letter and not ending with a hyphen. Names may not look like deployment
+// IDs, so a name never shadows an ID.
+func ValidateDeploymentName(name string) error {
+	if name == "" || len(name) > MaxDeploymentNameLength {
+		return fmt.Errorf("deployment name must be 1-%d characters", MaxDeploymentNameLength)
+	}
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z':
+		case (c >= '0' && c <= '9') || c == '-':
+			if i == 0 {
+				return fmt.Errorf("deployment name %q must start with a lowercase letter", name)
+			}
+		default:
+			return fmt.Errorf("deployment name %q may only contain lowercase letters, digits and hyphens", name)
+		}
+	}
+	if strings.HasSuffix(name, "-") {
+		return fmt.Errorf("deployment name %q must not end with a hyphen", name)
+	}
+	for _, prefix := range []string{DeploymentIDPrefix, ReplicaSetIDPrefix} {
+		if strings.HasPrefix(name, prefix) {
+			return fmt.Errorf("deployment name %q must not start with %q", name, prefix)
+		}
+	}
+	return nil
+}
+
+// DeploymentIDPrefix prefixes every deployment ID.
+const DeploymentIDPrefix = "dep-"
+
+// ValidateDeploymentID checks that a deployment ID is "dep-" followed by 16
+// lowercase hex digits, the form providers generate.
+func ValidateDeploymentID(id string) error {
+	hexPart, ok := strings.CutPrefix(id, DeploymentIDPrefix)
+	if !ok || !isHexID(hexPart) {
+		return fmt.Errorf("invalid deployment ID %q", id)
+	}
+	return nil
+}
+
+// OwnerLabel returns the short label of a requester's peer ID that scopes
+// its deployment names on the gateway.
+func OwnerLabel(requesterID string) string {
+	sum := sha256.Sum256([]byte(requesterID))
+	return hex.EncodeToString(sum[:ownerLabelLength/2])
+}
+
+// GatewaySubdomain returns the gateway subdomain of a deployment. Named
+// deployments are served under their name followed by their owner's label,
+// so names are scoped per requester and nobody can claim another
+// requester's name. Unnamed replicas are served under their replica set and
+// other deployments under their ID; both are random. Names never look like
+// IDs, so the three never collide.
+func GatewaySubdomain(deploymentID, name, replicaSet, requesterID string) string {
+	if name != "" {
+		return name + "-" + OwnerLabel(requesterID)
+	}
+	if replicaSet != "" {
+		return replicaSet
+	}
+	return deploymentID
+}
+
+// ReplicaSetIDPrefix prefixes every replica set ID.
+const ReplicaSetIDPrefix = "rs-"
+
+// MaxReplicas limits how many replicas one request may ask a provider for.
+const MaxReplicas = 10
+
+// MaxCPUMillicores is the highest CPU limit a requester may ask for.
+const MaxCPUMillicores = 32000
+
+// ValidateReplicas checks the replica fields of a deployment request.
+// Jobs run to completion and are not replicated.
+func (r *DeployRequest) ValidateReplicas() error {
+	if r.Replicas < 0 || r.Replicas > MaxReplicas {
+		return fmt.Errorf("replicas must be between 1 and %d", MaxReplicas)
+	}
+	if r.Replicas > 1 && r.Job != nil {
+		return fmt.Errorf("jobs cannot have replicas")
+	}
+	if r.ReplicaSet != "" {
+		return ValidateReplicaSetID(r.ReplicaSet)
+	}
+	return nil
+}
+
+// ValidateReplicaSetID checks that a replica set ID is "rs-" followed by 16
+// lowercase hex digits, the form requesters and providers generate.
+func ValidateReplicaSetID(id string) error {
+	hexPart, ok := strings.CutPrefix(id, ReplicaSetIDPrefix)
+	if !ok || !isHexID(hexPart) {
+		return fmt.Errorf("invalid replica set ID %q", id)
+	}
+	return nil
+}
+
+// isHexID reports whether s is 16 lowercase hex digits.
+func isHexID(s string) bool {
+	if len(s) != 16 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// PriorityClass ranks deployments for preemption.
+type PriorityClass string
+
+const (
+	// ClassBestEffort deployments may be preempted by any higher class
+	ClassBestEffort PriorityClass = "best-effort"
+	// ClassNormal is the default class
+	ClassNormal PriorityClass = "normal"
+	// ClassCritical deployments may preempt all lower classes
+	ClassCritical PriorityClass = "critical"
+)
+
+// DefaultAllowedClasses are the classes a peer may use unless the provider
+// grants it others.
+var DefaultAllowedClasses = []PriorityClass{ClassBestEffort, ClassNormal}
+
+// Rank orders classes; a deployment may preempt deployments of lower rank.
+// Unknown classes rank below best-effort.
+func (c PriorityClass) Rank() int {
+	switch c {
+	case ClassBestEffort:
+		return 1
+	case ClassNormal, "":
+		return 2
+	case ClassCritical:
+		return 3
+	}
+	return 0
+}
+
+// Validate checks that the class is known.
+func (c PriorityClass) Validate() error {
+	if c.Rank() == 0 {
+		return fmt.Errorf("unknown priority class %q (use best-effort, normal or critical)", c)
+	}
+	return nil
+}
+
+// JobSpec describes a run-to-completion job.
+type JobSpec struct {
+	// Command overrides the image entrypoint (empty = image default)
+	Command []string `json:"command,omitempty"`
+
+	// Args overrides the image command arguments (empty = image default)
+	Args []string `json:"args,omitempty"`
+
+	// DeadlineSeconds is how long the job may run in total, including
+	// retries, before it is stopped and failed (0 = no deadline)
+	DeadlineSeconds int64 `json:"deadline_seconds,omitempty"`
+
+	// Retries is how many times a failed job is retried
+	Retries int `json:"retries,omitempty"`
+
+	// Input is the digest of an uploaded tarball copied into the container
+	// before it starts
+	Input string `json:"input,omitempty"`
+
+	// InputPath is the container directory the input is extracted into
+	// (empty = DefaultInputPath)
+	InputPath string `json:"input_path,omitempty"`
+
+	// Outputs are container paths copied out as artifacts after the job exits
+	Outputs []string `json:"outputs,omitempty"`
+}
+
+const (
+	// DefaultInputPath is where job inputs are extracted by default
+	DefaultInputPath = "/"
+
+	// MaxJobOutputs limits how many output paths a job may declare
+	MaxJobOutputs = 16
+)
+
+// Validate checks that the job spec is well-formed.
+func (j *JobSpec) Validate() error {
+	if len(j.Command) > 0 && strings.TrimSpace(j.Command[0]) == "" {
+		return fmt.Errorf("job command must start with an executable")
+	}
+	if j.DeadlineSeconds < 0 {
+		return fmt.Errorf("job deadline must not be negative")
+	}
+	if j.Retries < 0 {
+		return fmt.Errorf("job retries must not be negative")
+	}
+	if j.InputPath != "" {
+		if j.Input == "" {
+			return fmt.Errorf("job input path set without an input")
+		}
+		if err := validContainerPath(j.InputPath); err != nil {
+			return fmt.Errorf("invalid job input path: %w", err)
+		}
+	}
+	if len(j.Outputs) > MaxJobOutputs {
+		return fmt.Errorf("at most %d job outputs allowed", MaxJobOutputs)
+	}
+	for _, p := range j.Outputs {
+		if err := validContainerPath(p); err != nil {
+			return fmt.Errorf("invalid job output %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// InputDir returns where the job input is extracted.
+func (j *JobSpec) InputDir() string {
+	if j.InputPath == "" {
+		return DefaultInputPath
+	}
+	return j.InputPath
+}
+
+// validContainerPath checks that p is a clean absolute path.
+func validContainerPath(p string) error {
+	if !strings.HasPrefix(p, "/") {
+		return fmt.Errorf("must be absolute")
+	}
+	for _, part := range strings.Split(p, "/") {
+		if part == ".." {
+			return fmt.Errorf("must not contain ..")
+		}
+	}
+	if strings.ContainsAny(p, ":\x00") {
+		return fmt.Errorf("contains invalid characters")
+	}
+	return nil
+}
+
+// RestartMode selects when a deployment's container is restarted.
+type RestartMode string
+
+const (
+	// RestartNever leaves exited containers stopped
+	RestartNever RestartMode = "never"
+	// RestartOnFailure restarts containers that exit with a non-zero code
+	RestartOnFailure RestartMode = "on-failure"
+	// RestartAlways restarts containers whenever they exit
+	RestartAlways RestartMode = "always"
+)
+
+// RestartPolicy describes how a provider restarts a crashed deployment.
+// Restarts are delayed with exponential backoff to contain crash loops.
+type RestartPolicy struct {
+	// Mode is when to restart
+	Mode RestartMode `json:"mode"`
+
+	// MaxRetries limits restarts for on-failure (0 = unlimited)
+	MaxRetries int `json:"max_retries,omitempty"`
+}
+
+// Validate checks that the restart policy is well-formed.
+func (p *RestartPolicy) Validate() error {
+	switch p.Mode {
+	case RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return fmt.Errorf("unknown restart mode %q (use never, on-failure or always)", p.Mode)
+	}
+	if p.MaxRetries < 0 {
+		return fmt.Errorf("max retries must not be negative")
+	}
+	return nil
+}
+
+// HealthCheckType selects how a container's health is probed.
+type HealthCheckType string
+
+const (
+	// HealthCheckHTTP expects a 2xx or 3xx response to a GET request
+	HealthCheckHTTP HealthCheckType = "http"
+	// HealthCheckTCP expects a TCP connection to be accepted
+	HealthCheckTCP HealthCheckType = "tcp"
+	// HealthCheckExec expects a command run inside the container to exit 0
+	HealthCheckExec HealthCheckType = "exec"
+)
+
+// Health check defaults, used when the corresponding field is zero.
+const (
+	DefaultHealthInterval           = 10 * time.Second
+	DefaultHealthTimeout            = 3 * time.Second
+	DefaultHealthHealthyThreshold   = 1
+	DefaultHealthUnhealthyThreshold = 3
+)
+
+// HealthCheck describes a probe the provider runs against a deployment.
+type HealthCheck struct {
+	// Type is the probe type
+	Type HealthCheckType `json:"type"`
+
+	// Port is the container port for http and tcp probes (0 = ExposePort)
+	Port int `json:"port,omitempty"`
+
+	// Path is the request path for http probes (default "/")
+	Path string `json:"path,omitempty"`
+
+	// Command is run inside the container for exec probes
+	Command []string `json:"command,omitempty"`
+
+	// IntervalSeconds is the time between probes
+	IntervalSeconds int `json:"interval_seconds,omitempty"`
+
+	// TimeoutSeconds is how long a single probe may take
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+
+	// StartPeriodSeconds is a grace period after start during which failed
+	// probes do not count towards UnhealthyThreshold
+	StartPeriodSeconds int `json:"start_period_seconds,omitempty"`
+
+	// HealthyThreshold is the consecutive successes needed to become healthy
+	HealthyThreshold int `json:"healthy_threshold,omitempty"`
+
+	// UnhealthyThreshold is the consecutive failures needed to become unhealthy
+	UnhealthyThreshold int `json:"unhealthy_threshold,omitempty"`
+
+	// HoldRoute delays gateway registration until the first successful check
+	HoldRoute bool `json:"hold_route,omitempty"`
+}
+
+// Validate checks that the health check is well-formed.
+func (hc *HealthCheck) Validate() error {
+	switch hc.Type {
+	case HealthCheckHTTP:
+		if hc.Path != "" && !strings.HasPrefix(hc.Path, "/") {
+			return fmt.Errorf("health check path must start with /")
+		}
+	case HealthCheckTCP:
+	case HealthCheckExec:
+		if len(hc.Command) == 0 {
+			return fmt.Errorf("exec health check requires a command")
+		}
+	default:
+		return fmt.Errorf("unknown health check type %q (use http, tcp or exec)", hc.Type)
+	}
+	if hc.Port < 0 || hc.Port > 65535 {
+		return fmt.Errorf("invalid health check port %d", hc.Port)
+	}
+	if hc.IntervalSeconds < 0 || hc.TimeoutSeconds < 0 || hc.StartPeriodSeconds < 0 ||
+		hc.HealthyThreshold < 0 || hc.UnhealthyThreshold < 0 {
+		return fmt.Errorf("health check intervals and thresholds must not be negative")
+	}
+	return nil
+}
+
+// TargetPort returns the port probed by http and tcp checks.
+func (hc *HealthCheck) TargetPort(exposePort int) int {
+	if hc.Port > 0 {
+		return hc.Port
+	}
+	return exposePort
+}
+
+// Interval returns the time between probes.
+func (hc *HealthCheck) Interval() time.Duration {
+	return secondsOr(hc.IntervalSeconds, DefaultHealthInterval)
+}
+
+// Timeout returns how long a single probe may take.
+func (hc *HealthCheck) Timeout() time.Duration {
+	return secondsOr(hc.TimeoutSeconds, DefaultHealthTimeout)
+}
+
+// StartPeriod returns the grace period after the container starts.
+func (hc *HealthCheck) StartPeriod() time.Duration {
+	return time.Duration(hc.StartPeriodSeconds) * time.Second
+}
+
+// Healthy returns the consecutive successes needed to become healthy.
+func (hc *HealthCheck) Healthy() int {
+	if hc.HealthyThreshold > 0 {
+		return hc.HealthyThreshold
+	}
+	return DefaultHealthHealthyThreshold
+}
+
+// Unhealthy returns the consecutive failures needed to become unhealthy.
+func (hc *HealthCheck) Unhealthy() int {
+	if hc.UnhealthyThreshold > 0 {
+		return hc.UnhealthyThreshold
+	}
+	return DefaultHealthUnhealthyThreshold
+}
+
+// secondsOr converts seconds to a duration, using def when seconds is zero.
+func secondsOr(seconds int, def time.Duration) time.Duration {
+	if seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return def
+}
+
+// HealthState is the result of a deployment's health checks.
+type HealthState string
+
+const (
+	// HealthStarting means no check has succeeded or failed conclusively yet
+	HealthStarting HealthState = "starting"
+	// HealthHealthy means the last checks succeeded
+	HealthHealthy HealthState = "healthy"
+	// HealthUnhealthy means the last checks failed
+	HealthUnhealthy HealthState = "unhealthy"
+)
+
 // DeployResponse is the response to a deployment request.
 type DeployResponse struct {
 	// RequestID matches the request
@@ -93,27 +592,146 @@ type DeployResponse struct {
 	// ContainerID is the Docker container ID
 	ContainerID string `json:"container_id,omitempty"`
 
+	// LeaseExpiresAt is when the deployment will be stopped unless renewed
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty"`
+
+	// QueuePosition is the request's position in the admission queue
+	// (1 = next) when Status is queued
+	QueuePosition int `json:"queue_position,omitempty"`
+
+	// ReplicaSet is the replica set the deployments belong to, if replicated
+	ReplicaSet string `json:"replica_set,omitempty"`
+
+	// Replicas lists every replica started for the request; the top-level
+	// deployment fields describe the first one
+	Replicas []ReplicaInfo `json:"replicas,omitempty"`
+
 	// Message is a human-readable message
 	Message string `json:"message,omitempty"`
 
 	// Error is the error message if the deployment failed
 	Error string `json:"error,omitempty"`
+
+	// Code is a machine-readable reason when the request was refused
+	Code ErrorCode `json:"code,omitempty"`
+
+	// RetryAfterMs suggests when a refused request may be retried
+	RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
+}
+
+// ReplicaInfo describes one replica started for a deployment request.
+type ReplicaInfo struct {
+	// DeploymentID is the replica's deployment ID
+	DeploymentID string `json:"deployment_id"`
+
+	// Status is the replica's status
+	Status DeploymentStatus `json:"status"`
+
+	// ContainerID is the replica's container ID, once started
+	ContainerID string `json:"container_id,omitempty"`
+
+	// QueuePosition is the replica's position in the admission queue
+	QueuePosition int `json:"queue_position,omitempty"`
+}
+
+// ErrorCode is a machine-readable reason for a refused request.
+type ErrorCode string
+
+const (
+	// ErrCodeRateLimited means the peer exceeded its stream or request limits
+	ErrCodeRateLimited ErrorCode = "rate_limited"
+
+	// ErrCodeInsufficientCredit means the peer has consumed more from the
+	// provider than its credit policy allows
+	ErrCodeInsufficientCredit ErrorCode = "insufficient_credit"
+
+	// ErrCodeReceiptRejected means the requester disputes a usage receipt
+	// and will not countersign it
+	ErrCodeReceiptRejected ErrorCode = "receipt_rejected"
+
+	// ErrCodeDraining means the provider is drained for maintenance and
+	// not accepting new deployments
+	ErrCodeDraining ErrorCode = "draining"
+
+	// ErrCodePolicyViolation means the provider's security policy does not
+	// allow the image or resources
+	ErrCodePolicyViolation ErrorCode = "policy_violation"
+)
+
+// ErrorResponse is written when a request is refused before it is processed.
+// Its fields are a subset of every response type, so it decodes into whichever
+// response the client expects.
+type ErrorResponse struct {
+	// Success is always false
+	Success bool `json:"success"`
+
+	// Code is a machine-readable reason
+	Code ErrorCode `json:"code"`
+
+	// Message is a human-readable message
+	Message string `json:"message"`
+
+	// Error repeats Message for clients that only check Error
+	Error string `json:"error"`
+
+	// RetryAfterMs suggests when the request may be retried
+	RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
 }
 
 // DeploymentStatus represents the status of a deployment.
 type DeploymentStatus string
 
 const (
-	StatusPending     DeploymentStatus = "pending"
-	StatusPulling     DeploymentStatus = "pulling"
-	StatusStarting    DeploymentStatus = "starting"
-	StatusRunning     DeploymentStatus = "running"
-	StatusStopping    DeploymentStatus = "stopping"
-	StatusStopped     DeploymentStatus = "stopped"
-	StatusFailed      DeploymentStatus = "failed"
-	StatusTerminated  DeploymentStatus = "terminated"
+	StatusPending    DeploymentStatus = "pending"
+	StatusPulling    DeploymentStatus = "pulling"
+	StatusStarting   DeploymentStatus = "starting"
+	StatusRunning    DeploymentStatus = "running"
+	StatusStopping   DeploymentStatus = "stopping"
+	StatusStopped    DeploymentStatus = "stopped"
+	StatusFailed     DeploymentStatus = "failed"
+	StatusTerminated DeploymentStatus = "terminated"
+	StatusBackoff    DeploymentStatus = "backoff"
+	StatusQueued     DeploymentStatus = "queued"
+	StatusSucceeded  DeploymentStatus = "succeeded"
+)
+
+// TerminationReason explains why a deployment is no longer running.
+type TerminationReason string
+
+const (
+	// ReasonExited means the container exited with code 0
+	ReasonExited TerminationReason = "Exited"
+	// ReasonError means the container exited with a non-zero code
+	ReasonError TerminationReason = "Error"
+	// ReasonOOMKilled means the container exceeded its memory limit
+	ReasonOOMKilled TerminationReason = "OOMKilled"
+	// ReasonContainerRemoved means the container was removed outside Peer Compute
+	ReasonContainerRemoved TerminationReason = "ContainerRemoved"
+	// ReasonLeaseExpired means the deployment's lease ran out without renewal
+	ReasonLeaseExpired TerminationReason = "LeaseExpired"
+	// ReasonQueueTimeout means the request was not admitted before its queue timeout
+	ReasonQueueTimeout TerminationReason = "QueueTimeout"
+	// ReasonPreempted means the deployment was stopped to make room for a
+	// deployment of a higher priority class
+	ReasonPreempted TerminationReason = "Preempted"
+	// ReasonDeadlineExceeded means a job ran past its deadline
+	ReasonDeadlineExceeded TerminationReason = "DeadlineExceeded"
+	// ReasonReplaced means a scheduled run was stopped because the next run
+	// started under the replace concurrency policy
+	ReasonReplaced TerminationReason = "Replaced"
+	// ReasonDrained means the provider was drained for maintenance
+	ReasonDrained TerminationReason = "Drained"
 )
 
+// IsFinished reports whether a deployment in this status has stopped for good.
+func (s DeploymentStatus) IsFinished() bool {
+	switch s {
+	case StatusStopped, StatusFailed, StatusTerminated, StatusSucceeded:
+		return true
+	}
+	return false
+}
+
 // StopRequest is a request to stop a deployment.
 type StopRequest struct {
 	// DeploymentID is the deployment to stop
@@ -142,6 +760,532 @@ type StopResponse struct {
 
 	// Error is the error message if the stop failed
 	Error string `json:"error,omitempty"`
+
+	// Code is a machine-readable reason when the request was refused
+	Code ErrorCode `json:"code,omitempty"`
+
+	// RetryAfterMs suggests when a refused request may be retried
+	RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
+}
+
+// RenewRequest extends a deployment's lease.
+type RenewRequest struct {
+	// DeploymentID is the deployment to renew
+	DeploymentID string `json:"deployment_id"`
+
+	// LeaseSeconds is the new lease, counted from now
+	LeaseSeconds int64 `json:"lease_seconds"`
+
+	// RequesterID is the peer ID of the requester
+	RequesterID string `json:"requester_id"`
+
+	// Timestamp is when the request was created
+	Timestamp int64 `json:"timestamp"`
+
+	// Signature is the Ed25519 signature
+	Signature []byte `json:"signature"`
+}
+
+// UpdateRequest rolls out a new revision of a running deployment in place,
+// keeping its ID and gateway route. It either changes the current revision's
+// image, environment or resources, or with Rollback restores an earlier
+// revision from the provider's history.
+type UpdateRequest struct {
+	// DeploymentID is the deployment to update (ID or name)
+	DeploymentID string `json:"deployment_id"`
+
+	// Image is the new image (empty = unchanged)
+	Image string `json:"image,omitempty"`
+
+	// Environment sets environment variables, keeping the others
+	Environment map[string]string `json:"environment,omitempty"`
+
+	// UnsetEnvironment removes environment variables
+	UnsetEnvironment []string `json:"unset_environment,omitempty"`
+
+	// CPUMillicores is the new CPU limit (0 = unchanged)
+	CPUMillicores int64 `json:"cpu_millicores,omitempty"`
+
+	// MemoryBytes is the new memory limit (0 = unchanged)
+	MemoryBytes int64 `json:"memory_bytes,omitempty"`
+
+	// Rollback restores an earlier revision instead of applying changes
+	Rollback bool `json:"rollback,omitempty"`
+
+	// ToRevision is the revision to roll back to (0 = the previous one)
+	ToRevision int `json:"to_revision,omitempty"`
+
+	// RequesterID is the peer ID of the requester
+	RequesterID string `json:"requester_id"`
+
+	// Timestamp is when the request was created
+	Timestamp int64 `json:"timestamp"`
+
+	// Signature is the Ed25519 signature
+	Signature []byte `json:"signature"`
+}
+
+// Validate checks that an update request asks for exactly one kind of change.
+func (r *UpdateRequest) Validate() error {
+	changes := r.Image != "" || len(r.Environment) > 0 || len(r.UnsetEnvironment) > 0 ||
+		r.CPUMillicores != 0 || r.MemoryBytes != 0
+	if r.Rollback {
+		if changes {
+			return fmt.Errorf("a rollback cannot change the image, environment or resources")
+		}
+		if r.ToRevision < 0 {
+			return fmt.Errorf("revision must not be negative")
+		}
+		return nil
+	}
+	if r.ToRevision != 0 {
+		return fmt.Errorf("a target revision is only used by rollbacks")
+	}
+	if !changes {
+		return fmt.Errorf("update changes nothing")
+	}
+	if r.CPUMillicores < 0 || r.MemoryBytes < 0 {
+		return fmt.Errorf("resource limits must not be negative")
+	}
+	return nil
+}
+
+// UpdateResponse is the response to an update request, sent once the new
+// revision serves the deployment or the update was abandoned.
+type UpdateResponse struct {
+	// DeploymentID is the deployment that was updated
+	DeploymentID string `json:"deployment_id"`
+
+	// Success indicates if the new revision is now running
+	Success bool `json:"success"`
+
+	// Revision is the deployment's revision after the update
+	Revision int `json:"revision,omitempty"`
+
+	// Image is the image of that revision
+	Image string `json:"image,omitempty"`
+
+	// Message is a human-readable message
+	Message string `json:"message,omitempty"`
+
+	// Error is the error message if the update failed
+	Error string `json:"error,omitempty"`
+
+	// Code is a machine-readable reason when the request was refused
+	Code ErrorCode `json:"code,omitempty"`
+
+	// RetryAfterMs suggests when a refused request may be retried
+	RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
+}
+
+// RenewResponse is the response to a renew request.
+type RenewResponse struct {
+	// DeploymentID is the deployment that was renewed
+	DeploymentID string `json:"deployment_id"`
+
+	// Success indicates if the renewal was successful
+	Success bool `json:"success"`
+
+	// LeaseExpiresAt is the new lease expiry
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty"`
+
+	// Message is a human-readable message
+	Message string `json:"message,omitempty"`
+
+	// Error is the error message if the renewal failed
+	Error string `json:"error,omitempty"`
+
+	// Code is a machine-readable reason when the request was refused
+	Code ErrorCode `json:"code,omitempty"`
+
+	// RetryAfterMs suggests when a refused request may be retried
+	RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
+}
+
+// ArtifactOp selects what an artifact request does.
+type ArtifactOp string
+
+const (
+	// ArtifactUpload stores the bytes following the request
+	ArtifactUpload ArtifactOp = "upload"
+	// ArtifactDownload returns an artifact's bytes after the response
+	ArtifactDownload ArtifactOp = "download"
+)
+
+// ArtifactRequest is sent on ArtifactProtocol as a single JSON line. For
+// uploads, exactly Size raw bytes follow it on the stream.
+type ArtifactRequest struct {
+	// Op is the operation
+	Op ArtifactOp `json:"op"`
+
+	// Digest is the artifact's "sha256:<hex>" digest; for uploads it is
+	// checked against the content
+	Digest string `json:"digest"`
+
+	// Size is the number of bytes uploaded
+	Size int64 `json:"size,omitempty"`
+}
+
+// ArtifactResponse is sent as a single JSON line. For successful downloads,
+// exactly Size raw bytes follow it on the stream.
+type ArtifactResponse struct {
+	// Success indicates whether the operation succeeded
+	Success bool `json:"success"`
+
+	// Digest is the artifact's digest
+	Digest string `json:"digest,omitempty"`
+
+	// Size is the artifact's size in bytes
+	Size int64 `json:"size,omitempty"`
+
+	// Error is set if the request failed
+	Error string `json:"error,omitempty"`
+
+	// Code is a machine-readable reason when the request was refused
+	Code ErrorCode `json:"code,omitempty"`
+
+	// RetryAfterMs suggests when a refused request may be retried
+	RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
+}
+
+// Artifact is a job output copied out of the container.
+type Artifact struct {
+	// Path is the container path the artifact was copied from
+	Path string `json:"path"`
+
+	// Digest is the "sha256:<hex>" digest of the tarball
+	Digest string `json:"digest,omitempty"`
+
+	// Size is the tarball's size in bytes
+	Size int64 `json:"size,omitempty"`
+
+	// Error is set if the path could not be copied out
+	Error string `json:"error,omitempty"`
+}
+
+// ConcurrencyPolicy decides what happens when a scheduled run is due while
+// the previous run is still active.
+type ConcurrencyPolicy string
+
+const (
+	// ConcurrencyForbid skips the new run
+	ConcurrencyForbid ConcurrencyPolicy = "forbid"
+	// ConcurrencyReplace stops the active run and starts the new one
+	ConcurrencyReplace ConcurrencyPolicy = "replace"
+	// ConcurrencyAllow starts the new run alongside the active one
+	ConcurrencyAllow ConcurrencyPolicy = "allow"
+)
+
+const (
+	// DefaultCronHistory is how many runs of a schedule are kept by default
+	DefaultCronHistory = 5
+
+	// MaxCronHistory caps how many runs of a schedule are kept
+	MaxCronHistory = 50
+)
+
+// CronSpec describes a job launched on a schedule.
+type CronSpec struct {
+	// Name is an optional label for the schedule
+	Name string `json:"name,omitempty"`
+
+	// Schedule is a five-field cron expression evaluated in UTC
+	// (e.g., "0 2 * * *" or "@daily")
+	Schedule string `json:"schedule"`
+
+	// ConcurrencyPolicy applies when a run is due while another is active
+	// (empty = forbid)
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrency_policy,omitempty"`
+
+	// History is how many runs are kept with their exit codes and logs
+	// (0 = DefaultCronHistory)
+	History int `json:"history,omitempty"`
+
+	// Image is the Docker image to run
+	Image string `json:"image"`
+
+	// CPUMillicores is the CPU limit per run in millicores
+	CPUMillicores int64 `json:"cpu_millicores"`
+
+	// MemoryBytes is the memory limit per run in bytes
+	MemoryBytes int64 `json:"memory_bytes"`
+
+	// Environment contains environment variables
+	Environment map[string]string `json:"environment,omitempty"`
+
+	// Job is the job each run executes
+	Job *JobSpec `json:"job"`
+
+	// PriorityClass of each run (empty = normal)
+	PriorityClass PriorityClass `json:"priority_class,omitempty"`
+
+	// Priority orders runs in the admission queue
+	Priority int `json:"priority,omitempty"`
+
+	// QueueTimeoutSeconds is how long a run may wait for capacity
+	// (0 = the run fails if the provider is full)
+	QueueTimeoutSeconds int64 `json:"queue_timeout_seconds,omitempty"`
+}
+
+// Validate checks the parts of the spec that don't depend on the provider.
+// The schedule expression itself is parsed by the provider's scheduler.
+func (c *CronSpec) Validate() error {
+	if strings.TrimSpace(c.Schedule) == "" {
+		return fmt.Errorf("schedule is required")
+	}
+	switch c.ConcurrencyPolicy {
+	case "", ConcurrencyForbid, ConcurrencyReplace, ConcurrencyAllow:
+	default:
+		return fmt.Errorf("unknown concurrency policy %q (use forbid, replace or allow)", c.ConcurrencyPolicy)
+	}
+	if c.History < 0 || c.History > MaxCronHistory {
+		return fmt.Errorf("history must be between 0 and %d", MaxCronHistory)
+	}
+	if c.Image == "" {
+		return fmt.Errorf("image is required")
+	}
+	if c.QueueTimeoutSeconds < 0 {
+		return fmt.Errorf("queue timeout must not be negative")
+	}
+	if c.Job == nil {
+		return fmt.Errorf("a job spec is required")
+	}
+	if err := c.Job.Validate(); err != nil {
+		return err
+	}
+	return c.PriorityClass.Validate()
+}
+
+// Policy returns the concurrency policy, defaulting to forbid.
+func (c *CronSpec) Policy() ConcurrencyPolicy {
+	if c.ConcurrencyPolicy == "" {
+		return ConcurrencyForbid
+	}
+	return c.ConcurrencyPolicy
+}
+
+// KeepRuns returns how many runs are kept.
+func (c *CronSpec) KeepRuns() int {
+	if c.History <= 0 {
+		return DefaultCronHistory
+	}
+	return c.History
+}
+
+// CronRun is one run of a schedule.
+type CronRun struct {
+	// ScheduledAt is the tick the run belongs to
+	ScheduledAt time.Time `json:"scheduled_at"`
+
+	// DeploymentID is the run's deployment (empty if it was skipped)
+	DeploymentID string `json:"deployment_id,omitempty"`
+
+	// Status is the run's deployment status when listed
+	Status DeploymentStatus `json:"status,omitempty"`
+
+	// ExitCode is the run's exit code once it finished
+	ExitCode *int `json:"exit_code,omitempty"`
+
+	// Reason is why the run finished
+	Reason TerminationReason `json:"reason,omitempty"`
+
+	// Error describes why the run was skipped, failed to start or failed
+	Error string `json:"error,omitempty"`
+}
+
+// CronJob is a schedule registered with a provider.
+type CronJob struct {
+	// ID is the schedule's identifier
+	ID string `json:"id"`
+
+	// RequesterID is the peer that registered the schedule
+	RequesterID string `json:"requester_id"`
+
+	// Spec is the schedule and job
+	Spec CronSpec `json:"spec"`
+
+	// CreatedAt is when the schedule was registered
+	CreatedAt time.Time `json:"created_at"`
+
+	// NextRunAt is the next tick
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+
+	// Runs are the most recent runs, oldest first
+	Runs []CronRun `json:"runs,omitempty"`
+}
+
+// CronOp selects what a cron request does.
+type CronOp string
+
+const (
+	// CronRegister registers a new schedule
+	CronRegister CronOp = "register"
+	// CronList lists the requester's schedules
+	CronList CronOp = "list"
+	// CronDelete deletes one of the requester's schedules
+	CronDelete CronOp = "delete"
+)
+
+// CronRequest manages the requester's schedules on a provider.
+type CronRequest struct {
+	// Op is the operation
+	Op CronOp `json:"op"`
+
+	// ScheduleID is the schedule to delete
+	ScheduleID string `json:"schedule_id,omitempty"`
+
+	// Spec is the schedule to register
+	Spec *CronSpec `json:"spec,omitempty"`
+
+	// RequesterID is the peer ID of the requester
+	RequesterID string `json:"requester_id"`
+
+	// Timestamp is when the request was created
+	Timestamp int64 `json:"timestamp"`
+
+	// Signature is the Ed25519 signature
+	Signature []byte `json:"signature"`
+}
+
+// CronResponse is the response to a cron request.
+type CronResponse struct {
+	// Success indicates whether the operation succeeded
+	Success bool `json:"success"`
+
+	// Schedules are the registered or listed schedules
+	Schedules []CronJob `json:"schedules,omitempty"`
+
+	// Message is a human-readable message
+	Message string `json:"message,omitempty"`
+
+	// Error is set if the request failed
+	Error string `json:"error,omitempty"`
+
+	// Code is a machine-readable reason when the request was refused
+	Code ErrorCode `json:"code,omitempty"`
+
+	// RetryAfterMs suggests when a refused request may be retried
+	RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
+}
+
+// Notification tells a deployment's owner that the provider changed its
+// deployment, e.g. preempted it. The sender is the authenticated remote peer
+// of the stream.
+type Notification struct {
+	// DeploymentID is the affected deployment
+	DeploymentID string `json:"deployment_id"`
+
+	// Status is the deployment's new status
+	Status DeploymentStatus `json:"status"`
+
+	// Reason explains the change
+	Reason TerminationReason `json:"reason,omitempty"`
+
+	// Message is a human-readable message
+	Message string `json:"message,omitempty"`
+
+	// StopAt is when the provider will stop the deployment, for advance
+	// warnings such as a drain
+	StopAt *time.Time `json:"stop_at,omitempty"`
+
+	// Timestamp is when the change happened
+	Timestamp int64 `json:"timestamp"`
+}
+
+// EventType identifies a deployment lifecycle event.
+type EventType string
+
+const (
+	// EventCreated is emitted when a deployment is accepted (or queued)
+	EventCreated EventType = "created"
+	// EventPulling is emitted when the provider starts pulling the image
+	EventPulling EventType = "pulling"
+	// EventStarted is emitted when the container starts or restarts
+	EventStarted EventType = "started"
+	// EventHealthy is emitted when the health check passes
+	EventHealthy EventType = "healthy"
+	// EventUnhealthy is emitted when the health check fails
+	EventUnhealthy EventType = "unhealthy"
+	// EventExited is emitted when the container exits on its own
+	EventExited EventType = "exited"
+	// EventOOMKilled is emitted when the container is killed for exceeding
+	// its memory limit
+	EventOOMKilled EventType = "oom_killed"
+	// EventStopped is emitted when the provider stops a deployment, e.g. on
+	// request or when its lease expires
+	EventStopped EventType = "stopped"
+	// EventPreempted is emitted when a deployment is preempted
+	EventPreempted EventType = "preempted"
+	// EventFailed is emitted when a deployment fails to start
+	EventFailed EventType = "failed"
+	// EventUpdating is emitted when a new revision starts rolling out
+	EventUpdating EventType = "updating"
+	// EventUpdated is emitted when a deployment switched to a new revision
+	EventUpdated EventType = "updated"
+	// EventUpdateFailed is emitted when an update is abandoned; the previous
+	// revision keeps running
+	EventUpdateFailed EventType = "update_failed"
+)
+
+// DeploymentEvent is a change in a deployment's lifecycle.
+type DeploymentEvent struct {
+	// Type is the kind of event
+	Type EventType `json:"type"`
+
+	// DeploymentID is the deployment the event is about
+	DeploymentID string `json:"deployment_id"`
+
+	// Name is the deployment's name (may be empty)
+	Name string `json:"name,omitempty"`
+
+	// RequesterID is the deployment's owner
+	RequesterID string `json:"requester_id"`
+
+	// Image is the deployment's image
+	Image string `json:"image,omitempty"`
+
+	// Status is the deployment's status after the event
+	Status DeploymentStatus `json:"status"`
+
+	// Reason explains why the deployment finished
+	Reason TerminationReason `json:"reason,omitempty"`
+
+	// ExitCode is the container's exit code, for exits
+	ExitCode *int `json:"exit_code,omitempty"`
+
+	// Message is a human-readable message
+	Message string `json:"message,omitempty"`
+
+	// Time is when the event happened
+	Time time.Time `json:"time"`
+}
+
+// EventsRequest asks a provider for the requester's deployment events.
+// Recent events are sent first; with Follow, new events are streamed until
+// either side closes the stream.
+type EventsRequest struct {
+	// DeploymentID limits events to one deployment (empty = all of the
+	// requester's deployments)
+	DeploymentID string `json:"deployment_id,omitempty"`
+
+	// Follow keeps the stream open for new events
+	Follow bool `json:"follow"`
+}
+
+// EventsResponse is the first line of an events stream. If Success is set,
+// DeploymentEvent lines follow.
+type EventsResponse struct {
+	// Success indicates whether events follow
+	Success bool `json:"success"`
+
+	// Error is set if the request was refused
+	Error string `json:"error,omitempty"`
+
+	// Code is a machine-readable reason when the request was refused
+	Code ErrorCode `json:"code,omitempty"`
+
+	// RetryAfterMs suggests when a refused request may be retried
+	RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
 }
 
 // LogEntry represents a log message from a container.
@@ -170,29 +1314,114 @@ type DeploymentStatusInfo struct {
 	// DeploymentID is the deployment ID
 	DeploymentID string `json:"deployment_id"`
 
+	// Name is the deployment's name (may be empty)
+	Name string `json:"name,omitempty"`
+
+	// ReplicaSet is the replica set the deployment belongs to (may be empty)
+	ReplicaSet string `json:"replica_set,omitempty"`
+
 	// Status is the current status
 	Status string `json:"status"`
 
 	// Image is the container image
 	Image string `json:"image"`
 
+	// ImageDigest is the digest the image was resolved to
+	ImageDigest string `json:"image_digest,omitempty"`
+
 	// StartedAt is when the container started
 	StartedAt time.Time `json:"started_at,omitempty"`
 
+	// CPULimit is the CPU limit in millicores
+	CPULimit int64 `json:"cpu_limit,omitempty"`
+
+	// MemoryLimit is the memory limit in bytes
+	MemoryLimit int64 `json:"memory_limit,omitempty"`
+
+	// ExposePort is the exposed container port (0 = not exposed)
+	ExposePort int `json:"expose_port,omitempty"`
+
 	// ExposedURL is the public URL if exposed
 	ExposedURL string `json:"exposed_url,omitempty"`
 
-	// ResourceUsage contains current resource usage
+	// ResourceUsage contains current resource usage, for running
+	// deployments whose container could be sampled
 	ResourceUsage *ResourceUsage `json:"resource_usage,omitempty"`
 
 	// Error is set if the deployment failed
 	Error string `json:"error,omitempty"`
+
+	// ExitCode is the container's exit code once it has stopped
+	ExitCode *int `json:"exit_code,omitempty"`
+
+	// Reason explains why the deployment stopped running
+	Reason TerminationReason `json:"reason,omitempty"`
+
+	// FinishedAt is when the deployment stopped running
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	// RestartCount is how many times the container has been restarted
+	RestartCount int `json:"restart_count,omitempty"`
+
+	// LastExitReason is why the container last exited before a restart
+	LastExitReason TerminationReason `json:"last_exit_reason,omitempty"`
+
+	// NextRestartAt is when a deployment in backoff will be restarted
+	NextRestartAt *time.Time `json:"next_restart_at,omitempty"`
+
+	// Health is the health check state (empty without a health check)
+	Health HealthState `json:"health,omitempty"`
+
+	// HealthCheckedAt is when the last health check completed
+	HealthCheckedAt *time.Time `json:"health_checked_at,omitempty"`
+
+	// HealthError is the last failed health check's error
+	HealthError string `json:"health_error,omitempty"`
+
+	// LeaseExpiresAt is when the deployment will be stopped unless renewed
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty"`
+
+	// QueuePosition is the position in the admission queue (1 = next)
+	QueuePosition int `json:"queue_position,omitempty"`
+
+	// QueueDeadline is when a queued request gives up waiting
+	QueueDeadline *time.Time `json:"queue_deadline,omitempty"`
+
+	// PriorityClass is the deployment's priority class
+	PriorityClass PriorityClass `json:"priority_class,omitempty"`
+
+	// Job is set for run-to-completion jobs
+	Job bool `json:"job,omitempty"`
+
+	// Outputs are the artifacts copied out of a finished job
+	Outputs []Artifact `json:"outputs,omitempty"`
+
+	// CronID is the schedule that started this run
+	CronID string `json:"cron_id,omitempty"`
+
+	// Revision is the deployment's current revision
+	Revision int `json:"revision,omitempty"`
+
+	// Revisions is the revision history, without environment values
+	Revisions []DeploymentRevision `json:"revisions,omitempty"`
+
+	// UpdatingTo is the revision being rolled out, if any
+	UpdatingTo int `json:"updating_to,omitempty"`
 }
 
 // StatusResponse is the response to a status request.
 type StatusResponse struct {
 	// Deployments contains status for one or more deployments
 	Deployments []DeploymentStatusInfo `json:"deployments"`
+
+	// Error is set if the request was refused
+	Error string `json:"error,omitempty"`
+
+	// Code is a machine-readable reason when the request was refused
+	Code ErrorCode `json:"code,omitempty"`
+
+	// RetryAfterMs suggests when a refused request may be retried
+	RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
 }
 
 // ResourceUsage contains resource usage metrics.
@@ -205,6 +1434,157 @@ type ResourceUsage struct {
 
 	// MemoryLimit is the memory limit
 	MemoryLimit int64 `json:"memory_limit"`
+
+	// NetworkRxBytes is the total received since the container started
+	NetworkRxBytes uint64 `json:"network_rx_bytes"`
+
+	// NetworkTxBytes is the total sent since the container started
+	NetworkTxBytes uint64 `json:"network_tx_bytes"`
+
+	// BlockReadBytes is the total read from block devices
+	BlockReadBytes uint64 `json:"block_read_bytes"`
+
+	// BlockWriteBytes is the total written to block devices
+	BlockWriteBytes uint64 `json:"block_write_bytes"`
+
+	// PIDs is the number of processes and threads in the container
+	PIDs uint64 `json:"pids"`
+
+	// PIDsLimit is the process limit (0 = unlimited)
+	PIDsLimit uint64 `json:"pids_limit,omitempty"`
+
+	// SampledAt is when the usage was sampled
+	SampledAt time.Time `json:"sampled_at"`
+}
+
+// UsageRequest asks a provider how much of its resources the requester
+// has consumed.
+type UsageRequest struct {
+	// Since limits the report to usage from this time on, rounded down to
+	// the hour (zero = all usage the provider keeps)
+	Since time.Time `json:"since,omitempty"`
+}
+
+// UsageResponse reports the requester's metered usage on a provider.
+type UsageResponse struct {
+	// Success indicates if the request was served
+	Success bool `json:"success"`
+
+	// Usage is the requester's usage over the period
+	Usage *UsageRecord `json:"usage,omitempty"`
+
+	// Since is the start of the reported period
+	Since time.Time `json:"since,omitempty"`
+
+	// Error is set if the request was refused
+	Error string `json:"error,omitempty"`
+
+	// Code is a machine-readable reason when the request was refused
+	Code ErrorCode `json:"code,omitempty"`
+
+	// RetryAfterMs suggests when a refused request may be retried
+	RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
+}
+
+// UsageRecord is the resource usage of one requester's deployments on a
+// provider, sampled while they run.
+type UsageRecord struct {
+	// RequesterID is the peer whose deployments used the resources
+	RequesterID string `json:"requester_id"`
+
+	// CPUSeconds is the CPU time used (1 = one core for one second)
+	CPUSeconds float64 `json:"cpu_seconds"`
+
+	// MemoryByteSeconds is memory in use integrated over time
+	MemoryByteSeconds float64 `json:"memory_byte_seconds"`
+
+	// WallSeconds is how long containers ran, summed over deployments
+	WallSeconds float64 `json:"wall_seconds"`
+
+	// NetworkRxBytes is the network traffic received by the containers
+	NetworkRxBytes uint64 `json:"network_rx_bytes"`
+
+	// NetworkTxBytes is the network traffic sent by the containers
+	NetworkTxBytes uint64 `json:"network_tx_bytes"`
+
+	// Deployments is how many deployments ran during the period
+	Deployments int `json:"deployments"`
+}
+
+// ReceiptIDPrefix starts every usage receipt ID.
+const ReceiptIDPrefix = "rcpt-"
+
+// UsageReceipt is a provider's signed statement of what one deployment
+// consumed during a period. The requester countersigns it to acknowledge the
+// usage; receipts signed by both sides are what peers' credit balances are
+// computed from.
+type UsageReceipt struct {
+	// ReceiptID uniquely identifies the receipt
+	ReceiptID string `json:"receipt_id"`
+
+	// ProviderID is the peer that ran the deployment
+	ProviderID string `json:"provider_id"`
+
+	// RequesterID is the peer that owns the deployment
+	RequesterID string `json:"requester_id"`
+
+	// DeploymentID is the deployment the usage belongs to
+	DeploymentID string `json:"deployment_id"`
+
+	// CronID is the schedule that started the deployment, if any
+	CronID string `json:"cron_id,omitempty"`
+
+	// PeriodStart and PeriodEnd bound the metered samples
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+
+	// CPUSeconds is the CPU time used (1 = one core for one second)
+	CPUSeconds float64 `json:"cpu_seconds"`
+
+	// MemoryByteSeconds is memory in use integrated over time
+	MemoryByteSeconds float64 `json:"memory_byte_seconds"`
+
+	// WallSeconds is how long the container ran during the period
+	WallSeconds float64 `json:"wall_seconds"`
+
+	// NetworkRxBytes is the network traffic received by the container
+	NetworkRxBytes uint64 `json:"network_rx_bytes"`
+
+	// NetworkTxBytes is the network traffic sent by the container
+	NetworkTxBytes uint64 `json:"network_tx_bytes"`
+
+	// IssuedAt is when the provider signed the receipt
+	IssuedAt time.Time `json:"issued_at"`
+
+	// ProviderSignature is the provider's signature over the receipt
+	ProviderSignature []byte `json:"provider_signature"`
+
+	// RequesterSignature is the requester's countersignature (empty until
+	// countersigned)
+	RequesterSignature []byte `json:"requester_signature,omitempty"`
+}
+
+// Countersigned reports whether both sides have signed the receipt.
+func (r *UsageReceipt) Countersigned() bool {
+	return len(r.ProviderSignature) > 0 && len(r.RequesterSignature) > 0
+}
+
+// ReceiptResponse answers a receipt sent for countersigning.
+type ReceiptResponse struct {
+	// Success indicates if the receipt was countersigned
+	Success bool `json:"success"`
+
+	// Receipt is the countersigned receipt
+	Receipt *UsageReceipt `json:"receipt,omitempty"`
+
+	// Error is set if the receipt was not countersigned
+	Error string `json:"error,omitempty"`
+
+	// Code is a machine-readable reason when the receipt was refused
+	Code ErrorCode `json:"code,omitempty"`
+
+	// RetryAfterMs suggests when a refused receipt may be sent again
+	RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
 }
 
 // Deployment represents an active deployment on a provider.
@@ -212,9 +1592,21 @@ type Deployment struct {
 	// ID is the unique deployment identifier
 	ID string `json:"id"`
 
+	// Name is the requester's name for the deployment (may be empty)
+	Name string `json:"name,omitempty"`
+
+	// ReplicaSet groups the replicas of one deployment request (may be
+	// empty). Replicas in a set share their name and gateway route
+	ReplicaSet string `json:"replica_set,omitempty"`
+
 	// Image is the Docker image
 	Image string `json:"image"`
 
+	// ImageDigest is the digest Image was resolved to when the deployment
+	// was requested; its containers run that digest even if the tag moves
+	// (empty if the image could not be resolved)
+	ImageDigest string `json:"image_digest,omitempty"`
+
 	// ContainerID is the Docker container ID
 	ContainerID string `json:"container_id"`
 
@@ -244,4 +1636,166 @@ type Deployment struct {
 
 	// StoppedAt is when the deployment stopped
 	StoppedAt *time.Time `json:"stopped_at,omitempty"`
+
+	// ExitCode is the container's exit code once it has stopped
+	ExitCode *int `json:"exit_code,omitempty"`
+
+	// Reason explains why the deployment stopped running
+	Reason TerminationReason `json:"reason,omitempty"`
+
+	// Error is the last error encountered by the deployment
+	Error string `json:"error,omitempty"`
+
+	// Environment is kept so the container can be recreated
+	Environment map[string]string `json:"environment,omitempty"`
+
+	// RestartPolicy is the requested restart policy (nil = never)
+	RestartPolicy *RestartPolicy `json:"restart_policy,omitempty"`
+
+	// RestartCount is how many times the container has been restarted
+	RestartCount int `json:"restart_count,omitempty"`
+
+	// ConsecutiveCrashes drives the crash-loop backoff delay
+	ConsecutiveCrashes int `json:"consecutive_crashes,omitempty"`
+
+	// LastExitReason is why the container last exited before a restart
+	LastExitReason TerminationReason `json:"last_exit_reason,omitempty"`
+
+	// NextRestartAt is when a deployment in backoff will be restarted
+	NextRestartAt *time.Time `json:"next_restart_at,omitempty"`
+
+	// HealthCheck is the requested health check (nil = none)
+	HealthCheck *HealthCheck `json:"health_check,omitempty"`
+
+	// Health is the health check state (empty without a health check)
+	Health HealthState `json:"health,omitempty"`
+
+	// HealthCheckedAt is when the last health check completed
+	HealthCheckedAt *time.Time `json:"health_checked_at,omitempty"`
+
+	// HealthError is the last failed health check's error
+	HealthError string `json:"health_error,omitempty"`
+
+	// LeaseExpiresAt is when the deployment will be stopped unless renewed
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty"`
+
+	// LeaseSeconds is the granted lease, applied from when the deployment starts
+	LeaseSeconds int64 `json:"lease_seconds,omitempty"`
+
+	// PriorityClass decides which deployments may preempt which
+	PriorityClass PriorityClass `json:"priority_class,omitempty"`
+
+	// Job is set for run-to-completion jobs
+	Job *JobSpec `json:"job,omitempty"`
+
+	// Outputs are the artifacts copied out of a finished job
+	Outputs []Artifact `json:"outputs,omitempty"`
+
+	// CronID is the schedule that started this run (empty for direct deployments)
+	CronID string `json:"cron_id,omitempty"`
+
+	// Priority orders the deployment in the admission queue
+	Priority int `json:"priority,omitempty"`
+
+	// QueuePosition is the position in the admission queue (1 = next)
+	QueuePosition int `json:"queue_position,omitempty"`
+
+	// QueueDeadline is when a queued request gives up waiting
+	QueueDeadline *time.Time `json:"queue_deadline,omitempty"`
+
+	// Revision is the current revision (1 until the first update)
+	Revision int `json:"revision,omitempty"`
+
+	// Revisions is the revision history, oldest first and ending with the
+	// current revision; empty until the first update
+	Revisions []DeploymentRevision `json:"revisions,omitempty"`
+
+	// Update is the revision being rolled out, if any
+	Update *PendingUpdate `json:"update,omitempty"`
+}
+
+// MaxRevisionHistory is how many revisions of a deployment a provider keeps
+// for rollbacks.
+const MaxRevisionHistory = 10
+
+// DeploymentRevision is one version of a deployment's image and
+// configuration.
+type DeploymentRevision struct {
+	// Revision numbers the revisions of a deployment, starting at 1
+	Revision int `json:"revision"`
+
+	// Image is the revision's image
+	Image string `json:"image"`
+
+	// ImageDigest is the digest the revision's image was resolved to
+	ImageDigest string `json:"image_digest,omitempty"`
+
+	// Environment is the revision's environment
+	Environment map[string]string `json:"environment,omitempty"`
+
+	// CPULimit is the CPU limit in millicores
+	CPULimit int64 `json:"cpu_limit"`
+
+	// MemoryLimit is the memory limit in bytes
+	MemoryLimit int64 `json:"memory_limit"`
+
+	// Cause describes how the revision was created, e.g. "rollback to revision 2"
+	Cause string `json:"cause,omitempty"`
+
+	// CreatedAt is when the revision was created
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PendingUpdate is a revision being rolled out next to the running one.
+type PendingUpdate struct {
+	// Revision is the revision being rolled out
+	Revision DeploymentRevision `json:"revision"`
+
+	// ContainerID is the new revision's container, once created
+	ContainerID string `json:"container_id,omitempty"`
+
+	// StartedAt is when the update started
+	StartedAt time.Time `json:"started_at"`
+}
+
+// CapacityAdvert is a provider's signed announcement of its free capacity.
+// Providers publish it periodically on CapacityTopic so requesters can pick
+// a provider without querying each one.
+type CapacityAdvert struct {
+	// PeerID is the provider's peer ID
+	PeerID string `json:"peer_id"`
+
+	// FreeCPU is the unreserved CPU in millicores
+	FreeCPU int64 `json:"free_cpu"`
+
+	// TotalCPU is the provider's CPU budget in millicores
+	TotalCPU int64 `json:"total_cpu"`
+
+	// FreeMemory is the unreserved memory in bytes
+	FreeMemory int64 `json:"free_memory"`
+
+	// TotalMemory is the provider's memory budget in bytes
+	TotalMemory int64 `json:"total_memory"`
+
+	// FreeSlots is the number of deployments that can still be started
+	FreeSlots int `json:"free_slots"`
+
+	// MaxSlots is the maximum number of concurrent deployments
+	MaxSlots int `json:"max_slots"`
+
+	// Region is an operator-assigned location label (e.g., "eu-west", "office")
+	Region string `json:"region,omitempty"`
+
+	// GatewayConnected reports whether the provider can expose deployments publicly
+	GatewayConnected bool `json:"gateway_connected"`
+
+	// Draining reports that the provider is drained for maintenance and
+	// not accepting new deployments
+	Draining bool `json:"draining,omitempty"`
+
+	// Timestamp is when the advert was created
+	Timestamp int64 `json:"timestamp"`
+
+	// Signature is the provider's Ed25519 signature over the advert
+	Signature []byte `json:"signature"`
 }
@@ -0,0 +1,926 @@
+// Package runtime - Docker Engine API backend
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultDockerHost is the Docker daemon socket used when DOCKER_HOST
+	// is not set
+	DefaultDockerHost = "unix:///var/run/docker.sock"
+
+	// EngineAPIVersion is the Engine API version requests are made with.
+	// 1.41 is supported by Docker 20.10 and later.
+	EngineAPIVersion = "1.41"
+
+	// maxErrorBody limits how much of an error response is read
+	maxErrorBody = 64 * 1024
+
+	// maxExecOutput limits how much exec output is kept for error messages
+	maxExecOutput = 256
+)
+
+var _ Runtime = (*Engine)(nil)
+
+// Engine is a Runtime that talks to the Docker Engine API over plain HTTP,
+// normally on the daemon's unix socket.
+type Engine struct {
+	client  *http.Client
+	baseURL string
+}
+
+// DockerHost returns the Docker daemon address from DOCKER_HOST, or
+// DefaultDockerHost.
+func DockerHost() string {
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		return host
+	}
+	return DefaultDockerHost
+}
+
+// NewEngine creates an Engine API client for a Docker daemon address:
+// unix:///path/to/socket, tcp://host:port, or an http:// or https:// URL
+// (e.g., a local stand-in server in tests).
+// SECURITY: The Docker API is root-equivalent on the host; only point this
+// at a daemon socket that is not exposed to the network.
+func NewEngine(host string) (*Engine, error) {
+	scheme, addr, ok := strings.Cut(host, "://")
+	if !ok {
+		return nil, fmt.Errorf("invalid Docker host %q", host)
+	}
+
+	switch scheme {
+	case "unix":
+		if addr == "" {
+			return nil, fmt.Errorf("invalid Docker host %q", host)
+		}
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", addr)
+			},
+		}
+		// The host part of the URL is ignored by the dialer
+		return NewEngineClient("http://docker", &http.Client{Transport: transport}), nil
+	case "tcp":
+		return NewEngineClient("http://"+addr, nil), nil
+	case "http", "https":
+		return NewEngineClient(host, nil), nil
+	default:
+		return nil, fmt.Errorf("unsupported Docker host scheme %q", scheme)
+	}
+}
+
+// NewEngineClient creates an Engine API client with a base URL and HTTP
+// client (nil = http.DefaultClient).
+func NewEngineClient(baseURL string, client *http.Client) *Engine {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Engine{
+		client:  client,
+		baseURL: strings.TrimSuffix(baseURL, "/") + "/v" + EngineAPIVersion,
+	}
+}
+
+// Close releases idle connections to the daemon.
+func (e *Engine) Close() error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// apiError is an error response from the Engine API.
+type apiError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+// do sends a request to the Engine API and returns the response if its
+// status is below 400; otherwise it returns an *apiError. A non-nil body
+// that is not an io.Reader is sent as JSON.
+func (e *Engine) do(ctx context.Context, method, path string, query url.Values, body any) (*http.Response, error) {
+	return e.send(ctx, method, path, query, body, nil)
+}
+
+// send is do with extra request headers.
+func (e *Engine) send(ctx context.Context, method, path string, query url.Values, body any, header http.Header) (*http.Response, error) {
+	var reader io.Reader
+	contentType := ""
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		reader = b
+		contentType = "application/x-tar"
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+
+	u := e.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		var msg struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &msg) != nil || msg.Message == "" {
+			msg.Message = strings.TrimSpace(string(data))
+		}
+		if msg.Message == "" {
+			msg.Message = resp.Status
+		}
+		return nil, &apiError{StatusCode: resp.StatusCode, Message: msg.Message}
+	}
+	return resp, nil
+}
+
+// call sends a request and decodes a JSON response into out (nil = discard).
+func (e *Engine) call(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	resp, err := e.do(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// noSuchContainer reports whether an error is the engine's response for a
+// container that does not exist.
+func noSuchContainer(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound &&
+		strings.Contains(apiErr.Message, "No such container")
+}
+
+// notModified reports whether an error is a 304, which the engine returns
+// for containers already in the requested state.
+func notModified(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotModified
+}
+
+// containerPath returns the API path of a container endpoint.
+func containerPath(containerID, endpoint string) string {
+	return "/containers/" + url.PathEscape(containerID) + endpoint
+}
+
+// Ping checks if Docker is available.
+func (e *Engine) Ping(ctx context.Context) error {
+	if err := e.call(ctx, http.MethodGet, "/_ping", nil, nil, nil); err != nil {
+		return fmt.Errorf("Docker is not available: %w", err)
+	}
+	return nil
+}
+
+// Pull downloads a Docker image. Without a tag the image's latest tag is
+// pulled, as with docker pull.
+func (e *Engine) Pull(ctx context.Context, imageName string) error {
+	ref, err := ParseImageReference(imageName)
+	if err != nil {
+		return fmt.Errorf("failed to pull image: %w", err)
+	}
+	query := url.Values{"fromImage": {ref.Name()}}
+	if ref.Digest != "" {
+		query.Set("fromImage", ref.Name()+"@"+ref.Digest)
+	} else {
+		// The engine pulls every tag of a repository when none is given
+		query.Set("tag", ref.PulledTag())
+	}
+
+	var header http.Header
+	if auth := registryAuth(ref.Registry); auth != "" {
+		header = http.Header{"X-Registry-Auth": {auth}}
+	}
+
+	resp, err := e.send(ctx, http.MethodPost, "/images/create", query, nil, header)
+	if err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", imageName, err)
+	}
+	defer resp.Body.Close()
+
+	// The pull runs until its progress stream ends; failures are reported
+	// in the stream rather than by the status code
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Error       string `json:"error"`
+			ErrorDetail struct {
+				Message string `json:"message"`
+			} `json:"errorDetail"`
+		}
+		if err := dec.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to pull image %s: %w", imageName, err)
+		}
+		if msg.Error != "" || msg.ErrorDetail.Message != "" {
+			return fmt.Errorf("failed to pull image %s: %s", imageName, firstNonEmpty(msg.ErrorDetail.Message, msg.Error))
+		}
+	}
+}
+
+// ResolveDigest returns the digest an image's tag points to in its
+// registry, without pulling it. Images pinned by digest resolve to their
+// digest.
+func (e *Engine) ResolveDigest(ctx context.Context, imageName string) (string, error) {
+	ref, err := ParseImageReference(imageName)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve image: %w", err)
+	}
+	if ref.Digest != "" {
+		return ref.Digest, nil
+	}
+	ref.Tag = ref.PulledTag()
+
+	var header http.Header
+	if auth := registryAuth(ref.Registry); auth != "" {
+		header = http.Header{"X-Registry-Auth": {auth}}
+	}
+	resp, err := e.send(ctx, http.MethodGet, "/distribution/"+ref.String()+"/json", nil, nil, header)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve image %s: %w", imageName, err)
+	}
+	defer resp.Body.Close()
+
+	var dist struct {
+		Descriptor struct {
+			Digest string `json:"digest"`
+		} `json:"Descriptor"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&dist); err != nil {
+		return "", fmt.Errorf("failed to resolve image %s: %w", imageName, err)
+	}
+	if !sha256Re.MatchString(dist.Descriptor.Digest) {
+		return "", fmt.Errorf("failed to resolve image %s: registry returned digest %q", imageName, dist.Descriptor.Digest)
+	}
+	return dist.Descriptor.Digest, nil
+}
+
+// registryCredentials returns the credentials for a registry from the
+// Docker client configuration (docker login). Credential helpers are not
+// supported.
+func registryCredentials(registry string) (username, password string, ok bool) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", false
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		return "", "", false
+	}
+	var cfg struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+	if json.Unmarshal(data, &cfg) != nil {
+		return "", "", false
+	}
+
+	entry, ok := cfg.Auths[registry]
+	if !ok {
+		entry, ok = cfg.Auths["https://"+registry]
+	}
+	if !ok && registry == DockerHubRegistry {
+		entry, ok = cfg.Auths[dockerHubAuthKey]
+	}
+	if !ok || entry.Auth == "" {
+		return "", "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+	if err != nil {
+		return "", "", false
+	}
+	return strings.Cut(string(decoded), ":")
+}
+
+// registryAuth returns the X-Registry-Auth header for a registry, or "" if
+// there are no stored credentials.
+func registryAuth(registry string) string {
+	username, password, ok := registryCredentials(registry)
+	if !ok {
+		return ""
+	}
+	serverAddress := registry
+	if registry == DockerHubRegistry {
+		serverAddress = dockerHubAuthKey
+	}
+	header, _ := json.Marshal(map[string]string{
+		"username":      username,
+		"password":      password,
+		"serveraddress": serverAddress,
+	})
+	return base64.URLEncoding.EncodeToString(header)
+}
+
+// dockerHubAuthKey is the key docker login stores Docker Hub credentials
+// under
+const dockerHubAuthKey = "https://index.docker.io/v1/"
+
+// Run starts a container with the given configuration.
+// SECURITY: This function enforces all container isolation policies.
+func (e *Engine) Run(ctx context.Context, cfg ContainerConfig) (string, error) {
+	containerID, err := e.Create(ctx, cfg)
+	if err != nil {
+		return "", err
+	}
+	if err := e.Start(ctx, containerID); err != nil {
+		e.Stop(context.Background(), containerID)
+		return "", err
+	}
+	return containerID, nil
+}
+
+// createRequest is the body of a container create request.
+type createRequest struct {
+	Image      string            `json:"Image"`
+	Env        []string          `json:"Env,omitempty"`
+	Entrypoint []string          `json:"Entrypoint,omitempty"`
+	Cmd        []string          `json:"Cmd,omitempty"`
+	Labels     map[string]string `json:"Labels"`
+	HostConfig hostConfig        `json:"HostConfig"`
+}
+
+// hostConfig holds the isolation settings of a container.
+type hostConfig struct {
+	NanoCPUs    int64    `json:"NanoCpus"`
+	Memory      int64    `json:"Memory"`
+	PidsLimit   int64    `json:"PidsLimit"`
+	SecurityOpt []string `json:"SecurityOpt"`
+	CapDrop     []string `json:"CapDrop"`
+	CapAdd      []string `json:"CapAdd,omitempty"`
+	Privileged  bool     `json:"Privileged"`
+	NetworkMode string   `json:"NetworkMode"`
+}
+
+// Create creates a container with the same security constraints as Run
+// without starting it, so files can be copied in first. Start it with Start.
+func (e *Engine) Create(ctx context.Context, cfg ContainerConfig) (string, error) {
+	if err := validateConfig(cfg); err != nil {
+		return "", fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := e.call(ctx, http.MethodPost, "/containers/create", nil, newCreateRequest(cfg), &created); err != nil {
+		return "", fmt.Errorf("failed to create container: %w", err)
+	}
+	return created.ID, nil
+}
+
+// newCreateRequest builds the create request for a container.
+func newCreateRequest(cfg ContainerConfig) createRequest {
+	req := createRequest{
+		Image: cfg.Image,
+		// Labels for identification
+		Labels: map[string]string{
+			PeerComputeLabel:  "true",
+			DeploymentIDLabel: cfg.DeploymentID,
+			RequesterIDLabel:  cfg.RequesterID,
+		},
+		HostConfig: hostConfig{
+			// SECURITY: CPU limit
+			NanoCPUs: cfg.CPUMillicores * 1_000_000,
+			// SECURITY: Memory limit
+			Memory: cfg.MemoryBytes,
+			// SECURITY: PID limit to prevent fork bombs
+			PidsLimit: 100,
+			// SECURITY: No privileged mode
+			Privileged:  false,
+			SecurityOpt: []string{"no-new-privileges:true"},
+			// SECURITY: Drop all capabilities
+			CapDrop: CapabilitiesToDrop(),
+			CapAdd:  CapabilitiesToAdd(),
+			// SECURITY: Default bridge network, never the host's
+			NetworkMode: "bridge",
+		},
+	}
+
+	// SECURITY: Replace the engine's default seccomp profile when the
+	// policy provides one
+	if cfg.SeccompProfile != "" {
+		req.HostConfig.SecurityOpt = append(req.HostConfig.SecurityOpt, "seccomp="+cfg.SeccompProfile)
+	}
+
+	// Add environment variables
+	for k, v := range cfg.Environment {
+		if isValidEnvVar(k) {
+			req.Env = append(req.Env, fmt.Sprintf("%s=%s", k, v))
+		}
+	}
+
+	// Override the entrypoint; the image's command is replaced along with
+	// it, as with docker run --entrypoint
+	if len(cfg.Command) > 0 {
+		req.Entrypoint = cfg.Command
+	}
+	req.Cmd = cfg.Args
+
+	return req
+}
+
+// CopyTo extracts a tar archive into a directory of a container. The
+// directory must exist in the image.
+// SECURITY: Files are streamed into the container; nothing is mounted from
+// the host.
+func (e *Engine) CopyTo(ctx context.Context, containerID, dir string, archive io.Reader) error {
+	err := e.call(ctx, http.MethodPut, containerPath(containerID, "/archive"), url.Values{"path": {dir}}, archive, nil)
+	if err != nil {
+		if noSuchContainer(err) {
+			return ErrContainerNotFound
+		}
+		return fmt.Errorf("failed to copy into container: %w", err)
+	}
+	return nil
+}
+
+// CopyFrom returns a tar archive of a path in a container, which may be
+// stopped. The caller must close the reader.
+func (e *Engine) CopyFrom(ctx context.Context, containerID, path string) (io.ReadCloser, error) {
+	resp, err := e.do(ctx, http.MethodGet, containerPath(containerID, "/archive"), url.Values{"path": {path}}, nil)
+	if err != nil {
+		if noSuchContainer(err) {
+			return nil, ErrContainerNotFound
+		}
+		return nil, fmt.Errorf("failed to copy from container: %w", err)
+	}
+	return resp.Body, nil
+}
+
+// Start restarts an existing, stopped container without pulling or
+// recreating it. The original security constraints still apply.
+func (e *Engine) Start(ctx context.Context, containerID string) error {
+	err := e.call(ctx, http.MethodPost, containerPath(containerID, "/start"), nil, nil, nil)
+	if err != nil && !notModified(err) {
+		if noSuchContainer(err) {
+			return ErrContainerNotFound
+		}
+		return fmt.Errorf("failed to start container: %w", err)
+	}
+	return nil
+}
+
+// Stop stops and removes a container.
+func (e *Engine) Stop(ctx context.Context, containerID string) error {
+	// Ignore if already stopped
+	e.Halt(ctx, containerID)
+
+	err := e.call(ctx, http.MethodDelete, containerPath(containerID, ""), url.Values{"force": {"1"}}, nil, nil)
+	if err != nil && !noSuchContainer(err) {
+		return fmt.Errorf("failed to remove container: %w", err)
+	}
+	return nil
+}
+
+// Halt stops a container without removing it, so its exit code and logs
+// remain available.
+func (e *Engine) Halt(ctx context.Context, containerID string) error {
+	query := url.Values{"t": {strconv.Itoa(int(DefaultStopTimeout.Seconds()))}}
+	err := e.call(ctx, http.MethodPost, containerPath(containerID, "/stop"), query, nil, nil)
+	if err != nil && !notModified(err) {
+		if noSuchContainer(err) {
+			return ErrContainerNotFound
+		}
+		return fmt.Errorf("failed to stop container: %w", err)
+	}
+	return nil
+}
+
+// logs opens a container's multiplexed log stream.
+func (e *Engine) logs(ctx context.Context, containerID string, follow bool) (io.ReadCloser, error) {
+	query := url.Values{
+		"stdout":     {"1"},
+		"stderr":     {"1"},
+		"timestamps": {"1"},
+		"follow":     {strconv.FormatBool(follow)},
+	}
+	resp, err := e.do(ctx, http.MethodGet, containerPath(containerID, "/logs"), query, nil)
+	if err != nil {
+		if noSuchContainer(err) {
+			return nil, ErrContainerNotFound
+		}
+		return nil, fmt.Errorf("failed to get container logs: %w", err)
+	}
+	return resp.Body, nil
+}
+
+// Logs returns a reader for container logs, with stdout and stderr
+// interleaved.
+func (e *Engine) Logs(ctx context.Context, containerID string, follow bool) (io.ReadCloser, error) {
+	body, err := e.logs(ctx, containerID, follow)
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{newFrameReader(body), body}, nil
+}
+
+// StreamLogs copies container logs to stdout/stderr writers.
+func (e *Engine) StreamLogs(ctx context.Context, containerID string, stdout, stderr io.Writer) error {
+	body, err := e.logs(ctx, containerID, true)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	return demux(stdout, stderr, body)
+}
+
+// containerJSON is the part of a container inspect response Peer Compute
+// uses.
+type containerJSON struct {
+	State struct {
+		Status     string `json:"Status"`
+		ExitCode   int    `json:"ExitCode"`
+		OOMKilled  bool   `json:"OOMKilled"`
+		Error      string `json:"Error"`
+		StartedAt  string `json:"StartedAt"`
+		FinishedAt string `json:"FinishedAt"`
+	} `json:"State"`
+	Config struct {
+		Image  string            `json:"Image"`
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+// inspect returns a container's inspect response.
+func (e *Engine) inspect(ctx context.Context, containerID string) (*containerJSON, error) {
+	var c containerJSON
+	if err := e.call(ctx, http.MethodGet, containerPath(containerID, "/json"), nil, nil, &c); err != nil {
+		if noSuchContainer(err) {
+			return nil, ErrContainerNotFound
+		}
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
+	}
+	return &c, nil
+}
+
+// Inspect returns information about a container.
+// It returns ErrContainerNotFound if the container has been removed.
+func (e *Engine) Inspect(ctx context.Context, containerID string) (*ContainerInfo, error) {
+	c, err := e.inspect(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ContainerInfo{
+		ContainerID: containerID,
+		Status:      c.State.Status,
+		StartedAt:   parseEngineTime(c.State.StartedAt),
+		Image:       c.Config.Image,
+		ExitCode:    c.State.ExitCode,
+		OOMKilled:   c.State.OOMKilled,
+		FinishedAt:  parseEngineTime(c.State.FinishedAt),
+		Error:       c.State.Error,
+	}, nil
+}
+
+// parseEngineTime parses a timestamp from the engine, which uses
+// 0001-01-01T00:00:00Z for times that have not happened.
+func parseEngineTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil || t.Year() <= 1 {
+		return time.Time{}
+	}
+	return t
+}
+
+// ContainerIP returns the container's IP address on its Docker network.
+func (e *Engine) ContainerIP(ctx context.Context, containerID string) (string, error) {
+	c, err := e.inspect(ctx, containerID)
+	if err != nil {
+		return "", err
+	}
+
+	for _, n := range c.NetworkSettings.Networks {
+		if n.IPAddress != "" {
+			return n.IPAddress, nil
+		}
+	}
+	return "", fmt.Errorf("container has no IP address")
+}
+
+// Exec runs a command inside a running container and returns an error if it
+// exits non-zero.
+// SECURITY: The command runs with the container's own isolation and limits.
+func (e *Engine) Exec(ctx context.Context, containerID string, command []string) error {
+	var created struct {
+		ID string `json:"Id"`
+	}
+	err := e.call(ctx, http.MethodPost, containerPath(containerID, "/exec"), nil, map[string]any{
+		"Cmd":          command,
+		"AttachStdout": true,
+		"AttachStderr": true,
+	}, &created)
+	if err != nil {
+		if noSuchContainer(err) {
+			return ErrContainerNotFound
+		}
+		return fmt.Errorf("failed to run command: %w", err)
+	}
+
+	// Without a hijacked connection the engine streams the output as the
+	// response body and closes it when the command exits
+	resp, err := e.do(ctx, http.MethodPost, "/exec/"+url.PathEscape(created.ID)+"/start", nil, map[string]any{
+		"Detach": false,
+		"Tty":    false,
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("command timed out")
+		}
+		return fmt.Errorf("failed to run command: %w", err)
+	}
+	output := &limitedBuffer{limit: maxExecOutput}
+	err = demux(output, output, resp.Body)
+	resp.Body.Close()
+	if ctx.Err() != nil {
+		return fmt.Errorf("command timed out")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to run command: %w", err)
+	}
+
+	var result struct {
+		ExitCode int  `json:"ExitCode"`
+		Running  bool `json:"Running"`
+	}
+	if err := e.call(ctx, http.MethodGet, "/exec/"+url.PathEscape(created.ID)+"/json", nil, nil, &result); err != nil {
+		return fmt.Errorf("failed to inspect command: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("command failed: exit status %d: %s", result.ExitCode, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards
+// the rest.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+// statsJSON is the part of a container stats response Peer Compute uses.
+type statsJSON struct {
+	CPUStats    cpuStats `json:"cpu_stats"`
+	PreCPUStats cpuStats `json:"precpu_stats"`
+	MemoryStats struct {
+		Usage uint64            `json:"usage"`
+		Limit uint64            `json:"limit"`
+		Stats map[string]uint64 `json:"stats"`
+	} `json:"memory_stats"`
+	Networks map[string]struct {
+		RxBytes uint64 `json:"rx_bytes"`
+		TxBytes uint64 `json:"tx_bytes"`
+	} `json:"networks"`
+	BlkioStats struct {
+		IOServiceBytesRecursive []struct {
+			Op    string `json:"op"`
+			Value uint64 `json:"value"`
+		} `json:"io_service_bytes_recursive"`
+	} `json:"blkio_stats"`
+	PidsStats struct {
+		Current uint64 `json:"current"`
+		Limit   uint64 `json:"limit"`
+	} `json:"pids_stats"`
+}
+
+// cpuStats is a CPU usage sample from the engine.
+type cpuStats struct {
+	CPUUsage struct {
+		TotalUsage  uint64   `json:"total_usage"`
+		PercpuUsage []uint64 `json:"percpu_usage"`
+	} `json:"cpu_usage"`
+	SystemUsage uint64 `json:"system_cpu_usage"`
+	OnlineCPUs  uint32 `json:"online_cpus"`
+}
+
+// Stats returns current resource usage for a container.
+func (e *Engine) Stats(ctx context.Context, containerID string) (*ResourceUsage, error) {
+	// A single sample; the engine fills precpu_stats with a sample taken
+	// about a second earlier, which CPU usage is computed against
+	var stats statsJSON
+	err := e.call(ctx, http.MethodGet, containerPath(containerID, "/stats"), url.Values{"stream": {"false"}}, nil, &stats)
+	if err != nil {
+		if noSuchContainer(err) {
+			return nil, ErrContainerNotFound
+		}
+		return nil, fmt.Errorf("failed to get container stats: %w", err)
+	}
+
+	usage := &ResourceUsage{
+		CPUPercent:  cpuPercent(stats.CPUStats, stats.PreCPUStats),
+		MemoryBytes: memoryUsage(stats.MemoryStats.Usage, stats.MemoryStats.Stats),
+		MemoryLimit: stats.MemoryStats.Limit,
+		PIDs:        stats.PidsStats.Current,
+		PIDsLimit:   stats.PidsStats.Limit,
+	}
+	for _, n := range stats.Networks {
+		usage.NetworkRxBytes += n.RxBytes
+		usage.NetworkTxBytes += n.TxBytes
+	}
+	// Entries are per device; cgroup v1 capitalizes the operations and v2
+	// does not
+	for _, e := range stats.BlkioStats.IOServiceBytesRecursive {
+		switch strings.ToLower(e.Op) {
+		case "read":
+			usage.BlockReadBytes += e.Value
+		case "write":
+			usage.BlockWriteBytes += e.Value
+		}
+	}
+	return usage, nil
+}
+
+// cpuPercent computes CPU usage between two samples the way docker stats
+// does: 100% is one fully used CPU.
+func cpuPercent(cur, prev cpuStats) float64 {
+	if cur.CPUUsage.TotalUsage < prev.CPUUsage.TotalUsage || cur.SystemUsage <= prev.SystemUsage {
+		return 0
+	}
+	cpus := float64(cur.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(cur.CPUUsage.PercpuUsage))
+	}
+	containerDelta := float64(cur.CPUUsage.TotalUsage - prev.CPUUsage.TotalUsage)
+	systemDelta := float64(cur.SystemUsage - prev.SystemUsage)
+	return containerDelta / systemDelta * cpus * 100
+}
+
+// memoryUsage subtracts the page cache the kernel can reclaim from memory
+// usage, as docker stats does (inactive_file on cgroup v2,
+// total_inactive_file on cgroup v1).
+func memoryUsage(usage uint64, stats map[string]uint64) uint64 {
+	for _, key := range []string{"total_inactive_file", "inactive_file"} {
+		if v, ok := stats[key]; ok {
+			if v < usage {
+				return usage - v
+			}
+			return usage
+		}
+	}
+	return usage
+}
+
+// Events streams lifecycle events of Peer Compute containers.
+func (e *Engine) Events(ctx context.Context) (<-chan Event, <-chan error) {
+	events := make(chan Event)
+	errs := make(chan error, 1)
+
+	filters, _ := json.Marshal(map[string][]string{
+		"type":  {"container"},
+		"label": {PeerComputeLabel + "=true"},
+	})
+	resp, err := e.do(ctx, http.MethodGet, "/events", url.Values{"filters": {string(filters)}}, nil)
+	if err != nil {
+		errs <- fmt.Errorf("failed to watch container events: %w", err)
+		close(events)
+		close(errs)
+		return events, errs
+	}
+
+	go func() {
+		defer close(errs)
+		defer close(events)
+		defer resp.Body.Close()
+
+		dec := json.NewDecoder(resp.Body)
+		for {
+			var msg struct {
+				Action string `json:"Action"`
+				Actor  struct {
+					ID         string            `json:"ID"`
+					Attributes map[string]string `json:"Attributes"`
+				} `json:"Actor"`
+				TimeNano int64 `json:"timeNano"`
+			}
+			if err := dec.Decode(&msg); err != nil {
+				if ctx.Err() == nil {
+					errs <- fmt.Errorf("container event stream ended: %w", err)
+				}
+				return
+			}
+
+			// Actions of exec events carry the command, e.g.
+			// "exec_start: sh -c ..."
+			action, _, _ := strings.Cut(msg.Action, ":")
+			exitCode, _ := strconv.Atoi(msg.Actor.Attributes["exitCode"])
+			ev := Event{
+				ContainerID:  msg.Actor.ID,
+				DeploymentID: msg.Actor.Attributes[DeploymentIDLabel],
+				Action:       action,
+				ExitCode:     exitCode,
+				Time:         time.Unix(0, msg.TimeNano),
+			}
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, errs
+}
+
+// ListPeerComputeContainers returns all containers managed by Peer Compute,
+// including their deployment and requester labels.
+func (e *Engine) ListPeerComputeContainers(ctx context.Context) ([]Container, error) {
+	filters, _ := json.Marshal(map[string][]string{
+		"label": {PeerComputeLabel + "=true"},
+	})
+	var list []struct {
+		ID     string            `json:"Id"`
+		State  string            `json:"State"`
+		Labels map[string]string `json:"Labels"`
+	}
+	query := url.Values{"all": {"1"}, "filters": {string(filters)}}
+	if err := e.call(ctx, http.MethodGet, "/containers/json", query, nil, &list); err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	containers := make([]Container, 0, len(list))
+	for _, c := range list {
+		containers = append(containers, Container{
+			ID:     c.ID,
+			State:  c.State,
+			Labels: c.Labels,
+		})
+	}
+	return containers, nil
+}
+
+// firstNonEmpty returns the first non-empty string.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,416 @@
+package runtime
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestEngine serves handler as a Docker daemon on a unix socket and
+// returns an Engine connected to it.
+func newTestEngine(t *testing.T, handler http.Handler) *Engine {
+	t.Helper()
+	// Socket paths are limited to about 100 bytes, which t.TempDir can exceed
+	dir, err := os.MkdirTemp("", "engine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "docker.sock")
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(handler)
+	srv.Listener = l
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	e, err := NewEngine("unix://" + socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { e.Close() })
+	return e
+}
+
+// apiPath returns the path of an Engine API endpoint.
+func apiPath(endpoint string) string {
+	return "/v" + EngineAPIVersion + endpoint
+}
+
+func TestEngineCreateSecurityOptions(t *testing.T) {
+	var got createRequest
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+apiPath("/containers/create"), func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"Id":"c0ffee"}`)
+	})
+	e := newTestEngine(t, mux)
+
+	id, err := e.Create(context.Background(), ContainerConfig{
+		DeploymentID:   "dep-0000000000000001",
+		RequesterID:    "12D3KooWRequester",
+		Image:          "nginx:1.27",
+		CPUMillicores:  500,
+		MemoryBytes:    64 * 1024 * 1024,
+		Environment:    map[string]string{"MODE": "test", "LD_PRELOAD": "/evil.so"},
+		Command:        []string{"/bin/sh", "-c"},
+		Args:           []string{"sleep 1"},
+		SeccompProfile: `{"defaultAction":"SCMP_ACT_ERRNO"}`,
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if id != "c0ffee" {
+		t.Errorf("Create() = %q, want c0ffee", id)
+	}
+
+	hc := got.HostConfig
+	if hc.Privileged {
+		t.Error("container is privileged")
+	}
+	if hc.NetworkMode != "bridge" {
+		t.Errorf("NetworkMode = %q, want bridge", hc.NetworkMode)
+	}
+	if !slices.Equal(hc.CapDrop, []string{"ALL"}) || len(hc.CapAdd) != 0 {
+		t.Errorf("CapDrop = %v, CapAdd = %v, want all dropped and none added", hc.CapDrop, hc.CapAdd)
+	}
+	wantOpts := []string{"no-new-privileges:true", `seccomp={"defaultAction":"SCMP_ACT_ERRNO"}`}
+	if !slices.Equal(hc.SecurityOpt, wantOpts) {
+		t.Errorf("SecurityOpt = %v, want %v", hc.SecurityOpt, wantOpts)
+	}
+	if hc.NanoCPUs != 500_000_000 || hc.Memory != 64*1024*1024 || hc.PidsLimit != 100 {
+		t.Errorf("limits = %d CPU, %d memory, %d PIDs", hc.NanoCPUs, hc.Memory, hc.PidsLimit)
+	}
+	if !slices.Equal(got.Env, []string{"MODE=test"}) {
+		t.Errorf("Env = %v, want only MODE", got.Env)
+	}
+	if !slices.Equal(got.Entrypoint, []string{"/bin/sh", "-c"}) || !slices.Equal(got.Cmd, []string{"sleep 1"}) {
+		t.Errorf("Entrypoint = %v, Cmd = %v", got.Entrypoint, got.Cmd)
+	}
+	if got.Labels[PeerComputeLabel] != "true" || got.Labels[DeploymentIDLabel] != "dep-0000000000000001" {
+		t.Errorf("Labels = %v", got.Labels)
+	}
+}
+
+func TestEngineCreateRejectsInvalidConfig(t *testing.T) {
+	e := newTestEngine(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+	}))
+
+	_, err := e.Create(context.Background(), ContainerConfig{
+		DeploymentID:  "dep-0000000000000001",
+		Image:         "nginx",
+		CPUMillicores: 500,
+		MemoryBytes:   1024 * 1024,
+	})
+	if err == nil {
+		t.Fatal("Create() succeeded with 1MB of memory")
+	}
+}
+
+// writeDockerConfig stores docker login credentials for registries in a
+// client configuration that DOCKER_CONFIG points to.
+func writeDockerConfig(t *testing.T, auths map[string]string) {
+	t.Helper()
+	cfg := map[string]map[string]map[string]string{"auths": {}}
+	for registry, userpass := range auths {
+		cfg["auths"][registry] = map[string]string{"auth": base64.StdEncoding.EncodeToString([]byte(userpass))}
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DOCKER_CONFIG", dir)
+}
+
+func TestEnginePullWithAuth(t *testing.T) {
+	writeDockerConfig(t, map[string]string{
+		"ghcr.io":        "alice:ghcr-token",
+		dockerHubAuthKey: "bob:hub-token",
+	})
+	digest := "sha256:" + strings.Repeat("ab", 32)
+
+	tests := []struct {
+		image     string
+		fromImage string
+		tag       string
+		auth      map[string]string
+	}{
+		{"ghcr.io/acme/app:1.0", "ghcr.io/acme/app", "1.0",
+			map[string]string{"username": "alice", "password": "ghcr-token", "serveraddress": "ghcr.io"}},
+		{"nginx", "docker.io/library/nginx", "latest",
+			map[string]string{"username": "bob", "password": "hub-token", "serveraddress": dockerHubAuthKey}},
+		{"quay.io/acme/app@" + digest, "quay.io/acme/app@" + digest, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("POST "+apiPath("/images/create"), func(w http.ResponseWriter, r *http.Request) {
+				q := r.URL.Query()
+				if q.Get("fromImage") != tt.fromImage || q.Get("tag") != tt.tag {
+					t.Errorf("pulled %s with tag %q, want %s with tag %q", q.Get("fromImage"), q.Get("tag"), tt.fromImage, tt.tag)
+				}
+
+				var auth map[string]string
+				if header := r.Header.Get("X-Registry-Auth"); header != "" {
+					data, err := base64.URLEncoding.DecodeString(header)
+					if err != nil {
+						t.Errorf("X-Registry-Auth is not base64: %v", err)
+					}
+					json.Unmarshal(data, &auth)
+				}
+				if fmt.Sprint(auth) != fmt.Sprint(tt.auth) {
+					t.Errorf("X-Registry-Auth = %v, want %v", auth, tt.auth)
+				}
+				fmt.Fprintln(w, `{"status":"Pulling from acme/app"}`)
+				fmt.Fprintln(w, `{"status":"Download complete"}`)
+			})
+			e := newTestEngine(t, mux)
+
+			if err := e.Pull(context.Background(), tt.image); err != nil {
+				t.Fatalf("Pull() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestEnginePullStreamError(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+apiPath("/images/create"), func(w http.ResponseWriter, r *http.Request) {
+		// The engine reports failed pulls in the stream with status 200
+		fmt.Fprintln(w, `{"status":"Pulling from library/nginx"}`)
+		fmt.Fprintln(w, `{"errorDetail":{"message":"unauthorized: authentication required"},"error":"unauthorized"}`)
+	})
+	e := newTestEngine(t, mux)
+
+	err := e.Pull(context.Background(), "nginx")
+	if err == nil || err.Error() != "failed to pull image nginx: unauthorized: authentication required" {
+		t.Fatalf("Pull() error = %v, want the stream's error", err)
+	}
+}
+
+func TestEngineStats(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+apiPath("/containers/c0ffee/stats"), func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("stream") != "false" {
+			t.Errorf("stats requested with stream=%s, want a single sample", r.URL.Query().Get("stream"))
+		}
+		fmt.Fprint(w, `{
+			"cpu_stats": {"cpu_usage": {"total_usage": 3000000000}, "system_cpu_usage": 20000000000, "online_cpus": 4},
+			"precpu_stats": {"cpu_usage": {"total_usage": 1000000000}, "system_cpu_usage": 12000000000},
+			"memory_stats": {"usage": 52428800, "limit": 67108864, "stats": {"inactive_file": 10485760}},
+			"networks": {"eth0": {"rx_bytes": 1000, "tx_bytes": 200}, "eth1": {"rx_bytes": 24, "tx_bytes": 6}},
+			"blkio_stats": {"io_service_bytes_recursive": [
+				{"op": "Read", "value": 4096}, {"op": "read", "value": 4096},
+				{"op": "Write", "value": 512}, {"op": "Total", "value": 8704}
+			]},
+			"pids_stats": {"current": 7, "limit": 100}
+		}`)
+	})
+	mux.HandleFunc("GET "+apiPath("/containers/gone/stats"), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message":"No such container: gone"}`)
+	})
+	e := newTestEngine(t, mux)
+
+	usage, err := e.Stats(context.Background(), "c0ffee")
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+	want := ResourceUsage{
+		// 2s of 8s of system time on 4 CPUs is one CPU
+		CPUPercent:      100,
+		MemoryBytes:     40 * 1024 * 1024,
+		MemoryLimit:     64 * 1024 * 1024,
+		NetworkRxBytes:  1024,
+		NetworkTxBytes:  206,
+		BlockReadBytes:  8192,
+		BlockWriteBytes: 512,
+		PIDs:            7,
+		PIDsLimit:       100,
+	}
+	if *usage != want {
+		t.Errorf("Stats() = %+v, want %+v", *usage, want)
+	}
+
+	if _, err := e.Stats(context.Background(), "gone"); !errors.Is(err, ErrContainerNotFound) {
+		t.Errorf("Stats() error = %v, want ErrContainerNotFound", err)
+	}
+}
+
+func TestCPUPercent(t *testing.T) {
+	tests := []struct {
+		name  string
+		stats string
+		want  float64
+	}{
+		{"one of four CPUs", `{
+			"cpu_stats": {"cpu_usage": {"total_usage": 3000000000}, "system_cpu_usage": 20000000000, "online_cpus": 4},
+			"precpu_stats": {"cpu_usage": {"total_usage": 1000000000}, "system_cpu_usage": 12000000000}
+		}`, 100},
+		{"half of one CPU", `{
+			"cpu_stats": {"cpu_usage": {"total_usage": 1500000000}, "system_cpu_usage": 3000000000, "online_cpus": 1},
+			"precpu_stats": {"cpu_usage": {"total_usage": 1000000000}, "system_cpu_usage": 2000000000, "online_cpus": 1}
+		}`, 50},
+		// Older engines report no online_cpus, only per-CPU usage
+		{"per-CPU usage without online CPUs", `{
+			"cpu_stats": {"cpu_usage": {"total_usage": 2000000000, "percpu_usage": [1, 1]}, "system_cpu_usage": 6000000000},
+			"precpu_stats": {"cpu_usage": {"total_usage": 1000000000}, "system_cpu_usage": 2000000000}
+		}`, 50},
+		// The first sample of a new container has no previous sample
+		{"no previous sample", `{
+			"cpu_stats": {"cpu_usage": {"total_usage": 1000000000}, "system_cpu_usage": 2000000000, "online_cpus": 2},
+			"precpu_stats": {}
+		}`, 100},
+		{"system time did not advance", `{
+			"cpu_stats": {"cpu_usage": {"total_usage": 2000000000}, "system_cpu_usage": 2000000000, "online_cpus": 2},
+			"precpu_stats": {"cpu_usage": {"total_usage": 1000000000}, "system_cpu_usage": 2000000000}
+		}`, 0},
+		// A restarted container's counters start again from zero
+		{"counter reset", `{
+			"cpu_stats": {"cpu_usage": {"total_usage": 1000}, "system_cpu_usage": 3000000000, "online_cpus": 2},
+			"precpu_stats": {"cpu_usage": {"total_usage": 2000000000}, "system_cpu_usage": 2000000000}
+		}`, 0},
+		{"stopped container", `{"cpu_stats": {"cpu_usage": {}}, "precpu_stats": {"cpu_usage": {}}}`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stats statsJSON
+			if err := json.Unmarshal([]byte(tt.stats), &stats); err != nil {
+				t.Fatal(err)
+			}
+			if got := cpuPercent(stats.CPUStats, stats.PreCPUStats); got != tt.want {
+				t.Errorf("cpuPercent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryUsage(t *testing.T) {
+	tests := []struct {
+		name  string
+		stats string
+		want  uint64
+	}{
+		{"cgroup v2", `{"memory_stats": {"usage": 52428800, "stats": {"inactive_file": 10485760, "active_file": 4096}}}`, 41943040},
+		{"cgroup v1", `{"memory_stats": {"usage": 52428800, "stats": {"total_inactive_file": 20971520, "cache": 31457280}}}`, 31457280},
+		// cgroup v1 reports both; the hierarchy total is the one docker
+		// stats uses
+		{"cgroup v1 with both", `{"memory_stats": {"usage": 52428800, "stats": {"total_inactive_file": 20971520, "inactive_file": 1048576}}}`, 31457280},
+		{"no page cache statistics", `{"memory_stats": {"usage": 52428800}}`, 52428800},
+		{"page cache above usage", `{"memory_stats": {"usage": 4096, "stats": {"inactive_file": 8192}}}`, 4096},
+		{"stopped container", `{"memory_stats": {}}`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stats statsJSON
+			if err := json.Unmarshal([]byte(tt.stats), &stats); err != nil {
+				t.Fatal(err)
+			}
+			if got := memoryUsage(stats.MemoryStats.Usage, stats.MemoryStats.Stats); got != tt.want {
+				t.Errorf("memoryUsage() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngineEvents(t *testing.T) {
+	// Ends the daemon's side of the stream
+	ended, end := context.WithCancel(context.Background())
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+apiPath("/events"), func(w http.ResponseWriter, r *http.Request) {
+		var filters map[string][]string
+		if err := json.Unmarshal([]byte(r.URL.Query().Get("filters")), &filters); err != nil {
+			t.Errorf("filters are not JSON: %v", err)
+		}
+		if !slices.Equal(filters["type"], []string{"container"}) ||
+			!slices.Equal(filters["label"], []string{PeerComputeLabel + "=true"}) {
+			t.Errorf("filters = %v, want Peer Compute containers", filters)
+		}
+
+		fmt.Fprintln(w, `{"Action":"start","Actor":{"ID":"c0ffee","Attributes":{"peercompute.deployment-id":"dep-0000000000000001"}},"timeNano":1700000000000000000}`)
+		fmt.Fprintln(w, `{"Action":"exec_start: sh -c true","Actor":{"ID":"c0ffee","Attributes":{}},"timeNano":1700000001000000000}`)
+		fmt.Fprintln(w, `{"Action":"die","Actor":{"ID":"c0ffee","Attributes":{"exitCode":"137"}},"timeNano":1700000002000000000}`)
+		w.(http.Flusher).Flush()
+		<-ended.Done()
+	})
+	e := newTestEngine(t, mux)
+	t.Cleanup(end)
+
+	events, errs := e.Events(context.Background())
+	want := []Event{
+		{ContainerID: "c0ffee", DeploymentID: "dep-0000000000000001", Action: "start", Time: time.Unix(0, 1700000000000000000)},
+		{ContainerID: "c0ffee", Action: "exec_start", Time: time.Unix(0, 1700000001000000000)},
+		{ContainerID: "c0ffee", Action: "die", ExitCode: 137, Time: time.Unix(0, 1700000002000000000)},
+	}
+	for _, w := range want {
+		select {
+		case ev := <-events:
+			if ev != w {
+				t.Errorf("event = %+v, want %+v", ev, w)
+			}
+		case err := <-errs:
+			t.Fatalf("event stream failed: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for an event")
+		}
+	}
+
+	// A stream the daemon ends is reported, so the caller can reconnect
+	end()
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("event stream ended without an error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the stream to end")
+	}
+}
+
+func TestEngineEventsCanceled(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+apiPath("/events"), func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	e := newTestEngine(t, mux)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, errs := e.Events(ctx)
+	cancel()
+
+	// Canceling the watch closes both channels without an error
+	select {
+	case err, ok := <-errs:
+		if ok {
+			t.Errorf("error after cancel = %v, want none", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event stream not closed after cancel")
+	}
+	if _, ok := <-events; ok {
+		t.Error("events channel not closed after cancel")
+	}
+}
@@ -0,0 +1,477 @@
+// Package runtime - In-memory runtime for tests
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+var _ Runtime = (*Fake)(nil)
+
+// Fake is an in-memory Runtime for tests. Containers exist only as records:
+// they start running when started and stay running until the test ends
+// them with Exit or they are stopped. Files copied in are kept as the tar
+// archives they arrived as.
+//
+// The exported hook fields may be set before use to inject failures.
+type Fake struct {
+	// PullHook is called for every pull; a non-nil error fails it
+	PullHook func(imageName string) error
+
+	// ExecHook is called for every exec; a non-nil error fails it
+	ExecHook func(containerID string, command []string) error
+
+	// StopHook is called for every stop; a non-nil error fails it and
+	// leaves the container as it was
+	StopHook func(containerID string) error
+
+	// ResolveHook resolves image tags to digests. Without it, an image
+	// resolves to the sha256 of its normalized name
+	ResolveHook func(imageName string) (string, error)
+
+	mu          sync.Mutex
+	containers  map[string]*fakeContainer
+	images      map[string]bool
+	nextID      int
+	subscribers map[chan Event]struct{}
+}
+
+// fakeContainer is the record of a fake container.
+type fakeContainer struct {
+	config   ContainerConfig
+	info     ContainerInfo
+	ip       string
+	logs     bytes.Buffer
+	archives map[string][]byte // directory -> tar archive copied in
+	usage    ResourceUsage
+}
+
+// NewFake creates an empty fake runtime.
+func NewFake() *Fake {
+	return &Fake{
+		containers:  make(map[string]*fakeContainer),
+		images:      make(map[string]bool),
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Close does nothing.
+func (f *Fake) Close() error {
+	return nil
+}
+
+// Ping always succeeds.
+func (f *Fake) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Pull records the image as present.
+func (f *Fake) Pull(ctx context.Context, imageName string) error {
+	if f.PullHook != nil {
+		if err := f.PullHook(imageName); err != nil {
+			return fmt.Errorf("failed to pull image %s: %w", imageName, err)
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.images[imageName] = true
+	return nil
+}
+
+// ResolveDigest resolves an image with ResolveHook.
+func (f *Fake) ResolveDigest(ctx context.Context, imageName string) (string, error) {
+	ref, err := ParseImageReference(imageName)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve image: %w", err)
+	}
+	if ref.Digest != "" {
+		return ref.Digest, nil
+	}
+	if f.ResolveHook != nil {
+		return f.ResolveHook(imageName)
+	}
+	ref.Tag = ref.PulledTag()
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(ref.String()))), nil
+}
+
+// HasImage reports whether an image has been pulled.
+func (f *Fake) HasImage(imageName string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.images[imageName]
+}
+
+// Run creates and starts a container.
+func (f *Fake) Run(ctx context.Context, cfg ContainerConfig) (string, error) {
+	containerID, err := f.Create(ctx, cfg)
+	if err != nil {
+		return "", err
+	}
+	return containerID, f.Start(ctx, containerID)
+}
+
+// Create records a container in the created state. Like the engine, it
+// fails for images that have not been pulled.
+func (f *Fake) Create(ctx context.Context, cfg ContainerConfig) (string, error) {
+	if err := validateConfig(cfg); err != nil {
+		return "", fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.images[cfg.Image] {
+		return "", fmt.Errorf("failed to create container: No such image: %s", cfg.Image)
+	}
+	f.nextID++
+	containerID := fmt.Sprintf("fake%060x", f.nextID)
+	f.containers[containerID] = &fakeContainer{
+		config: cfg,
+		info: ContainerInfo{
+			ContainerID: containerID,
+			Status:      "created",
+			Image:       cfg.Image,
+		},
+		ip:       fmt.Sprintf("172.17.%d.%d", f.nextID/250, f.nextID%250+2),
+		archives: make(map[string][]byte),
+	}
+	f.publishLocked(containerID, "create", 0)
+	return containerID, nil
+}
+
+// Start marks a container running.
+func (f *Fake) Start(ctx context.Context, containerID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.containers[containerID]
+	if !ok {
+		return ErrContainerNotFound
+	}
+	if c.info.Status == "running" {
+		return nil
+	}
+	c.info.Status = "running"
+	c.info.StartedAt = time.Now()
+	c.info.FinishedAt = time.Time{}
+	c.info.ExitCode = 0
+	c.info.OOMKilled = false
+	f.publishLocked(containerID, "start", 0)
+	return nil
+}
+
+// Stop removes a container, stopping it first if it is running, unless
+// StopHook fails it.
+func (f *Fake) Stop(ctx context.Context, containerID string) error {
+	if f.StopHook != nil {
+		if err := f.StopHook(containerID); err != nil {
+			return fmt.Errorf("failed to stop container %s: %w", containerID, err)
+		}
+	}
+	f.Halt(ctx, containerID)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.containers[containerID]; ok {
+		f.publishLocked(containerID, "destroy", 0)
+		delete(f.containers, containerID)
+	}
+	return nil
+}
+
+// Halt stops a running container with exit code 137, as if killed after
+// ignoring SIGTERM.
+func (f *Fake) Halt(ctx context.Context, containerID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.containers[containerID]
+	if !ok {
+		return ErrContainerNotFound
+	}
+	if c.info.Status == "running" {
+		f.exitLocked(containerID, c, 137, false)
+	}
+	return nil
+}
+
+// Exit ends a running container with an exit code, as if its process
+// exited or, with oomKilled, was killed for exceeding its memory limit.
+func (f *Fake) Exit(containerID string, exitCode int, oomKilled bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.containers[containerID]
+	if !ok {
+		return ErrContainerNotFound
+	}
+	if c.info.Status != "running" {
+		return fmt.Errorf("container %s is not running", containerID)
+	}
+	f.exitLocked(containerID, c, exitCode, oomKilled)
+	return nil
+}
+
+// Remove deletes a container as if it was removed outside Peer Compute.
+func (f *Fake) Remove(containerID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.containers[containerID]; ok {
+		f.publishLocked(containerID, "destroy", 0)
+		delete(f.containers, containerID)
+	}
+}
+
+// exitLocked records a container's exit (caller must hold lock).
+func (f *Fake) exitLocked(containerID string, c *fakeContainer, exitCode int, oomKilled bool) {
+	c.info.Status = "exited"
+	c.info.ExitCode = exitCode
+	c.info.OOMKilled = oomKilled
+	c.info.FinishedAt = time.Now()
+	c.usage = ResourceUsage{}
+	if oomKilled {
+		f.publishLocked(containerID, "oom", 0)
+	}
+	f.publishLocked(containerID, "die", exitCode)
+}
+
+// CopyTo stores an archive for a directory of a container.
+func (f *Fake) CopyTo(ctx context.Context, containerID, dir string, archive io.Reader) error {
+	data, err := io.ReadAll(archive)
+	if err != nil {
+		return fmt.Errorf("failed to copy into container: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.containers[containerID]
+	if !ok {
+		return ErrContainerNotFound
+	}
+	c.archives[dir] = data
+	return nil
+}
+
+// CopyFrom returns the archive last copied to or set for a path.
+func (f *Fake) CopyFrom(ctx context.Context, containerID, path string) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.containers[containerID]
+	if !ok {
+		return nil, ErrContainerNotFound
+	}
+	data, ok := c.archives[path]
+	if !ok {
+		return nil, fmt.Errorf("failed to copy from container: Could not find the file %s in container %s", path, containerID)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// SetArchive sets the tar archive CopyFrom returns for a path, as if the
+// container had written files there.
+func (f *Fake) SetArchive(containerID, path string, archive []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.containers[containerID]
+	if !ok {
+		return ErrContainerNotFound
+	}
+	c.archives[path] = archive
+	return nil
+}
+
+// WriteLogs appends output to a container's logs.
+func (f *Fake) WriteLogs(containerID, output string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.containers[containerID]
+	if !ok {
+		return ErrContainerNotFound
+	}
+	c.logs.WriteString(output)
+	return nil
+}
+
+// Logs returns the logs written so far. Following is not simulated.
+func (f *Fake) Logs(ctx context.Context, containerID string, follow bool) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.containers[containerID]
+	if !ok {
+		return nil, ErrContainerNotFound
+	}
+	return io.NopCloser(bytes.NewReader(bytes.Clone(c.logs.Bytes()))), nil
+}
+
+// StreamLogs copies the logs written so far to stdout.
+func (f *Fake) StreamLogs(ctx context.Context, containerID string, stdout, stderr io.Writer) error {
+	logs, err := f.Logs(ctx, containerID, true)
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+
+	_, err = io.Copy(stdout, logs)
+	return err
+}
+
+// Inspect returns a container's current state.
+func (f *Fake) Inspect(ctx context.Context, containerID string) (*ContainerInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.containers[containerID]
+	if !ok {
+		return nil, ErrContainerNotFound
+	}
+	info := c.info
+	return &info, nil
+}
+
+// ContainerIP returns the container's made-up address.
+func (f *Fake) ContainerIP(ctx context.Context, containerID string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.containers[containerID]
+	if !ok {
+		return "", ErrContainerNotFound
+	}
+	return c.ip, nil
+}
+
+// Exec succeeds in running containers unless ExecHook fails it.
+func (f *Fake) Exec(ctx context.Context, containerID string, command []string) error {
+	f.mu.Lock()
+	c, ok := f.containers[containerID]
+	running := ok && c.info.Status == "running"
+	f.mu.Unlock()
+
+	if !ok {
+		return ErrContainerNotFound
+	}
+	if !running {
+		return fmt.Errorf("failed to run command: container %s is not running", containerID)
+	}
+	if f.ExecHook != nil {
+		if err := f.ExecHook(containerID, command); err != nil {
+			return fmt.Errorf("command failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// SetStats sets the usage Stats reports for a container.
+func (f *Fake) SetStats(containerID string, usage ResourceUsage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.containers[containerID]
+	if !ok {
+		return ErrContainerNotFound
+	}
+	c.usage = usage
+	return nil
+}
+
+// Stats returns the usage set with SetStats. The memory limit defaults to
+// the container's configured limit.
+func (f *Fake) Stats(ctx context.Context, containerID string) (*ResourceUsage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.containers[containerID]
+	if !ok {
+		return nil, ErrContainerNotFound
+	}
+	usage := c.usage
+	if usage.MemoryLimit == 0 {
+		usage.MemoryLimit = uint64(c.config.MemoryBytes)
+	}
+	return &usage, nil
+}
+
+// Events streams lifecycle events until the context is cancelled.
+func (f *Fake) Events(ctx context.Context) (<-chan Event, <-chan error) {
+	sub := make(chan Event, 64)
+	events := make(chan Event)
+	errs := make(chan error)
+
+	f.mu.Lock()
+	f.subscribers[sub] = struct{}{}
+	f.mu.Unlock()
+
+	go func() {
+		defer close(errs)
+		defer close(events)
+		defer func() {
+			f.mu.Lock()
+			delete(f.subscribers, sub)
+			f.mu.Unlock()
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev := <-sub:
+				select {
+				case events <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, errs
+}
+
+// publishLocked sends an event to every subscriber (caller must hold lock).
+// Events for subscribers that fall too far behind are dropped.
+func (f *Fake) publishLocked(containerID, action string, exitCode int) {
+	ev := Event{
+		ContainerID: containerID,
+		Action:      action,
+		ExitCode:    exitCode,
+		Time:        time.Now(),
+	}
+	if c, ok := f.containers[containerID]; ok {
+		ev.DeploymentID = c.config.DeploymentID
+	}
+	for sub := range f.subscribers {
+		select {
+		case sub <- ev:
+		default:
+		}
+	}
+}
+
+// ListPeerComputeContainers returns every container, since the fake only
+// ever creates Peer Compute containers.
+func (f *Fake) ListPeerComputeContainers(ctx context.Context) ([]Container, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	containers := make([]Container, 0, len(f.containers))
+	for id, c := range f.containers {
+		containers = append(containers, Container{
+			ID:    id,
+			State: c.info.Status,
+			Labels: map[string]string{
+				PeerComputeLabel:  "true",
+				DeploymentIDLabel: c.config.DeploymentID,
+				RequesterIDLabel:  c.config.RequesterID,
+			},
+		})
+	}
+	return containers, nil
+}
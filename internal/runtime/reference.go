@@ -0,0 +1,270 @@
+// Package runtime - Image reference parsing
+package runtime
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DockerHubRegistry is the registry of images without a registry host
+	DockerHubRegistry = "docker.io"
+
+	// maxReferenceLength is the longest image name the engine accepts
+	maxReferenceLength = 255
+)
+
+var (
+	// pathComponentRe matches one component of a repository path
+	pathComponentRe = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*$`)
+
+	// hostRe matches a registry host with an optional port
+	hostRe = regexp.MustCompile(`^(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*(?::[0-9]+)?$`)
+
+	// tagRe matches an image tag
+	tagRe = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+
+	// digestRe matches a content digest; sha256 digests are checked
+	// further
+	digestRe = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-zA-Z0-9=_-]{32,}$`)
+
+	// sha256Re matches a sha256 digest
+	sha256Re = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+)
+
+// ImageReference is a parsed image reference such as
+// "ghcr.io/acme/app:1.2@sha256:...".
+type ImageReference struct {
+	// Registry is the registry host, with its port (e.g., "docker.io",
+	// "localhost:5000")
+	Registry string
+
+	// Repository is the repository path within the registry. Docker Hub
+	// images without a namespace are in "library/" (e.g., "library/nginx")
+	Repository string
+
+	// Tag is the tag (empty if not given)
+	Tag string
+
+	// Digest is the content digest (empty if not given)
+	Digest string
+}
+
+// ParseImageReference parses and normalizes an image reference the way
+// Docker does. The first path component is the registry if it contains a
+// dot or a port or is localhost; otherwise the image is on Docker Hub.
+func ParseImageReference(image string) (ImageReference, error) {
+	var ref ImageReference
+	if image == "" {
+		return ref, fmt.Errorf("image reference is empty")
+	}
+
+	rest := image
+	if name, digest, ok := strings.Cut(rest, "@"); ok {
+		if !digestRe.MatchString(digest) ||
+			(strings.HasPrefix(digest, "sha256:") && !sha256Re.MatchString(digest)) {
+			return ref, fmt.Errorf("invalid digest %q in image reference %q", digest, image)
+		}
+		ref.Digest = digest
+		rest = name
+	}
+
+	if i := strings.LastIndex(rest, ":"); i > strings.LastIndex(rest, "/") {
+		ref.Tag = rest[i+1:]
+		rest = rest[:i]
+		if !tagRe.MatchString(ref.Tag) {
+			return ref, fmt.Errorf("invalid tag %q in image reference %q", ref.Tag, image)
+		}
+	}
+
+	ref.Registry, ref.Repository = splitRegistry(rest)
+	if !hostRe.MatchString(ref.Registry) {
+		return ref, fmt.Errorf("invalid registry %q in image reference %q", ref.Registry, image)
+	}
+	for _, component := range strings.Split(ref.Repository, "/") {
+		if !pathComponentRe.MatchString(component) {
+			return ref, fmt.Errorf("invalid repository %q in image reference %q (must be lowercase)", ref.Repository, image)
+		}
+	}
+	if len(ref.Registry)+1+len(ref.Repository) > maxReferenceLength {
+		return ref, fmt.Errorf("image name %q is too long", image)
+	}
+	return ref, nil
+}
+
+// splitRegistry splits a name into registry and repository, normalizing
+// Docker Hub names.
+func splitRegistry(name string) (string, string) {
+	registry, repository := DockerHubRegistry, name
+	if first, remainder, ok := strings.Cut(name, "/"); ok && isRegistryHost(first) {
+		registry, repository = first, remainder
+	}
+	if registry == "index.docker.io" {
+		registry = DockerHubRegistry
+	}
+	if registry == DockerHubRegistry && !strings.Contains(repository, "/") {
+		repository = "library/" + repository
+	}
+	return registry, repository
+}
+
+// isRegistryHost reports whether the first component of a name is a
+// registry host rather than a Docker Hub namespace.
+func isRegistryHost(component string) bool {
+	return strings.ContainsAny(component, ".:") || component == "localhost"
+}
+
+// Name returns the registry and repository, e.g. "docker.io/library/nginx".
+func (r ImageReference) Name() string {
+	return r.Registry + "/" + r.Repository
+}
+
+// String returns the normalized reference.
+func (r ImageReference) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// PulledTag returns the tag that is pulled for the reference: its tag, or
+// "latest" when neither a tag nor a digest is given.
+func (r ImageReference) PulledTag() string {
+	if r.Tag == "" && r.Digest == "" {
+		return "latest"
+	}
+	return r.Tag
+}
+
+// PinImage returns an image reference pinned to a digest, keeping its tag
+// for readability, e.g. "docker.io/library/nginx:1.27@sha256:...". Images
+// that are already pinned, or an empty digest, leave the image as it is.
+func PinImage(image, digest string) string {
+	ref, err := ParseImageReference(image)
+	if err != nil || ref.Digest != "" || digest == "" {
+		return image
+	}
+	ref.Tag = ref.PulledTag()
+	ref.Digest = digest
+	return ref.String()
+}
+
+// ImagePattern matches image references. Patterns are written like image
+// references and normalized the same way, with glob wildcards: "*" and "?"
+// match within a path component and "**" matches across components.
+//
+//	docker.io               every image on Docker Hub
+//	*.example.com           every image on a matching registry
+//	nginx                   docker.io/library/nginx, any tag
+//	ghcr.io/acme/*          repositories directly under ghcr.io/acme
+//	ghcr.io/acme/**         every repository under ghcr.io/acme
+//	redis:7.*               docker.io/library/redis with a 7.x tag
+//
+// A pattern without a slash is a registry if its host contains a dot or is
+// localhost, optionally with a port ("localhost:5000"); otherwise it is a
+// Docker Hub repository with an optional tag ("node:20").
+type ImagePattern struct {
+	pattern    string
+	registry   *regexp.Regexp
+	repository *regexp.Regexp // nil = any repository on the registry
+	tag        *regexp.Regexp // nil = any tag
+}
+
+// ParseImagePattern parses an image pattern.
+func ParseImagePattern(pattern string) (*ImagePattern, error) {
+	if pattern == "" || strings.Contains(pattern, "@") {
+		return nil, fmt.Errorf("invalid image pattern %q", pattern)
+	}
+	p := &ImagePattern{pattern: pattern}
+
+	if !strings.Contains(pattern, "/") && isRegistryPattern(pattern) {
+		p.registry = compileGlob(pattern)
+		return p, nil
+	}
+
+	rest := pattern
+	if i := strings.LastIndex(rest, ":"); i > strings.LastIndex(rest, "/") {
+		if rest[i+1:] == "" {
+			return nil, fmt.Errorf("invalid image pattern %q", pattern)
+		}
+		p.tag = compileGlob(rest[i+1:])
+		rest = rest[:i]
+	}
+	registry, repository := splitRegistry(rest)
+	if repository == "" || registry == "" {
+		return nil, fmt.Errorf("invalid image pattern %q", pattern)
+	}
+	p.registry = compileGlob(registry)
+	p.repository = compileGlob(repository)
+	return p, nil
+}
+
+// isRegistryPattern reports whether a pattern without a slash names a
+// registry: its host contains a dot or is localhost, and any port is
+// numeric (or a wildcard). "node:20" is a repository and tag, not a host
+// and port.
+func isRegistryPattern(pattern string) bool {
+	host, port, hasPort := strings.Cut(pattern, ":")
+	if !strings.Contains(host, ".") && host != "localhost" {
+		return false
+	}
+	return !hasPort || (port != "" && strings.Trim(port, "0123456789*") == "")
+}
+
+// compileGlob compiles a glob into an anchored regular expression.
+func compileGlob(glob string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case c == '*' && i+1 < len(glob) && glob[i+1] == '*':
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// Match reports whether an image reference matches the pattern. A tag
+// pattern is matched against the tag that would be pulled, so images
+// pinned only by digest never match one.
+func (p *ImagePattern) Match(ref ImageReference) bool {
+	if !p.registry.MatchString(ref.Registry) {
+		return false
+	}
+	if p.repository != nil && !p.repository.MatchString(ref.Repository) {
+		return false
+	}
+	if p.tag != nil {
+		tag := ref.PulledTag()
+		return tag != "" && p.tag.MatchString(tag)
+	}
+	return true
+}
+
+// denies reports whether a deny pattern matches an image reference. An image
+// pinned only by digest could be any tag, so a tag pattern denies every
+// digest-only image of the repositories it matches.
+func (p *ImagePattern) denies(ref ImageReference) bool {
+	if p.tag != nil && ref.Tag == "" && ref.Digest != "" {
+		return p.registry.MatchString(ref.Registry) && p.repository.MatchString(ref.Repository)
+	}
+	return p.Match(ref)
+}
+
+// String returns the pattern as written.
+func (p *ImagePattern) String() string {
+	return p.pattern
+}
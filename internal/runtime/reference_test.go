@@ -0,0 +1,177 @@
+package runtime
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseImageReference(t *testing.T) {
+	tests := []struct {
+		image string
+		want  ImageReference
+	}{
+		{"nginx", ImageReference{Registry: "docker.io", Repository: "library/nginx"}},
+		{"nginx:1.27", ImageReference{Registry: "docker.io", Repository: "library/nginx", Tag: "1.27"}},
+		{"acme/app", ImageReference{Registry: "docker.io", Repository: "acme/app"}},
+		{"index.docker.io/library/redis:7", ImageReference{Registry: "docker.io", Repository: "library/redis", Tag: "7"}},
+		{"localhost/app", ImageReference{Registry: "localhost", Repository: "app"}},
+		{"localhost:5000/app:dev", ImageReference{Registry: "localhost:5000", Repository: "app", Tag: "dev"}},
+		{"ghcr.io/acme/tools/app@" + testDigest, ImageReference{Registry: "ghcr.io", Repository: "acme/tools/app", Digest: testDigest}},
+		{"nginx:1.27@" + testDigest, ImageReference{Registry: "docker.io", Repository: "library/nginx", Tag: "1.27", Digest: testDigest}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			got, err := ParseImageReference(tt.image)
+			if err != nil {
+				t.Fatalf("ParseImageReference() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseImageReference() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseImageReferenceInvalid(t *testing.T) {
+	for _, image := range []string{
+		"",
+		"Nginx",
+		"nginx:",
+		"nginx@sha256:abc",
+		"ghcr.io/acme/App",
+		"nginx:" + strings.Repeat("a", 129),
+	} {
+		if _, err := ParseImageReference(image); err == nil {
+			t.Errorf("ParseImageReference(%q) succeeded, want error", image)
+		}
+	}
+}
+
+func TestImagePatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		image   string
+		want    bool
+	}{
+		// The examples of the ImagePattern doc comment
+		{"docker.io", "nginx", true},
+		{"docker.io", "acme/app:1.0", true},
+		{"docker.io", "ghcr.io/acme/app", false},
+		{"*.example.com", "registry.example.com/app", true},
+		{"*.example.com", "example.com/app", false},
+		{"nginx", "nginx", true},
+		{"nginx", "docker.io/library/nginx:1.27", true},
+		{"nginx", "nginx@" + testDigest, true},
+		{"nginx", "acme/nginx", false},
+		{"ghcr.io/acme/*", "ghcr.io/acme/app", true},
+		{"ghcr.io/acme/*", "ghcr.io/acme/tools/app", false},
+		{"ghcr.io/acme/**", "ghcr.io/acme/tools/app", true},
+		{"ghcr.io/acme/**", "ghcr.io/other/app", false},
+		{"redis:7.*", "redis:7.2", true},
+		{"redis:7.*", "redis:6.2", false},
+		{"redis:7.*", "redis", false},
+
+		// Repository and tag patterns without a slash are not registries
+		{"node:20", "node:20", true},
+		{"node:20", "node:22", false},
+		{"node:20", "docker.io/library/node:20", true},
+		{"redis:7", "redis:7", true},
+		{"redis:7", "redis", false},
+		{"*:latest", "nginx", true},
+
+		// Registries with ports
+		{"localhost", "localhost/app", true},
+		{"localhost:5000", "localhost:5000/app", true},
+		{"localhost:5000", "localhost/app", false},
+		{"registry.example.com:*", "registry.example.com:5000/app", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.image, func(t *testing.T) {
+			p, err := ParseImagePattern(tt.pattern)
+			if err != nil {
+				t.Fatalf("ParseImagePattern() error = %v", err)
+			}
+			ref, err := ParseImageReference(tt.image)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.Match(ref); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseImagePatternInvalid(t *testing.T) {
+	for _, pattern := range []string{"", "nginx:", "nginx@" + testDigest} {
+		if _, err := ParseImagePattern(pattern); err == nil {
+			t.Errorf("ParseImagePattern(%q) succeeded, want error", pattern)
+		}
+	}
+}
+
+func TestCheckImage(t *testing.T) {
+	policy := DefaultSecurityPolicy()
+	policy.AllowedImages = []string{"docker.io", "ghcr.io/acme/**"}
+	policy.DeniedImages = []string{"nginx:1.14", "redis:7", "ghcr.io/acme/legacy"}
+
+	tests := []struct {
+		image   string
+		allowed bool
+	}{
+		{"nginx:1.27", true},
+		{"nginx:1.14", false},
+		{"redis:7", false},
+		{"redis:6", true},
+		{"node:20", true},
+		{"ghcr.io/acme/app", true},
+		{"ghcr.io/acme/legacy:2", false},
+		{"quay.io/acme/app", false},
+
+		// A digest could be the denied tag's image
+		{"nginx@" + testDigest, false},
+		{"nginx:1.27@" + testDigest, true},
+		{"node@" + testDigest, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			err := policy.CheckImage(tt.image)
+			if tt.allowed && err != nil {
+				t.Errorf("CheckImage() error = %v, want allowed", err)
+			}
+			if !tt.allowed && !errors.Is(err, ErrPolicyViolation) {
+				t.Errorf("CheckImage() error = %v, want policy violation", err)
+			}
+		})
+	}
+}
+
+func TestCheckRequestedImageIgnoresTagNextToDigest(t *testing.T) {
+	policy := DefaultSecurityPolicy()
+	policy.DeniedImages = []string{"nginx:1.14"}
+
+	// The engine runs the digest, whatever the tag says
+	if err := policy.CheckRequestedImage("nginx:1.27@" + testDigest); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("CheckRequestedImage() error = %v, want policy violation", err)
+	}
+	if err := policy.CheckRequestedImage("nginx:1.27"); err != nil {
+		t.Errorf("CheckRequestedImage() error = %v, want allowed", err)
+	}
+}
+
+func TestPinImage(t *testing.T) {
+	tests := []struct {
+		image, digest, want string
+	}{
+		{"nginx", testDigest, "docker.io/library/nginx:latest@" + testDigest},
+		{"ghcr.io/acme/app:1.2", testDigest, "ghcr.io/acme/app:1.2@" + testDigest},
+		{"nginx@" + testDigest, "sha256:other", "nginx@" + testDigest},
+		{"nginx:1.27", "", "nginx:1.27"},
+	}
+	for _, tt := range tests {
+		if got := PinImage(tt.image, tt.digest); got != tt.want {
+			t.Errorf("PinImage(%q, %q) = %q, want %q", tt.image, tt.digest, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Package runtime - Registry API client
+package runtime
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// RegistryTimeout bounds one request to a registry, including
+	// authentication
+	RegistryTimeout = 30 * time.Second
+
+	// dockerHubAPIHost serves the registry API for Docker Hub
+	dockerHubAPIHost = "registry-1.docker.io"
+
+	// maxRegistryResponse bounds manifests, blobs and tokens read from a
+	// registry
+	maxRegistryResponse = 1 << 20
+)
+
+// errRegistryNotFound is returned for manifests and blobs the registry
+// does not have.
+var errRegistryNotFound = errors.New("not found in registry")
+
+// challengeParamRe matches one parameter of a WWW-Authenticate challenge
+var challengeParamRe = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// registryClient reads manifests and blobs from registries with the
+// Docker Registry HTTP API V2, which OCI registries also serve. It
+// authenticates with the credentials docker login stored, or anonymously.
+type registryClient struct {
+	client *http.Client
+}
+
+// newRegistryClient creates a registry client.
+func newRegistryClient() *registryClient {
+	return &registryClient{client: &http.Client{Timeout: RegistryTimeout}}
+}
+
+// registryURL returns the API base URL of a registry. Registries on the
+// loopback interface are used over plain HTTP, as Docker does; all others
+// require HTTPS.
+func registryURL(registry string) string {
+	if registry == DockerHubRegistry {
+		return "https://" + dockerHubAPIHost
+	}
+	host := registry
+	if h, _, err := net.SplitHostPort(registry); err == nil {
+		host = h
+	}
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return "http://" + registry
+	}
+	return "https://" + registry
+}
+
+// get fetches a path below a repository (e.g., "/manifests/latest"),
+// answering the registry's authentication challenge if it sends one.
+func (c *registryClient) get(ctx context.Context, registry, repository, path string, accept []string) ([]byte, error) {
+	u := registryURL(registry) + "/v2/" + repository + path
+
+	resp, err := c.fetch(ctx, u, accept, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		auth, err := c.authorize(ctx, challenge, registry, repository)
+		if err != nil {
+			return nil, fmt.Errorf("failed to authenticate to %s: %w", registry, err)
+		}
+		if resp, err = c.fetch(ctx, u, accept, auth); err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, errRegistryNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("registry %s returned %s for %s", registry, resp.Status, path)
+	}
+	return readLimited(resp.Body)
+}
+
+// fetch sends a GET request with an optional Authorization header.
+func (c *registryClient) fetch(ctx context.Context, u string, accept []string, auth string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(accept) > 0 {
+		req.Header.Set("Accept", strings.Join(accept, ", "))
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	return c.client.Do(req)
+}
+
+// authorize answers a WWW-Authenticate challenge with the Authorization
+// header to retry with: basic credentials, or a bearer token for pulling
+// from the repository.
+func (c *registryClient) authorize(ctx context.Context, challenge, registry, repository string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	username, password, hasCredentials := registryCredentials(registry)
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if !hasCredentials {
+			return "", fmt.Errorf("registry requires credentials (run docker login %s)", registry)
+		}
+		return basic, nil
+	case "bearer":
+	default:
+		return "", fmt.Errorf("unsupported authentication challenge %q", challenge)
+	}
+
+	values := make(map[string]string)
+	for _, m := range challengeParamRe.FindAllStringSubmatch(params, -1) {
+		values[m[1]] = m[2]
+	}
+	// SECURITY: Credentials are only sent to a plain HTTP token server for
+	// registries that are themselves on the loopback interface
+	realm, err := url.Parse(values["realm"])
+	insecure := strings.HasPrefix(registryURL(registry), "http://")
+	if err != nil || (realm.Scheme != "https" && !(insecure && realm.Scheme == "http")) {
+		return "", fmt.Errorf("invalid token realm %q", values["realm"])
+	}
+	query := realm.Query()
+	if values["service"] != "" {
+		query.Set("service", values["service"])
+	}
+	query.Set("scope", "repository:"+repository+":pull")
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if hasCredentials {
+		req.Header.Set("Authorization", basic)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token server returned %s", resp.Status)
+	}
+	data, err := readLimited(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(data, &token); err != nil {
+		return "", fmt.Errorf("invalid token response: %w", err)
+	}
+	t := firstNonEmpty(token.Token, token.AccessToken)
+	if t == "" {
+		return "", fmt.Errorf("token server returned no token")
+	}
+	return "Bearer " + t, nil
+}
+
+// readLimited reads a registry response of at most maxRegistryResponse
+// bytes.
+func readLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxRegistryResponse+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxRegistryResponse {
+		return nil, fmt.Errorf("registry response exceeds %d bytes", maxRegistryResponse)
+	}
+	return data, nil
+}
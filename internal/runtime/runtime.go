@@ -0,0 +1,300 @@
+// Package runtime provides secure container execution for Peer Compute.
+//
+// SECURITY CRITICAL: This package is responsible for enforcing container
+// isolation. All containers run with:
+// - No host filesystem mounts
+// - No host networking
+// - Non-privileged mode
+// - Strict CPU and memory limits
+// - Seccomp profile
+// - Dropped capabilities
+//
+// Containers are managed through the Runtime interface. Engine implements it
+// against the Docker Engine API; Fake is an in-memory implementation for
+// tests.
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultStopTimeout is the timeout for stopping containers
+	DefaultStopTimeout = 10 * time.Second
+
+	// PeerComputeLabel is the label used to identify Peer Compute containers
+	PeerComputeLabel = "peercompute.managed"
+
+	// DeploymentIDLabel stores the deployment ID on the container
+	DeploymentIDLabel = "peercompute.deployment-id"
+
+	// RequesterIDLabel stores the requester's peer ID
+	RequesterIDLabel = "peercompute.requester-id"
+)
+
+// ContainerConfig specifies how to run a container.
+type ContainerConfig struct {
+	// DeploymentID is the unique deployment identifier
+	DeploymentID string
+
+	// RequesterID is the peer ID that requested this deployment
+	RequesterID string
+
+	// Image is the Docker image to run (e.g., "nginx:alpine")
+	Image string
+
+	// CPUMillicores is the CPU limit in millicores (1000 = 1 CPU)
+	// SECURITY: Prevents container from consuming excessive CPU
+	CPUMillicores int64
+
+	// MemoryBytes is the memory limit in bytes
+	// SECURITY: Prevents container from consuming excessive memory
+	MemoryBytes int64
+
+	// ExposePort is the container port to expose (0 = no exposure)
+	ExposePort int
+
+	// Environment is a map of environment variables
+	Environment map[string]string
+
+	// Command overrides the image entrypoint (empty = image default)
+	Command []string
+
+	// Args overrides the image command arguments (empty = image default)
+	Args []string
+
+	// SeccompProfile is a seccomp profile (JSON) replacing the engine's
+	// default (empty = engine default)
+	// SECURITY: Set from the security policy by Enforce
+	SeccompProfile string
+}
+
+// ContainerInfo contains information about a running container.
+type ContainerInfo struct {
+	// ContainerID is the Docker container ID
+	ContainerID string
+
+	// Status is the container status
+	Status string
+
+	// StartedAt is when the container started
+	StartedAt time.Time
+
+	// HostPort is the port mapped on the host (if exposed)
+	HostPort int
+
+	// Image is the container image
+	Image string
+
+	// ExitCode is the exit code of a stopped container
+	ExitCode int
+
+	// OOMKilled reports whether the kernel OOM killer stopped the container
+	OOMKilled bool
+
+	// FinishedAt is when the container stopped (zero if still running)
+	FinishedAt time.Time
+
+	// Error is the runtime error message, if any
+	Error string
+}
+
+// ErrContainerNotFound is returned when a container no longer exists.
+var ErrContainerNotFound = errors.New("container not found")
+
+// ResourceUsage contains current resource usage metrics.
+type ResourceUsage struct {
+	// CPUPercent is the CPU usage percentage
+	CPUPercent float64
+
+	// MemoryBytes is the current memory usage
+	MemoryBytes uint64
+
+	// MemoryLimit is the memory limit
+	MemoryLimit uint64
+
+	// NetworkRxBytes is the total received since the container started
+	NetworkRxBytes uint64
+
+	// NetworkTxBytes is the total sent since the container started
+	NetworkTxBytes uint64
+
+	// BlockReadBytes is the total read from block devices
+	BlockReadBytes uint64
+
+	// BlockWriteBytes is the total written to block devices
+	BlockWriteBytes uint64
+
+	// PIDs is the number of processes and threads in the container
+	PIDs uint64
+
+	// PIDsLimit is the process limit (0 = unlimited)
+	PIDsLimit uint64
+}
+
+// Event is a container lifecycle event reported by the runtime.
+type Event struct {
+	// ContainerID is the container the event is about
+	ContainerID string
+
+	// DeploymentID is the container's deployment label, if any
+	DeploymentID string
+
+	// Action is what happened (e.g., "start", "die", "oom", "destroy")
+	Action string
+
+	// ExitCode is the container's exit code, for "die" events
+	ExitCode int
+
+	// Time is when the event happened
+	Time time.Time
+}
+
+// Runtime manages container execution.
+// SECURITY: Implementations must apply the isolation constraints described
+// in the package documentation to every container they create.
+type Runtime interface {
+	// Close releases the runtime's resources.
+	Close() error
+
+	// Ping checks that the container engine is available.
+	Ping(ctx context.Context) error
+
+	// Pull downloads an image.
+	Pull(ctx context.Context, imageName string) error
+
+	// ResolveDigest returns the digest an image's tag points to in its
+	// registry, without pulling it. Images pinned by digest resolve to
+	// their digest.
+	ResolveDigest(ctx context.Context, imageName string) (string, error)
+
+	// Run creates and starts a container.
+	Run(ctx context.Context, cfg ContainerConfig) (string, error)
+
+	// Create creates a container without starting it, so files can be
+	// copied in first. Start it with Start.
+	Create(ctx context.Context, cfg ContainerConfig) (string, error)
+
+	// Start starts an existing container without pulling or recreating it.
+	Start(ctx context.Context, containerID string) error
+
+	// Stop stops and removes a container. Missing containers are not an
+	// error.
+	Stop(ctx context.Context, containerID string) error
+
+	// Halt stops a container without removing it, so its exit code and
+	// logs remain available.
+	Halt(ctx context.Context, containerID string) error
+
+	// CopyTo extracts a tar archive into a directory of a container. The
+	// directory must exist in the image.
+	CopyTo(ctx context.Context, containerID, dir string, archive io.Reader) error
+
+	// CopyFrom returns a tar archive of a path in a container, which may be
+	// stopped. The caller must close the reader.
+	CopyFrom(ctx context.Context, containerID, path string) (io.ReadCloser, error)
+
+	// Logs returns the container's stdout and stderr, interleaved and
+	// prefixed with timestamps. With follow, the reader stays open until
+	// the container stops or the context is cancelled.
+	Logs(ctx context.Context, containerID string, follow bool) (io.ReadCloser, error)
+
+	// StreamLogs follows the container's logs, copying stdout and stderr
+	// to separate writers.
+	StreamLogs(ctx context.Context, containerID string, stdout, stderr io.Writer) error
+
+	// Inspect returns information about a container.
+	// It returns ErrContainerNotFound if the container has been removed.
+	Inspect(ctx context.Context, containerID string) (*ContainerInfo, error)
+
+	// ContainerIP returns the container's IP address on its network.
+	ContainerIP(ctx context.Context, containerID string) (string, error)
+
+	// Exec runs a command inside a running container and returns an error
+	// if it exits non-zero.
+	Exec(ctx context.Context, containerID string, command []string) error
+
+	// Stats returns current resource usage for a container.
+	Stats(ctx context.Context, containerID string) (*ResourceUsage, error)
+
+	// Events streams lifecycle events of Peer Compute containers until the
+	// context is cancelled or the stream fails. The error channel receives
+	// at most one error; both channels are closed when the stream ends.
+	Events(ctx context.Context) (<-chan Event, <-chan error)
+
+	// ListPeerComputeContainers returns all containers managed by Peer
+	// Compute, including their deployment and requester labels.
+	ListPeerComputeContainers(ctx context.Context) ([]Container, error)
+}
+
+// CleanupAll stops and removes all Peer Compute containers.
+// SECURITY: Called on daemon shutdown to ensure no orphaned containers.
+func CleanupAll(ctx context.Context, rt Runtime) error {
+	containers, err := rt.ListPeerComputeContainers(ctx)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for _, c := range containers {
+		if err := rt.Stop(ctx, c.ID); err != nil {
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
+// Container represents a Docker container in the list output.
+type Container struct {
+	ID     string
+	Labels map[string]string
+	// State is the container state (e.g., "running", "exited")
+	State string
+}
+
+// validateConfig validates container configuration.
+func validateConfig(cfg ContainerConfig) error {
+	if cfg.DeploymentID == "" {
+		return fmt.Errorf("deployment ID is required")
+	}
+	if cfg.Image == "" {
+		return fmt.Errorf("image is required")
+	}
+	if cfg.CPUMillicores <= 0 {
+		return fmt.Errorf("CPU limit must be positive")
+	}
+	if cfg.MemoryBytes <= 0 {
+		return fmt.Errorf("memory limit must be positive")
+	}
+	// SECURITY: Enforce minimum memory to prevent OOM kills affecting the host
+	if cfg.MemoryBytes < 4*1024*1024 { // 4MB minimum
+		return fmt.Errorf("memory limit must be at least 4MB")
+	}
+	return nil
+}
+
+// isValidEnvVar checks if an environment variable name is valid.
+func isValidEnvVar(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	// SECURITY: Block potentially dangerous environment variables
+	blockedVars := []string{
+		"LD_PRELOAD",
+		"LD_LIBRARY_PATH",
+		"DOCKER_HOST",
+	}
+	upperName := strings.ToUpper(name)
+	for _, blocked := range blockedVars {
+		if upperName == blocked {
+			return false
+		}
+	}
+	return true
+}
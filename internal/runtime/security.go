@@ -2,33 +2,69 @@
 package runtime
 
 import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 )
 
+// SecurityPolicyFileName is the daemon's security policy file in its data
+// directory
+const SecurityPolicyFileName = "security_policy.json"
+
+// ErrPolicyViolation is returned for containers the security policy does
+// not allow.
+var ErrPolicyViolation = errors.New("not allowed by the provider's security policy")
+
 // SecurityPolicy defines the security constraints for container execution.
-// All policies are enforced by the Runtime.Run function.
+// All policies are enforced by the Runtime returned by Enforce.
 type SecurityPolicy struct {
 	// AllowPrivileged allows privileged containers (NEVER set to true)
-	AllowPrivileged bool
+	AllowPrivileged bool `json:"-"`
 
 	// AllowHostNetwork allows host network access (NEVER set to true)
-	AllowHostNetwork bool
+	AllowHostNetwork bool `json:"-"`
 
 	// AllowHostMounts allows host filesystem mounts (NEVER set to true)
-	AllowHostMounts bool
+	AllowHostMounts bool `json:"-"`
 
 	// MaxCPUMillicores is the maximum CPU allocation per container
-	MaxCPUMillicores int64
+	MaxCPUMillicores int64 `json:"max_cpu_millicores"`
 
 	// MaxMemoryBytes is the maximum memory allocation per container
-	MaxMemoryBytes int64
+	MaxMemoryBytes int64 `json:"max_memory_bytes"`
+
+	// MaxContainers is the maximum number of deployments with running
+	// containers (0 = no limit)
+	MaxContainers int `json:"max_containers"`
+
+	// AllowedImages are the image patterns that may run (see ImagePattern).
+	// Empty list means all images are allowed
+	AllowedImages []string `json:"allowed_images,omitempty"`
+
+	// DeniedImages are image patterns that may never run, even if they are
+	// also allowed
+	DeniedImages []string `json:"denied_images,omitempty"`
 
-	// MaxContainers is the maximum number of concurrent containers
-	MaxContainers int
+	// SeccompProfile is the path of a seccomp profile (JSON) applied to
+	// every container. Empty means the engine's default profile
+	SeccompProfile string `json:"seccomp_profile,omitempty"`
 
-	// AllowedRegistries is the list of allowed Docker registries
-	// Empty list means all registries are allowed (MVP mode)
-	AllowedRegistries []string
+	// RequireDigest requires images to be pinned by digest. Deployments
+	// resolve their tag to a digest when requested and keep running it
+	RequireDigest bool `json:"require_digest,omitempty"`
+
+	// SignatureKeys are paths of public keys (PEM) images must be signed
+	// by, as with cosign sign --key. Setting keys requires digests
+	SignatureKeys []string `json:"signature_keys,omitempty"`
+
+	// seccompJSON is the loaded seccomp profile
+	seccompJSON string
+
+	// verifier checks signatures by the loaded signature keys
+	verifier *SignatureVerifier
 }
 
 // DefaultSecurityPolicy returns the default security policy.
@@ -47,9 +83,79 @@ func DefaultSecurityPolicy() *SecurityPolicy {
 		MaxMemoryBytes: 4 * 1024 * 1024 * 1024,
 		// Default max 10 concurrent containers
 		MaxContainers: 10,
-		// Empty = allow all registries (MVP)
-		AllowedRegistries: []string{},
+		// Empty = allow all images (MVP)
+		AllowedImages: []string{},
+	}
+}
+
+// LoadSecurityPolicy reads a policy file. Settings missing from the file
+// keep their defaults, and a missing file gives the default policy.
+func LoadSecurityPolicy(path string) (*SecurityPolicy, error) {
+	p := DefaultSecurityPolicy()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read security policy: %w", err)
+	}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("failed to parse security policy: %w", err)
+	}
+
+	if p.MaxCPUMillicores <= 0 || p.MaxMemoryBytes <= 0 || p.MaxContainers < 0 {
+		return nil, fmt.Errorf("invalid security policy: CPU and memory limits must be positive")
+	}
+	if _, _, err := p.imagePatterns(); err != nil {
+		return nil, fmt.Errorf("invalid security policy: %w", err)
+	}
+	if p.SeccompProfile != "" {
+		profile, err := os.ReadFile(p.SeccompProfile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read seccomp profile: %w", err)
+		}
+		if !json.Valid(profile) {
+			return nil, fmt.Errorf("seccomp profile %s is not valid JSON", p.SeccompProfile)
+		}
+		p.seccompJSON = string(profile)
+	}
+	if len(p.SignatureKeys) > 0 {
+		keys := make([]crypto.PublicKey, 0, len(p.SignatureKeys))
+		for _, path := range p.SignatureKeys {
+			key, err := LoadPublicKey(path)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+		p.verifier = NewSignatureVerifier(keys)
+	}
+	return p, nil
+}
+
+// RequiresDigest reports whether images must be pinned by digest.
+func (p *SecurityPolicy) RequiresDigest() bool {
+	return p.RequireDigest || len(p.SignatureKeys) > 0
+}
+
+// imagePatterns parses the allowed and denied image patterns.
+func (p *SecurityPolicy) imagePatterns() (allowed, denied []*ImagePattern, err error) {
+	for _, s := range p.AllowedImages {
+		pattern, err := ParseImagePattern(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		allowed = append(allowed, pattern)
 	}
+	for _, s := range p.DeniedImages {
+		pattern, err := ParseImagePattern(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		denied = append(denied, pattern)
+	}
+	return allowed, denied, nil
 }
 
 // Validate checks if a container configuration complies with the security policy.
@@ -62,40 +168,66 @@ func (p *SecurityPolicy) Validate(cfg ContainerConfig) error {
 		return fmt.Errorf("memory limit %d exceeds maximum %d", cfg.MemoryBytes, p.MaxMemoryBytes)
 	}
 
-	// Check registry restrictions
-	if len(p.AllowedRegistries) > 0 {
-		allowed := false
-		for _, registry := range p.AllowedRegistries {
-			if registryMatches(cfg.Image, registry) {
-				allowed = true
-				break
-			}
+	return p.CheckImage(cfg.Image)
+}
+
+// CheckImage checks an image reference against the allowed and denied
+// image patterns. Denied patterns take precedence.
+func (p *SecurityPolicy) CheckImage(image string) error {
+	ref, err := ParseImageReference(image)
+	if err != nil {
+		return err
+	}
+	allowed, denied, err := p.imagePatterns()
+	if err != nil {
+		return err
+	}
+
+	for _, pattern := range denied {
+		if pattern.denies(ref) {
+			return fmt.Errorf("image %s is %w (denied by %q)", image, ErrPolicyViolation, pattern)
 		}
-		if !allowed {
-			return fmt.Errorf("image registry not in allowed list")
+	}
+	if len(allowed) == 0 {
+		return nil
+	}
+	for _, pattern := range allowed {
+		if pattern.Match(ref) {
+			return nil
 		}
 	}
-
-	return nil
+	return fmt.Errorf("image %s is %w (not in the allowed images)", image, ErrPolicyViolation)
 }
 
-// registryMatches checks if an image is from a specific registry.
-func registryMatches(image, registry string) bool {
-	// Simple prefix match for MVP
-	// In production, use proper image name parsing
-	if registry == "" || registry == "docker.io" {
-		// Docker Hub images may not have a registry prefix
-		return true
+// CheckRequestedImage checks an image named in a request. The engine ignores
+// the tag of an image pinned by digest, so a requester could name a denied
+// digest with an allowed tag; such tags are ignored here too.
+func (p *SecurityPolicy) CheckRequestedImage(image string) error {
+	ref, err := ParseImageReference(image)
+	if err != nil {
+		return err
 	}
-	return len(image) >= len(registry) && image[:len(registry)] == registry
+	if ref.Digest != "" && ref.Tag != "" {
+		ref.Tag = ""
+		image = ref.String()
+	}
+	return p.CheckImage(image)
 }
 
-// SeccompProfile returns the default seccomp profile for containers.
-// SECURITY: This restricts dangerous system calls.
-func SeccompProfile() string {
-	// Use Docker's default seccomp profile
-	// In production, consider a custom, more restrictive profile
-	return "default"
+// CheckProvenance checks that an image is pinned by digest if the policy
+// requires it, and signed by one of its keys if it has any.
+func (p *SecurityPolicy) CheckProvenance(ctx context.Context, image string) error {
+	ref, err := ParseImageReference(image)
+	if err != nil {
+		return err
+	}
+	if ref.Digest == "" && p.RequiresDigest() {
+		return fmt.Errorf("image %s is %w (images must be pinned by digest)", image, ErrPolicyViolation)
+	}
+	if p.verifier == nil {
+		return nil
+	}
+	return p.verifier.Verify(ctx, ref)
 }
 
 // CapabilitiesToDrop returns the list of capabilities to drop.
@@ -112,3 +244,81 @@ func CapabilitiesToAdd() []string {
 	// For most containers, no additional capabilities are needed
 	return []string{}
 }
+
+// policyRuntime is a Runtime that enforces a security policy.
+type policyRuntime struct {
+	Runtime
+	policy *SecurityPolicy
+}
+
+// Enforce wraps a runtime so every image pull and container it creates is
+// checked against a policy, including digest pinning and signatures, and
+// containers get the policy's seccomp profile.
+// SECURITY: The daemon only hands out the wrapped runtime, so no code path
+// can start a container the policy forbids.
+func Enforce(rt Runtime, policy *SecurityPolicy) Runtime {
+	return &policyRuntime{Runtime: rt, policy: policy}
+}
+
+// Pull refuses images the policy does not allow before downloading them.
+func (r *policyRuntime) Pull(ctx context.Context, imageName string) error {
+	if err := r.policy.CheckImage(imageName); err != nil {
+		return err
+	}
+	if err := r.policy.CheckProvenance(ctx, imageName); err != nil {
+		return err
+	}
+	return r.Runtime.Pull(ctx, imageName)
+}
+
+// Run checks a container against the policy before running it.
+func (r *policyRuntime) Run(ctx context.Context, cfg ContainerConfig) (string, error) {
+	if err := r.admit(ctx, &cfg); err != nil {
+		return "", err
+	}
+	return r.Runtime.Run(ctx, cfg)
+}
+
+// Create checks a container against the policy before creating it.
+func (r *policyRuntime) Create(ctx context.Context, cfg ContainerConfig) (string, error) {
+	if err := r.admit(ctx, &cfg); err != nil {
+		return "", err
+	}
+	return r.Runtime.Create(ctx, cfg)
+}
+
+// admit validates a container configuration and applies the policy's
+// seccomp profile to it.
+func (r *policyRuntime) admit(ctx context.Context, cfg *ContainerConfig) error {
+	if err := r.policy.Validate(*cfg); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	// SECURITY: Signatures are checked again before every container, not
+	// only when the image was pulled
+	if err := r.policy.CheckProvenance(ctx, cfg.Image); err != nil {
+		return err
+	}
+
+	if r.policy.MaxContainers > 0 {
+		containers, err := r.ListPeerComputeContainers(ctx)
+		if err != nil {
+			return err
+		}
+		// Count deployments rather than containers, so a rolling update
+		// may start its new container next to the old one. Exited
+		// containers kept for their logs don't count
+		live := make(map[string]bool)
+		for _, c := range containers {
+			switch c.State {
+			case "created", "running", "restarting", "paused":
+				live[c.Labels[DeploymentIDLabel]] = true
+			}
+		}
+		if !live[cfg.DeploymentID] && len(live) >= r.policy.MaxContainers {
+			return fmt.Errorf("container limit of %d reached", r.policy.MaxContainers)
+		}
+	}
+
+	cfg.SeccompProfile = r.policy.seccompJSON
+	return nil
+}
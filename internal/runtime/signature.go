@@ -0,0 +1,194 @@
+// Package runtime - Image signature verification
+package runtime
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	// simpleSigningMediaType is the media type of cosign signature payloads
+	simpleSigningMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+
+	// cosignSignatureAnnotation holds a payload's base64 signature
+	cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+	// cosignSignatureType is the type of a container image signature
+	// payload
+	cosignSignatureType = "cosign container image signature"
+)
+
+// signatureManifestTypes are the manifest types cosign stores signatures as
+var signatureManifestTypes = []string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// SignatureVerifier checks that images are signed by one of a set of
+// public keys. It reads signatures the way cosign stores them: as the tag
+// "sha256-<digest>.sig" in the image's repository, with one simple-signing
+// payload per layer and its signature in a layer annotation.
+//
+// ECDSA, RSA (PKCS #1 v1.5) and Ed25519 keys are supported; cosign
+// generates ECDSA P-256 keys. Keyless signatures are not supported.
+type SignatureVerifier struct {
+	keys     []crypto.PublicKey
+	registry *registryClient
+
+	mu       sync.Mutex
+	verified map[string]bool // "registry/repository@digest" -> signed
+}
+
+// NewSignatureVerifier creates a verifier accepting signatures by any of
+// the given keys.
+func NewSignatureVerifier(keys []crypto.PublicKey) *SignatureVerifier {
+	return &SignatureVerifier{
+		keys:     keys,
+		registry: newRegistryClient(),
+		verified: make(map[string]bool),
+	}
+}
+
+// LoadPublicKey reads a PEM-encoded public key, such as cosign.pub.
+func LoadPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("%s is not a PEM public key", path)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %s: %w", path, err)
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T in %s", key, path)
+	}
+}
+
+// simpleSigningPayload is the signed payload of a cosign signature.
+type simpleSigningPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// signatureManifest is the part of a signature manifest that is read.
+type signatureManifest struct {
+	Layers []struct {
+		MediaType   string            `json:"mediaType"`
+		Digest      string            `json:"digest"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"layers"`
+}
+
+// Verify checks that an image pinned by digest carries a signature by one
+// of the verifier's keys for that digest. Images found signed are
+// remembered, since a digest always names the same content.
+func (v *SignatureVerifier) Verify(ctx context.Context, ref ImageReference) error {
+	if ref.Digest == "" {
+		return fmt.Errorf("image %s is %w (signed images must be pinned by digest)", ref, ErrPolicyViolation)
+	}
+	name := ref.Name() + "@" + ref.Digest
+
+	v.mu.Lock()
+	signed := v.verified[name]
+	v.mu.Unlock()
+	if signed {
+		return nil
+	}
+
+	tag := strings.Replace(ref.Digest, ":", "-", 1) + ".sig"
+	data, err := v.registry.get(ctx, ref.Registry, ref.Repository, "/manifests/"+tag, signatureManifestTypes)
+	if errors.Is(err, errRegistryNotFound) {
+		return fmt.Errorf("image %s is %w (not signed)", name, ErrPolicyViolation)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch signatures of %s: %w", name, err)
+	}
+	var manifest signatureManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("failed to parse signatures of %s: %w", name, err)
+	}
+
+	for _, layer := range manifest.Layers {
+		signature, ok := layer.Annotations[cosignSignatureAnnotation]
+		if layer.MediaType != simpleSigningMediaType || !ok || !sha256Re.MatchString(layer.Digest) {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(signature)
+		if err != nil {
+			continue
+		}
+		payload, err := v.registry.get(ctx, ref.Registry, ref.Repository, "/blobs/"+layer.Digest, nil)
+		if err != nil {
+			return fmt.Errorf("failed to fetch signature payload of %s: %w", name, err)
+		}
+		if fmt.Sprintf("sha256:%x", sha256.Sum256(payload)) != layer.Digest {
+			continue
+		}
+		if v.signedBy(payload, sig) && signsDigest(payload, ref.Digest) {
+			v.mu.Lock()
+			v.verified[name] = true
+			v.mu.Unlock()
+			return nil
+		}
+	}
+	return fmt.Errorf("image %s is %w (not signed by a trusted key)", name, ErrPolicyViolation)
+}
+
+// signedBy reports whether a signature of a payload was made by one of the
+// verifier's keys.
+func (v *SignatureVerifier) signedBy(payload, sig []byte) bool {
+	hash := sha256.Sum256(payload)
+	for _, key := range v.keys {
+		switch k := key.(type) {
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(k, hash[:], sig) {
+				return true
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(k, crypto.SHA256, hash[:], sig) == nil {
+				return true
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(k, payload, sig) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// signsDigest reports whether a signed payload is an image signature for
+// a manifest digest.
+// SECURITY: The payload names what was signed; without this check a
+// signature of one image could be copied to another.
+func signsDigest(payload []byte, digest string) bool {
+	var p simpleSigningPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return false
+	}
+	return p.Critical.Type == cosignSignatureType && p.Critical.Image.DockerManifestDigest == digest
+}
@@ -0,0 +1,273 @@
+package runtime
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+// testRegistry is a registry on the loopback interface holding cosign
+// signatures. It requires a bearer token, as Docker Hub and GHCR do.
+type testRegistry struct {
+	host      string
+	manifests map[string][]byte // "repository/tag" -> manifest
+	blobs     map[string][]byte // digest -> blob
+	requests  atomic.Int32
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	t.Helper()
+	r := &testRegistry{
+		manifests: make(map[string][]byte),
+		blobs:     make(map[string][]byte),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(r.serve))
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.host = u.Host
+	return r
+}
+
+func (r *testRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		if req.URL.Query().Get("scope") != "repository:acme/app:pull" {
+			http.Error(w, "bad scope", http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"token":"pull-token"}`)
+		return
+	}
+
+	r.requests.Add(1)
+	if req.Header.Get("Authorization") != "Bearer pull-token" {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="http://%s/token",service="test"`, r.host))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	if repo, tag, ok := strings.Cut(path, "/manifests/"); ok {
+		if m, ok := r.manifests[repo+"/"+tag]; ok {
+			w.Write(m)
+			return
+		}
+	}
+	if _, digest, ok := strings.Cut(path, "/blobs/"); ok {
+		if b, ok := r.blobs[digest]; ok {
+			w.Write(b)
+			return
+		}
+	}
+	http.NotFound(w, req)
+}
+
+// sign stores a cosign signature of a payload naming digest, made with key,
+// for the image acme/app@imageDigest.
+func (r *testRegistry) sign(t *testing.T, key *ecdsa.PrivateKey, imageDigest, digest string) {
+	t.Helper()
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"%s/acme/app"},`+
+		`"image":{"docker-manifest-digest":"%s"},"type":"cosign container image signature"},"optional":null}`,
+		r.host, digest))
+	hash := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payloadDigest := fmt.Sprintf("sha256:%x", hash)
+	r.blobs[payloadDigest] = payload
+	manifest, err := json.Marshal(map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"layers": []map[string]any{{
+			"mediaType":   simpleSigningMediaType,
+			"digest":      payloadDigest,
+			"size":        len(payload),
+			"annotations": map[string]string{cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(sig)},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.manifests["acme/app/"+strings.Replace(imageDigest, ":", "-", 1)+".sig"] = manifest
+}
+
+// image returns the name of acme/app in the registry pinned to a digest.
+func (r *testRegistry) image(digest string) string {
+	return r.host + "/acme/app@" + digest
+}
+
+// generateKey returns an ECDSA P-256 key, as cosign generate-key-pair
+// creates, and the path of its PEM public key.
+func generateKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "cosign.pub")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return key, path
+}
+
+// loadPolicy writes a security policy file and loads it.
+func loadPolicy(t *testing.T, policy map[string]any) *SecurityPolicy {
+	t.Helper()
+	data, err := json.Marshal(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), SecurityPolicyFileName)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadSecurityPolicy(path)
+	if err != nil {
+		t.Fatalf("LoadSecurityPolicy() error = %v", err)
+	}
+	return p
+}
+
+func TestCheckProvenanceSignatures(t *testing.T) {
+	// No stored credentials; the registry hands out anonymous tokens
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+
+	reg := newTestRegistry(t)
+	trusted, keyPath := generateKey(t)
+	untrusted, _ := generateKey(t)
+	policy := loadPolicy(t, map[string]any{"signature_keys": []string{keyPath}})
+
+	signed := "sha256:" + strings.Repeat("1", 64)
+	otherKey := "sha256:" + strings.Repeat("2", 64)
+	copied := "sha256:" + strings.Repeat("3", 64)
+	unsigned := "sha256:" + strings.Repeat("4", 64)
+	reg.sign(t, trusted, signed, signed)
+	reg.sign(t, untrusted, otherKey, otherKey)
+	// A valid signature of another image, stored as this image's signature
+	reg.sign(t, trusted, copied, signed)
+
+	tests := []struct {
+		name  string
+		image string
+		ok    bool
+	}{
+		{"signed by a trusted key", reg.image(signed), true},
+		{"signed by another key", reg.image(otherKey), false},
+		{"signature of another image", reg.image(copied), false},
+		{"not signed", reg.image(unsigned), false},
+		{"not pinned", reg.host + "/acme/app:1.0", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.CheckProvenance(context.Background(), tt.image)
+			if tt.ok && err != nil {
+				t.Errorf("CheckProvenance() error = %v, want allowed", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrPolicyViolation) {
+				t.Errorf("CheckProvenance() error = %v, want policy violation", err)
+			}
+		})
+	}
+
+	// A digest always names the same content, so it is verified only once
+	before := reg.requests.Load()
+	if err := policy.CheckProvenance(context.Background(), reg.image(signed)); err != nil {
+		t.Fatalf("CheckProvenance() error = %v", err)
+	}
+	if after := reg.requests.Load(); after != before {
+		t.Errorf("verified image fetched from the registry again (%d requests)", after-before)
+	}
+}
+
+func TestCheckProvenanceRequireDigest(t *testing.T) {
+	policy := loadPolicy(t, map[string]any{"require_digest": true})
+
+	if err := policy.CheckProvenance(context.Background(), "nginx:1.27"); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("CheckProvenance() error = %v, want policy violation", err)
+	}
+	if err := policy.CheckProvenance(context.Background(), "nginx@"+testDigest); err != nil {
+		t.Errorf("CheckProvenance() error = %v, want allowed", err)
+	}
+
+	// Without either setting, tags run as they are
+	if err := DefaultSecurityPolicy().CheckProvenance(context.Background(), "nginx:1.27"); err != nil {
+		t.Errorf("CheckProvenance() with the default policy error = %v", err)
+	}
+}
+
+func TestEnforceChecksProvenance(t *testing.T) {
+	policy := loadPolicy(t, map[string]any{"require_digest": true})
+	fake := NewFake()
+	rt := Enforce(fake, policy)
+	ctx := context.Background()
+
+	if err := rt.Pull(ctx, "nginx:1.27"); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("Pull() error = %v, want policy violation", err)
+	}
+	if fake.HasImage("nginx:1.27") {
+		t.Error("image refused by the policy was pulled")
+	}
+
+	// An image pulled before the policy changed is still checked when run
+	if err := fake.Pull(ctx, "nginx:1.27"); err != nil {
+		t.Fatal(err)
+	}
+	cfg := ContainerConfig{
+		DeploymentID:  "dep-0000000000000001",
+		Image:         "nginx:1.27",
+		CPUMillicores: 500,
+		MemoryBytes:   64 * 1024 * 1024,
+	}
+	if _, err := rt.Run(ctx, cfg); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("Run() error = %v, want policy violation", err)
+	}
+
+	cfg.Image = "nginx@" + testDigest
+	if err := rt.Pull(ctx, cfg.Image); err != nil {
+		t.Fatalf("Pull() error = %v", err)
+	}
+	if _, err := rt.Run(ctx, cfg); err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+}
+
+func TestLoadPublicKeyInvalid(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "key.txt")
+	if err := os.WriteFile(notPEM, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPublicKey(notPEM); err == nil {
+		t.Error("LoadPublicKey() accepted a file that is not PEM")
+	}
+	if _, err := LoadPublicKey(filepath.Join(dir, "missing.pub")); err == nil {
+		t.Error("LoadPublicKey() accepted a missing file")
+	}
+}
@@ -0,0 +1,144 @@
+// Package runtime - Multiplexed stream decoding
+package runtime
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Stream identifiers in the header of a multiplexed frame. Containers
+// without a TTY have their stdout and stderr interleaved on one connection,
+// each frame prefixed with an 8-byte header: the stream identifier, three
+// zero bytes and the big-endian payload size.
+const (
+	streamStdin  = 0
+	streamStdout = 1
+	streamStderr = 2
+	streamSystem = 3 // engine error, ends the stream
+
+	frameHeaderSize = 8
+
+	// maxFrameSize guards against a corrupt header allocating unbounded
+	// memory. The engine splits output into much smaller frames.
+	maxFrameSize = 1 << 24
+)
+
+// frameReader reads a multiplexed stream and returns the payloads of
+// stdout and stderr frames, interleaved in the order they arrive.
+type frameReader struct {
+	src     io.Reader
+	remain  int   // bytes left in the current frame
+	discard bool  // whether the current frame is skipped (stdin)
+	err     error // sticky error
+}
+
+// newFrameReader returns a reader of a multiplexed stream's payloads.
+func newFrameReader(src io.Reader) *frameReader {
+	return &frameReader{src: src}
+}
+
+func (f *frameReader) Read(p []byte) (int, error) {
+	for f.err == nil && (f.remain == 0 || f.discard) {
+		if f.remain > 0 {
+			if _, err := io.CopyN(io.Discard, f.src, int64(f.remain)); err != nil {
+				f.err = unexpectedEOF(err)
+				break
+			}
+			f.remain = 0
+		}
+		stream, size, err := readFrameHeader(f.src)
+		if err != nil {
+			f.err = err
+			break
+		}
+		if stream == streamSystem {
+			f.err = readSystemError(f.src, size)
+			break
+		}
+		f.remain = size
+		f.discard = stream == streamStdin
+	}
+	if f.err != nil {
+		return 0, f.err
+	}
+
+	if len(p) > f.remain {
+		p = p[:f.remain]
+	}
+	n, err := f.src.Read(p)
+	f.remain -= n
+	if err != nil && (err != io.EOF || f.remain > 0) {
+		f.err = unexpectedEOF(err)
+	}
+	return n, nil
+}
+
+// demux copies a multiplexed stream to separate stdout and stderr writers
+// until the stream ends. It returns nil at a clean end of stream.
+func demux(stdout, stderr io.Writer, src io.Reader) error {
+	for {
+		stream, size, err := readFrameHeader(src)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var dst io.Writer
+		switch stream {
+		case streamStdout:
+			dst = stdout
+		case streamStderr:
+			dst = stderr
+		case streamSystem:
+			return readSystemError(src, size)
+		default:
+			dst = io.Discard
+		}
+		if _, err := io.CopyN(dst, src, int64(size)); err != nil {
+			return unexpectedEOF(err)
+		}
+	}
+}
+
+// readFrameHeader reads the header of the next frame. It returns io.EOF
+// only if the stream ended cleanly between frames.
+func readFrameHeader(src io.Reader) (byte, int, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(src, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, 0, fmt.Errorf("truncated stream frame header")
+		}
+		return 0, 0, err
+	}
+
+	stream := header[0]
+	if stream > streamSystem {
+		return 0, 0, fmt.Errorf("invalid stream identifier %d", stream)
+	}
+	size := binary.BigEndian.Uint32(header[4:])
+	if size > maxFrameSize {
+		return 0, 0, fmt.Errorf("stream frame of %d bytes exceeds the limit", size)
+	}
+	return stream, int(size), nil
+}
+
+// readSystemError reads the payload of a system frame as an error.
+func readSystemError(src io.Reader, size int) error {
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(src, msg); err != nil {
+		return unexpectedEOF(err)
+	}
+	return fmt.Errorf("container engine error: %s", msg)
+}
+
+// unexpectedEOF reports a stream that ended inside a frame.
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("truncated stream frame")
+	}
+	return err
+}
@@ -0,0 +1,137 @@
+// Package scheduler - Job input and output artifacts
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/artifact"
+	"github.com/xdas-research/peer-compute/internal/protocol"
+	"github.com/xdas-research/peer-compute/internal/runtime"
+)
+
+// UnreferencedArtifactRetention is how long an artifact no deployment
+// refers to is kept, e.g. an input uploaded for a job not yet submitted.
+const UnreferencedArtifactRetention = time.Hour
+
+// SetArtifactStore sets the store job inputs are read from and outputs are
+// written to.
+func (s *Scheduler) SetArtifactStore(store *artifact.Store) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.artifacts = store
+}
+
+// createWithInput creates a job's container, copies its input in and
+// starts it. The container is removed if any step fails.
+func (s *Scheduler) createWithInput(ctx context.Context, d *protocol.Deployment, cfg runtime.ContainerConfig) (string, error) {
+	s.mu.RLock()
+	store := s.artifacts
+	s.mu.RUnlock()
+	if store == nil {
+		return "", fmt.Errorf("job inputs are not supported by this provider")
+	}
+
+	input, _, err := store.Open(d.RequesterID, d.Job.Input)
+	if err != nil {
+		return "", err
+	}
+	defer input.Close()
+
+	containerID, err := s.runtime.Create(ctx, cfg)
+	if err != nil {
+		return "", err
+	}
+	if err := s.runtime.CopyTo(ctx, containerID, d.Job.InputDir(), input); err != nil {
+		s.runtime.Stop(context.Background(), containerID)
+		return "", fmt.Errorf("failed to stage input: %w", err)
+	}
+	if err := s.runtime.Start(ctx, containerID); err != nil {
+		s.runtime.Stop(context.Background(), containerID)
+		return "", err
+	}
+	return containerID, nil
+}
+
+// collectOutputs copies the declared outputs of a job whose container has
+// exited into the artifact store. Each output is a tarball of the path,
+// counted against the owner's artifact quota. It runs before the job is
+// marked finished, so a finished job's outputs are always recorded.
+func (s *Scheduler) collectOutputs(ctx context.Context, deploymentID, containerID string) {
+	s.mu.RLock()
+	store := s.artifacts
+	s.mu.RUnlock()
+
+	d, ok := s.Get(deploymentID)
+	if !ok || store == nil || d.Job == nil || len(d.Job.Outputs) == 0 ||
+		containerID == "" || d.ContainerID != containerID || d.Outputs != nil {
+		return
+	}
+
+	outputs := make([]protocol.Artifact, 0, len(d.Job.Outputs))
+	for _, path := range d.Job.Outputs {
+		a := protocol.Artifact{Path: path}
+		if info, err := s.copyOutput(ctx, store, d, path); err != nil {
+			a.Error = err.Error()
+			log.Printf("[SCHEDULER] Failed to collect output %s of %s: %v", path, deploymentID, err)
+		} else {
+			a.Digest, a.Size = info.Digest, info.Size
+		}
+		outputs = append(outputs, a)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cur, ok := s.deployments[deploymentID]; ok && cur.ContainerID == d.ContainerID {
+		cur.Outputs = outputs
+		s.persistLocked()
+	}
+}
+
+// copyOutput stores one output path of a job's container.
+func (s *Scheduler) copyOutput(ctx context.Context, store *artifact.Store, d *protocol.Deployment, path string) (artifact.Info, error) {
+	archive, err := s.runtime.CopyFrom(ctx, d.ContainerID, path)
+	if err != nil {
+		return artifact.Info{}, err
+	}
+	info, err := store.Put(d.RequesterID, archive, "")
+	if cerr := archive.Close(); err == nil {
+		err = cerr
+	}
+	return info, err
+}
+
+// collectArtifacts removes stored artifacts that no deployment refers to.
+func (s *Scheduler) collectArtifacts() {
+	s.mu.RLock()
+	store := s.artifacts
+	referenced := make(map[string]bool)
+	for _, d := range s.deployments {
+		if d.Job == nil {
+			continue
+		}
+		if d.Job.Input != "" {
+			referenced[d.RequesterID+"/"+d.Job.Input] = true
+		}
+		for _, o := range d.Outputs {
+			if o.Digest != "" {
+				referenced[d.RequesterID+"/"+o.Digest] = true
+			}
+		}
+	}
+	for _, c := range s.crons {
+		if c.Spec.Job != nil && c.Spec.Job.Input != "" {
+			referenced[c.RequesterID+"/"+c.Spec.Job.Input] = true
+		}
+	}
+	s.mu.RUnlock()
+
+	if store == nil {
+		return
+	}
+	store.Collect(func(owner, digest string) bool {
+		return referenced[owner+"/"+digest]
+	}, UnreferencedArtifactRetention)
+}
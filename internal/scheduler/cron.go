@@ -0,0 +1,379 @@
+// Package scheduler - Scheduled (cron) jobs
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+const (
+	// CronFileName is the file schedules are persisted to
+	CronFileName = "cron.json"
+
+	// CronCheckInterval is how often schedules are checked for due runs
+	CronCheckInterval = 15 * time.Second
+
+	// MaxCronsPerPeer limits how many schedules a peer may register
+	MaxCronsPerPeer = 20
+)
+
+// AddCron registers a schedule for owner. The first run is at the next tick.
+func (s *Scheduler) AddCron(owner string, spec protocol.CronSpec) (*protocol.CronJob, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	expr, err := ParseCron(spec.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	next := expr.Next(now)
+	if next.IsZero() {
+		return nil, fmt.Errorf("schedule %q never runs", spec.Schedule)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, c := range s.crons {
+		if c.RequesterID == owner {
+			count++
+		}
+	}
+	if count >= MaxCronsPerPeer {
+		return nil, fmt.Errorf("schedule limit reached (%d per peer)", MaxCronsPerPeer)
+	}
+
+	c := &protocol.CronJob{
+		ID:          generateCronID(),
+		RequesterID: owner,
+		Spec:        spec,
+		CreatedAt:   now,
+		NextRunAt:   &next,
+	}
+	s.crons[c.ID] = c
+	s.persistCronsLocked()
+
+	log.Printf("[SCHEDULER] Registered schedule %s (%s) for %s, next run %s",
+		c.ID, spec.Schedule, owner, next.Format(time.RFC3339))
+	view := s.cronViewLocked(c)
+	return &view, nil
+}
+
+// ListCrons returns owner's schedules with the current state of their runs,
+// oldest schedule first.
+func (s *Scheduler) ListCrons(owner string) []protocol.CronJob {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var list []protocol.CronJob
+	for _, c := range s.crons {
+		if c.RequesterID == owner {
+			list = append(list, s.cronViewLocked(c))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	return list
+}
+
+// DeleteCron removes one of owner's schedules. Active runs keep running,
+// and past runs become ordinary finished deployments that are pruned after
+// FinishedRetention.
+func (s *Scheduler) DeleteCron(owner, cronID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.crons[cronID]
+	if !ok || c.RequesterID != owner {
+		return fmt.Errorf("schedule %s not found", cronID)
+	}
+	delete(s.crons, cronID)
+
+	for _, d := range s.deployments {
+		if d.CronID == cronID {
+			d.CronID = ""
+		}
+	}
+	s.persistLocked()
+	s.persistCronsLocked()
+
+	log.Printf("[SCHEDULER] Deleted schedule %s", cronID)
+	return nil
+}
+
+// RunCron starts scheduled runs when they are due, until the context is
+// cancelled.
+func (s *Scheduler) RunCron(ctx context.Context) {
+	ticker := time.NewTicker(CronCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runDueCrons(ctx)
+		}
+	}
+}
+
+// runDueCrons starts a run of every schedule whose tick has passed. Ticks
+// missed while the daemon was down collapse into a single run.
+func (s *Scheduler) runDueCrons(ctx context.Context) {
+	type dueRun struct {
+		cron protocol.CronJob
+		at   time.Time
+	}
+
+	now := time.Now()
+	var due []dueRun
+
+	s.mu.Lock()
+	for _, c := range s.crons {
+		if c.NextRunAt == nil || now.Before(*c.NextRunAt) {
+			continue
+		}
+		due = append(due, dueRun{cron: *c, at: *c.NextRunAt})
+
+		c.NextRunAt = nil
+		if expr, err := ParseCron(c.Spec.Schedule); err == nil {
+			if next := expr.Next(now); !next.IsZero() {
+				c.NextRunAt = &next
+			}
+		}
+	}
+	if len(due) > 0 {
+		s.persistCronsLocked()
+	}
+	s.mu.Unlock()
+
+	for _, r := range due {
+		s.startCronRun(ctx, r.cron, r.at)
+	}
+}
+
+// startCronRun starts one run of a schedule, applying its concurrency
+// policy to runs that are still active.
+func (s *Scheduler) startCronRun(ctx context.Context, c protocol.CronJob, at time.Time) {
+	run := protocol.CronRun{ScheduledAt: at}
+
+	if active := s.activeCronRuns(c.ID); len(active) > 0 {
+		switch c.Spec.Policy() {
+		case protocol.ConcurrencyForbid:
+			log.Printf("[SCHEDULER] Skipping run of schedule %s: previous run still active", c.ID)
+			run.Error = "skipped: previous run still active"
+			s.addCronRun(ctx, c.ID, run)
+			return
+		case protocol.ConcurrencyReplace:
+			for _, id := range active {
+				log.Printf("[SCHEDULER] Replacing run %s of schedule %s", id, c.ID)
+				s.halt(ctx, id, protocol.StatusTerminated, protocol.ReasonReplaced,
+					"replaced by the next scheduled run")
+			}
+		}
+	}
+
+	spec := c.Spec
+	d := newDeployment(&protocol.DeployRequest{
+		RequestID:     uuid.New().String(),
+		Image:         spec.Image,
+		CPUMillicores: spec.CPUMillicores,
+		MemoryBytes:   spec.MemoryBytes,
+		Environment:   spec.Environment,
+		PriorityClass: spec.PriorityClass,
+		Job:           spec.Job,
+		Priority:      spec.Priority,
+		RequesterID:   c.RequesterID,
+	})
+	d.CronID = c.ID
+	run.DeploymentID = d.ID
+	s.addCronRun(ctx, c.ID, run)
+
+	log.Printf("[SCHEDULER] Starting run %s of schedule %s", d.ID, c.ID)
+	go func() {
+		// Each run resolves the schedule's tag when it starts
+		d.ImageDigest = s.resolveDigest(ctx, d.Image)
+		if _, err := s.schedule(ctx, d, spec.QueueTimeoutSeconds); err != nil {
+			log.Printf("[SCHEDULER] Run %s of schedule %s failed to start: %v", d.ID, c.ID, err)
+			s.cronRunFailed(c.ID, d.ID, err)
+		}
+	}()
+}
+
+// activeCronRuns returns the runs of a schedule that are queued or still
+// hold resources.
+func (s *Scheduler) activeCronRuns(cronID string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var active []string
+	for _, d := range s.deployments {
+		if d.CronID == cronID && isActive(d.Status) {
+			active = append(active, d.ID)
+		}
+	}
+	return active
+}
+
+// addCronRun records a run and drops the oldest finished runs beyond the
+// schedule's history, removing their deployments and containers.
+func (s *Scheduler) addCronRun(ctx context.Context, cronID string, run protocol.CronRun) {
+	var expired []string
+
+	s.mu.Lock()
+	c, ok := s.crons[cronID]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	c.Runs = append(c.Runs, run)
+	for i := 0; len(c.Runs) > c.Spec.KeepRuns() && i < len(c.Runs); {
+		old := c.Runs[i]
+		if d, ok := s.deployments[old.DeploymentID]; ok && isActive(d.Status) {
+			// Never drop a run that is still active
+			i++
+			continue
+		}
+		c.Runs = append(c.Runs[:i], c.Runs[i+1:]...)
+		if old.DeploymentID != "" {
+			expired = append(expired, old.DeploymentID)
+		}
+	}
+	s.persistCronsLocked()
+	s.mu.Unlock()
+
+	for _, id := range expired {
+		if _, ok := s.Get(id); !ok {
+			continue
+		}
+		if err := s.Stop(ctx, id); err != nil {
+			log.Printf("[SCHEDULER] Failed to remove old run %s: %v", id, err)
+		}
+	}
+}
+
+// cronRunFailed records why a run could not be started when no deployment
+// record remains to tell.
+func (s *Scheduler) cronRunFailed(cronID, deploymentID string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.deployments[deploymentID]; ok {
+		return
+	}
+	if c, ok := s.crons[cronID]; ok {
+		for i := range c.Runs {
+			if c.Runs[i].DeploymentID == deploymentID {
+				c.Runs[i].Error = err.Error()
+			}
+		}
+		s.persistCronsLocked()
+	}
+}
+
+// keptByCron reports whether a finished deployment is a run a schedule still
+// keeps in its history; such runs are not pruned by age.
+func (s *Scheduler) keptByCron(d *protocol.Deployment) bool {
+	if d.CronID == "" {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.crons[d.CronID]
+	return ok
+}
+
+// cronViewLocked returns a copy of a schedule with the current state of its
+// runs filled in from their deployment records (caller must hold lock).
+func (s *Scheduler) cronViewLocked(c *protocol.CronJob) protocol.CronJob {
+	view := *c
+	view.Runs = make([]protocol.CronRun, len(c.Runs))
+	for i, r := range c.Runs {
+		if d, ok := s.deployments[r.DeploymentID]; ok && r.DeploymentID != "" {
+			r.Status = d.Status
+			r.ExitCode = d.ExitCode
+			r.Reason = d.Reason
+			if r.Error == "" {
+				r.Error = d.Error
+			}
+		}
+		view.Runs[i] = r
+	}
+	return view
+}
+
+// persistCronsLocked writes all schedules to the cron file (caller must hold
+// lock). Failures are logged, like deployment state.
+func (s *Scheduler) persistCronsLocked() {
+	if s.cronPath == "" {
+		return
+	}
+
+	crons := make([]*protocol.CronJob, 0, len(s.crons))
+	for _, c := range s.crons {
+		crons = append(crons, c)
+	}
+	data, err := json.MarshalIndent(crons, "", "  ")
+	if err != nil {
+		log.Printf("[SCHEDULER] Failed to marshal schedules: %v", err)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.cronPath), 0700); err != nil {
+		log.Printf("[SCHEDULER] Failed to create state directory: %v", err)
+		return
+	}
+	tmp := s.cronPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		log.Printf("[SCHEDULER] Failed to write schedules: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, s.cronPath); err != nil {
+		log.Printf("[SCHEDULER] Failed to replace schedule file: %v", err)
+	}
+}
+
+// loadCrons reads persisted schedules.
+func (s *Scheduler) loadCrons() error {
+	if s.cronPath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.cronPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read schedule file: %w", err)
+	}
+
+	var crons []*protocol.CronJob
+	if err := json.Unmarshal(data, &crons); err != nil {
+		return fmt.Errorf("failed to parse schedule file: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range crons {
+		s.crons[c.ID] = c
+	}
+	return nil
+}
+
+// generateCronID generates a unique schedule ID.
+func generateCronID() string {
+	return randomID("cron-")
+}
@@ -0,0 +1,181 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+// testCronSpec returns an hourly schedule of an nginx job.
+func testCronSpec(policy protocol.ConcurrencyPolicy) protocol.CronSpec {
+	return protocol.CronSpec{
+		Schedule:          "@hourly",
+		ConcurrencyPolicy: policy,
+		Image:             "nginx:1.27",
+		CPUMillicores:     250,
+		MemoryBytes:       testMemory,
+		Job:               &protocol.JobSpec{},
+	}
+}
+
+// tickCron makes a schedule due and starts its run, waiting until the
+// runs of the schedule are recorded.
+func tickCron(t *testing.T, s *Scheduler, cronID string, runs int) []protocol.CronRun {
+	t.Helper()
+	s.mu.Lock()
+	past := time.Now().Add(-time.Second)
+	s.crons[cronID].NextRunAt = &past
+	s.mu.Unlock()
+
+	s.runDueCrons(context.Background())
+	return waitForRuns(t, s, cronID, runs)
+}
+
+// waitForRuns waits until a schedule has the given number of runs and
+// none of them is still starting.
+func waitForRuns(t *testing.T, s *Scheduler, cronID string, runs int) []protocol.CronRun {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var c *protocol.CronJob
+		for _, job := range s.ListCrons(testRequester) {
+			if job.ID == cronID {
+				c = &job
+			}
+		}
+		if c == nil {
+			t.Fatalf("schedule %s not found", cronID)
+		}
+		starting := false
+		for _, r := range c.Runs {
+			starting = starting || (r.DeploymentID != "" && r.Status == "" && r.Error == "") ||
+				r.Status == protocol.StatusPending
+		}
+		if len(c.Runs) == runs && !starting {
+			return c.Runs
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("schedule has runs %+v, want %d", c.Runs, runs)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCronForbidSkipsWhileActive(t *testing.T) {
+	s, _ := newTestScheduler(t, nil)
+	c, err := s.AddCron(testRequester, testCronSpec(""))
+	if err != nil {
+		t.Fatalf("AddCron() error = %v", err)
+	}
+
+	runs := tickCron(t, s, c.ID, 1)
+	if runs[0].Status != protocol.StatusRunning {
+		t.Fatalf("first run = %+v, want running", runs[0])
+	}
+	runs = tickCron(t, s, c.ID, 2)
+	if runs[1].DeploymentID != "" || runs[1].Error == "" {
+		t.Errorf("second run = %+v, want skipped", runs[1])
+	}
+	if runs[0].Status != protocol.StatusRunning {
+		t.Errorf("first run = %+v, want still running", runs[0])
+	}
+}
+
+func TestCronReplaceStopsActiveRun(t *testing.T) {
+	s, _ := newTestScheduler(t, nil)
+	c, err := s.AddCron(testRequester, testCronSpec(protocol.ConcurrencyReplace))
+	if err != nil {
+		t.Fatalf("AddCron() error = %v", err)
+	}
+
+	tickCron(t, s, c.ID, 1)
+	runs := tickCron(t, s, c.ID, 2)
+	if runs[0].Status != protocol.StatusTerminated || runs[0].Reason != protocol.ReasonReplaced {
+		t.Errorf("first run = %+v, want replaced", runs[0])
+	}
+	if runs[1].Status != protocol.StatusRunning {
+		t.Errorf("second run = %+v, want running", runs[1])
+	}
+	if used, _, _, _, _, _ := s.ResourceUsage(); used != 250 {
+		t.Errorf("used CPU = %d, want 250", used)
+	}
+}
+
+func TestCronAllowRunsConcurrently(t *testing.T) {
+	s, _ := newTestScheduler(t, nil)
+	c, err := s.AddCron(testRequester, testCronSpec(protocol.ConcurrencyAllow))
+	if err != nil {
+		t.Fatalf("AddCron() error = %v", err)
+	}
+
+	tickCron(t, s, c.ID, 1)
+	runs := tickCron(t, s, c.ID, 2)
+	for i, r := range runs {
+		if r.Status != protocol.StatusRunning {
+			t.Errorf("run %d = %+v, want running", i, r)
+		}
+	}
+}
+
+func TestCronHistoryDropsOldRuns(t *testing.T) {
+	s, rt := newTestScheduler(t, nil)
+	spec := testCronSpec("")
+	spec.History = 1
+	c, err := s.AddCron(testRequester, spec)
+	if err != nil {
+		t.Fatalf("AddCron() error = %v", err)
+	}
+
+	first := tickCron(t, s, c.ID, 1)[0]
+	d, _ := s.Get(first.DeploymentID)
+	if err := rt.Exit(d.ContainerID, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	s.Reconcile(context.Background())
+
+	runs := tickCron(t, s, c.ID, 1)
+	if runs[0].DeploymentID == first.DeploymentID {
+		t.Fatal("the old run was kept instead of the new one")
+	}
+	if _, ok := s.Get(first.DeploymentID); ok {
+		t.Error("the old run's deployment was not removed")
+	}
+	if _, err := rt.Inspect(context.Background(), d.ContainerID); err == nil {
+		t.Error("the old run's container was not removed")
+	}
+}
+
+func TestCronOwnership(t *testing.T) {
+	s, _ := newTestScheduler(t, nil)
+	c, err := s.AddCron(testRequester, testCronSpec(""))
+	if err != nil {
+		t.Fatalf("AddCron() error = %v", err)
+	}
+
+	if list := s.ListCrons("12D3KooWOther"); len(list) != 0 {
+		t.Errorf("another peer lists %d schedules, want 0", len(list))
+	}
+	if err := s.DeleteCron("12D3KooWOther", c.ID); err == nil {
+		t.Error("another peer deleted the schedule")
+	}
+	if err := s.DeleteCron(testRequester, c.ID); err != nil {
+		t.Errorf("DeleteCron() error = %v", err)
+	}
+	if list := s.ListCrons(testRequester); len(list) != 0 {
+		t.Errorf("ListCrons() = %d schedules after delete, want 0", len(list))
+	}
+}
+
+func TestCronLimitPerPeer(t *testing.T) {
+	s, _ := newTestScheduler(t, nil)
+	for i := 0; i < MaxCronsPerPeer; i++ {
+		if _, err := s.AddCron(testRequester, testCronSpec("")); err != nil {
+			t.Fatalf("AddCron() error = %v", err)
+		}
+	}
+	if _, err := s.AddCron(testRequester, testCronSpec("")); err == nil {
+		t.Error("AddCron() succeeded beyond the per-peer limit")
+	}
+}
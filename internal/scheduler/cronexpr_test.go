@@ -0,0 +1,127 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+// cronBits returns the bit set of a cron field holding values.
+func cronBits(values ...int) uint64 {
+	var bits uint64
+	for _, v := range values {
+		bits |= 1 << uint(v)
+	}
+	return bits
+}
+
+// cronRange returns the bit set of a cron field holding lo through hi.
+func cronRange(lo, hi, step int) uint64 {
+	var bits uint64
+	for v := lo; v <= hi; v += step {
+		bits |= 1 << uint(v)
+	}
+	return bits
+}
+
+func TestParseCron(t *testing.T) {
+	var (
+		anyMinute = cronRange(0, 59, 1)
+		anyHour   = cronRange(0, 23, 1)
+		anyDom    = cronRange(1, 31, 1)
+		anyMonth  = cronRange(1, 12, 1)
+		anyDow    = cronRange(0, 7, 1)
+	)
+	tests := []struct {
+		spec string
+		want *CronExpr // nil when the spec is invalid
+	}{
+		// Valid expressions
+		{"* * * * *", &CronExpr{anyMinute, anyHour, anyDom, anyMonth, anyDow, true, true}},
+		{"30 2 * * 1-5", &CronExpr{cronBits(30), cronBits(2), anyDom, anyMonth, cronRange(1, 5, 1), true, false}},
+		{"*/15 * * * *", &CronExpr{cronRange(0, 59, 15), anyHour, anyDom, anyMonth, anyDow, true, true}},
+		{"0,30 9-17/4 1,15 * *", &CronExpr{cronBits(0, 30), cronBits(9, 13, 17), cronBits(1, 15), anyMonth, anyDow, false, true}},
+		{"5/20 * * * *", &CronExpr{cronBits(5, 25, 45), anyHour, anyDom, anyMonth, anyDow, true, true}},
+		{"  0 0 * * *  ", &CronExpr{cronBits(0), cronBits(0), anyDom, anyMonth, anyDow, true, true}},
+		// Sunday may be written as 7
+		{"0 0 * * 7", &CronExpr{cronBits(0), cronBits(0), anyDom, anyMonth, cronBits(0, 7), true, false}},
+
+		// Macros
+		{"@yearly", &CronExpr{cronBits(0), cronBits(0), cronBits(1), cronBits(1), anyDow, false, true}},
+		{"@annually", &CronExpr{cronBits(0), cronBits(0), cronBits(1), cronBits(1), anyDow, false, true}},
+		{"@monthly", &CronExpr{cronBits(0), cronBits(0), cronBits(1), anyMonth, anyDow, false, true}},
+		{"@weekly", &CronExpr{cronBits(0), cronBits(0), anyDom, anyMonth, cronBits(0), true, false}},
+		{"@daily", &CronExpr{cronBits(0), cronBits(0), anyDom, anyMonth, anyDow, true, true}},
+		{"@midnight", &CronExpr{cronBits(0), cronBits(0), anyDom, anyMonth, anyDow, true, true}},
+		{"@hourly", &CronExpr{cronBits(0), anyHour, anyDom, anyMonth, anyDow, true, true}},
+
+		// Invalid expressions
+		{"", nil},
+		{"* * * *", nil},
+		{"* * * * * *", nil},
+		{"60 * * * *", nil},
+		{"* 24 * * *", nil},
+		{"* * 0 * *", nil},
+		{"* * 32 * *", nil},
+		{"* * * 13 *", nil},
+		{"* * * * 8", nil},
+		{"5-1 * * * *", nil},
+		{"*/0 * * * *", nil},
+		{"*/x * * * *", nil},
+		{"a * * * *", nil},
+		{"1,,2 * * * *", nil},
+		{"@reboot", nil},
+		{"@DAILY", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseCron(tt.spec)
+			if tt.want == nil {
+				if err == nil {
+					t.Errorf("ParseCron() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCron() error = %v", err)
+			}
+			if *got != *tt.want {
+				t.Errorf("ParseCron() = %+v, want %+v", *got, *tt.want)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	at := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"*/15 * * * *", at(2026, 3, 10, 10, 7).Add(30 * time.Second), at(2026, 3, 10, 10, 15)},
+		{"*/15 * * * *", at(2026, 3, 10, 10, 15), at(2026, 3, 10, 10, 30)},
+		{"@daily", at(2026, 3, 10, 23, 59), at(2026, 3, 11, 0, 0)},
+		{"@hourly", at(2026, 12, 31, 23, 30), at(2027, 1, 1, 0, 0)},
+		// Saturday to the next weekday
+		{"30 2 * * 1-5", at(2026, 3, 14, 12, 0), at(2026, 3, 16, 2, 30)},
+		// Sunday may be written as 7
+		{"0 0 * * 7", at(2026, 3, 14, 12, 0), at(2026, 3, 15, 0, 0)},
+		// With both day fields restricted, either one matches
+		{"0 12 1 * 0", at(2026, 3, 2, 0, 0), at(2026, 3, 8, 12, 0)},
+		{"0 0 29 2 *", at(2026, 3, 1, 0, 0), at(2028, 2, 29, 0, 0)},
+		{"0 0 31 2 *", at(2026, 3, 1, 0, 0), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			expr, err := ParseCron(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseCron() error = %v", err)
+			}
+			if got := expr.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+// Package scheduler - Draining providers for maintenance
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+const (
+	// DrainFileName is the file the drain state is persisted to
+	DrainFileName = "drain.json"
+
+	// DrainCheckInterval is how often a drain checks whether its grace
+	// period has ended
+	DrainCheckInterval = 5 * time.Second
+)
+
+// ErrCordoned is returned for new deployments while the provider is
+// cordoned.
+var ErrCordoned = errors.New("provider is draining for maintenance and not accepting new deployments")
+
+// DrainState describes whether the provider is cordoned.
+type DrainState struct {
+	// Cordoned means no new deployments are admitted
+	Cordoned bool `json:"cordoned"`
+
+	// Since is when the provider was cordoned
+	Since time.Time `json:"since,omitempty"`
+
+	// StopAt is when deployments still running are stopped
+	StopAt *time.Time `json:"stop_at,omitempty"`
+
+	// Message is the operator's reason for the drain
+	Message string `json:"message,omitempty"`
+
+	// Notify means owners are told about the drain
+	Notify bool `json:"notify,omitempty"`
+}
+
+// DrainHook is called for each deployment a drain stopped.
+type DrainHook func(d *protocol.Deployment)
+
+// SetDrainHook sets the function called when a drain stops a deployment.
+func (s *Scheduler) SetDrainHook(hook DrainHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drainHook = hook
+}
+
+// Cordon stops admitting new deployments and schedules the deployments that
+// are still running to be stopped after the grace period. Queued requests
+// are terminated right away, since they could never start. Cordoning a
+// cordoned provider updates the grace period and message. It returns the
+// new state and the deployments that were active, so their owners can be
+// told.
+func (s *Scheduler) Cordon(grace time.Duration, message string, notify bool) (DrainState, []*protocol.Deployment) {
+	if grace < 0 {
+		grace = 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	stopAt := now.Add(grace)
+	if !s.drain.Cordoned {
+		s.drain = DrainState{Cordoned: true, Since: now}
+	}
+	s.drain.StopAt = &stopAt
+	s.drain.Message = message
+	s.drain.Notify = notify
+	s.persistDrainLocked()
+
+	var affected []*protocol.Deployment
+	changed := false
+	for _, d := range s.deployments {
+		switch {
+		case d.Status == protocol.StatusQueued:
+			d.Status = protocol.StatusTerminated
+			d.Reason = protocol.ReasonDrained
+			d.Error = "not admitted: the provider was drained for maintenance"
+			d.StoppedAt = &now
+			d.QueuePosition = 0
+			d.QueueDeadline = nil
+			changed = true
+			s.emit(protocol.EventStopped, d, d.Error)
+		case !holdsResources(d.Status) || d.Status == protocol.StatusStopping:
+			continue
+		}
+		copy := *d
+		affected = append(affected, &copy)
+	}
+	if changed {
+		s.persistLocked()
+	}
+
+	log.Printf("[SCHEDULER] Cordoned; %d deployments will be stopped at %s",
+		len(affected), stopAt.Format(time.RFC3339))
+	return s.drain, affected
+}
+
+// Uncordon admits new deployments again and cancels a pending stop. It
+// reports whether the provider was cordoned.
+func (s *Scheduler) Uncordon() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.drain.Cordoned {
+		return false
+	}
+	s.drain = DrainState{}
+	s.persistDrainLocked()
+	s.wakeQueue()
+
+	log.Printf("[SCHEDULER] Uncordoned; admitting new deployments")
+	return true
+}
+
+// DrainStatus returns the current drain state.
+func (s *Scheduler) DrainStatus() DrainState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.drain
+}
+
+// RunDrain stops the remaining deployments once a drain's grace period has
+// ended, until the context is cancelled.
+func (s *Scheduler) RunDrain(ctx context.Context) {
+	ticker := time.NewTicker(DrainCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.stopDrained(ctx)
+		}
+	}
+}
+
+// stopDrained stops every deployment still holding resources when the
+// provider is cordoned and the grace period has ended.
+func (s *Scheduler) stopDrained(ctx context.Context) {
+	s.mu.RLock()
+	due := s.drain.Cordoned && s.drain.StopAt != nil && time.Now().After(*s.drain.StopAt)
+	hook := s.drainHook
+	var ids []string
+	if due {
+		for _, d := range s.deployments {
+			if holdsResources(d.Status) && d.Status != protocol.StatusStopping {
+				ids = append(ids, d.ID)
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, id := range ids {
+		log.Printf("[SCHEDULER] Drain grace period ended, stopping %s", id)
+		s.halt(ctx, id, protocol.StatusTerminated, protocol.ReasonDrained,
+			"stopped because the provider was drained for maintenance")
+		if d, ok := s.Get(id); ok && hook != nil && d.Reason == protocol.ReasonDrained {
+			hook(d)
+		}
+	}
+}
+
+// persistDrainLocked writes the drain state to the drain file (caller must
+// hold lock). Failures are logged, like deployment state.
+func (s *Scheduler) persistDrainLocked() {
+	if s.drainPath == "" {
+		return
+	}
+	if !s.drain.Cordoned {
+		if err := os.Remove(s.drainPath); err != nil && !os.IsNotExist(err) {
+			log.Printf("[SCHEDULER] Failed to remove drain state: %v", err)
+		}
+		return
+	}
+
+	data, err := json.MarshalIndent(s.drain, "", "  ")
+	if err != nil {
+		log.Printf("[SCHEDULER] Failed to marshal drain state: %v", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(s.drainPath), 0700); err != nil {
+		log.Printf("[SCHEDULER] Failed to create state directory: %v", err)
+		return
+	}
+	tmp := s.drainPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		log.Printf("[SCHEDULER] Failed to write drain state: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, s.drainPath); err != nil {
+		log.Printf("[SCHEDULER] Failed to replace drain state file: %v", err)
+	}
+}
+
+// loadDrain reads a persisted drain state, so a provider that restarts
+// during a drain stays cordoned.
+func (s *Scheduler) loadDrain() error {
+	if s.drainPath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.drainPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read drain state: %w", err)
+	}
+
+	var state DrainState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse drain state: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drain = state
+	return nil
+}
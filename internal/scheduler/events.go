@@ -0,0 +1,115 @@
+// Package scheduler - Deployment lifecycle events
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+const (
+	// EventHistorySize is how many recent events are kept for new subscribers
+	EventHistorySize = 512
+
+	// eventBufferSize is how many events a subscriber may fall behind by
+	// before it is dropped
+	eventBufferSize = 64
+)
+
+// EventFilter selects the events a subscriber receives.
+type EventFilter func(e *protocol.DeploymentEvent) bool
+
+// EventBus fans deployment events out to subscribers and keeps the most
+// recent ones.
+type EventBus struct {
+	mu     sync.Mutex
+	recent []protocol.DeploymentEvent
+	subs   map[*eventSub]struct{}
+}
+
+// eventSub is a single subscriber.
+type eventSub struct {
+	ch     chan protocol.DeploymentEvent
+	filter EventFilter
+}
+
+// NewEventBus creates an empty event bus.
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[*eventSub]struct{})}
+}
+
+// Publish records an event and delivers it to matching subscribers. It never
+// blocks: a subscriber whose buffer is full is dropped and its channel
+// closed, so it can tell it missed events.
+func (b *EventBus) Publish(e protocol.DeploymentEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.recent = append(b.recent, e)
+	if len(b.recent) > EventHistorySize {
+		b.recent = append([]protocol.DeploymentEvent(nil), b.recent[len(b.recent)-EventHistorySize:]...)
+	}
+
+	for sub := range b.subs {
+		if sub.filter != nil && !sub.filter(&e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			delete(b.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// Subscribe returns the recent events matching filter and a channel of new
+// ones. The returned function unsubscribes; the channel is closed then, or
+// earlier if the subscriber falls too far behind.
+func (b *EventBus) Subscribe(filter EventFilter) ([]protocol.DeploymentEvent, <-chan protocol.DeploymentEvent, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var recent []protocol.DeploymentEvent
+	for i := range b.recent {
+		if filter == nil || filter(&b.recent[i]) {
+			recent = append(recent, b.recent[i])
+		}
+	}
+
+	sub := &eventSub{ch: make(chan protocol.DeploymentEvent, eventBufferSize), filter: filter}
+	b.subs[sub] = struct{}{}
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[sub]; ok {
+			delete(b.subs, sub)
+			close(sub.ch)
+		}
+	}
+	return recent, sub.ch, cancel
+}
+
+// Events returns the scheduler's event bus.
+func (s *Scheduler) Events() *EventBus {
+	return s.events
+}
+
+// emit publishes an event about a deployment. d must be a copy, or the
+// caller must hold the lock.
+func (s *Scheduler) emit(t protocol.EventType, d *protocol.Deployment, message string) {
+	s.events.Publish(protocol.DeploymentEvent{
+		Type:         t,
+		DeploymentID: d.ID,
+		Name:         d.Name,
+		RequesterID:  d.RequesterID,
+		Image:        d.Image,
+		Status:       d.Status,
+		Reason:       d.Reason,
+		ExitCode:     d.ExitCode,
+		Message:      message,
+		Time:         time.Now(),
+	})
+}
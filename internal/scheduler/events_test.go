@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+func TestEventBusFilter(t *testing.T) {
+	b := NewEventBus()
+	b.Publish(protocol.DeploymentEvent{Type: protocol.EventStarted, DeploymentID: "dep-a"})
+	b.Publish(protocol.DeploymentEvent{Type: protocol.EventStarted, DeploymentID: "dep-b"})
+
+	onlyA := func(e *protocol.DeploymentEvent) bool { return e.DeploymentID == "dep-a" }
+	recent, events, cancel := b.Subscribe(onlyA)
+	defer cancel()
+	if len(recent) != 1 || recent[0].DeploymentID != "dep-a" {
+		t.Errorf("recent events = %+v, want the one of dep-a", recent)
+	}
+
+	b.Publish(protocol.DeploymentEvent{Type: protocol.EventStopped, DeploymentID: "dep-b"})
+	b.Publish(protocol.DeploymentEvent{Type: protocol.EventStopped, DeploymentID: "dep-a"})
+	select {
+	case e := <-events:
+		if e.DeploymentID != "dep-a" || e.Type != protocol.EventStopped {
+			t.Errorf("received %+v, want dep-a stopping", e)
+		}
+	default:
+		t.Fatal("matching event was not delivered")
+	}
+	select {
+	case e := <-events:
+		t.Errorf("received unexpected event %+v", e)
+	default:
+	}
+}
+
+func TestEventBusKeepsRecentHistory(t *testing.T) {
+	b := NewEventBus()
+	for i := 0; i < EventHistorySize+10; i++ {
+		b.Publish(protocol.DeploymentEvent{Type: protocol.EventStarted, Message: string(rune('a' + i%26))})
+	}
+
+	recent, _, cancel := b.Subscribe(nil)
+	defer cancel()
+	if len(recent) != EventHistorySize {
+		t.Fatalf("kept %d events, want %d", len(recent), EventHistorySize)
+	}
+	// The oldest events are dropped first
+	if want := string(rune('a' + 10%26)); recent[0].Message != want {
+		t.Errorf("oldest kept event = %q, want %q", recent[0].Message, want)
+	}
+}
+
+func TestEventBusDropsSlowSubscribers(t *testing.T) {
+	b := NewEventBus()
+	_, events, cancel := b.Subscribe(nil)
+	defer cancel()
+
+	for i := 0; i < eventBufferSize+1; i++ {
+		b.Publish(protocol.DeploymentEvent{Type: protocol.EventStarted})
+	}
+
+	n := 0
+	for range events {
+		n++
+	}
+	if n != eventBufferSize {
+		t.Errorf("received %d events before being dropped, want %d", n, eventBufferSize)
+	}
+
+	// Unsubscribing after being dropped is harmless
+	cancel()
+}
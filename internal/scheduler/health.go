@@ -0,0 +1,231 @@
+// Package scheduler - Container health checks
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+// HealthTick is how often the health checker looks for due probes.
+const HealthTick = time.Second
+
+// HealthHook is called when a deployment's health state changes.
+type HealthHook func(d *protocol.Deployment, prev protocol.HealthState)
+
+// healthProbe tracks the in-memory probe state of one deployment.
+type healthProbe struct {
+	startedAt time.Time
+	nextAt    time.Time
+	successes int
+	failures  int
+	inFlight  bool
+}
+
+// SetHealthHook sets the function called on health state changes.
+func (s *Scheduler) SetHealthHook(hook HealthHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.healthHook = hook
+}
+
+// RunHealthChecks probes running deployments that have a health check until
+// the context is cancelled. Each deployment is probed at its own interval.
+func (s *Scheduler) RunHealthChecks(ctx context.Context) {
+	ticker := time.NewTicker(HealthTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.startDueProbes(ctx)
+		}
+	}
+}
+
+// startDueProbes launches the probes whose interval has elapsed.
+func (s *Scheduler) startDueProbes(ctx context.Context) {
+	now := time.Now()
+	var due []*protocol.Deployment
+
+	s.mu.Lock()
+	for id, d := range s.deployments {
+		if d.HealthCheck == nil || d.Status != protocol.StatusRunning || d.ContainerID == "" {
+			delete(s.probes, id)
+			continue
+		}
+
+		p, ok := s.probes[id]
+		if !ok {
+			p = &healthProbe{startedAt: now, nextAt: now}
+			s.probes[id] = p
+		}
+		if p.inFlight || now.Before(p.nextAt) {
+			continue
+		}
+
+		p.inFlight = true
+		p.nextAt = now.Add(d.HealthCheck.Interval())
+		copy := *d
+		due = append(due, &copy)
+	}
+	for id := range s.probes {
+		if _, ok := s.deployments[id]; !ok {
+			delete(s.probes, id)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, d := range due {
+		go s.probe(ctx, d)
+	}
+}
+
+// probe runs a single health check and records its result.
+func (s *Scheduler) probe(ctx context.Context, d *protocol.Deployment) {
+	probeCtx, cancel := context.WithTimeout(ctx, d.HealthCheck.Timeout())
+	err := s.runProbe(probeCtx, d)
+	cancel()
+
+	if ctx.Err() != nil {
+		return
+	}
+	s.recordHealth(d.ID, d.ContainerID, err)
+}
+
+// runProbe performs the check described by the deployment's health check.
+// HTTP and TCP probes connect to the container's own address, so a requester
+// can only ever probe their own container.
+func (s *Scheduler) runProbe(ctx context.Context, d *protocol.Deployment) error {
+	hc := d.HealthCheck
+
+	if hc.Type == protocol.HealthCheckExec {
+		return s.runtime.Exec(ctx, d.ContainerID, hc.Command)
+	}
+
+	ip, err := s.runtime.ContainerIP(ctx, d.ContainerID)
+	if err != nil {
+		return err
+	}
+	addr := net.JoinHostPort(ip, strconv.Itoa(hc.TargetPort(d.ExposePort)))
+
+	switch hc.Type {
+	case protocol.HealthCheckTCP:
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return fmt.Errorf("connection failed: %w", err)
+		}
+		return conn.Close()
+
+	case protocol.HealthCheckHTTP:
+		path := hc.Path
+		if path == "" {
+			path = "/"
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+addr+path, nil)
+		if err != nil {
+			return err
+		}
+		client := &http.Client{
+			// Redirects could point outside the container
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("request failed: %w", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			return fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown health check type %q", hc.Type)
+}
+
+// recordHealth applies a probe result and updates the health state once a
+// threshold is reached. containerID guards against stale results.
+func (s *Scheduler) recordHealth(deploymentID, containerID string, probeErr error) {
+	s.mu.Lock()
+
+	p, ok := s.probes[deploymentID]
+	if ok {
+		p.inFlight = false
+	}
+	d, found := s.deployments[deploymentID]
+	if !ok || !found || d.ContainerID != containerID || d.Status != protocol.StatusRunning {
+		s.mu.Unlock()
+		return
+	}
+
+	now := time.Now()
+	d.HealthCheckedAt = &now
+	prev := d.Health
+	hc := d.HealthCheck
+
+	if probeErr == nil {
+		p.successes++
+		p.failures = 0
+		d.HealthError = ""
+		if p.successes >= hc.Healthy() {
+			d.Health = protocol.HealthHealthy
+		}
+	} else {
+		p.successes = 0
+		d.HealthError = probeErr.Error()
+		// Failures during the start period don't count while still starting
+		if d.Health != protocol.HealthStarting || now.Sub(p.startedAt) >= hc.StartPeriod() {
+			p.failures++
+		}
+		if p.failures >= hc.Unhealthy() {
+			d.Health = protocol.HealthUnhealthy
+		}
+	}
+
+	if d.Health == prev {
+		s.mu.Unlock()
+		return
+	}
+
+	s.persistLocked()
+	switch d.Health {
+	case protocol.HealthHealthy:
+		s.emit(protocol.EventHealthy, d, "")
+	case protocol.HealthUnhealthy:
+		s.emit(protocol.EventUnhealthy, d, d.HealthError)
+	}
+	hook := s.healthHook
+	copy := *d
+	s.mu.Unlock()
+
+	log.Printf("[HEALTH] Deployment %s is %s", deploymentID, copy.Health)
+	if probeErr != nil {
+		log.Printf("[HEALTH] Last check failed: %v", probeErr)
+	}
+	if hook != nil {
+		hook(&copy, prev)
+	}
+}
+
+// resetHealthLocked returns a deployment to the starting health state, e.g.
+// after its container was restarted (caller must hold lock).
+func (s *Scheduler) resetHealthLocked(d *protocol.Deployment) {
+	if d.HealthCheck == nil {
+		return
+	}
+	d.Health = protocol.HealthStarting
+	d.HealthError = ""
+	delete(s.probes, d.ID)
+}
@@ -0,0 +1,159 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+// probeOnce runs one health check of a deployment synchronously, as
+// startDueProbes would once its interval has elapsed.
+func probeOnce(t *testing.T, s *Scheduler, deploymentID string) {
+	t.Helper()
+	s.mu.Lock()
+	d, ok := s.deployments[deploymentID]
+	if !ok {
+		s.mu.Unlock()
+		t.Fatalf("deployment %s not found", deploymentID)
+	}
+	if _, ok := s.probes[deploymentID]; !ok {
+		s.probes[deploymentID] = &healthProbe{}
+	}
+	s.probes[deploymentID].inFlight = true
+	copy := *d
+	s.mu.Unlock()
+
+	s.probe(context.Background(), &copy)
+}
+
+func TestHealthCheckThresholds(t *testing.T) {
+	s, rt := newTestScheduler(t, nil)
+
+	// Probes run synchronously, so failing needs no lock
+	var failing bool
+	rt.ExecHook = func(string, []string) error {
+		if failing {
+			return errors.New("exit code 1")
+		}
+		return nil
+	}
+
+	var changes []protocol.HealthState
+	s.SetHealthHook(func(d *protocol.Deployment, prev protocol.HealthState) {
+		changes = append(changes, d.Health)
+	})
+
+	req := testRequest(500)
+	req.HealthCheck = &protocol.HealthCheck{
+		Type:               protocol.HealthCheckExec,
+		Command:            []string{"true"},
+		HealthyThreshold:   2,
+		UnhealthyThreshold: 2,
+	}
+	d := mustSchedule(t, s, req)
+	if d.Health != protocol.HealthStarting {
+		t.Fatalf("Health = %s, want starting", d.Health)
+	}
+
+	health := func() protocol.HealthState {
+		got, _ := s.Get(d.ID)
+		return got.Health
+	}
+
+	probeOnce(t, s, d.ID)
+	if got := health(); got != protocol.HealthStarting {
+		t.Errorf("Health after one success = %s, want starting", got)
+	}
+	probeOnce(t, s, d.ID)
+	if got := health(); got != protocol.HealthHealthy {
+		t.Errorf("Health after two successes = %s, want healthy", got)
+	}
+
+	failing = true
+	probeOnce(t, s, d.ID)
+	if got := health(); got != protocol.HealthHealthy {
+		t.Errorf("Health after one failure = %s, want healthy", got)
+	}
+	probeOnce(t, s, d.ID)
+	got, _ := s.Get(d.ID)
+	if got.Health != protocol.HealthUnhealthy || got.HealthError == "" {
+		t.Errorf("after two failures = %s (%q), want unhealthy with an error", got.Health, got.HealthError)
+	}
+
+	// A success in between resets the failure count
+	failing = false
+	probeOnce(t, s, d.ID)
+	probeOnce(t, s, d.ID)
+	failing = true
+	probeOnce(t, s, d.ID)
+	failing = false
+	probeOnce(t, s, d.ID)
+	if got := health(); got != protocol.HealthHealthy {
+		t.Errorf("Health after an isolated failure = %s, want healthy", got)
+	}
+
+	want := []protocol.HealthState{protocol.HealthHealthy, protocol.HealthUnhealthy, protocol.HealthHealthy}
+	if len(changes) != len(want) {
+		t.Fatalf("health hook saw %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("health hook saw %v, want %v", changes, want)
+			break
+		}
+	}
+}
+
+func TestHealthCheckStartPeriod(t *testing.T) {
+	s, rt := newTestScheduler(t, nil)
+	rt.ExecHook = func(string, []string) error { return errors.New("exit code 1") }
+
+	req := testRequest(500)
+	req.HealthCheck = &protocol.HealthCheck{
+		Type:               protocol.HealthCheckExec,
+		Command:            []string{"false"},
+		StartPeriodSeconds: 3600,
+		UnhealthyThreshold: 1,
+	}
+	d := mustSchedule(t, s, req)
+
+	// Failures during the start period don't count
+	s.mu.Lock()
+	s.probes[d.ID] = &healthProbe{startedAt: time.Now()}
+	s.mu.Unlock()
+	probeOnce(t, s, d.ID)
+	if got, _ := s.Get(d.ID); got.Health != protocol.HealthStarting {
+		t.Errorf("Health during start period = %s, want starting", got.Health)
+	}
+}
+
+func TestHealthResetOnRestart(t *testing.T) {
+	s, rt := newTestScheduler(t, nil)
+	req := testRequest(500)
+	req.RestartPolicy = &protocol.RestartPolicy{Mode: protocol.RestartAlways}
+	req.HealthCheck = &protocol.HealthCheck{Type: protocol.HealthCheckExec, Command: []string{"true"}}
+	d := mustSchedule(t, s, req)
+
+	probeOnce(t, s, d.ID)
+	if got, _ := s.Get(d.ID); got.Health != protocol.HealthHealthy {
+		t.Fatalf("Health = %s, want healthy", got.Health)
+	}
+
+	if err := rt.Exit(d.ContainerID, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	s.Reconcile(context.Background())
+	s.mu.Lock()
+	past := time.Now().Add(-time.Second)
+	s.deployments[d.ID].NextRestartAt = &past
+	s.mu.Unlock()
+	s.Reconcile(context.Background())
+
+	got, _ := s.Get(d.ID)
+	if got.Status != protocol.StatusRunning || got.Health != protocol.HealthStarting {
+		t.Errorf("after restart = %s/%s, want running/starting", got.Status, got.Health)
+	}
+}
@@ -0,0 +1,201 @@
+// Package scheduler - Host resource detection
+package scheduler
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strconv"
+	"strings"
+)
+
+// cgroupRoot is where cgroup filesystems are mounted, and procSelfCgroup
+// lists the daemon's own cgroups. They are variables so tests can point
+// them at a fixture.
+var (
+	cgroupRoot     = "/sys/fs/cgroup"
+	procSelfCgroup = "/proc/self/cgroup"
+)
+
+// HostResources describes the CPU and memory available to the daemon.
+type HostResources struct {
+	// CPUMillicores is the usable CPU in millicores
+	CPUMillicores int64
+	// MemoryBytes is the usable memory in bytes
+	MemoryBytes int64
+}
+
+// DetectHostResources returns the CPU and memory available to this process:
+// the host's CPUs and /proc/meminfo total, capped by the daemon's own cgroup
+// limits (cgroup v2 or v1) when it runs inside a container or a systemd
+// slice with quotas.
+func DetectHostResources() (HostResources, error) {
+	var res HostResources
+
+	// NumCPU honours the process's CPU affinity (cpusets)
+	res.CPUMillicores = int64(goruntime.NumCPU()) * 1000
+	if quota, ok := cgroupCPULimit(); ok && quota < res.CPUMillicores {
+		res.CPUMillicores = quota
+	}
+
+	total, err := memTotal()
+	if err != nil {
+		return res, err
+	}
+	res.MemoryBytes = total
+	if limit, ok := cgroupMemoryLimit(); ok && limit < res.MemoryBytes {
+		res.MemoryBytes = limit
+	}
+
+	return res, nil
+}
+
+// memTotal reads the host's total memory from /proc/meminfo.
+func memTotal() (int64, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read memory size: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("failed to parse memory size: %w", err)
+			}
+			return kb * 1024, nil
+		}
+	}
+	return 0, fmt.Errorf("failed to read memory size: MemTotal not found in /proc/meminfo")
+}
+
+// cgroupCPULimit returns the CPU quota of the daemon's cgroup in
+// millicores, if one is set.
+func cgroupCPULimit() (int64, bool) {
+	// cgroup v2: "max 100000" or "<quota> <period>"
+	if data, ok := readCgroupFile("", "cpu.max"); ok {
+		fields := strings.Fields(data)
+		if len(fields) != 2 || fields[0] == "max" {
+			return 0, false
+		}
+		return cpuQuotaMillicores(fields[0], fields[1])
+	}
+
+	// cgroup v1: quota is -1 when unlimited
+	quota, ok := readCgroupFile("cpu", "cpu.cfs_quota_us")
+	if !ok {
+		return 0, false
+	}
+	period, ok := readCgroupFile("cpu", "cpu.cfs_period_us")
+	if !ok {
+		return 0, false
+	}
+	return cpuQuotaMillicores(quota, period)
+}
+
+// cpuQuotaMillicores converts a CFS quota and period into millicores.
+func cpuQuotaMillicores(quota, period string) (int64, bool) {
+	q, err := strconv.ParseInt(quota, 10, 64)
+	if err != nil || q <= 0 {
+		return 0, false
+	}
+	p, err := strconv.ParseInt(period, 10, 64)
+	if err != nil || p <= 0 {
+		return 0, false
+	}
+	return q * 1000 / p, true
+}
+
+// cgroupMemoryLimit returns the memory limit of the daemon's cgroup in
+// bytes, if one is set.
+func cgroupMemoryLimit() (int64, bool) {
+	// cgroup v2: "max" when unlimited
+	data, ok := readCgroupFile("", "memory.max")
+	if !ok {
+		// cgroup v1: a huge value (close to the maximum int64) when unlimited,
+		// which the /proc/meminfo total then caps
+		if data, ok = readCgroupFile("memory", "memory.limit_in_bytes"); !ok {
+			return 0, false
+		}
+	}
+	if data == "max" {
+		return 0, false
+	}
+	limit, err := strconv.ParseInt(data, 10, 64)
+	if err != nil || limit <= 0 {
+		return 0, false
+	}
+	return limit, true
+}
+
+// readCgroupFile reads a file of the daemon's own cgroup. controller selects
+// a cgroup v1 hierarchy; empty selects the cgroup v2 unified hierarchy.
+func readCgroupFile(controller, name string) (string, bool) {
+	path, ok := cgroupPath(controller)
+	if !ok {
+		return "", false
+	}
+
+	// Inside a container the cgroup namespace may hide the path, leaving the
+	// daemon's own cgroup at the mount root
+	var dirs []string
+	if controller == "" {
+		dirs = []string{
+			filepath.Join(cgroupRoot, path),
+			filepath.Join(cgroupRoot, "unified", path),
+			cgroupRoot,
+		}
+	} else {
+		mounts := []string{controller}
+		if controller == "cpu" {
+			mounts = append(mounts, "cpu,cpuacct")
+		}
+		for _, m := range mounts {
+			dirs = append(dirs, filepath.Join(cgroupRoot, m, path), filepath.Join(cgroupRoot, m))
+		}
+	}
+
+	for _, dir := range dirs {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return strings.TrimSpace(string(data)), true
+		}
+	}
+	return "", false
+}
+
+// cgroupPath returns the daemon's cgroup path for a v1 controller, or for
+// the v2 unified hierarchy when controller is empty.
+func cgroupPath(controller string) (string, bool) {
+	f, err := os.Open(procSelfCgroup)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	// Lines are "<id>:<controllers>:<path>"; v2 has id 0 and no controllers
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if controller == "" {
+			if parts[0] == "0" && parts[1] == "" {
+				return parts[2], true
+			}
+			continue
+		}
+		for _, c := range strings.Split(parts[1], ",") {
+			if c == controller {
+				return parts[2], true
+			}
+		}
+	}
+	return "", false
+}
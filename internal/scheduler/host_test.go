@@ -0,0 +1,194 @@
+package scheduler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeCgroup points cgroup detection at a temporary directory holding
+// selfCgroup as /proc/self/cgroup and files relative to the cgroup root.
+func fakeCgroup(t *testing.T, selfCgroup string, files map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	root := filepath.Join(dir, "cgroup")
+	for name, data := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	self := filepath.Join(dir, "self-cgroup")
+	if err := os.WriteFile(self, []byte(selfCgroup), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	oldRoot, oldSelf := cgroupRoot, procSelfCgroup
+	cgroupRoot, procSelfCgroup = root, self
+	t.Cleanup(func() { cgroupRoot, procSelfCgroup = oldRoot, oldSelf })
+}
+
+func TestCPUQuotaMillicores(t *testing.T) {
+	tests := []struct {
+		quota, period string
+		want          int64
+		ok            bool
+	}{
+		{"200000", "100000", 2000, true},
+		{"50000", "100000", 500, true},
+		{"150000", "100000", 1500, true},
+		// cgroup v1 reports no quota as -1
+		{"-1", "100000", 0, false},
+		{"100000", "0", 0, false},
+		{"max", "100000", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := cpuQuotaMillicores(tt.quota, tt.period)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("cpuQuotaMillicores(%q, %q) = %d, %v, want %d, %v", tt.quota, tt.period, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCgroupV2Limits(t *testing.T) {
+	fakeCgroup(t, "0::/system.slice/peercomputed.service\n", map[string]string{
+		"system.slice/peercomputed.service/cpu.max":    "150000 100000",
+		"system.slice/peercomputed.service/memory.max": "536870912",
+	})
+
+	if cpu, ok := cgroupCPULimit(); !ok || cpu != 1500 {
+		t.Errorf("cgroupCPULimit() = %d, %v, want 1500", cpu, ok)
+	}
+	if mem, ok := cgroupMemoryLimit(); !ok || mem != 512*1024*1024 {
+		t.Errorf("cgroupMemoryLimit() = %d, %v, want 512MB", mem, ok)
+	}
+}
+
+func TestCgroupV2Unlimited(t *testing.T) {
+	// Inside a container the cgroup namespace leaves the daemon's cgroup at
+	// the mount root
+	fakeCgroup(t, "0::/\n", map[string]string{
+		"cpu.max":    "max 100000",
+		"memory.max": "max",
+	})
+
+	if cpu, ok := cgroupCPULimit(); ok {
+		t.Errorf("cgroupCPULimit() = %d, want no limit", cpu)
+	}
+	if mem, ok := cgroupMemoryLimit(); ok {
+		t.Errorf("cgroupMemoryLimit() = %d, want no limit", mem)
+	}
+}
+
+func TestCgroupV1HugeMemoryLimit(t *testing.T) {
+	// cgroup v1 reports an unlimited cgroup as a huge limit
+	fakeCgroup(t, "4:memory:/docker/abc\n", map[string]string{
+		"memory/docker/abc/memory.limit_in_bytes": "9223372036854771712",
+	})
+
+	if mem, ok := cgroupMemoryLimit(); !ok || mem != 9223372036854771712 {
+		t.Errorf("cgroupMemoryLimit() = %d, %v, want the v1 limit", mem, ok)
+	}
+
+	// The host's memory caps it
+	total, err := memTotal()
+	if err != nil {
+		t.Skipf("host memory unavailable: %v", err)
+	}
+	res, err := DetectHostResources()
+	if err != nil {
+		t.Fatalf("DetectHostResources() error = %v", err)
+	}
+	if res.MemoryBytes != total {
+		t.Errorf("MemoryBytes = %d, want the host's %d", res.MemoryBytes, total)
+	}
+}
+
+func TestCgroupV1CPUAcctMount(t *testing.T) {
+	// Most distributions mount the cpu controller together with cpuacct
+	fakeCgroup(t, "5:cpu,cpuacct:/docker/abc\n4:memory:/docker/abc\n", map[string]string{
+		"cpu,cpuacct/docker/abc/cpu.cfs_quota_us":  "50000",
+		"cpu,cpuacct/docker/abc/cpu.cfs_period_us": "100000",
+		"memory/docker/abc/memory.limit_in_bytes":  "268435456",
+	})
+
+	if cpu, ok := cgroupCPULimit(); !ok || cpu != 500 {
+		t.Errorf("cgroupCPULimit() = %d, %v, want 500", cpu, ok)
+	}
+
+	total, err := memTotal()
+	if err != nil {
+		t.Skipf("host memory unavailable: %v", err)
+	}
+	res, err := DetectHostResources()
+	if err != nil {
+		t.Fatalf("DetectHostResources() error = %v", err)
+	}
+	want := HostResources{CPUMillicores: 500, MemoryBytes: min(total, 256*1024*1024)}
+	if res != want {
+		t.Errorf("DetectHostResources() = %+v, want %+v", res, want)
+	}
+}
+
+func TestCgroupV1Unlimited(t *testing.T) {
+	fakeCgroup(t, "5:cpu,cpuacct:/\n4:memory:/\n", map[string]string{
+		"cpu,cpuacct/cpu.cfs_quota_us":  "-1",
+		"cpu,cpuacct/cpu.cfs_period_us": "100000",
+	})
+
+	if cpu, ok := cgroupCPULimit(); ok {
+		t.Errorf("cgroupCPULimit() = %d, want no limit", cpu)
+	}
+	if mem, ok := cgroupMemoryLimit(); ok {
+		t.Errorf("cgroupMemoryLimit() = %d, want no limit", mem)
+	}
+}
+
+func TestOvercommitAdmission(t *testing.T) {
+	s, _ := newTestScheduler(t, &Config{
+		MaxDeployments:   10,
+		MaxCPU:           1000,
+		MaxMemory:        256 * 1024 * 1024,
+		CPUOvercommit:    2,
+		MemoryOvercommit: 1.5,
+	})
+	if _, maxCPU, _, maxMemory, _, _ := s.ResourceUsage(); maxCPU != 2000 || maxMemory != 384*1024*1024 {
+		t.Fatalf("capacity = %d millicores, %d bytes, want 2000 and 384MB", maxCPU, maxMemory)
+	}
+
+	mustSchedule(t, s, testRequest(1000))
+	mustSchedule(t, s, testRequest(1000))
+	if _, err := s.Schedule(context.Background(), testRequest(100)); err == nil {
+		t.Error("Schedule() succeeded beyond the overcommitted CPU capacity")
+	}
+}
+
+func TestOvercommitKeepsHostCap(t *testing.T) {
+	s, _ := newTestScheduler(t, &Config{
+		MaxDeployments: 10,
+		MaxCPU:         1000,
+		MaxMemory:      1 << 30,
+		CPUOvercommit:  4,
+	})
+
+	// Overcommit shares the host between deployments; no single one may
+	// ask for more than the host has
+	if err := s.CanSchedule(1500, testMemory); err == nil {
+		t.Error("CanSchedule() admitted a deployment larger than the host")
+	}
+	if err := s.CanSchedule(1000, testMemory); err != nil {
+		t.Errorf("CanSchedule() error = %v", err)
+	}
+}
+
+func TestOvercommitBelowOneIgnored(t *testing.T) {
+	for _, ratio := range []float64{0, 0.5, 1} {
+		if got := overcommit(1000, ratio); got != 1000 {
+			t.Errorf("overcommit(1000, %v) = %d, want 1000", ratio, got)
+		}
+	}
+}
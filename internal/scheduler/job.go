@@ -0,0 +1,77 @@
+// Package scheduler - Run-to-completion jobs
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+	"github.com/xdas-research/peer-compute/internal/runtime"
+)
+
+// jobDeadlineExceeded reports whether a job has run past its deadline. The
+// deadline counts from when the job was started on this provider and covers
+// all of its retries.
+func jobDeadlineExceeded(d *protocol.Deployment, now time.Time) bool {
+	if d.Job == nil || d.Job.DeadlineSeconds <= 0 || d.Status == protocol.StatusQueued {
+		return false
+	}
+	return now.Sub(d.StartedAt) > time.Duration(d.Job.DeadlineSeconds)*time.Second
+}
+
+// expireJob stops a job that ran past its deadline and marks it failed with
+// ReasonDeadlineExceeded.
+func (s *Scheduler) expireJob(ctx context.Context, d *protocol.Deployment) {
+	log.Printf("[SCHEDULER] Job %s exceeded its deadline of %ds, stopping", d.ID, d.Job.DeadlineSeconds)
+	s.halt(ctx, d.ID, protocol.StatusFailed, protocol.ReasonDeadlineExceeded,
+		fmt.Sprintf("job did not finish within its deadline of %ds", d.Job.DeadlineSeconds))
+}
+
+// halt stops a job's container and finishes its record with the given
+// status and reason. Unlike an expired lease the container is kept, so the
+// job's logs stay available until the record is pruned, and its outputs are
+// collected since partial results are often still useful.
+func (s *Scheduler) halt(ctx context.Context, deploymentID string, status protocol.DeploymentStatus,
+	reason protocol.TerminationReason, message string) {
+	s.mu.Lock()
+	d, ok := s.deployments[deploymentID]
+	if !ok || !holdsResources(d.Status) || d.Status == protocol.StatusStopping {
+		s.mu.Unlock()
+		return
+	}
+	d.Status = protocol.StatusStopping
+	containerID := d.ContainerID
+	s.persistLocked()
+	s.mu.Unlock()
+
+	var exitCode *int
+	if containerID != "" {
+		if err := s.runtime.Halt(ctx, containerID); err != nil && !errors.Is(err, runtime.ErrContainerNotFound) {
+			log.Printf("[SCHEDULER] Failed to stop job %s: %v", deploymentID, err)
+		}
+		if info, err := s.runtime.Inspect(ctx, containerID); err == nil {
+			exitCode = &info.ExitCode
+		}
+	}
+
+	s.collectOutputs(ctx, deploymentID, containerID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if d, ok := s.deployments[deploymentID]; ok && d.Status == protocol.StatusStopping {
+		s.releaseLocked(d)
+		now := time.Now()
+		d.Status = status
+		d.Reason = reason
+		d.ExitCode = exitCode
+		d.Error = message
+		d.StoppedAt = &now
+		d.NextRestartAt = nil
+		s.persistLocked()
+		s.emit(protocol.EventStopped, d, message)
+	}
+}
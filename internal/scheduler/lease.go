@@ -0,0 +1,92 @@
+// Package scheduler - Deployment leases
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+// Renew extends a deployment's lease to lease from now and returns the new
+// expiry. Only deployments that still hold resources can be renewed.
+func (s *Scheduler) Renew(deploymentID string, lease time.Duration) (time.Time, error) {
+	if lease <= 0 {
+		return time.Time{}, fmt.Errorf("lease must be positive")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.deployments[deploymentID]
+	if !ok {
+		return time.Time{}, fmt.Errorf("deployment %s not found", deploymentID)
+	}
+	if !holdsResources(d.Status) || d.Status == protocol.StatusStopping {
+		return time.Time{}, fmt.Errorf("deployment %s is %s", deploymentID, d.Status)
+	}
+
+	expiresAt := time.Now().Add(lease)
+	d.LeaseExpiresAt = &expiresAt
+	s.persistLocked()
+
+	log.Printf("[SCHEDULER] Renewed lease of %s until %s", deploymentID, expiresAt.Format(time.RFC3339))
+	return expiresAt, nil
+}
+
+// leaseExpired reports whether a deployment's lease has run out.
+func leaseExpired(d *protocol.Deployment, now time.Time) bool {
+	return d.LeaseExpiresAt != nil && now.After(*d.LeaseExpiresAt)
+}
+
+// expireLease stops a deployment whose lease ran out, removes its container
+// and keeps the record as history with ReasonLeaseExpired.
+func (s *Scheduler) expireLease(ctx context.Context, deploymentID string) {
+	s.mu.Lock()
+	d, ok := s.deployments[deploymentID]
+	if !ok || !holdsResources(d.Status) || d.Status == protocol.StatusStopping || !leaseExpired(d, time.Now()) {
+		s.mu.Unlock()
+		return
+	}
+	previous := d.Status
+	d.Status = protocol.StatusStopping
+	containerID := d.ContainerID
+	s.persistLocked()
+	s.mu.Unlock()
+
+	log.Printf("[SCHEDULER] Lease of deployment %s expired, stopping", deploymentID)
+
+	if containerID != "" {
+		if err := s.runtime.Stop(ctx, containerID); err != nil {
+			// The container may still be running, so the deployment keeps
+			// its reservation and container and goes back to its status;
+			// the next reconcile tries again
+			log.Printf("[SCHEDULER] Failed to stop expired deployment %s: %v", deploymentID, err)
+			s.mu.Lock()
+			if d, ok := s.deployments[deploymentID]; ok && d.Status == protocol.StatusStopping {
+				d.Status = previous
+				s.persistLocked()
+			}
+			s.mu.Unlock()
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if d, ok := s.deployments[deploymentID]; ok && d.Status == protocol.StatusStopping {
+		s.releaseLocked(d)
+		now := time.Now()
+		d.Status = protocol.StatusTerminated
+		d.Reason = protocol.ReasonLeaseExpired
+		d.Error = "lease expired without renewal"
+		d.StoppedAt = &now
+		d.NextRestartAt = nil
+		d.ContainerID = ""
+		s.persistLocked()
+		s.emit(protocol.EventStopped, d, d.Error)
+	}
+}
@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+// expireLeaseOf moves a deployment's lease expiry into the past.
+func expireLeaseOf(s *Scheduler, deploymentID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expired := time.Now().Add(-time.Second)
+	s.deployments[deploymentID].LeaseExpiresAt = &expired
+}
+
+func TestLeaseExpiryStopsDeployment(t *testing.T) {
+	s, _ := newTestScheduler(t, nil)
+	req := testRequest(500)
+	req.LeaseSeconds = 3600
+	d := mustSchedule(t, s, req)
+
+	expireLeaseOf(s, d.ID)
+	s.Reconcile(context.Background())
+
+	got, ok := s.Get(d.ID)
+	if !ok {
+		t.Fatal("deployment record was removed")
+	}
+	if got.Status != protocol.StatusTerminated || got.Reason != protocol.ReasonLeaseExpired {
+		t.Errorf("deployment = %s (%s), want terminated (%s)", got.Status, got.Reason, protocol.ReasonLeaseExpired)
+	}
+	if used, _, _, _, _, _ := s.ResourceUsage(); used != 0 {
+		t.Errorf("used CPU = %d, want 0", used)
+	}
+}
+
+func TestLeaseExpiryStopFailureKeepsDeployment(t *testing.T) {
+	s, rt := newTestScheduler(t, nil)
+	req := testRequest(500)
+	req.LeaseSeconds = 3600
+	d := mustSchedule(t, s, req)
+	expireLeaseOf(s, d.ID)
+
+	rt.StopHook = func(string) error { return errors.New("daemon unavailable") }
+	s.Reconcile(context.Background())
+
+	got, ok := s.Get(d.ID)
+	if !ok || got.Status != protocol.StatusRunning {
+		t.Fatalf("deployment after failed stop = %+v, want running", got)
+	}
+	if got.ContainerID != d.ContainerID {
+		t.Errorf("ContainerID = %q, want %q", got.ContainerID, d.ContainerID)
+	}
+	if used, _, _, _, _, _ := s.ResourceUsage(); used != 500 {
+		t.Errorf("used CPU = %d, want 500", used)
+	}
+
+	// The next reconcile stops it
+	rt.StopHook = nil
+	s.Reconcile(context.Background())
+	if got, _ := s.Get(d.ID); got.Status != protocol.StatusTerminated {
+		t.Errorf("deployment = %s after the next reconcile, want terminated", got.Status)
+	}
+	if used, _, _, _, _, _ := s.ResourceUsage(); used != 0 {
+		t.Errorf("used CPU = %d, want 0", used)
+	}
+}
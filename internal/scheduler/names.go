@@ -0,0 +1,104 @@
+// Package scheduler - Deployment names
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+// checkNameLocked checks that a new deployment's name is free among its
+// requester's active deployments (caller must hold lock). Finished
+// deployments keep their name as history, but it may be reused. Replicas of
+// the same replica set share their name.
+func (s *Scheduler) checkNameLocked(d *protocol.Deployment) error {
+	if d.Name == "" {
+		return nil
+	}
+	if err := protocol.ValidateDeploymentName(d.Name); err != nil {
+		return err
+	}
+	for _, other := range s.deployments {
+		if other.RequesterID != d.RequesterID || other.Name != d.Name || !isActive(other.Status) {
+			continue
+		}
+		if d.ReplicaSet != "" && other.ReplicaSet == d.ReplicaSet {
+			continue
+		}
+		return fmt.Errorf("deployment name %q is already used by %s", d.Name, other.ID)
+	}
+	return nil
+}
+
+// Resolve returns one of owner's deployments by ID or name. A name refers to
+// the active deployment with that name, or else the most recently started
+// finished one.
+func (s *Scheduler) Resolve(owner, ref string) (*protocol.Deployment, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if d, ok := s.deployments[ref]; ok {
+		if d.RequesterID != owner {
+			return nil, false
+		}
+		copy := *d
+		return &copy, true
+	}
+
+	var found *protocol.Deployment
+	for _, d := range s.deployments {
+		if d.RequesterID != owner || d.Name != ref {
+			continue
+		}
+		if isActive(d.Status) {
+			found = d
+			break
+		}
+		if found == nil || d.StartedAt.After(found.StartedAt) {
+			found = d
+		}
+	}
+	if found == nil {
+		return nil, false
+	}
+	copy := *found
+	return &copy, true
+}
+
+// ResolveAll returns every deployment of owner that ref refers to. An ID
+// refers to that deployment alone; a name or replica set ID refers to all
+// active replicas with it, or else to the same finished deployment Resolve
+// would return.
+func (s *Scheduler) ResolveAll(owner, ref string) []*protocol.Deployment {
+	s.mu.RLock()
+	var replicas []*protocol.Deployment
+	if _, ok := s.deployments[ref]; !ok {
+		for _, d := range s.deployments {
+			if d.RequesterID != owner || !isActive(d.Status) {
+				continue
+			}
+			if d.Name == ref || (d.ReplicaSet != "" && d.ReplicaSet == ref) {
+				copy := *d
+				replicas = append(replicas, &copy)
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	if len(replicas) > 0 {
+		sort.Slice(replicas, func(i, j int) bool {
+			return replicas[i].StartedAt.Before(replicas[j].StartedAt)
+		})
+		return replicas
+	}
+	if d, ok := s.Resolve(owner, ref); ok {
+		return []*protocol.Deployment{d}
+	}
+	return nil
+}
+
+// isActive reports whether a deployment is queued or holds resources.
+func isActive(status protocol.DeploymentStatus) bool {
+	return holdsResources(status) || status == protocol.StatusQueued
+}
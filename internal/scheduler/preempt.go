@@ -0,0 +1,124 @@
+// Package scheduler - Preemption of lower priority classes
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+// PreemptHook is called after a deployment was preempted.
+type PreemptHook func(d *protocol.Deployment)
+
+// SetPreemptHook sets the function called when a deployment is preempted.
+func (s *Scheduler) SetPreemptHook(hook PreemptHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.preemptHook = hook
+}
+
+// preemptionVictimsLocked picks the deployments to preempt so that d fits,
+// or returns nil if preempting every lower-class deployment would not be
+// enough (caller must hold lock). Victims are taken from the lowest class
+// first and, within a class, newest first so the least work is lost.
+func (s *Scheduler) preemptionVictimsLocked(d *protocol.Deployment) []*protocol.Deployment {
+	// A request larger than the host never fits, whatever is preempted
+	if d.CPULimit > s.hostCPU || d.MemoryLimit > s.hostMemory {
+		return nil
+	}
+
+	rank := d.PriorityClass.Rank()
+
+	var candidates []*protocol.Deployment
+	for _, c := range s.deployments {
+		if holdsResources(c.Status) && c.Status != protocol.StatusStopping && c.PriorityClass.Rank() < rank {
+			candidates = append(candidates, c)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		ri, rj := candidates[i].PriorityClass.Rank(), candidates[j].PriorityClass.Rank()
+		if ri != rj {
+			return ri < rj
+		}
+		return candidates[i].StartedAt.After(candidates[j].StartedAt)
+	})
+
+	freeSlots := s.maxSlots - s.activeCountLocked()
+	freeCPU := s.maxCPU - s.usedCPU
+	freeMemory := s.maxMemory - s.usedMemory
+	fits := func() bool {
+		return freeSlots >= 1 && freeCPU >= d.CPULimit && freeMemory >= d.MemoryLimit
+	}
+
+	var victims []*protocol.Deployment
+	for _, c := range candidates {
+		if fits() {
+			break
+		}
+		victims = append(victims, c)
+		freeSlots++
+		freeCPU += c.CPULimit
+		freeMemory += c.MemoryLimit
+	}
+	if len(victims) == 0 || !fits() {
+		return nil
+	}
+	return victims
+}
+
+// evictLocked marks a victim as preempted and releases its resources. The
+// container is stopped by the caller after the lock is released
+// (caller must hold lock).
+func (s *Scheduler) evictLocked(v *protocol.Deployment, by *protocol.Deployment) {
+	s.releaseLocked(v)
+
+	now := time.Now()
+	v.Status = protocol.StatusTerminated
+	v.Reason = protocol.ReasonPreempted
+	v.Error = fmt.Sprintf("preempted by %s deployment %s", className(by.PriorityClass), by.ID)
+	v.StoppedAt = &now
+	v.NextRestartAt = nil
+	s.emit(protocol.EventPreempted, v, v.Error)
+
+	log.Printf("[SCHEDULER] Preempting %s deployment %s for %s", className(v.PriorityClass), v.ID, by.ID)
+}
+
+// stopPreempted removes the containers of preempted deployments and notifies
+// the preempt hook.
+func (s *Scheduler) stopPreempted(ctx context.Context, victims []protocol.Deployment) {
+	s.mu.RLock()
+	hook := s.preemptHook
+	s.mu.RUnlock()
+
+	for i := range victims {
+		v := &victims[i]
+		if v.ContainerID != "" {
+			if err := s.runtime.Stop(ctx, v.ContainerID); err != nil {
+				log.Printf("[SCHEDULER] Failed to stop preempted deployment %s: %v", v.ID, err)
+			}
+		}
+
+		s.mu.Lock()
+		if cur, ok := s.deployments[v.ID]; ok && cur.Reason == protocol.ReasonPreempted {
+			cur.ContainerID = ""
+			s.persistLocked()
+		}
+		s.mu.Unlock()
+
+		if hook != nil {
+			hook(v)
+		}
+	}
+}
+
+// className returns a class name for messages.
+func className(c protocol.PriorityClass) string {
+	if c == "" {
+		return string(protocol.ClassNormal)
+	}
+	return string(c)
+}
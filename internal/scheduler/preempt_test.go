@@ -0,0 +1,73 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+func TestPreemptLowerClass(t *testing.T) {
+	s, rt := newTestScheduler(t, &Config{MaxDeployments: 10, MaxCPU: 1000, MaxMemory: 1 << 30})
+
+	var notified []string
+	s.SetPreemptHook(func(d *protocol.Deployment) {
+		notified = append(notified, d.ID)
+	})
+
+	victim := testRequest(800)
+	victim.PriorityClass = protocol.ClassBestEffort
+	v := mustSchedule(t, s, victim)
+
+	critical := testRequest(800)
+	critical.PriorityClass = protocol.ClassCritical
+	d := mustSchedule(t, s, critical)
+	if d.Status != protocol.StatusRunning {
+		t.Fatalf("Status = %s, want running", d.Status)
+	}
+
+	got, ok := s.Get(v.ID)
+	if !ok {
+		t.Fatal("preempted deployment was removed")
+	}
+	if got.Status != protocol.StatusTerminated || got.Reason != protocol.ReasonPreempted {
+		t.Errorf("victim status = %s (%s), want terminated (%s)", got.Status, got.Reason, protocol.ReasonPreempted)
+	}
+	if _, err := rt.Inspect(context.Background(), v.ContainerID); err == nil {
+		t.Error("victim's container still exists")
+	}
+	if len(notified) != 1 || notified[0] != v.ID {
+		t.Errorf("preempt hook called for %v, want [%s]", notified, v.ID)
+	}
+}
+
+func TestPreemptOnlyLowerClasses(t *testing.T) {
+	s, _ := newTestScheduler(t, &Config{MaxDeployments: 10, MaxCPU: 1000, MaxMemory: 1 << 30})
+
+	v := mustSchedule(t, s, testRequest(800))
+	if _, err := s.Schedule(context.Background(), testRequest(800)); err == nil {
+		t.Fatal("Schedule() preempted a deployment of the same class")
+	}
+	if got, _ := s.Get(v.ID); got.Status != protocol.StatusRunning {
+		t.Errorf("Status = %s, want running", got.Status)
+	}
+}
+
+func TestPreemptNotBeyondHostCapacity(t *testing.T) {
+	// With overcommit, 1500 millicores fit the admission budget but not
+	// the host, so preempting would not make the request fit
+	s, _ := newTestScheduler(t, &Config{MaxDeployments: 10, MaxCPU: 1000, MaxMemory: 1 << 30, CPUOvercommit: 2})
+
+	victim := testRequest(1000)
+	victim.PriorityClass = protocol.ClassBestEffort
+	v := mustSchedule(t, s, victim)
+
+	critical := testRequest(1500)
+	critical.PriorityClass = protocol.ClassCritical
+	if _, err := s.Schedule(context.Background(), critical); err == nil {
+		t.Fatal("Schedule() accepted a request larger than the host")
+	}
+	if got, _ := s.Get(v.ID); got.Status != protocol.StatusRunning {
+		t.Errorf("victim status = %s, want running", got.Status)
+	}
+}
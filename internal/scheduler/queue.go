@@ -0,0 +1,164 @@
+// Package scheduler - Admission queue for requests that don't fit yet
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+// QueueCheckInterval is how often the admission queue checks for timed-out
+// requests when no resources are being released.
+const QueueCheckInterval = 5 * time.Second
+
+// StartHook is called when a queued deployment has been started.
+type StartHook func(d *protocol.Deployment)
+
+// SetStartHook sets the function called when a queued deployment starts.
+func (s *Scheduler) SetStartHook(hook StartHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.startHook = hook
+}
+
+// enqueueLocked adds a deployment that doesn't fit to the admission queue
+// (caller must hold lock). It fails if queueing is disabled, the request
+// didn't ask to wait, the queue is full, or the request can never fit.
+func (s *Scheduler) enqueueLocked(d *protocol.Deployment, timeoutSeconds int64) error {
+	if s.maxQueue <= 0 || timeoutSeconds <= 0 {
+		return fmt.Errorf("admission queue not used")
+	}
+	if d.CPULimit > s.hostCPU || d.MemoryLimit > s.hostMemory {
+		return fmt.Errorf("request exceeds the provider's total capacity")
+	}
+	if len(s.queuedLocked()) >= s.maxQueue {
+		return fmt.Errorf("admission queue is full (%d requests)", s.maxQueue)
+	}
+
+	deadline := time.Now().Add(time.Duration(timeoutSeconds) * time.Second)
+	d.Status = protocol.StatusQueued
+	d.QueueDeadline = &deadline
+	s.deployments[d.ID] = d
+	s.renumberQueueLocked()
+	s.persistLocked()
+
+	log.Printf("[SCHEDULER] Queued deployment %s at position %d (priority %d)", d.ID, d.QueuePosition, d.Priority)
+	return nil
+}
+
+// queuedLocked returns queued deployments in admission order: highest
+// priority class first, then highest priority, then oldest first
+// (caller must hold lock).
+func (s *Scheduler) queuedLocked() []*protocol.Deployment {
+	var queued []*protocol.Deployment
+	for _, d := range s.deployments {
+		if d.Status == protocol.StatusQueued {
+			queued = append(queued, d)
+		}
+	}
+	sort.Slice(queued, func(i, j int) bool {
+		ri, rj := queued[i].PriorityClass.Rank(), queued[j].PriorityClass.Rank()
+		if ri != rj {
+			return ri > rj
+		}
+		if queued[i].Priority != queued[j].Priority {
+			return queued[i].Priority > queued[j].Priority
+		}
+		return queued[i].StartedAt.Before(queued[j].StartedAt)
+	})
+	return queued
+}
+
+// renumberQueueLocked refreshes the queue positions (caller must hold lock).
+func (s *Scheduler) renumberQueueLocked() {
+	for i, d := range s.queuedLocked() {
+		d.QueuePosition = i + 1
+	}
+}
+
+// wakeQueue asks the admission queue to look for requests that now fit.
+// It never blocks, so it is safe to call with the lock held.
+func (s *Scheduler) wakeQueue() {
+	select {
+	case s.queueWake <- struct{}{}:
+	default:
+	}
+}
+
+// RunQueue admits queued deployments as resources free up and times out
+// those that waited too long, until the context is cancelled.
+func (s *Scheduler) RunQueue(ctx context.Context) {
+	ticker := time.NewTicker(QueueCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.queueWake:
+		}
+		s.admitQueued(ctx)
+	}
+}
+
+// admitQueued starts queued deployments in order while they fit. Admission
+// stops at the first request that doesn't fit, so large high-priority
+// requests are not starved by smaller ones behind them.
+func (s *Scheduler) admitQueued(ctx context.Context) {
+	now := time.Now()
+	var admitted []string
+
+	s.mu.Lock()
+	// Nothing is admitted while the provider is cordoned
+	changed, blocked := false, s.drain.Cordoned
+	for _, d := range s.queuedLocked() {
+		if d.QueueDeadline != nil && now.After(*d.QueueDeadline) {
+			d.Status = protocol.StatusTerminated
+			d.Reason = protocol.ReasonQueueTimeout
+			d.Error = "not admitted before the queue timeout"
+			d.StoppedAt = &now
+			d.QueuePosition = 0
+			changed = true
+			s.emit(protocol.EventStopped, d, d.Error)
+			log.Printf("[SCHEDULER] Queued deployment %s timed out", d.ID)
+			continue
+		}
+		// Once blocked, only time out the requests behind
+		if blocked || s.fitsLocked(d.CPULimit, d.MemoryLimit) != nil {
+			blocked = true
+			continue
+		}
+
+		d.Status = protocol.StatusPending
+		d.StartedAt = now
+		d.QueueDeadline = nil
+		d.QueuePosition = 0
+		s.reserveLocked(d)
+		admitted = append(admitted, d.ID)
+		changed = true
+	}
+	if changed {
+		s.renumberQueueLocked()
+		s.persistLocked()
+	}
+	hook := s.startHook
+	s.mu.Unlock()
+
+	for _, id := range admitted {
+		log.Printf("[SCHEDULER] Admitting queued deployment %s", id)
+		go func(id string) {
+			if err := s.launch(ctx, id); err != nil {
+				log.Printf("[SCHEDULER] Failed to start queued deployment %s: %v", id, err)
+				return
+			}
+			if d, ok := s.Get(id); ok && hook != nil {
+				hook(d)
+			}
+		}(id)
+	}
+}
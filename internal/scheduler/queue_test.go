@@ -0,0 +1,147 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+// queueRequest returns a request that waits in the admission queue.
+func queueRequest(cpu int64, class protocol.PriorityClass, priority int) *protocol.DeployRequest {
+	req := testRequest(cpu)
+	req.PriorityClass = class
+	req.Priority = priority
+	req.QueueTimeoutSeconds = 3600
+	return req
+}
+
+// waitForStatus waits until a deployment reaches a status.
+func waitForStatus(t *testing.T, s *Scheduler, id string, status protocol.DeploymentStatus) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if d, ok := s.Get(id); ok && d.Status == status {
+			return
+		}
+		if time.Now().After(deadline) {
+			d, _ := s.Get(id)
+			t.Fatalf("deployment %s is %s, want %s", id, d.Status, status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestQueueAdmissionOrder(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxCPU = 1000
+	cfg.MaxQueue = 8
+	s, _ := newTestScheduler(t, cfg)
+	ctx := context.Background()
+
+	fillers := []*protocol.Deployment{
+		mustSchedule(t, s, testRequest(500)),
+		mustSchedule(t, s, testRequest(500)),
+	}
+
+	// Queued in this order, admitted class first, then priority, then age
+	bestEffort := mustSchedule(t, s, queueRequest(500, protocol.ClassBestEffort, 10))
+	normalLow := mustSchedule(t, s, queueRequest(500, protocol.ClassNormal, 0))
+	normalHigh := mustSchedule(t, s, queueRequest(500, protocol.ClassNormal, 5))
+	normalHighLater := mustSchedule(t, s, queueRequest(500, protocol.ClassNormal, 5))
+
+	want := []*protocol.Deployment{normalHigh, normalHighLater, normalLow, bestEffort}
+	for i, d := range want {
+		got, _ := s.Get(d.ID)
+		if got.Status != protocol.StatusQueued || got.QueuePosition != i+1 {
+			t.Errorf("%s is %s at position %d, want queued at %d", d.ID, got.Status, got.QueuePosition, i+1)
+		}
+	}
+
+	// Each freed filler admits the head of the queue
+	for i, filler := range fillers {
+		if err := s.Stop(ctx, filler.ID); err != nil {
+			t.Fatal(err)
+		}
+		s.admitQueued(ctx)
+		waitForStatus(t, s, want[i].ID, protocol.StatusRunning)
+
+		for j, d := range want[i+1:] {
+			got, _ := s.Get(d.ID)
+			if got.Status != protocol.StatusQueued || got.QueuePosition != j+1 {
+				t.Errorf("%s is %s at position %d, want queued at %d", d.ID, got.Status, got.QueuePosition, j+1)
+			}
+		}
+	}
+}
+
+func TestQueueHeadBlocksSmallerRequests(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxCPU = 1000
+	cfg.MaxQueue = 8
+	s, _ := newTestScheduler(t, cfg)
+	ctx := context.Background()
+
+	filler := mustSchedule(t, s, testRequest(500))
+	mustSchedule(t, s, testRequest(500))
+	large := mustSchedule(t, s, queueRequest(1000, protocol.ClassNormal, 9))
+	small := mustSchedule(t, s, queueRequest(250, protocol.ClassNormal, 0))
+
+	if err := s.Stop(ctx, filler.ID); err != nil {
+		t.Fatal(err)
+	}
+	s.admitQueued(ctx)
+
+	// The small request would fit, but may not overtake the large one
+	for _, d := range []*protocol.Deployment{large, small} {
+		if got, _ := s.Get(d.ID); got.Status != protocol.StatusQueued {
+			t.Errorf("%s is %s, want still queued", d.ID, got.Status)
+		}
+	}
+	if used, _, _, _, _, _ := s.ResourceUsage(); used != 500 {
+		t.Errorf("used CPU = %d, want 500", used)
+	}
+}
+
+func TestQueueTimeout(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxCPU = 500
+	cfg.MaxQueue = 8
+	s, _ := newTestScheduler(t, cfg)
+
+	mustSchedule(t, s, testRequest(500))
+	d := mustSchedule(t, s, queueRequest(500, protocol.ClassNormal, 0))
+
+	s.mu.Lock()
+	past := time.Now().Add(-time.Second)
+	s.deployments[d.ID].QueueDeadline = &past
+	s.mu.Unlock()
+	s.admitQueued(context.Background())
+
+	got, _ := s.Get(d.ID)
+	if got.Status != protocol.StatusTerminated || got.Reason != protocol.ReasonQueueTimeout {
+		t.Errorf("deployment = %s (%s), want terminated by the queue timeout", got.Status, got.Reason)
+	}
+}
+
+func TestQueueRefusals(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxCPU = 1000
+	cfg.MaxQueue = 1
+	s, _ := newTestScheduler(t, cfg)
+	ctx := context.Background()
+
+	mustSchedule(t, s, testRequest(1000))
+
+	if _, err := s.Schedule(ctx, testRequest(500)); err == nil {
+		t.Error("request without a queue timeout was queued")
+	}
+	if _, err := s.Schedule(ctx, queueRequest(2000, protocol.ClassNormal, 0)); err == nil {
+		t.Error("request larger than the host was queued")
+	}
+	mustSchedule(t, s, queueRequest(500, protocol.ClassNormal, 0))
+	if _, err := s.Schedule(ctx, queueRequest(500, protocol.ClassNormal, 0)); err == nil {
+		t.Error("request queued beyond MaxQueue")
+	}
+}
@@ -0,0 +1,197 @@
+// Package scheduler - Container lifecycle reconciliation
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+	"github.com/xdas-research/peer-compute/internal/runtime"
+)
+
+const (
+	// DefaultReconcileInterval is how often container state is checked
+	DefaultReconcileInterval = 10 * time.Second
+
+	// FinishedRetention is how long finished deployment records (and their
+	// exited containers, for logs) are kept before being pruned
+	FinishedRetention = 24 * time.Hour
+
+	// EventRetryInterval is how long to wait before watching container
+	// events again after the stream fails
+	EventRetryInterval = 5 * time.Second
+)
+
+// RunReconciler periodically reconciles deployment records with container
+// state until the context is cancelled.
+func (s *Scheduler) RunReconciler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultReconcileInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Reconcile(ctx)
+		}
+	}
+}
+
+// WatchEvents reconciles a deployment as soon as the runtime reports that
+// its container stopped or was removed, rather than at the next reconcile
+// tick. The stream is reopened after errors until the context is cancelled.
+func (s *Scheduler) WatchEvents(ctx context.Context) {
+	for {
+		events, errs := s.runtime.Events(ctx)
+		for ev := range events {
+			switch ev.Action {
+			case "die", "destroy":
+				s.reconcileContainer(ctx, ev.DeploymentID, ev.ContainerID)
+			}
+		}
+		if err := <-errs; err != nil {
+			log.Printf("[SCHEDULER] Container events unavailable, retrying: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(EventRetryInterval):
+		}
+	}
+}
+
+// reconcileContainer reconciles the running deployment a container event
+// is about. Events for containers the deployment no longer uses, such as
+// the old container of an update, are ignored.
+func (s *Scheduler) reconcileContainer(ctx context.Context, deploymentID, containerID string) {
+	d, ok := s.Get(deploymentID)
+	if !ok || d.Status != protocol.StatusRunning || d.ContainerID != containerID {
+		return
+	}
+	s.reconcileRunning(ctx, d)
+}
+
+// Reconcile inspects every running deployment's container and records exits,
+// OOM kills and external removals, releasing their resources so status,
+// quotas and capacity stay truthful. It also stops deployments whose lease
+// expired or job deadline passed, prunes finished records older than
+// FinishedRetention and removes job artifacts no record refers to.
+func (s *Scheduler) Reconcile(ctx context.Context) {
+	now := time.Now()
+	for _, d := range s.List() {
+		switch {
+		case holdsResources(d.Status) && leaseExpired(d, now):
+			s.expireLease(ctx, d.ID)
+		case holdsResources(d.Status) && jobDeadlineExceeded(d, now):
+			s.expireJob(ctx, d)
+		case d.Status == protocol.StatusRunning && d.ContainerID != "":
+			s.reconcileRunning(ctx, d)
+		case d.Status == protocol.StatusBackoff:
+			s.restartDue(ctx, d)
+		case !holdsResources(d.Status) && d.StoppedAt != nil && now.Sub(*d.StoppedAt) > FinishedRetention && !s.keptByCron(d):
+			if err := s.Stop(ctx, d.ID); err != nil {
+				log.Printf("[SCHEDULER] Failed to prune deployment %s: %v", d.ID, err)
+			}
+		}
+	}
+	s.collectArtifacts()
+}
+
+// reconcileRunning checks a single running deployment's container.
+func (s *Scheduler) reconcileRunning(ctx context.Context, d *protocol.Deployment) {
+	s.reconcileMu.Lock()
+	defer s.reconcileMu.Unlock()
+
+	info, err := s.runtime.Inspect(ctx, d.ContainerID)
+	if errors.Is(err, runtime.ErrContainerNotFound) {
+		s.markFinished(d.ID, d.ContainerID, protocol.StatusTerminated, protocol.ReasonContainerRemoved, nil, "container was removed outside Peer Compute", time.Now())
+		return
+	}
+	if err != nil {
+		log.Printf("[SCHEDULER] Failed to inspect %s: %v", d.ID, err)
+		return
+	}
+
+	switch info.Status {
+	case "running", "created", "restarting", "paused":
+		return
+	}
+
+	exitCode := info.ExitCode
+	finishedAt := info.FinishedAt
+	if finishedAt.IsZero() {
+		finishedAt = time.Now()
+	}
+
+	status, reason, message := protocol.StatusTerminated, protocol.ReasonExited, ""
+	switch {
+	case info.OOMKilled:
+		status, reason = protocol.StatusFailed, protocol.ReasonOOMKilled
+		message = fmt.Sprintf("container exceeded its memory limit of %d bytes", d.MemoryLimit)
+	case exitCode != 0:
+		status, reason = protocol.StatusFailed, protocol.ReasonError
+		message = firstNonEmpty(info.Error, fmt.Sprintf("container exited with code %d", exitCode))
+	case d.Job != nil:
+		status = protocol.StatusSucceeded
+	}
+
+	if s.enterBackoff(d.ID, d.ContainerID, reason, exitCode, finishedAt.Sub(info.StartedAt)) {
+		return
+	}
+	s.collectOutputs(ctx, d.ID, d.ContainerID)
+	s.markFinished(d.ID, d.ContainerID, status, reason, &exitCode, message, finishedAt)
+}
+
+// markFinished records that a deployment's container stopped running and
+// releases its resources. containerID guards against the deployment having
+// been stopped or restarted since it was inspected.
+func (s *Scheduler) markFinished(deploymentID, containerID string, status protocol.DeploymentStatus,
+	reason protocol.TerminationReason, exitCode *int, message string, finishedAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.deployments[deploymentID]
+	if !ok || d.ContainerID != containerID ||
+		(d.Status != protocol.StatusRunning && d.Status != protocol.StatusBackoff) {
+		return
+	}
+
+	s.releaseLocked(d)
+	d.Status = status
+	d.Reason = reason
+	d.ExitCode = exitCode
+	d.Error = message
+	d.StoppedAt = &finishedAt
+	d.NextRestartAt = nil
+	s.persistLocked()
+	s.emit(exitEvent(reason), d, message)
+
+	log.Printf("[SCHEDULER] Deployment %s finished: %s (%s)", deploymentID, status, reason)
+}
+
+// exitEvent returns the event type for a container that stopped running
+// with the given reason.
+func exitEvent(reason protocol.TerminationReason) protocol.EventType {
+	if reason == protocol.ReasonOOMKilled {
+		return protocol.EventOOMKilled
+	}
+	return protocol.EventExited
+}
+
+// firstNonEmpty returns the first non-empty string.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,185 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+	"github.com/xdas-research/peer-compute/internal/runtime"
+)
+
+func TestReconcileRecordsExit(t *testing.T) {
+	tests := []struct {
+		name     string
+		exitCode int
+		oom      bool
+		status   protocol.DeploymentStatus
+		reason   protocol.TerminationReason
+	}{
+		{"clean exit", 0, false, protocol.StatusTerminated, protocol.ReasonExited},
+		{"error", 1, false, protocol.StatusFailed, protocol.ReasonError},
+		{"OOM kill", 137, true, protocol.StatusFailed, protocol.ReasonOOMKilled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, rt := newTestScheduler(t, nil)
+			d := mustSchedule(t, s, testRequest(500))
+
+			if err := rt.Exit(d.ContainerID, tt.exitCode, tt.oom); err != nil {
+				t.Fatal(err)
+			}
+			s.Reconcile(context.Background())
+
+			got, ok := s.Get(d.ID)
+			if !ok {
+				t.Fatal("deployment record was removed")
+			}
+			if got.Status != tt.status || got.Reason != tt.reason {
+				t.Errorf("deployment = %s (%s), want %s (%s)", got.Status, got.Reason, tt.status, tt.reason)
+			}
+			if got.ExitCode == nil || *got.ExitCode != tt.exitCode {
+				t.Errorf("ExitCode = %v, want %d", got.ExitCode, tt.exitCode)
+			}
+			if used, _, _, _, _, _ := s.ResourceUsage(); used != 0 {
+				t.Errorf("used CPU = %d, want 0", used)
+			}
+		})
+	}
+}
+
+func TestReconcileRestartsOnFailure(t *testing.T) {
+	s, rt := newTestScheduler(t, nil)
+	req := testRequest(500)
+	req.RestartPolicy = &protocol.RestartPolicy{Mode: protocol.RestartOnFailure, MaxRetries: 1}
+	d := mustSchedule(t, s, req)
+
+	if err := rt.Exit(d.ContainerID, 1, false); err != nil {
+		t.Fatal(err)
+	}
+	s.Reconcile(context.Background())
+
+	got, _ := s.Get(d.ID)
+	if got.Status != protocol.StatusBackoff || got.NextRestartAt == nil {
+		t.Fatalf("deployment = %s, want backoff with a restart time", got.Status)
+	}
+	// A deployment waiting to restart keeps its reservation
+	if used, _, _, _, _, _ := s.ResourceUsage(); used != 500 {
+		t.Errorf("used CPU = %d, want 500", used)
+	}
+
+	// Once the delay has passed the same container is started again
+	s.mu.Lock()
+	past := time.Now().Add(-time.Second)
+	s.deployments[d.ID].NextRestartAt = &past
+	s.mu.Unlock()
+	s.Reconcile(context.Background())
+
+	got, _ = s.Get(d.ID)
+	if got.Status != protocol.StatusRunning || got.RestartCount != 1 || got.ContainerID != d.ContainerID {
+		t.Fatalf("deployment = %s after %d restarts, want running after 1", got.Status, got.RestartCount)
+	}
+
+	// MaxRetries is used up, so the next failure is final
+	if err := rt.Exit(d.ContainerID, 1, false); err != nil {
+		t.Fatal(err)
+	}
+	s.Reconcile(context.Background())
+
+	got, _ = s.Get(d.ID)
+	if got.Status != protocol.StatusFailed {
+		t.Errorf("deployment = %s, want failed once retries are used up", got.Status)
+	}
+	if used, _, _, _, _, _ := s.ResourceUsage(); used != 0 {
+		t.Errorf("used CPU = %d, want 0", used)
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	tests := []struct {
+		crashes int
+		want    time.Duration
+	}{
+		{1, RestartBackoffBase},
+		{2, 2 * RestartBackoffBase},
+		{3, 4 * RestartBackoffBase},
+		{100, RestartBackoffMax},
+	}
+	for _, tt := range tests {
+		if got := backoffDelay(tt.crashes); got != tt.want {
+			t.Errorf("backoffDelay(%d) = %s, want %s", tt.crashes, got, tt.want)
+		}
+	}
+}
+
+func TestWatchEventsReconcilesExit(t *testing.T) {
+	s, rt := newTestScheduler(t, nil)
+	d := mustSchedule(t, s, testRequest(500))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.WatchEvents(ctx)
+
+	// The watch may not be subscribed yet, so the container is restarted
+	// and exits again until the scheduler notices
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rt.Start(context.Background(), d.ContainerID)
+		rt.Exit(d.ContainerID, 2, false)
+		if got, _ := s.Get(d.ID); got.Status == protocol.StatusFailed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("exit was not reconciled from the event stream")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRecoverAdoptsRecordedContainers(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.StateDir = t.TempDir()
+	rt := runtime.NewFake()
+	s := NewScheduler(rt, cfg)
+	ctx := context.Background()
+	kept := mustSchedule(t, s, testRequest(500))
+	exited := mustSchedule(t, s, testRequest(250))
+
+	// A container no record accounts for, and one that exited while the
+	// daemon was down
+	stray, err := rt.Run(ctx, runtime.ContainerConfig{
+		DeploymentID:  "dep-00000000000000ff",
+		RequesterID:   testRequester,
+		Image:         "nginx:1.27",
+		CPUMillicores: 1000,
+		MemoryBytes:   testMemory,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Exit(exited.ContainerID, 1, false); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := NewScheduler(rt, cfg)
+	adopted, dropped, err := restarted.Recover(ctx)
+	if err != nil {
+		t.Fatalf("Recover() error = %v", err)
+	}
+	if adopted != 2 || dropped != 0 {
+		t.Errorf("Recover() = %d adopted, %d dropped, want 2 and 0", adopted, dropped)
+	}
+
+	if got, ok := restarted.Get(kept.ID); !ok || got.Status != protocol.StatusRunning {
+		t.Errorf("running deployment after Recover() = %+v", got)
+	}
+	if got, ok := restarted.Get(exited.ID); !ok || got.Status != protocol.StatusFailed {
+		t.Errorf("exited deployment after Recover() = %+v", got)
+	}
+	if _, err := rt.Inspect(ctx, stray); err == nil {
+		t.Error("unrecorded container was not removed")
+	}
+	if used, _, _, _, _, _ := restarted.ResourceUsage(); used != 500 {
+		t.Errorf("used CPU = %d, want 500", used)
+	}
+}
@@ -0,0 +1,56 @@
+// Package scheduler - Deployment replicas
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+// ScheduleReplicas creates and starts the replicas a request asks for. The
+// replicas share the request's replica set, or a new one if the request has
+// several replicas but no set. Replicas are scheduled one after another like
+// single deployments, so some may be queued; if one fails, the ones already
+// created are stopped and the request fails as a whole.
+func (s *Scheduler) ScheduleReplicas(ctx context.Context, req *protocol.DeployRequest) ([]*protocol.Deployment, error) {
+	n := max(req.Replicas, 1)
+	if n > 1 && req.Job != nil {
+		return nil, fmt.Errorf("jobs cannot have replicas")
+	}
+
+	replicaReq := *req
+	if n > 1 && replicaReq.ReplicaSet == "" {
+		replicaReq.ReplicaSet = generateReplicaSetID()
+	}
+
+	replicas := make([]*protocol.Deployment, 0, n)
+	for i := 0; i < n; i++ {
+		d, err := s.schedule(ctx, newDeployment(&replicaReq), req.QueueTimeoutSeconds)
+		if err != nil {
+			for _, r := range replicas {
+				if stopErr := s.Stop(ctx, r.ID); stopErr != nil {
+					log.Printf("[SCHEDULER] Failed to remove replica %s: %v", r.ID, stopErr)
+				}
+			}
+			if n == 1 {
+				return nil, err
+			}
+			return nil, fmt.Errorf("replica %d of %d: %w", i+1, n, err)
+		}
+		if d != nil {
+			replicas = append(replicas, d)
+		}
+	}
+
+	if n > 1 {
+		log.Printf("[SCHEDULER] Scheduled %d replicas of replica set %s", n, replicaReq.ReplicaSet)
+	}
+	return replicas, nil
+}
+
+// generateReplicaSetID generates a unique replica set ID.
+func generateReplicaSetID() string {
+	return randomID(protocol.ReplicaSetIDPrefix)
+}
@@ -0,0 +1,126 @@
+// Package scheduler - Restart policies with crash-loop backoff
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+	"github.com/xdas-research/peer-compute/internal/runtime"
+)
+
+const (
+	// RestartBackoffBase is the delay before the first restart
+	RestartBackoffBase = 10 * time.Second
+
+	// RestartBackoffMax caps the restart delay
+	RestartBackoffMax = 5 * time.Minute
+
+	// CrashLoopResetAfter is how long a container must run before an exit is
+	// no longer counted as part of a crash loop
+	CrashLoopResetAfter = 10 * time.Minute
+)
+
+// shouldRestart reports whether a deployment's restart policy (or a job's
+// retry count) asks for a restart after the container exited with exitCode.
+func shouldRestart(d *protocol.Deployment, exitCode int) bool {
+	// Jobs are retried on failure only, up to their retry count
+	if d.Job != nil {
+		return exitCode != 0 && d.RestartCount < d.Job.Retries
+	}
+
+	p := d.RestartPolicy
+	if p == nil {
+		return false
+	}
+
+	switch p.Mode {
+	case protocol.RestartAlways:
+		return true
+	case protocol.RestartOnFailure:
+		return exitCode != 0 && (p.MaxRetries == 0 || d.RestartCount < p.MaxRetries)
+	}
+	return false
+}
+
+// backoffDelay returns the restart delay after n consecutive crashes.
+func backoffDelay(n int) time.Duration {
+	delay := RestartBackoffBase
+	for i := 1; i < n && delay < RestartBackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > RestartBackoffMax {
+		delay = RestartBackoffMax
+	}
+	return delay
+}
+
+// enterBackoff moves a deployment whose container exited into backoff if its
+// restart policy asks for a restart. Resources stay reserved while in backoff.
+// It returns false if the deployment should finish instead.
+func (s *Scheduler) enterBackoff(deploymentID, containerID string, reason protocol.TerminationReason,
+	exitCode int, ranFor time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.deployments[deploymentID]
+	if !ok || d.ContainerID != containerID || d.Status != protocol.StatusRunning {
+		return false
+	}
+	if !shouldRestart(d, exitCode) {
+		return false
+	}
+
+	if ranFor >= CrashLoopResetAfter {
+		d.ConsecutiveCrashes = 0
+	}
+	d.ConsecutiveCrashes++
+
+	next := time.Now().Add(backoffDelay(d.ConsecutiveCrashes))
+	d.Status = protocol.StatusBackoff
+	d.LastExitReason = reason
+	d.ExitCode = &exitCode
+	d.NextRestartAt = &next
+	s.persistLocked()
+	s.emit(exitEvent(reason), d, fmt.Sprintf("exited with code %d; restarting at %s", exitCode, next.Format(time.RFC3339)))
+
+	log.Printf("[SCHEDULER] Deployment %s exited (%s, code %d); restarting at %s",
+		deploymentID, reason, exitCode, next.Format(time.RFC3339))
+	return true
+}
+
+// restartDue restarts a deployment in backoff once its delay has elapsed.
+// The existing container is started again, so no image pull is needed.
+func (s *Scheduler) restartDue(ctx context.Context, d *protocol.Deployment) {
+	if d.NextRestartAt == nil || time.Now().Before(*d.NextRestartAt) {
+		return
+	}
+
+	err := s.runtime.Start(ctx, d.ContainerID)
+	if errors.Is(err, runtime.ErrContainerNotFound) {
+		s.markFinished(d.ID, d.ContainerID, protocol.StatusTerminated, protocol.ReasonContainerRemoved, d.ExitCode,
+			"container was removed outside Peer Compute", time.Now())
+		return
+	}
+	if err != nil {
+		s.markFinished(d.ID, d.ContainerID, protocol.StatusFailed, d.LastExitReason, d.ExitCode,
+			"restart failed: "+err.Error(), time.Now())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cur, ok := s.deployments[d.ID]; ok && cur.Status == protocol.StatusBackoff && cur.ContainerID == d.ContainerID {
+		cur.Status = protocol.StatusRunning
+		cur.RestartCount++
+		cur.NextRestartAt = nil
+		s.resetHealthLocked(cur)
+		s.persistLocked()
+		s.emit(protocol.EventStarted, cur, fmt.Sprintf("restart %d", cur.RestartCount))
+		log.Printf("[SCHEDULER] Restarted deployment %s (restart %d)", d.ID, cur.RestartCount)
+	}
+}
@@ -3,24 +3,47 @@ package scheduler
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"log"
+	"path/filepath"
 	"sync"
 	"time"
 
+	"github.com/xdas-research/peer-compute/internal/artifact"
 	"github.com/xdas-research/peer-compute/internal/protocol"
 	"github.com/xdas-research/peer-compute/internal/runtime"
 )
 
 // Scheduler manages active deployments on a provider peer.
 type Scheduler struct {
-	runtime     *runtime.Runtime
+	runtime     runtime.Runtime
 	deployments map[string]*protocol.Deployment
 	mu          sync.RWMutex
 	maxSlots    int
 	usedCPU     int64 // millicores
 	usedMemory  int64 // bytes
-	maxCPU      int64
+	maxCPU      int64 // effective capacity, after overcommit
 	maxMemory   int64
+	hostCPU     int64 // capacity before overcommit
+	hostMemory  int64
+	statePath   string
+	probes      map[string]*healthProbe
+	healthHook  HealthHook
+	maxQueue    int
+	queueWake   chan struct{}
+	startHook   StartHook
+	preemptHook PreemptHook
+	artifacts   *artifact.Store
+	crons       map[string]*protocol.CronJob
+	cronPath    string
+	events      *EventBus
+	updateHook  UpdateHook
+	drain       DrainState
+	drainPath   string
+	drainHook   DrainHook
+	reconcileMu sync.Mutex // serializes reconcileRunning across the ticker and events
 }
 
 // Config contains scheduler configuration.
@@ -31,37 +54,79 @@ type Config struct {
 	MaxCPU int64
 	// MaxMemory is the total memory budget in bytes
 	MaxMemory int64
+	// CPUOvercommit multiplies MaxCPU for admission (0 or 1 = no overcommit).
+	// No single deployment may ask for more than MaxCPU.
+	CPUOvercommit float64
+	// MemoryOvercommit multiplies MaxMemory for admission (0 or 1 = no
+	// overcommit). Deployments may be OOM-killed if they all use their limit.
+	MemoryOvercommit float64
+	// StateDir is where deployment records are persisted (empty = in-memory only)
+	StateDir string
+	// MaxQueue is the admission queue length (0 = no queue)
+	MaxQueue int
 }
 
 // DefaultConfig returns default scheduler configuration.
 func DefaultConfig() *Config {
 	return &Config{
 		MaxDeployments: 10,
-		MaxCPU:         4000,                  // 4 CPUs
+		MaxCPU:         4000,                   // 4 CPUs
 		MaxMemory:      4 * 1024 * 1024 * 1024, // 4GB
 	}
 }
 
 // NewScheduler creates a new deployment scheduler.
-func NewScheduler(rt *runtime.Runtime, cfg *Config) *Scheduler {
+func NewScheduler(rt runtime.Runtime, cfg *Config) *Scheduler {
 	if cfg == nil {
 		cfg = DefaultConfig()
 	}
-	return &Scheduler{
+	s := &Scheduler{
 		runtime:     rt,
 		deployments: make(map[string]*protocol.Deployment),
+		probes:      make(map[string]*healthProbe),
+		crons:       make(map[string]*protocol.CronJob),
+		events:      NewEventBus(),
+		maxQueue:    cfg.MaxQueue,
+		queueWake:   make(chan struct{}, 1),
 		maxSlots:    cfg.MaxDeployments,
-		maxCPU:      cfg.MaxCPU,
-		maxMemory:   cfg.MaxMemory,
+		maxCPU:      overcommit(cfg.MaxCPU, cfg.CPUOvercommit),
+		maxMemory:   overcommit(cfg.MaxMemory, cfg.MemoryOvercommit),
+		hostCPU:     cfg.MaxCPU,
+		hostMemory:  cfg.MaxMemory,
 	}
+	if cfg.StateDir != "" {
+		s.statePath = filepath.Join(cfg.StateDir, StateFileName)
+		s.cronPath = filepath.Join(cfg.StateDir, CronFileName)
+		s.drainPath = filepath.Join(cfg.StateDir, DrainFileName)
+	}
+	return s
+}
+
+// overcommit applies an overcommit ratio to a capacity.
+func overcommit(capacity int64, ratio float64) int64 {
+	if ratio <= 1 {
+		return capacity
+	}
+	return int64(float64(capacity) * ratio)
 }
 
 // CanSchedule checks if a deployment can be scheduled with the given resources.
+// The result is advisory; Schedule repeats the check atomically with the
+// reservation.
 func (s *Scheduler) CanSchedule(cpuMillicores, memoryBytes int64) error {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	return s.fitsLocked(cpuMillicores, memoryBytes)
+}
 
-	if len(s.deployments) >= s.maxSlots {
+// fitsLocked checks that the given resources are free (caller must hold lock).
+func (s *Scheduler) fitsLocked(cpuMillicores, memoryBytes int64) error {
+	if cpuMillicores > s.hostCPU || memoryBytes > s.hostMemory {
+		return fmt.Errorf("request exceeds the provider's total capacity (%d millicores, %d bytes)",
+			s.hostCPU, s.hostMemory)
+	}
+
+	if s.activeCountLocked() >= s.maxSlots {
 		return fmt.Errorf("maximum deployment slots (%d) reached", s.maxSlots)
 	}
 
@@ -78,74 +143,177 @@ func (s *Scheduler) CanSchedule(cpuMillicores, memoryBytes int64) error {
 	return nil
 }
 
-// Schedule creates and starts a new deployment.
+// Schedule creates and starts a new deployment. If the provider is full and
+// the request has a queue timeout, the deployment is queued instead and
+// returned with status queued; it starts once resources free up.
 func (s *Scheduler) Schedule(ctx context.Context, req *protocol.DeployRequest) (*protocol.Deployment, error) {
-	// Check resources
-	if err := s.CanSchedule(req.CPUMillicores, req.MemoryBytes); err != nil {
-		return nil, err
-	}
-
-	// Generate deployment ID
-	deploymentID := generateDeploymentID()
+	return s.schedule(ctx, newDeployment(req), req.QueueTimeoutSeconds)
+}
 
-	// Create deployment record
-	deployment := &protocol.Deployment{
-		ID:          deploymentID,
-		Image:       req.Image,
-		RequesterID: req.RequesterID,
-		Status:      protocol.StatusPending,
-		CPULimit:    req.CPUMillicores,
-		MemoryLimit: req.MemoryBytes,
-		ExposePort:  req.ExposePort,
-		StartedAt:   time.Now(),
+// newDeployment creates the record of a new deployment.
+func newDeployment(req *protocol.DeployRequest) *protocol.Deployment {
+	return &protocol.Deployment{
+		ID:            generateDeploymentID(),
+		Name:          req.Name,
+		ReplicaSet:    req.ReplicaSet,
+		Image:         req.Image,
+		ImageDigest:   req.ImageDigest,
+		RequesterID:   req.RequesterID,
+		Status:        protocol.StatusPending,
+		CPULimit:      req.CPUMillicores,
+		MemoryLimit:   req.MemoryBytes,
+		ExposePort:    req.ExposePort,
+		StartedAt:     time.Now(),
+		Environment:   req.Environment,
+		RestartPolicy: req.RestartPolicy,
+		HealthCheck:   req.HealthCheck,
+		LeaseSeconds:  req.LeaseSeconds,
+		PriorityClass: req.PriorityClass,
+		Job:           req.Job,
+		Priority:      req.Priority,
+		Revision:      1,
 	}
+}
+
+// schedule reserves resources for a new deployment record and starts it,
+// preempting or queueing as needed.
+func (s *Scheduler) schedule(ctx context.Context, deployment *protocol.Deployment, queueTimeoutSeconds int64) (*protocol.Deployment, error) {
+	deploymentID := deployment.ID
 
-	// Register the deployment
+	// Check and reserve resources atomically, so concurrent requests can't
+	// both pass the check. If the provider is full, preempt lower priority
+	// classes, or else queue the request.
+	var preempted []protocol.Deployment
 	s.mu.Lock()
+	if s.drain.Cordoned {
+		s.mu.Unlock()
+		return nil, ErrCordoned
+	}
+	if err := s.checkNameLocked(deployment); err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	if err := s.fitsLocked(deployment.CPULimit, deployment.MemoryLimit); err != nil {
+		victims := s.preemptionVictimsLocked(deployment)
+		if victims == nil {
+			if qerr := s.enqueueLocked(deployment, queueTimeoutSeconds); qerr != nil {
+				s.mu.Unlock()
+				if queueTimeoutSeconds > 0 {
+					return nil, fmt.Errorf("%v; %v", err, qerr)
+				}
+				return nil, err
+			}
+			s.emit(protocol.EventCreated, deployment, fmt.Sprintf("queued at position %d", deployment.QueuePosition))
+			copy := *deployment
+			s.mu.Unlock()
+			return &copy, nil
+		}
+		for _, v := range victims {
+			s.evictLocked(v, deployment)
+			preempted = append(preempted, *v)
+		}
+	}
 	s.deployments[deploymentID] = deployment
-	s.usedCPU += req.CPUMillicores
-	s.usedMemory += req.MemoryBytes
+	s.reserveLocked(deployment)
+	s.persistLocked()
+	s.emit(protocol.EventCreated, deployment, "")
 	s.mu.Unlock()
 
+	// Make room before starting the new container
+	s.stopPreempted(ctx, preempted)
+
+	if err := s.launch(ctx, deploymentID); err != nil {
+		return nil, err
+	}
+
+	d, _ := s.Get(deploymentID)
+	return d, nil
+}
+
+// launch pulls the image and starts the container of a deployment whose
+// resources are already reserved.
+func (s *Scheduler) launch(ctx context.Context, deploymentID string) error {
+	d, ok := s.Get(deploymentID)
+	if !ok {
+		return fmt.Errorf("deployment %s not found", deploymentID)
+	}
+
 	// Update status to pulling
-	s.updateStatus(deploymentID, protocol.StatusPulling)
+	if !s.advance(deploymentID, protocol.StatusPending, protocol.StatusPulling) {
+		return fmt.Errorf("deployment %s was stopped while starting", deploymentID)
+	}
+	d.Status = protocol.StatusPulling
+	s.emit(protocol.EventPulling, d, "")
 
-	// Pull the image
-	if err := s.runtime.Pull(ctx, req.Image); err != nil {
+	// Pull the image, pinned to the digest resolved when it was requested
+	image := runtime.PinImage(d.Image, d.ImageDigest)
+	if err := s.runtime.Pull(ctx, image); err != nil {
 		s.failDeployment(deploymentID, fmt.Errorf("failed to pull image: %w", err))
-		return nil, err
+		return err
 	}
 
 	// Update status to starting
-	s.updateStatus(deploymentID, protocol.StatusStarting)
+	if !s.advance(deploymentID, protocol.StatusPulling, protocol.StatusStarting) {
+		return fmt.Errorf("deployment %s was stopped while starting", deploymentID)
+	}
 
 	// Start the container
-	containerID, err := s.runtime.Run(ctx, runtime.ContainerConfig{
+	cfg := runtime.ContainerConfig{
 		DeploymentID:  deploymentID,
-		RequesterID:   req.RequesterID,
-		Image:         req.Image,
-		CPUMillicores: req.CPUMillicores,
-		MemoryBytes:   req.MemoryBytes,
-		ExposePort:    req.ExposePort,
-		Environment:   req.Environment,
-	})
+		RequesterID:   d.RequesterID,
+		Image:         image,
+		CPUMillicores: d.CPULimit,
+		MemoryBytes:   d.MemoryLimit,
+		ExposePort:    d.ExposePort,
+		Environment:   d.Environment,
+	}
+	var containerID string
+	var err error
+	if d.Job != nil {
+		cfg.Command = d.Job.Command
+		cfg.Args = d.Job.Args
+	}
+	if d.Job != nil && d.Job.Input != "" {
+		containerID, err = s.createWithInput(ctx, d, cfg)
+	} else {
+		containerID, err = s.runtime.Run(ctx, cfg)
+	}
 	if err != nil {
 		s.failDeployment(deploymentID, fmt.Errorf("failed to start container: %w", err))
-		return nil, err
+		return err
 	}
 
 	// Update deployment with container ID
 	s.mu.Lock()
-	if d, ok := s.deployments[deploymentID]; ok {
-		d.ContainerID = containerID
-		d.Status = protocol.StatusRunning
+	defer s.mu.Unlock()
+	cur, ok := s.deployments[deploymentID]
+	if !ok || cur.Status != protocol.StatusStarting {
+		// Stopped while starting; don't leak the container
+		go s.runtime.Stop(context.Background(), containerID)
+		return fmt.Errorf("deployment %s was stopped while starting", deploymentID)
 	}
-	s.mu.Unlock()
+	cur.ContainerID = containerID
+	cur.Status = protocol.StatusRunning
+	s.persistLocked()
+	s.emit(protocol.EventStarted, cur, "")
 
-	return deployment, nil
+	return nil
+}
+
+// resolveDigest resolves an image's tag to the digest it points to now.
+// Images that can't be resolved, such as locally built ones, give "" and
+// run by tag, unless the runtime's security policy refuses them.
+func (s *Scheduler) resolveDigest(ctx context.Context, image string) string {
+	digest, err := s.runtime.ResolveDigest(ctx, image)
+	if err != nil {
+		log.Printf("[SCHEDULER] Warning: failed to resolve %s to a digest, running it by tag: %v", image, err)
+		return ""
+	}
+	return digest
 }
 
 // Stop stops a deployment and releases resources.
+// Finished deployments are removed along with their retained container.
 func (s *Scheduler) Stop(ctx context.Context, deploymentID string) error {
 	s.mu.Lock()
 	deployment, ok := s.deployments[deploymentID]
@@ -153,12 +321,29 @@ func (s *Scheduler) Stop(ctx context.Context, deploymentID string) error {
 		s.mu.Unlock()
 		return fmt.Errorf("deployment %s not found", deploymentID)
 	}
-	deployment.Status = protocol.StatusStopping
+	previous := deployment.Status
+	stopping := holdsResources(previous) && previous != protocol.StatusStopping
+	if stopping {
+		deployment.Status = protocol.StatusStopping
+		s.persistLocked()
+	}
+	containerID := deployment.ContainerID
 	s.mu.Unlock()
 
 	// Stop the container
-	if deployment.ContainerID != "" {
-		if err := s.runtime.Stop(ctx, deployment.ContainerID); err != nil {
+	if containerID != "" {
+		if err := s.runtime.Stop(ctx, containerID); err != nil {
+			// The container may still be running, so the deployment keeps
+			// its reservation and goes back to its status. Left stopping,
+			// nothing (not even a drain) would try to stop it again
+			if stopping {
+				s.mu.Lock()
+				if d, ok := s.deployments[deploymentID]; ok && d.Status == protocol.StatusStopping {
+					d.Status = previous
+					s.persistLocked()
+				}
+				s.mu.Unlock()
+			}
 			return fmt.Errorf("failed to stop container: %w", err)
 		}
 	}
@@ -168,12 +353,20 @@ func (s *Scheduler) Stop(ctx context.Context, deploymentID string) error {
 	defer s.mu.Unlock()
 
 	if d, ok := s.deployments[deploymentID]; ok {
-		s.usedCPU -= d.CPULimit
-		s.usedMemory -= d.MemoryLimit
+		// Removing a finished record is housekeeping, not a lifecycle change
+		active := isActive(d.Status)
+		if holdsResources(d.Status) {
+			s.releaseLocked(d)
+		}
 		now := time.Now()
 		d.StoppedAt = &now
 		d.Status = protocol.StatusStopped
 		delete(s.deployments, deploymentID)
+		s.renumberQueueLocked()
+		s.persistLocked()
+		if active {
+			s.emit(protocol.EventStopped, d, "stopped on request")
+		}
 	}
 
 	return nil
@@ -192,6 +385,18 @@ func (s *Scheduler) Get(deploymentID string) (*protocol.Deployment, bool) {
 	return &copy, true
 }
 
+// SetExposedURL records the public URL a deployment's gateway route was
+// registered under ("" = no longer routed).
+func (s *Scheduler) SetExposedURL(deploymentID, url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if d, ok := s.deployments[deploymentID]; ok && d.ExposedURL != url {
+		d.ExposedURL = url
+		s.persistLocked()
+	}
+}
+
 // List returns all active deployments.
 func (s *Scheduler) List() []*protocol.Deployment {
 	s.mu.RLock()
@@ -220,7 +425,7 @@ func (s *Scheduler) ListByRequester(requesterID string) []*protocol.Deployment {
 	return result
 }
 
-// StopAll stops all deployments.
+// StopAll stops all deployments, including retained finished ones.
 // SECURITY: Called on daemon shutdown for cleanup.
 func (s *Scheduler) StopAll(ctx context.Context) []error {
 	deployments := s.List()
@@ -239,34 +444,100 @@ func (s *Scheduler) StopAll(ctx context.Context) []error {
 func (s *Scheduler) ResourceUsage() (cpuUsed, cpuTotal, memUsed, memTotal int64, slots, maxSlots int) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.usedCPU, s.maxCPU, s.usedMemory, s.maxMemory, len(s.deployments), s.maxSlots
+	return s.usedCPU, s.maxCPU, s.usedMemory, s.maxMemory, s.activeCountLocked(), s.maxSlots
 }
 
-// updateStatus updates the status of a deployment.
-func (s *Scheduler) updateStatus(deploymentID string, status protocol.DeploymentStatus) {
+// advance moves a launching deployment from one status to the next. It
+// returns false if the deployment was stopped, preempted or expired meanwhile.
+func (s *Scheduler) advance(deploymentID string, from, to protocol.DeploymentStatus) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if d, ok := s.deployments[deploymentID]; ok {
-		d.Status = status
+	d, ok := s.deployments[deploymentID]
+	if !ok || d.Status != from {
+		return false
 	}
+	d.Status = to
+	s.persistLocked()
+	return true
 }
 
 // failDeployment marks a deployment as failed and releases resources.
+// The record is retained so the requester can see why it failed.
 func (s *Scheduler) failDeployment(deploymentID string, err error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if d, ok := s.deployments[deploymentID]; ok {
+	// Deployments that were stopped or preempted meanwhile keep their status
+	if d, ok := s.deployments[deploymentID]; ok && holdsResources(d.Status) {
+		s.releaseLocked(d)
 		d.Status = protocol.StatusFailed
-		s.usedCPU -= d.CPULimit
-		s.usedMemory -= d.MemoryLimit
+		d.Error = err.Error()
 		now := time.Now()
 		d.StoppedAt = &now
-		delete(s.deployments, deploymentID)
+		s.persistLocked()
+		s.emit(protocol.EventFailed, d, d.Error)
 	}
 }
 
+// reserveLocked reserves a deployment's resources and starts its lease
+// (caller must hold lock).
+func (s *Scheduler) reserveLocked(d *protocol.Deployment) {
+	s.usedCPU += d.CPULimit
+	s.usedMemory += d.MemoryLimit
+
+	if d.HealthCheck != nil {
+		d.Health = protocol.HealthStarting
+	}
+	if d.LeaseSeconds > 0 {
+		expiresAt := d.StartedAt.Add(time.Duration(d.LeaseSeconds) * time.Second)
+		d.LeaseExpiresAt = &expiresAt
+	}
+}
+
+// releaseLocked returns a deployment's reserved resources and wakes the
+// admission queue (caller must hold lock).
+func (s *Scheduler) releaseLocked(d *protocol.Deployment) {
+	s.usedCPU -= d.CPULimit
+	s.usedMemory -= d.MemoryLimit
+	s.wakeQueue()
+}
+
+// activeCountLocked returns the number of deployments holding a slot
+// (caller must hold lock).
+func (s *Scheduler) activeCountLocked() int {
+	n := 0
+	for _, d := range s.deployments {
+		if holdsResources(d.Status) {
+			n++
+		}
+	}
+	return n
+}
+
+// holdsResources reports whether a deployment in the given status has CPU,
+// memory and a slot reserved.
+func holdsResources(status protocol.DeploymentStatus) bool {
+	switch status {
+	case protocol.StatusPending, protocol.StatusPulling, protocol.StatusStarting,
+		protocol.StatusRunning, protocol.StatusBackoff, protocol.StatusStopping:
+		return true
+	}
+	return false
+}
+
 // generateDeploymentID generates a unique deployment ID.
 func generateDeploymentID() string {
-	return fmt.Sprintf("dep-%d", time.Now().UnixNano())
+	return randomID(protocol.DeploymentIDPrefix)
+}
+
+// randomID returns prefix followed by 64 random bits in hex, which is
+// collision-resistant even for concurrent requests and short enough for a
+// DNS label.
+func randomID(prefix string) string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand only fails if the OS entropy source is broken
+		panic(fmt.Sprintf("failed to generate ID: %v", err))
+	}
+	return prefix + hex.EncodeToString(b)
 }
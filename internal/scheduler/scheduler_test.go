@@ -0,0 +1,139 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+	"github.com/xdas-research/peer-compute/internal/runtime"
+)
+
+const (
+	testRequester = "12D3KooWRequester"
+	testMemory    = 64 * 1024 * 1024
+)
+
+// newTestScheduler returns a scheduler on a fake runtime, persisting its
+// state in a temporary directory.
+func newTestScheduler(t *testing.T, cfg *Config) (*Scheduler, *runtime.Fake) {
+	t.Helper()
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	cfg.StateDir = t.TempDir()
+	rt := runtime.NewFake()
+	return NewScheduler(rt, cfg), rt
+}
+
+// testRequest returns a deployment request for an nginx container.
+func testRequest(cpuMillicores int64) *protocol.DeployRequest {
+	return &protocol.DeployRequest{
+		Image:         "nginx:1.27",
+		CPUMillicores: cpuMillicores,
+		MemoryBytes:   testMemory,
+		RequesterID:   testRequester,
+	}
+}
+
+// mustSchedule schedules a request and fails the test if it is refused.
+func mustSchedule(t *testing.T, s *Scheduler, req *protocol.DeployRequest) *protocol.Deployment {
+	t.Helper()
+	d, err := s.Schedule(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Schedule() error = %v", err)
+	}
+	return d
+}
+
+func TestScheduleAndStop(t *testing.T) {
+	s, rt := newTestScheduler(t, nil)
+	ctx := context.Background()
+
+	d := mustSchedule(t, s, testRequest(500))
+	if d.Status != protocol.StatusRunning {
+		t.Fatalf("Status = %s, want running", d.Status)
+	}
+	info, err := rt.Inspect(ctx, d.ContainerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != "running" {
+		t.Errorf("container status = %s, want running", info.Status)
+	}
+	if used, _, _, _, _, _ := s.ResourceUsage(); used != 500 {
+		t.Errorf("used CPU = %d, want 500", used)
+	}
+
+	if err := s.Stop(ctx, d.ID); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if _, ok := s.Get(d.ID); ok {
+		t.Error("deployment still exists after Stop()")
+	}
+	if _, err := rt.Inspect(ctx, d.ContainerID); err == nil {
+		t.Error("container still exists after Stop()")
+	}
+	if used, _, _, _, _, _ := s.ResourceUsage(); used != 0 {
+		t.Errorf("used CPU = %d after Stop(), want 0", used)
+	}
+}
+
+func TestScheduleRefusesWhenFull(t *testing.T) {
+	s, _ := newTestScheduler(t, &Config{MaxDeployments: 10, MaxCPU: 1000, MaxMemory: 1 << 30})
+
+	mustSchedule(t, s, testRequest(800))
+	if _, err := s.Schedule(context.Background(), testRequest(400)); err == nil {
+		t.Fatal("Schedule() succeeded beyond the CPU capacity")
+	}
+}
+
+func TestStopFailureKeepsDeployment(t *testing.T) {
+	s, rt := newTestScheduler(t, nil)
+	ctx := context.Background()
+	d := mustSchedule(t, s, testRequest(500))
+
+	rt.StopHook = func(string) error { return errors.New("daemon unavailable") }
+	if err := s.Stop(ctx, d.ID); err == nil {
+		t.Fatal("Stop() succeeded, want error")
+	}
+	got, ok := s.Get(d.ID)
+	if !ok || got.Status != protocol.StatusRunning {
+		t.Fatalf("deployment after failed Stop() = %+v, want running", got)
+	}
+	if used, _, _, _, _, _ := s.ResourceUsage(); used != 500 {
+		t.Errorf("used CPU = %d, want 500", used)
+	}
+
+	// The next attempt stops it
+	rt.StopHook = nil
+	if err := s.Stop(ctx, d.ID); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if used, _, _, _, _, _ := s.ResourceUsage(); used != 0 {
+		t.Errorf("used CPU = %d after Stop(), want 0", used)
+	}
+}
+
+func TestScheduleRunsResolvedDigest(t *testing.T) {
+	s, rt := newTestScheduler(t, nil)
+	digest := "sha256:" + strings.Repeat("ab", 32)
+
+	// The tag may move after the request; the container runs the digest
+	// it was resolved to
+	req := testRequest(500)
+	req.ImageDigest = digest
+	d := mustSchedule(t, s, req)
+
+	if d.ImageDigest != digest {
+		t.Errorf("ImageDigest = %q, want %q", d.ImageDigest, digest)
+	}
+	info, err := rt.Inspect(context.Background(), d.ContainerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "docker.io/library/nginx:1.27@" + digest; info.Image != want {
+		t.Errorf("container image = %q, want %q", info.Image, want)
+	}
+}
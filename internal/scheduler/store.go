@@ -0,0 +1,188 @@
+// Package scheduler - Deployment record persistence and restart recovery
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+	"github.com/xdas-research/peer-compute/internal/runtime"
+)
+
+// StateFileName is the file deployment records are persisted to.
+const StateFileName = "deployments.json"
+
+// persistLocked writes all deployment records to the state file
+// (caller must hold lock). Failures are logged, not returned: losing the
+// state file degrades recovery but must not fail the deployment itself.
+func (s *Scheduler) persistLocked() {
+	if s.statePath == "" {
+		return
+	}
+
+	records := make([]*protocol.Deployment, 0, len(s.deployments))
+	for _, d := range s.deployments {
+		records = append(records, d)
+	}
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		log.Printf("[SCHEDULER] Failed to marshal state: %v", err)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.statePath), 0700); err != nil {
+		log.Printf("[SCHEDULER] Failed to create state directory: %v", err)
+		return
+	}
+
+	// Write to a temporary file and rename so a crash never leaves a
+	// truncated state file behind
+	tmp := s.statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		log.Printf("[SCHEDULER] Failed to write state: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, s.statePath); err != nil {
+		log.Printf("[SCHEDULER] Failed to replace state file: %v", err)
+	}
+}
+
+// loadState reads persisted deployment records.
+func loadState(path string) ([]*protocol.Deployment, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var records []*protocol.Deployment
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+	return records, nil
+}
+
+// Recover reloads persisted deployment records after a daemon restart and
+// reconciles them with the containers Docker actually has.
+//
+// Records whose container still exists (matched by the deployment-id and
+// requester-id labels) are re-adopted and their resources reserved again;
+// containers that exited while the daemon was down are then picked up by
+// Reconcile. Active records without a container are dropped, finished records
+// are kept as history, and Peer Compute containers without a record are
+// removed, as are the containers of updates the restart interrupted. Cron
+// schedules are reloaded as well.
+//
+// SECURITY: Unknown containers are removed rather than adopted, since their
+// resource limits and owner cannot be verified against a record.
+func (s *Scheduler) Recover(ctx context.Context) (adopted, dropped int, err error) {
+	if s.statePath == "" {
+		return 0, 0, nil
+	}
+
+	records, err := loadState(s.statePath)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := s.loadCrons(); err != nil {
+		return 0, 0, err
+	}
+	if err := s.loadDrain(); err != nil {
+		return 0, 0, err
+	}
+
+	containers, err := s.runtime.ListPeerComputeContainers(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// During an update a deployment has two containers
+	byDeployment := make(map[string][]runtime.Container, len(containers))
+	for _, c := range containers {
+		depID := c.Labels[runtime.DeploymentIDLabel]
+		byDeployment[depID] = append(byDeployment[depID], c)
+	}
+
+	var leftover []runtime.Container
+	s.mu.Lock()
+	for _, d := range records {
+		c, ok := adoptContainer(byDeployment[d.ID], d.ContainerID)
+		if ok && c.Labels[runtime.RequesterIDLabel] != d.RequesterID {
+			ok = false
+		}
+		if ok {
+			d.ContainerID = c.ID
+			for _, other := range byDeployment[d.ID] {
+				if other.ID != c.ID {
+					leftover = append(leftover, other)
+				}
+			}
+			delete(byDeployment, d.ID)
+		}
+		if d.Update != nil {
+			log.Printf("[SCHEDULER] Abandoning update of %s to revision %d", d.ID, d.Update.Revision.Revision)
+			d.Update = nil
+		}
+
+		// Finished deployments are history; keep them (and their container, for logs)
+		if !holdsResources(d.Status) {
+			s.deployments[d.ID] = d
+			continue
+		}
+
+		if !ok {
+			log.Printf("[SCHEDULER] Dropping deployment %s: container no longer exists", d.ID)
+			dropped++
+			continue
+		}
+
+		// Deployments in backoff keep waiting for their restart
+		if d.Status != protocol.StatusBackoff {
+			d.Status = protocol.StatusRunning
+		}
+		s.deployments[d.ID] = d
+		s.usedCPU += d.CPULimit
+		s.usedMemory += d.MemoryLimit
+		adopted++
+	}
+	s.persistLocked()
+	s.mu.Unlock()
+
+	// Remove containers that no record accounts for
+	for _, cs := range byDeployment {
+		leftover = append(leftover, cs...)
+	}
+	for _, c := range leftover {
+		log.Printf("[SCHEDULER] Removing unrecorded container %s (deployment %q)", c.ID, c.Labels[runtime.DeploymentIDLabel])
+		if err := s.runtime.Stop(ctx, c.ID); err != nil {
+			log.Printf("[SCHEDULER] Failed to remove container %s: %v", c.ID, err)
+		}
+	}
+
+	// Pick up containers that exited while the daemon was down
+	s.Reconcile(ctx)
+
+	return adopted, dropped, nil
+}
+
+// adoptContainer picks the container a record refers to among the containers
+// labelled with its deployment ID, falling back to the only one if the record
+// lost track of it.
+func adoptContainer(containers []runtime.Container, containerID string) (runtime.Container, bool) {
+	for _, c := range containers {
+		if c.ID == containerID {
+			return c, true
+		}
+	}
+	if len(containers) == 1 {
+		return containers[0], true
+	}
+	return runtime.Container{}, false
+}
@@ -0,0 +1,427 @@
+// Package scheduler - In-place updates and rollbacks
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+	"github.com/xdas-research/peer-compute/internal/runtime"
+)
+
+const (
+	// UpdateTimeout bounds how long a new revision may take to start and
+	// become healthy before the update is abandoned
+	UpdateTimeout = 5 * time.Minute
+
+	// UpdateSettleTime is how long a new revision without a health check must
+	// keep running before the deployment switches to it
+	UpdateSettleTime = 5 * time.Second
+)
+
+// UpdateHook is called when a deployment switched to a new revision, so its
+// gateway route can be pointed at the new container.
+type UpdateHook func(d *protocol.Deployment)
+
+// SetUpdateHook sets the function called when a deployment switched to a
+// new revision.
+func (s *Scheduler) SetUpdateHook(hook UpdateHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updateHook = hook
+}
+
+// Update rolls out a new revision of one of owner's running deployments
+// without changing its ID. The new container is started next to the old one;
+// once it passes the deployment's health check (or, without one, keeps
+// running for UpdateSettleTime) the deployment switches to it and the old
+// container is stopped. If the new revision fails, the old one keeps running.
+//
+// Resources for both revisions are reserved while the update is in progress,
+// so the provider must have room for the new revision on top of the old one.
+func (s *Scheduler) Update(ctx context.Context, owner, deploymentID string, req *protocol.UpdateRequest) (*protocol.Deployment, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if s.drain.Cordoned {
+		s.mu.Unlock()
+		return nil, ErrCordoned
+	}
+	d, ok := s.deployments[deploymentID]
+	if !ok || d.RequesterID != owner {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("deployment %s not found", deploymentID)
+	}
+	if d.Job != nil {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("jobs cannot be updated; run a new job instead")
+	}
+	if d.Status != protocol.StatusRunning && d.Status != protocol.StatusBackoff {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("deployment %s is %s; only running deployments can be updated", deploymentID, d.Status)
+	}
+	if d.Update != nil {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("deployment %s is already being updated to revision %d", deploymentID, d.Update.Revision.Revision)
+	}
+
+	startHistoryLocked(d)
+	rev, err := nextRevision(d, req)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	if err := s.surgeFitsLocked(rev.CPULimit, rev.MemoryLimit); err != nil {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("not enough free capacity to run both revisions during the update: %w", err)
+	}
+
+	// The new revision's resources are reserved on top of the old one's
+	s.usedCPU += rev.CPULimit
+	s.usedMemory += rev.MemoryLimit
+	d.Update = &protocol.PendingUpdate{Revision: rev, StartedAt: time.Now()}
+	s.persistLocked()
+	s.emit(protocol.EventUpdating, d, fmt.Sprintf("rolling out revision %d (%s)", rev.Revision, rev.Image))
+	base := *d
+	s.mu.Unlock()
+
+	log.Printf("[SCHEDULER] Updating deployment %s to revision %d (%s)", deploymentID, rev.Revision, rev.Image)
+
+	ctx, cancel := context.WithTimeout(ctx, UpdateTimeout)
+	defer cancel()
+
+	containerID, err := s.startRevision(ctx, &base, &rev)
+	if err == nil {
+		err = s.waitReady(ctx, &base, rev, containerID)
+	}
+	if err != nil {
+		s.abortUpdate(deploymentID, rev, containerID, err)
+		return nil, err
+	}
+
+	return s.switchRevision(ctx, deploymentID, rev, containerID)
+}
+
+// nextRevision builds the revision an update request asks for from the
+// deployment's current revision (caller must hold lock).
+func nextRevision(d *protocol.Deployment, req *protocol.UpdateRequest) (protocol.DeploymentRevision, error) {
+	now := time.Now()
+
+	if req.Rollback {
+		target := req.ToRevision
+		if target == 0 {
+			if len(d.Revisions) < 2 {
+				return protocol.DeploymentRevision{}, fmt.Errorf("deployment %s has no previous revision", d.ID)
+			}
+			target = d.Revisions[len(d.Revisions)-2].Revision
+		}
+		if target == d.Revision {
+			return protocol.DeploymentRevision{}, fmt.Errorf("revision %d is already running", target)
+		}
+		for _, r := range d.Revisions {
+			if r.Revision == target {
+				r.Revision = d.Revision + 1
+				r.Environment = copyEnv(r.Environment)
+				r.Cause = fmt.Sprintf("rollback to revision %d", target)
+				r.CreatedAt = now
+				return r, nil
+			}
+		}
+		return protocol.DeploymentRevision{}, fmt.Errorf("revision %d is not in the history of deployment %s", target, d.ID)
+	}
+
+	rev := currentRevision(d)
+	rev.Revision = d.Revision + 1
+	rev.Environment = copyEnv(rev.Environment)
+	rev.Cause = "update"
+	rev.CreatedAt = now
+	if req.Image != "" {
+		// Resolved when the revision starts
+		rev.Image = req.Image
+		rev.ImageDigest = ""
+	}
+	for k, v := range req.Environment {
+		if rev.Environment == nil {
+			rev.Environment = make(map[string]string)
+		}
+		rev.Environment[k] = v
+	}
+	for _, k := range req.UnsetEnvironment {
+		delete(rev.Environment, k)
+	}
+	if req.CPUMillicores > 0 {
+		rev.CPULimit = req.CPUMillicores
+	}
+	if req.MemoryBytes > 0 {
+		rev.MemoryLimit = req.MemoryBytes
+	}
+	return rev, nil
+}
+
+// currentRevision describes the revision a deployment is running.
+func currentRevision(d *protocol.Deployment) protocol.DeploymentRevision {
+	return protocol.DeploymentRevision{
+		Revision:    d.Revision,
+		Image:       d.Image,
+		ImageDigest: d.ImageDigest,
+		Environment: d.Environment,
+		CPULimit:    d.CPULimit,
+		MemoryLimit: d.MemoryLimit,
+		Cause:       "deploy",
+		CreatedAt:   d.StartedAt,
+	}
+}
+
+// startHistoryLocked records a deployment's first revision before its first
+// update, including for records created before revisions existed (caller
+// must hold lock).
+func startHistoryLocked(d *protocol.Deployment) {
+	if d.Revision == 0 {
+		d.Revision = 1
+	}
+	if len(d.Revisions) == 0 {
+		d.Revisions = []protocol.DeploymentRevision{currentRevision(d)}
+	}
+}
+
+// surgeFitsLocked checks that a second container of an existing deployment
+// fits next to everything already reserved (caller must hold lock). It needs
+// no deployment slot of its own.
+func (s *Scheduler) surgeFitsLocked(cpuMillicores, memoryBytes int64) error {
+	if cpuMillicores > s.hostCPU || memoryBytes > s.hostMemory {
+		return fmt.Errorf("request exceeds the provider's total capacity (%d millicores, %d bytes)",
+			s.hostCPU, s.hostMemory)
+	}
+	if s.usedCPU+cpuMillicores > s.maxCPU {
+		return fmt.Errorf("insufficient CPU: need %d, available %d",
+			cpuMillicores, s.maxCPU-s.usedCPU)
+	}
+	if s.usedMemory+memoryBytes > s.maxMemory {
+		return fmt.Errorf("insufficient memory: need %d, available %d",
+			memoryBytes, s.maxMemory-s.usedMemory)
+	}
+	return nil
+}
+
+// startRevision pulls a new revision's image and starts its container with
+// the deployment's labels, exposed port and security constraints. A new
+// image is resolved to its digest first, and the digest recorded on rev.
+func (s *Scheduler) startRevision(ctx context.Context, d *protocol.Deployment, rev *protocol.DeploymentRevision) (string, error) {
+	if rev.ImageDigest == "" {
+		rev.ImageDigest = s.resolveDigest(ctx, rev.Image)
+	}
+	image := runtime.PinImage(rev.Image, rev.ImageDigest)
+	if err := s.runtime.Pull(ctx, image); err != nil {
+		return "", fmt.Errorf("failed to pull image: %w", err)
+	}
+
+	containerID, err := s.runtime.Run(ctx, runtime.ContainerConfig{
+		DeploymentID:  d.ID,
+		RequesterID:   d.RequesterID,
+		Image:         image,
+		CPUMillicores: rev.CPULimit,
+		MemoryBytes:   rev.MemoryLimit,
+		ExposePort:    d.ExposePort,
+		Environment:   rev.Environment,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to start container: %w", err)
+	}
+
+	// Record the container so a daemon restart can clean it up
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur, ok := s.deployments[d.ID]
+	if !ok || cur.Update == nil || cur.Update.Revision.Revision != rev.Revision {
+		return containerID, fmt.Errorf("deployment %s was stopped during the update", d.ID)
+	}
+	cur.Update.ContainerID = containerID
+	cur.Update.Revision.ImageDigest = rev.ImageDigest
+	s.persistLocked()
+	return containerID, nil
+}
+
+// waitReady waits until a new revision's container passes the deployment's
+// health check, or without one, until it has kept running for
+// UpdateSettleTime. The health check's thresholds and start period apply.
+func (s *Scheduler) waitReady(ctx context.Context, d *protocol.Deployment, rev protocol.DeploymentRevision, containerID string) error {
+	hc := d.HealthCheck
+	if hc == nil {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("update timed out: %w", ctx.Err())
+		case <-time.After(UpdateSettleTime):
+		}
+		return s.checkRevisionRunning(ctx, containerID)
+	}
+
+	probe := *d
+	probe.ContainerID = containerID
+	started := time.Now()
+	successes, failures := 0, 0
+
+	for {
+		if err := s.checkRevisionRunning(ctx, containerID); err != nil {
+			return err
+		}
+		if !s.updating(d.ID, rev.Revision) {
+			return fmt.Errorf("deployment %s was stopped during the update", d.ID)
+		}
+
+		probeCtx, cancel := context.WithTimeout(ctx, hc.Timeout())
+		err := s.runProbe(probeCtx, &probe)
+		cancel()
+
+		if err == nil {
+			successes++
+			failures = 0
+			if successes >= hc.Healthy() {
+				return nil
+			}
+		} else {
+			successes = 0
+			if time.Since(started) >= hc.StartPeriod() {
+				failures++
+			}
+			if failures >= hc.Unhealthy() {
+				return fmt.Errorf("new revision is unhealthy: %w", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("new revision did not become healthy in time: %w", ctx.Err())
+		case <-time.After(hc.Interval()):
+		}
+	}
+}
+
+// checkRevisionRunning checks that a new revision's container hasn't exited.
+func (s *Scheduler) checkRevisionRunning(ctx context.Context, containerID string) error {
+	info, err := s.runtime.Inspect(ctx, containerID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect new revision: %w", err)
+	}
+	switch info.Status {
+	case "running", "created", "restarting":
+		return nil
+	}
+	if info.OOMKilled {
+		return fmt.Errorf("new revision exceeded its memory limit")
+	}
+	return fmt.Errorf("new revision exited with code %d", info.ExitCode)
+}
+
+// updating reports whether a deployment is still being updated to a revision.
+func (s *Scheduler) updating(deploymentID string, revision int) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	d, ok := s.deployments[deploymentID]
+	return ok && d.Update != nil && d.Update.Revision.Revision == revision
+}
+
+// switchRevision makes a ready revision the deployment's current one and
+// stops the old container. The old revision's resources are released; the
+// new revision keeps the reservation made when the update started.
+func (s *Scheduler) switchRevision(ctx context.Context, deploymentID string, rev protocol.DeploymentRevision, containerID string) (*protocol.Deployment, error) {
+	s.mu.Lock()
+	d, ok := s.deployments[deploymentID]
+	if !ok || d.Update == nil || d.Update.Revision.Revision != rev.Revision ||
+		(d.Status != protocol.StatusRunning && d.Status != protocol.StatusBackoff) {
+		s.mu.Unlock()
+		err := fmt.Errorf("deployment %s was stopped during the update", deploymentID)
+		s.abortUpdate(deploymentID, rev, containerID, err)
+		return nil, err
+	}
+
+	oldContainer := d.ContainerID
+	s.releaseLocked(d)
+
+	d.ContainerID = containerID
+	d.Image = rev.Image
+	d.ImageDigest = rev.ImageDigest
+	d.Environment = rev.Environment
+	d.CPULimit = rev.CPULimit
+	d.MemoryLimit = rev.MemoryLimit
+	d.Revision = rev.Revision
+	d.Revisions = append(d.Revisions, rev)
+	if len(d.Revisions) > protocol.MaxRevisionHistory {
+		d.Revisions = d.Revisions[len(d.Revisions)-protocol.MaxRevisionHistory:]
+	}
+	d.Update = nil
+
+	// The new container starts with a clean slate
+	d.Status = protocol.StatusRunning
+	d.RestartCount = 0
+	d.ConsecutiveCrashes = 0
+	d.LastExitReason = ""
+	d.ExitCode = nil
+	d.NextRestartAt = nil
+	d.Error = ""
+	if d.HealthCheck != nil {
+		// waitReady already saw it pass the health check
+		now := time.Now()
+		d.Health = protocol.HealthHealthy
+		d.HealthError = ""
+		d.HealthCheckedAt = &now
+		delete(s.probes, d.ID)
+	}
+
+	s.persistLocked()
+	s.emit(protocol.EventUpdated, d, fmt.Sprintf("now running revision %d (%s)", rev.Revision, rev.Cause))
+	hook := s.updateHook
+	copy := *d
+	s.mu.Unlock()
+
+	log.Printf("[SCHEDULER] Deployment %s switched to revision %d", deploymentID, rev.Revision)
+	if hook != nil {
+		hook(&copy)
+	}
+
+	if oldContainer != "" {
+		if err := s.runtime.Stop(ctx, oldContainer); err != nil {
+			log.Printf("[SCHEDULER] Failed to stop previous container of %s: %v", deploymentID, err)
+		}
+	}
+	return &copy, nil
+}
+
+// abortUpdate removes a failed revision's container and returns its
+// reserved resources. The deployment keeps running its current revision.
+func (s *Scheduler) abortUpdate(deploymentID string, rev protocol.DeploymentRevision, containerID string, cause error) {
+	if containerID != "" {
+		if err := s.runtime.Stop(context.Background(), containerID); err != nil {
+			log.Printf("[SCHEDULER] Failed to remove container of revision %d of %s: %v", rev.Revision, deploymentID, err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.usedCPU -= rev.CPULimit
+	s.usedMemory -= rev.MemoryLimit
+	s.wakeQueue()
+
+	if d, ok := s.deployments[deploymentID]; ok && d.Update != nil && d.Update.Revision.Revision == rev.Revision {
+		d.Update = nil
+		s.persistLocked()
+		s.emit(protocol.EventUpdateFailed, d, cause.Error())
+	}
+	log.Printf("[SCHEDULER] Update of %s to revision %d failed: %v", deploymentID, rev.Revision, cause)
+}
+
+// copyEnv returns a copy of an environment map.
+func copyEnv(env map[string]string) map[string]string {
+	if env == nil {
+		return nil
+	}
+	copy := make(map[string]string, len(env))
+	for k, v := range env {
+		copy[k] = v
+	}
+	return copy
+}
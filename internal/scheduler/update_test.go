@@ -0,0 +1,30 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+func TestUpdateRefusedWhileCordoned(t *testing.T) {
+	s, _ := newTestScheduler(t, nil)
+	d := mustSchedule(t, s, testRequest(500))
+	s.Cordon(0, "maintenance", false)
+
+	_, err := s.Update(context.Background(), testRequester, d.ID, &protocol.UpdateRequest{
+		DeploymentID:  d.ID,
+		CPUMillicores: 1000,
+	})
+	if !errors.Is(err, ErrCordoned) {
+		t.Fatalf("Update() error = %v, want ErrCordoned", err)
+	}
+	got, ok := s.Get(d.ID)
+	if !ok || got.Status != protocol.StatusRunning || got.Update != nil {
+		t.Errorf("deployment changed by a refused update: %+v", got)
+	}
+	if used, _, _, _, _, _ := s.ResourceUsage(); used != 500 {
+		t.Errorf("used CPU = %d, want 500", used)
+	}
+}
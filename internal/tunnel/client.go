@@ -20,6 +20,8 @@ import (
 	"net/http"
 	"sync"
 	"time"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
 )
 
 const (
@@ -35,8 +37,12 @@ const (
 
 // TunnelMessage is the JSON protocol for tunnel communication
 type TunnelMessage struct {
-	Type         string            `json:"type"` // "register", "unregister", "request", "response"
+	Type         string            `json:"type"` // "register", "unregister", "health", "request", "response"
 	DeploymentID string            `json:"deployment_id,omitempty"`
+	Name         string            `json:"name,omitempty"` // requested subdomain
+	ReplicaSet   string            `json:"replica_set,omitempty"`
+	RequesterID  string            `json:"requester_id,omitempty"`
+	Healthy      bool              `json:"healthy,omitempty"`
 	Port         int               `json:"port,omitempty"`
 	PeerID       string            `json:"peer_id,omitempty"`
 	RequestID    string            `json:"request_id,omitempty"`
@@ -106,8 +112,27 @@ func (c *Client) Connect(ctx context.Context) error {
 	return nil
 }
 
+// Registration describes a deployment to expose via the tunnel.
+type Registration struct {
+	// DeploymentID is the deployment to route to
+	DeploymentID string
+	// Name is the deployment's name, if any
+	Name string
+	// ReplicaSet is the deployment's replica set, if any. Replicas of a set
+	// share one route and the gateway balances requests between them
+	ReplicaSet string
+	// RequesterID is the deployment's owner; only replicas of the same owner
+	// may join a route
+	RequesterID string
+	// Port is the container port to forward requests to
+	Port int
+}
+
 // RegisterDeployment registers a deployment for exposure via the tunnel.
-func (c *Client) RegisterDeployment(deploymentID string, containerPort int) (string, error) {
+// A named deployment is exposed under its name scoped to its requester,
+// otherwise under its replica set or its ID. Registering a deployment again updates its route and marks
+// it healthy.
+func (c *Client) RegisterDeployment(reg Registration) (string, error) {
 	c.mu.Lock()
 	if !c.connected || c.conn == nil {
 		c.mu.Unlock()
@@ -118,8 +143,11 @@ func (c *Client) RegisterDeployment(deploymentID string, containerPort int) (str
 	// Send registration message
 	msg := TunnelMessage{
 		Type:         "register",
-		DeploymentID: deploymentID,
-		Port:         containerPort,
+		DeploymentID: reg.DeploymentID,
+		Name:         reg.Name,
+		ReplicaSet:   reg.ReplicaSet,
+		RequesterID:  reg.RequesterID,
+		Port:         reg.Port,
 		PeerID:       c.peerID,
 	}
 
@@ -128,13 +156,35 @@ func (c *Client) RegisterDeployment(deploymentID string, containerPort int) (str
 	}
 
 	c.mu.Lock()
-	c.routes[deploymentID] = containerPort
+	c.routes[reg.DeploymentID] = reg.Port
 	c.mu.Unlock()
 
-	log.Printf("[TUNNEL] Registered deployment %s on port %d", deploymentID, containerPort)
+	log.Printf("[TUNNEL] Registered deployment %s on port %d", reg.DeploymentID, reg.Port)
 
 	// Return the URL (gateway will confirm, but we can predict it)
-	return fmt.Sprintf("https://%s.peercompute.xdastechnology.com", deploymentID), nil
+	return fmt.Sprintf("https://%s.peercompute.xdastechnology.com", protocol.GatewaySubdomain(reg.DeploymentID, reg.Name, reg.ReplicaSet, reg.RequesterID)), nil
+}
+
+// SetHealth tells the gateway whether a registered deployment can serve
+// requests. Unhealthy replicas are taken out of their route's rotation
+// until they report healthy again.
+func (c *Client) SetHealth(deploymentID string, healthy bool) error {
+	c.mu.Lock()
+	_, ok := c.routes[deploymentID]
+	c.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	msg := TunnelMessage{
+		Type:         "health",
+		DeploymentID: deploymentID,
+		Healthy:      healthy,
+	}
+	if err := c.send(&msg); err != nil {
+		return fmt.Errorf("failed to send health update: %w", err)
+	}
+	return nil
 }
 
 // UnregisterDeployment removes a deployment from the tunnel.
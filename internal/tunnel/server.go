@@ -11,6 +11,8 @@ import (
 	"net/http/httputil"
 	"sync"
 	"time"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
 )
 
 // Server is the tunnel server that runs on the gateway.
@@ -142,8 +144,9 @@ func (s *Server) handleConnection(conn net.Conn) {
 	}
 }
 
-// RegisterDeployment registers a deployment for routing.
-func (s *Server) RegisterDeployment(peerID, deploymentID string, localPort int) (string, error) {
+// RegisterDeployment registers a deployment for routing, under its name
+// scoped to its requester if it has one.
+func (s *Server) RegisterDeployment(peerID, deploymentID, name, requesterID string, localPort int) (string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -153,7 +156,10 @@ func (s *Server) RegisterDeployment(peerID, deploymentID string, localPort int)
 	}
 
 	// Generate subdomain
-	subdomain := generateSubdomain(deploymentID)
+	subdomain := protocol.GatewaySubdomain(deploymentID, name, "", requesterID)
+	if owner, taken := s.subdomains[subdomain]; taken && owner != deploymentID {
+		return "", fmt.Errorf("subdomain %s is already in use", subdomain)
+	}
 	fullDomain := fmt.Sprintf("%s.%s", subdomain, s.baseDomain)
 
 	route := &DeploymentRoute{
@@ -221,16 +227,6 @@ func (s *Server) HTTPHandler() http.Handler {
 	}
 }
 
-// generateSubdomain generates a subdomain for a deployment.
-func generateSubdomain(deploymentID string) string {
-	// Use a short hash of the deployment ID
-	// In production, use a proper hash function
-	if len(deploymentID) > 12 {
-		return deploymentID[:12]
-	}
-	return deploymentID
-}
-
 // extractSubdomain extracts the subdomain from a host.
 func extractSubdomain(host, baseDomain string) string {
 	// Remove port if present
@@ -0,0 +1,237 @@
+// Package usage meters the resources each requester's deployments consume
+// on a provider.
+//
+// A Meter samples running containers and adds what they used since the
+// previous sample to a Ledger. The ledger aggregates usage per requester in
+// hourly buckets, persisted so usage survives daemon restarts, and answers
+// "how much has this peer used since ..." for both sides: requesters asking
+// what they consumed and the provider reviewing what it has given.
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+)
+
+const (
+	// LedgerFileName is the file the ledger is persisted to
+	LedgerFileName = "usage.json"
+
+	// BucketSize is the granularity of the ledger
+	BucketSize = time.Hour
+
+	// Retention is how long usage is kept
+	Retention = 90 * 24 * time.Hour
+)
+
+// Sample is the usage of one deployment between two samples.
+type Sample struct {
+	CPUSeconds        float64 `json:"cpu_seconds"`
+	MemoryByteSeconds float64 `json:"memory_byte_seconds"`
+	WallSeconds       float64 `json:"wall_seconds"`
+	NetworkRxBytes    uint64  `json:"network_rx_bytes"`
+	NetworkTxBytes    uint64  `json:"network_tx_bytes"`
+}
+
+// bucket is one requester's usage during one hour.
+type bucket struct {
+	RequesterID       string    `json:"requester_id"`
+	Hour              time.Time `json:"hour"`
+	CPUSeconds        float64   `json:"cpu_seconds"`
+	MemoryByteSeconds float64   `json:"memory_byte_seconds"`
+	WallSeconds       float64   `json:"wall_seconds"`
+	NetworkRxBytes    uint64    `json:"network_rx_bytes"`
+	NetworkTxBytes    uint64    `json:"network_tx_bytes"`
+	Deployments       []string  `json:"deployments"`
+}
+
+// Ledger keeps usage per requester and hour.
+type Ledger struct {
+	path    string
+	buckets map[string]map[int64]*bucket // requester -> hour (unix) -> usage
+	dirty   bool
+	mu      sync.Mutex
+}
+
+// NewLedger creates an empty ledger persisted at path (empty = in memory).
+func NewLedger(path string) *Ledger {
+	return &Ledger{
+		path:    path,
+		buckets: make(map[string]map[int64]*bucket),
+	}
+}
+
+// LoadLedger reads a persisted ledger. A missing file is an empty ledger.
+func LoadLedger(path string) (*Ledger, error) {
+	l := NewLedger(path)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read usage ledger: %w", err)
+	}
+
+	var buckets []*bucket
+	if err := json.Unmarshal(data, &buckets); err != nil {
+		return nil, fmt.Errorf("failed to parse usage ledger: %w", err)
+	}
+	for _, b := range buckets {
+		l.bucketLocked(b.RequesterID, b.Hour)
+		l.buckets[b.RequesterID][b.Hour.Unix()] = b
+	}
+	return l, nil
+}
+
+// Add records a deployment's usage at a time.
+func (l *Ledger) Add(requesterID, deploymentID string, at time.Time, s Sample) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.bucketLocked(requesterID, at)
+	b.CPUSeconds += s.CPUSeconds
+	b.MemoryByteSeconds += s.MemoryByteSeconds
+	b.WallSeconds += s.WallSeconds
+	b.NetworkRxBytes += s.NetworkRxBytes
+	b.NetworkTxBytes += s.NetworkTxBytes
+	if !contains(b.Deployments, deploymentID) {
+		b.Deployments = append(b.Deployments, deploymentID)
+	}
+	l.dirty = true
+}
+
+// Get returns a requester's usage since a time.
+func (l *Ledger) Get(requesterID string, since time.Time) protocol.UsageRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.recordLocked(requesterID, since)
+}
+
+// List returns the usage of every requester since a time, largest CPU
+// consumer first.
+func (l *Ledger) List(since time.Time) []protocol.UsageRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var records []protocol.UsageRecord
+	for requesterID := range l.buckets {
+		if r := l.recordLocked(requesterID, since); r.Deployments > 0 {
+			records = append(records, r)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].CPUSeconds > records[j].CPUSeconds
+	})
+	return records
+}
+
+// Save drops usage older than Retention and persists the ledger if it
+// changed.
+func (l *Ledger) Save() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cutoff := time.Now().Add(-Retention).Unix()
+	var buckets []*bucket
+	for requesterID, hours := range l.buckets {
+		for hour, b := range hours {
+			if hour < cutoff {
+				delete(hours, hour)
+				l.dirty = true
+				continue
+			}
+			buckets = append(buckets, b)
+		}
+		if len(hours) == 0 {
+			delete(l.buckets, requesterID)
+		}
+	}
+	if !l.dirty || l.path == "" {
+		return nil
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].Hour.Before(buckets[j].Hour)
+	})
+
+	data, err := json.MarshalIndent(buckets, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage ledger: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write usage ledger: %w", err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return fmt.Errorf("failed to replace usage ledger: %w", err)
+	}
+	l.dirty = false
+	return nil
+}
+
+// bucketLocked returns the bucket of a requester for the hour containing at,
+// creating it if needed (caller must hold lock).
+func (l *Ledger) bucketLocked(requesterID string, at time.Time) *bucket {
+	hour := at.UTC().Truncate(BucketSize)
+	hours, ok := l.buckets[requesterID]
+	if !ok {
+		hours = make(map[int64]*bucket)
+		l.buckets[requesterID] = hours
+	}
+	b, ok := hours[hour.Unix()]
+	if !ok {
+		b = &bucket{RequesterID: requesterID, Hour: hour}
+		hours[hour.Unix()] = b
+	}
+	return b
+}
+
+// recordLocked sums a requester's buckets from the hour containing since
+// (caller must hold lock).
+func (l *Ledger) recordLocked(requesterID string, since time.Time) protocol.UsageRecord {
+	record := protocol.UsageRecord{RequesterID: requesterID}
+	from := since.UTC().Truncate(BucketSize).Unix()
+	seen := make(map[string]bool)
+	for hour, b := range l.buckets[requesterID] {
+		if hour < from {
+			continue
+		}
+		record.CPUSeconds += b.CPUSeconds
+		record.MemoryByteSeconds += b.MemoryByteSeconds
+		record.WallSeconds += b.WallSeconds
+		record.NetworkRxBytes += b.NetworkRxBytes
+		record.NetworkTxBytes += b.NetworkTxBytes
+		for _, id := range b.Deployments {
+			seen[id] = true
+		}
+	}
+	record.Deployments = len(seen)
+	return record
+}
+
+// saveOrLog persists the ledger, logging failures like other state files.
+func (l *Ledger) saveOrLog() {
+	if err := l.Save(); err != nil {
+		log.Printf("[USAGE] %v", err)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package usage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLedgerAggregatesPerRequester(t *testing.T) {
+	l := NewLedger("")
+	now := time.Now()
+	l.Add("alice", "dep-1", now.Add(-3*time.Hour), Sample{CPUSeconds: 10, WallSeconds: 60, NetworkRxBytes: 100})
+	l.Add("alice", "dep-1", now, Sample{CPUSeconds: 5, WallSeconds: 30})
+	l.Add("alice", "dep-2", now, Sample{CPUSeconds: 1, WallSeconds: 30, NetworkTxBytes: 7})
+	l.Add("bob", "dep-3", now, Sample{CPUSeconds: 100, WallSeconds: 30})
+
+	all := l.Get("alice", now.Add(-24*time.Hour))
+	if all.CPUSeconds != 16 || all.WallSeconds != 120 || all.NetworkRxBytes != 100 || all.NetworkTxBytes != 7 {
+		t.Errorf("Get() = %+v", all)
+	}
+	if all.Deployments != 2 {
+		t.Errorf("Deployments = %d, want 2", all.Deployments)
+	}
+
+	// Usage is kept per hour, so since starts at the hour it falls in
+	recent := l.Get("alice", now.Add(-time.Hour))
+	if recent.CPUSeconds != 6 {
+		t.Errorf("CPU-seconds in the last hour = %v, want 6", recent.CPUSeconds)
+	}
+
+	list := l.List(now.Add(-24 * time.Hour))
+	if len(list) != 2 || list[0].RequesterID != "bob" || list[1].RequesterID != "alice" {
+		t.Errorf("List() = %+v, want bob then alice", list)
+	}
+	if got := l.Get("carol", time.Time{}); got.Deployments != 0 || got.CPUSeconds != 0 {
+		t.Errorf("Get() of an unknown requester = %+v", got)
+	}
+}
+
+func TestLedgerPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), LedgerFileName)
+	now := time.Now()
+
+	l := NewLedger(path)
+	l.Add("alice", "dep-1", now, Sample{CPUSeconds: 12.5, MemoryByteSeconds: 1 << 30, WallSeconds: 60})
+	// Usage beyond the retention period is dropped when saving
+	l.Add("alice", "dep-0", now.Add(-Retention-time.Hour), Sample{CPUSeconds: 1000})
+	if err := l.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := LoadLedger(path)
+	if err != nil {
+		t.Fatalf("LoadLedger() error = %v", err)
+	}
+	got := loaded.Get("alice", time.Time{})
+	if got.CPUSeconds != 12.5 || got.MemoryByteSeconds != 1<<30 || got.Deployments != 1 {
+		t.Errorf("loaded usage = %+v", got)
+	}
+
+	// Usage keeps adding up after a restart
+	loaded.Add("alice", "dep-1", now, Sample{CPUSeconds: 2.5})
+	if got := loaded.Get("alice", time.Time{}); got.CPUSeconds != 15 {
+		t.Errorf("CPU-seconds = %v, want 15", got.CPUSeconds)
+	}
+}
+
+func TestLoadLedgerMissingFile(t *testing.T) {
+	l, err := LoadLedger(filepath.Join(t.TempDir(), LedgerFileName))
+	if err != nil {
+		t.Fatalf("LoadLedger() error = %v", err)
+	}
+	if records := l.List(time.Time{}); len(records) != 0 {
+		t.Errorf("List() = %+v, want empty", records)
+	}
+}
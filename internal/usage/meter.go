@@ -0,0 +1,143 @@
+package usage
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+	"github.com/xdas-research/peer-compute/internal/runtime"
+	"github.com/xdas-research/peer-compute/internal/scheduler"
+)
+
+// DefaultSampleInterval is how often running containers are sampled.
+const DefaultSampleInterval = 30 * time.Second
+
+// Meter samples the resource usage of running deployments into a ledger.
+//
+// CPU and memory are sampled as point-in-time rates and multiplied by the
+// time since the previous sample, so short spikes between samples are
+// smoothed out. Network traffic comes from the container's counters and is
+// exact.
+type Meter struct {
+	sched    *scheduler.Scheduler
+	runtime  runtime.Runtime
+	ledger   *Ledger
+	interval time.Duration
+	started  time.Time
+	last     map[string]previous // deploymentID -> previous sample
+	hook     SampleHook
+}
+
+// SampleHook is called with every sample the meter records.
+type SampleHook func(d *protocol.Deployment, at time.Time, s Sample)
+
+// previous is what the meter remembers of a deployment's last sample.
+type previous struct {
+	at          time.Time
+	containerID string
+	rx, tx      uint64
+}
+
+// NewMeter creates a meter that samples every interval (0 = default).
+func NewMeter(sched *scheduler.Scheduler, rt runtime.Runtime, ledger *Ledger, interval time.Duration) *Meter {
+	if interval <= 0 {
+		interval = DefaultSampleInterval
+	}
+	return &Meter{
+		sched:    sched,
+		runtime:  rt,
+		ledger:   ledger,
+		interval: interval,
+		started:  time.Now(),
+		last:     make(map[string]previous),
+	}
+}
+
+// SetSampleHook sets the function called with every recorded sample. It
+// must be set before Run.
+func (m *Meter) SetSampleHook(hook SampleHook) {
+	m.hook = hook
+}
+
+// Run samples running deployments until the context is cancelled, then
+// persists the ledger.
+func (m *Meter) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			m.ledger.saveOrLog()
+			return
+		case <-ticker.C:
+			m.sample(ctx)
+			m.ledger.saveOrLog()
+		}
+	}
+}
+
+// sample records the usage of every running deployment since its previous
+// sample.
+func (m *Meter) sample(ctx context.Context) {
+	present := make(map[string]bool)
+	for _, d := range m.sched.List() {
+		present[d.ID] = true
+		if d.Status != protocol.StatusRunning || d.ContainerID == "" {
+			continue
+		}
+
+		stats, err := m.runtime.Stats(ctx, d.ContainerID)
+		if err != nil {
+			// The container may have exited since the list was taken
+			log.Printf("[USAGE] Failed to sample %s: %v", d.ID, err)
+			continue
+		}
+		now := time.Now()
+
+		prev, ok := m.last[d.ID]
+		elapsed := m.interval
+		if ok {
+			// Don't bill time the daemon was not watching, e.g. while suspended
+			elapsed = min(now.Sub(prev.at), 2*m.interval)
+		} else if since := now.Sub(d.StartedAt); since < elapsed {
+			elapsed = max(since, 0)
+		}
+
+		// Network counters are totals since the container started. A new
+		// container (restart or update) starts from zero; a container that
+		// was running before the daemon started has already been metered.
+		rx, tx := stats.NetworkRxBytes, stats.NetworkTxBytes
+		switch {
+		case ok && prev.containerID == d.ContainerID && rx >= prev.rx && tx >= prev.tx:
+			rx, tx = rx-prev.rx, tx-prev.tx
+		case !ok && d.StartedAt.Before(m.started):
+			rx, tx = 0, 0
+		}
+
+		s := Sample{
+			CPUSeconds:        stats.CPUPercent / 100 * elapsed.Seconds(),
+			MemoryByteSeconds: float64(stats.MemoryBytes) * elapsed.Seconds(),
+			WallSeconds:       elapsed.Seconds(),
+			NetworkRxBytes:    rx,
+			NetworkTxBytes:    tx,
+		}
+		m.ledger.Add(d.RequesterID, d.ID, now, s)
+		if m.hook != nil {
+			m.hook(d, now, s)
+		}
+		m.last[d.ID] = previous{
+			at:          now,
+			containerID: d.ContainerID,
+			rx:          stats.NetworkRxBytes,
+			tx:          stats.NetworkTxBytes,
+		}
+	}
+
+	for id := range m.last {
+		if !present[id] {
+			delete(m.last, id)
+		}
+	}
+}
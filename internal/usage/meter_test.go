@@ -0,0 +1,137 @@
+package usage
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/xdas-research/peer-compute/internal/protocol"
+	"github.com/xdas-research/peer-compute/internal/runtime"
+	"github.com/xdas-research/peer-compute/internal/scheduler"
+)
+
+const testRequester = "12D3KooWRequester"
+
+// newTestScheduler returns a scheduler on a fake runtime running one
+// deployment.
+func newTestScheduler(t *testing.T) (*scheduler.Scheduler, *runtime.Fake, *protocol.Deployment) {
+	t.Helper()
+	cfg := scheduler.DefaultConfig()
+	cfg.StateDir = t.TempDir()
+	rt := runtime.NewFake()
+	s := scheduler.NewScheduler(rt, cfg)
+	d, err := s.Schedule(context.Background(), &protocol.DeployRequest{
+		Image:         "nginx:1.27",
+		CPUMillicores: 1000,
+		MemoryBytes:   64 * 1024 * 1024,
+		RequesterID:   testRequester,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, rt, d
+}
+
+func TestMeterSamplesRates(t *testing.T) {
+	s, rt, d := newTestScheduler(t)
+	m := NewMeter(s, rt, NewLedger(""), time.Minute)
+	var samples []Sample
+	m.SetSampleHook(func(_ *protocol.Deployment, _ time.Time, sample Sample) {
+		samples = append(samples, sample)
+	})
+
+	rt.SetStats(d.ContainerID, runtime.ResourceUsage{CPUPercent: 50, MemoryBytes: 1 << 20})
+	m.sample(context.Background())
+	// Pretend the first sample was taken a minute ago
+	prev := m.last[d.ID]
+	prev.at = prev.at.Add(-time.Minute)
+	m.last[d.ID] = prev
+	m.sample(context.Background())
+
+	if len(samples) != 2 {
+		t.Fatalf("got %d samples, want 2", len(samples))
+	}
+	s2 := samples[1]
+	if math.Abs(s2.WallSeconds-60) > 1 {
+		t.Errorf("WallSeconds = %v, want about 60", s2.WallSeconds)
+	}
+	if math.Abs(s2.CPUSeconds-s2.WallSeconds/2) > 1e-9 {
+		t.Errorf("CPUSeconds = %v, want half of %v", s2.CPUSeconds, s2.WallSeconds)
+	}
+	if math.Abs(s2.MemoryByteSeconds-s2.WallSeconds*(1<<20)) > 1 {
+		t.Errorf("MemoryByteSeconds = %v, want %v", s2.MemoryByteSeconds, s2.WallSeconds*(1<<20))
+	}
+
+	got := m.ledger.Get(testRequester, time.Time{})
+	if got.Deployments != 1 || got.WallSeconds != samples[0].WallSeconds+s2.WallSeconds {
+		t.Errorf("ledger = %+v", got)
+	}
+}
+
+func TestMeterCapsUnwatchedTime(t *testing.T) {
+	s, rt, d := newTestScheduler(t)
+	m := NewMeter(s, rt, NewLedger(""), time.Minute)
+	var last Sample
+	m.SetSampleHook(func(_ *protocol.Deployment, _ time.Time, sample Sample) { last = sample })
+
+	m.sample(context.Background())
+	// A sample an hour late, e.g. after the host was suspended
+	prev := m.last[d.ID]
+	prev.at = prev.at.Add(-time.Hour)
+	m.last[d.ID] = prev
+	m.sample(context.Background())
+
+	if last.WallSeconds > 120 {
+		t.Errorf("WallSeconds = %v, want at most two intervals", last.WallSeconds)
+	}
+}
+
+func TestMeterNetworkDeltas(t *testing.T) {
+	s, rt, d := newTestScheduler(t)
+	// The meter starts after the container, as after a daemon restart
+	m := NewMeter(s, rt, NewLedger(""), time.Minute)
+	m.started = d.StartedAt.Add(time.Second)
+	var samples []Sample
+	m.SetSampleHook(func(_ *protocol.Deployment, _ time.Time, sample Sample) {
+		samples = append(samples, sample)
+	})
+
+	for _, total := range []uint64{1000, 1500, 1600} {
+		rt.SetStats(d.ContainerID, runtime.ResourceUsage{NetworkRxBytes: total, NetworkTxBytes: total / 10})
+		m.sample(context.Background())
+	}
+
+	// Traffic before the meter started was metered by the previous daemon
+	want := []uint64{0, 500, 100}
+	for i, sample := range samples {
+		if sample.NetworkRxBytes != want[i] || sample.NetworkTxBytes != want[i]/10 {
+			t.Errorf("sample %d = %d rx, %d tx, want %d and %d", i, sample.NetworkRxBytes, sample.NetworkTxBytes, want[i], want[i]/10)
+		}
+	}
+}
+
+func TestMeterCountsNewContainerFromZero(t *testing.T) {
+	s, rt, d := newTestScheduler(t)
+	m := NewMeter(s, rt, NewLedger(""), time.Minute)
+	// The container started while the meter was watching
+	m.started = d.StartedAt.Add(-time.Second)
+	var last Sample
+	m.SetSampleHook(func(_ *protocol.Deployment, _ time.Time, sample Sample) { last = sample })
+
+	rt.SetStats(d.ContainerID, runtime.ResourceUsage{NetworkRxBytes: 5000})
+	m.sample(context.Background())
+	if last.NetworkRxBytes != 5000 {
+		t.Fatalf("first sample = %d rx, want 5000", last.NetworkRxBytes)
+	}
+
+	// A replaced container's counters start again from zero
+	prev := m.last[d.ID]
+	prev.containerID = "replaced"
+	m.last[d.ID] = prev
+	rt.SetStats(d.ContainerID, runtime.ResourceUsage{NetworkRxBytes: 300})
+	m.sample(context.Background())
+	if last.NetworkRxBytes != 300 {
+		t.Errorf("sample of the new container = %d rx, want 300", last.NetworkRxBytes)
+	}
+}